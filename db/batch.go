@@ -53,6 +53,7 @@ package db
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"io"
 	"sync"
 	"sync/atomic"
@@ -68,6 +69,8 @@ const (
 	countOffset    = 8
 )
 
+var errInvalidBatch = errors.New("nogodb: invalid batch")
+
 type Batch struct {
 	batchInternal
 	applied atomic.Bool
@@ -81,13 +84,25 @@ type batchInternal struct {
 	// data whenever Repr() is called.
 	count uint32
 	index nogodb_art.ITree[any]
+	// memTableSize is the number of bytes the batch needs in a memtable
+	memTableSize uint64
 
-	// TODO(high): In PebbleDB, when the batch is too large that can not fit into
-	// a memtable, it would be marked as <immutable>, return as a "flushable" and
-	// will be put into the <db.mem.flushableQueue>
+	// flushable is set when the batch is too large to be copied into the
+	// memtable. The batch is then committed to the WAL as usual, but queued
+	// as an immutable flushable in <db.mu.mem.flushQueue>. The flushable
+	// owns the batch buffer from then on.
+	flushable *flushableBatch
 
 	commitErr error
 
+	// durableSeqNum is the durable sequence number of the DB when the batch
+	// was acknowledged, see WriteResult
+	durableSeqNum nogodb_common.SeqNum
+
+	// replicated is set when the batch has been replicated from a primary,
+	// its seqNum is assigned already, see DB.ApplyReplicated
+	replicated bool
+
 	// committing is set to true when a batch begins to commit. It's used to
 	// ensure the batch is not mutated concurrently
 	committing atomic.Bool
@@ -107,6 +122,7 @@ var batchPool sync.Pool = sync.Pool{
 func newBatch(db *DB, needIndexing bool) *Batch {
 	b := batchPool.Get().(*Batch)
 	b.db = db
+	b.cmp = db.cmp
 	b.index = nil
 	if needIndexing {
 		b.index = nogodb_art.NewTree[any](context.TODO())
@@ -225,6 +241,7 @@ func (b *Batch) Get(key []byte) (value []byte, closer io.Closer, err error) {
 // Internal \\
 
 func (b *Batch) reset() {
+	ownedByFlushable := b.flushable != nil
 	b.batchInternal = batchInternal{
 		buf:   b.buf,
 		index: b.index,
@@ -233,7 +250,7 @@ func (b *Batch) reset() {
 	if b.index != nil {
 		b.index = nogodb_art.NewTree[any](context.Background())
 	}
-	if cap(b.buf) > maxRetainSize {
+	if ownedByFlushable || cap(b.buf) > maxRetainSize {
 		b.buf = nil
 	} else {
 		b.buf = b.buf[:0]
@@ -252,9 +269,10 @@ func (b *Batch) put(key, value []byte, kind nogodb_common.KeyKind) (offset int)
 	}
 
 	b.count += 1
+	b.memTableSize += memTableEntrySize(len(key), len(value))
 	offset = len(b.buf)
 	prev := offset
-	if len(value) > 0 {
+	if hasValue(kind) {
 		b.grow(len(key) + len(value) + 2*binary.MaxVarintLen32 + 1)
 	} else {
 		b.grow(len(key) + binary.MaxVarintLen32 + 1)
@@ -262,18 +280,73 @@ func (b *Batch) put(key, value []byte, kind nogodb_common.KeyKind) (offset int)
 	b.buf[offset] = byte(kind)
 	offset += 1
 	// encoding key to the b.buf
-	offset = binary.PutUvarint(b.buf, uint64(len(key)))
-	copy(b.buf[offset:offset+len(key)], key)
-	offset += len(key)
+	offset += binary.PutUvarint(b.buf[offset:], uint64(len(key)))
+	offset += copy(b.buf[offset:], key)
 
-	if len(value) > 0 {
+	if hasValue(kind) {
 		// encoding value to the b.buf
-		offset = binary.PutUvarint(b.buf, uint64(len(value)))
-		copy(b.buf[offset:offset+len(value)], value)
-		offset += len(value)
+		offset += binary.PutUvarint(b.buf[offset:], uint64(len(value)))
+		offset += copy(b.buf[offset:], value)
 	}
 
 	b.buf = b.buf[:offset]
 
 	return prev
 }
+
+// hasValue returns true if the record of the given kind carries a value varstring
+func hasValue(kind nogodb_common.KeyKind) bool {
+	switch kind {
+	case nogodb_common.KeyKindSet, nogodb_common.KeyKindMerge:
+		return true
+	default:
+		return false
+	}
+}
+
+// batchReader iterates over the records of a batch buffer, header excluded.
+type batchReader []byte
+
+// newBatchReader returns a reader over the records of the given batch
+// representation, along with its decoded header
+func newBatchReader(repr []byte) (batchReader, Header, error) {
+	h, ok := readHeader(repr)
+	if !ok {
+		return nil, h, errInvalidBatch
+	}
+
+	return batchReader(repr[BatchHeaderLen:]), h, nil
+}
+
+// next decodes the next record. ok is false once the reader is exhausted.
+func (r *batchReader) next() (kind nogodb_common.KeyKind, key, value []byte, ok bool, err error) {
+	if len(*r) == 0 {
+		return 0, nil, nil, false, nil
+	}
+
+	kind = nogodb_common.KeyKind((*r)[0])
+	*r = (*r)[1:]
+
+	if key, err = r.readVarstring(); err != nil {
+		return 0, nil, nil, false, err
+	}
+
+	if hasValue(kind) {
+		if value, err = r.readVarstring(); err != nil {
+			return 0, nil, nil, false, err
+		}
+	}
+
+	return kind, key, value, true, nil
+}
+
+func (r *batchReader) readVarstring() ([]byte, error) {
+	n, e := binary.Uvarint(*r)
+	if e <= 0 || uint64(len(*r)-e) < n {
+		return nil, errInvalidBatch
+	}
+
+	s := (*r)[e : e+int(n) : e+int(n)]
+	*r = (*r)[e+int(n):]
+	return s, nil
+}
@@ -0,0 +1,458 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_wal "github.com/datnguyenzzz/nogodb/lib/go-wal"
+)
+
+// Change data capture (CDC)
+//
+// A Subscription streams the committed batches, in the commit order, from a
+// given sequence number. The batches which have been committed before the
+// subscription are read from the WALs, then the subscription tails the new
+// commits, which are buffered in memory until they're consumed:
+//
+//	+---------------------------+      +-------------------------------+
+//	| WALs [fromSeqNum, live)   | ---> | commits [live, ...) (buffered) |
+//	+---------------------------+      +-------------------------------+
+//
+// If the consumer doesn't keep up with the commits, its buffer overflows and
+// is dropped, then the subscription catches up from the WALs again. For this
+// purpose, the WALs holding the batches that haven't been consumed yet are
+// not deleted, see DB.obsoleteWALs.
+
+var (
+	// ErrSeqNumGarbageCollected is returned when the requested sequence number
+	// is no longer in the WALs, the batches have been flushed and their WALs
+	// deleted.
+	ErrSeqNumGarbageCollected = errors.New("nogodb: sequence number has been garbage collected")
+
+	// ErrSubscriptionClosed is returned by Subscription.Next once the
+	// subscription is closed
+	ErrSubscriptionClosed = errors.New("nogodb: subscription is closed")
+)
+
+// walFile is a WAL which hasn't been obsoleted yet
+type walFile struct {
+	fileNum nogodb_common.DiskfileNum
+	// seqNum is the sequence number of the first batch written to the WAL
+	seqNum nogodb_common.SeqNum
+}
+
+// BatchOp is an operation of a committed batch
+type BatchOp struct {
+	SeqNum nogodb_common.SeqNum
+	Kind   nogodb_common.KeyKind
+	Key    []byte
+	// Value is nil for a deletion
+	Value []byte
+}
+
+// CommittedBatch is a batch streamed by a Subscription
+type CommittedBatch struct {
+	// SeqNum is the sequence number of the first operation of the batch
+	SeqNum nogodb_common.SeqNum
+	Ops    []BatchOp
+
+	repr []byte
+}
+
+// Repr returns the batch representation, which can be applied to a follower
+// with DB.ApplyReplicated
+func (cb *CommittedBatch) Repr() []byte {
+	return cb.repr
+}
+
+// nextSeqNum returns the sequence number following the batch
+func (cb *CommittedBatch) nextSeqNum() nogodb_common.SeqNum {
+	return cb.SeqNum + nogodb_common.SeqNum(len(cb.Ops))
+}
+
+// decodeCommittedBatch decodes the batch representation repr, the operations
+// are aliasing it.
+func decodeCommittedBatch(repr []byte) (*CommittedBatch, error) {
+	r, h, err := newBatchReader(repr)
+	if err != nil {
+		return nil, err
+	}
+
+	cb := &CommittedBatch{
+		SeqNum: h.SeqNum,
+		Ops:    make([]BatchOp, 0, h.Count),
+		repr:   repr,
+	}
+
+	for seqNum := h.SeqNum; ; seqNum++ {
+		kind, key, value, ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		cb.Ops = append(cb.Ops, BatchOp{SeqNum: seqNum, Kind: kind, Key: key, Value: value})
+	}
+
+	if len(cb.Ops) != int(h.Count) {
+		return nil, errInvalidBatch
+	}
+
+	return cb, nil
+}
+
+// Subscription is a stream of the committed batches, see DB.Subscribe.
+// Next must not be called concurrently.
+type Subscription struct {
+	db  *DB
+	ctx context.Context
+	// stopCtx unregisters the callback closing the subscription with ctx
+	stopCtx func() bool
+
+	// next is the sequence number of the next batch to be consumed. The WAL
+	// holding it is retained, see DB.obsoleteWALs.
+	next atomic.Uint64
+
+	// hist reads the batches below liveSeqNum from the WALs, it's nil once
+	// the subscription has caught up
+	hist       *nogodb_wal.WALReader
+	liveSeqNum nogodb_common.SeqNum
+
+	// notify is signaled when a batch is buffered, or the subscription closed
+	notify chan struct{}
+
+	mu struct {
+		sync.Mutex
+		// buffered are the committed batches from liveSeqNum, waiting to be
+		// consumed
+		buffered []*CommittedBatch
+		// lagging is true when the buffer has overflowed, the subscription
+		// must catch up from the WALs again
+		lagging bool
+		closed  bool
+	}
+}
+
+// Subscribe returns a stream of the batches committed from fromSeqNum. The
+// first batch streamed is the one holding fromSeqNum. It returns
+// ErrSeqNumGarbageCollected if the WAL holding fromSeqNum has been deleted.
+//
+// The WALs holding the batches which haven't been consumed yet are retained,
+// so the subscription must be closed, either with Close or by cancelling ctx.
+func (d *DB) Subscribe(ctx context.Context, fromSeqNum nogodb_common.SeqNum) (*Subscription, error) {
+	s := &Subscription{
+		db:     d,
+		ctx:    ctx,
+		notify: make(chan struct{}, 1),
+	}
+	s.next.Store(uint64(fromSeqNum))
+
+	// the WAL holding fromSeqNum must be checked and retained atomically,
+	// otherwise it might be obsoleted in between
+	d.mu.Lock()
+	if err := d.checkRetained(fromSeqNum); err != nil {
+		d.mu.Unlock()
+		return nil, err
+	}
+	d.subscriptions.Lock()
+	d.subscriptions.set[s] = struct{}{}
+	d.subscriptions.Unlock()
+	d.mu.Unlock()
+
+	if err := s.catchUp(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	s.stopCtx = context.AfterFunc(ctx, func() { _ = s.Close() })
+	return s, nil
+}
+
+// checkRetained returns ErrSeqNumGarbageCollected if the WAL holding seqNum
+// has been obsoleted.
+// d.mu must be held when calling this.
+func (d *DB) checkRetained(seqNum nogodb_common.SeqNum) error {
+	if oldest := d.mu.log.files[0].seqNum; seqNum < oldest {
+		return fmt.Errorf("%w: requested %d, the oldest retained is %d", ErrSeqNumGarbageCollected, seqNum, oldest)
+	}
+
+	return nil
+}
+
+// Next returns the next committed batch. It blocks until a batch is
+// committed, the subscription is closed or its context is done.
+func (s *Subscription) Next() (*CommittedBatch, error) {
+	for {
+		s.mu.Lock()
+		closed := s.mu.closed
+		s.mu.Unlock()
+
+		if err := s.ctx.Err(); err != nil || closed {
+			if s.hist != nil {
+				_ = s.closeHist()
+			}
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrSubscriptionClosed
+		}
+
+		if s.hist != nil {
+			cb, err := s.nextFromWAL()
+			if err != nil {
+				return nil, err
+			}
+			if cb != nil {
+				s.next.Store(uint64(cb.nextSeqNum()))
+				return cb, nil
+			}
+
+			// caught up, continue with the buffered batches
+			continue
+		}
+
+		s.mu.Lock()
+		switch {
+		case s.mu.lagging:
+			s.mu.Unlock()
+			if err := s.catchUp(); err != nil {
+				return nil, err
+			}
+			continue
+		case len(s.mu.buffered) > 0:
+			cb := s.mu.buffered[0]
+			s.mu.buffered[0] = nil
+			s.mu.buffered = s.mu.buffered[1:]
+			s.mu.Unlock()
+
+			if cb.nextSeqNum() <= nogodb_common.SeqNum(s.next.Load()) {
+				// the batch has been committed before the position of the
+				// subscription, e.g. fromSeqNum was ahead of the commits
+				continue
+			}
+
+			s.next.Store(uint64(cb.nextSeqNum()))
+			return cb, nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.ctx.Done():
+		case <-s.notify:
+		}
+	}
+}
+
+// catchUp positions the subscription at s.next: the batches committed from
+// then on are buffered, and the ones committed before are read from the WALs.
+func (s *Subscription) catchUp() error {
+	d := s.db
+	from := nogodb_common.SeqNum(s.next.Load())
+
+	// no commit can happen while positioning the subscription, the
+	// next batches are buffered from liveSeqNum on
+	d.commit.mu.Lock()
+	d.mu.Lock()
+	if err := d.checkRetained(from); err != nil {
+		d.mu.Unlock()
+		d.commit.mu.Unlock()
+		return err
+	}
+	minFileNum := d.walHolding(from)
+	d.mu.Unlock()
+
+	s.liveSeqNum = d.commit.nextSeqNum
+	s.mu.Lock()
+	clear(s.mu.buffered)
+	s.mu.buffered = s.mu.buffered[:0]
+	s.mu.lagging = false
+	s.mu.Unlock()
+
+	var err error
+	if from < s.liveSeqNum {
+		// the batches below liveSeqNum might still be buffered by the writer
+		err = d.mu.log.writer.Sync()
+	}
+	d.commit.mu.Unlock()
+
+	if err != nil || from >= s.liveSeqNum {
+		return err
+	}
+
+	var walOpts []nogodb_wal.ReaderOptionFn
+	if d.opts.WAL.SecondaryDir != "" {
+		walOpts = append(walOpts, nogodb_wal.WithReaderSecondaryDir(d.opts.WAL.SecondaryDir))
+	}
+
+	s.hist, err = nogodb_wal.NewWalReader(d.opts.WAL.Dir, d.opts.FS, minFileNum, walOpts...)
+	return err
+}
+
+// nextFromWAL returns the next batch read from the WALs, or nil once all the
+// batches below liveSeqNum have been read.
+func (s *Subscription) nextFromWAL() (*CommittedBatch, error) {
+	for {
+		next := nogodb_common.SeqNum(s.next.Load())
+		if next >= s.liveSeqNum {
+			return nil, s.closeHist()
+		}
+
+		r, _, err := s.hist.Next()
+		var cb *CommittedBatch
+		if err == nil {
+			var repr []byte
+			if repr, err = io.ReadAll(r); err == nil {
+				cb, err = decodeCommittedBatch(repr)
+			}
+		}
+
+		switch {
+		case errors.Is(err, io.EOF):
+			// the WALs are read while being written, but every batch below
+			// liveSeqNum has been synced. The missing seqnums, if any, are the
+			// ones of the failed commits which never reached the WAL.
+			return nil, s.closeHist()
+		case err != nil:
+			_ = s.closeHist()
+			return nil, fmt.Errorf("nogodb: failed to read the committed batches from seqnum %d: %w", next, err)
+		case cb.SeqNum >= s.liveSeqNum:
+			// buffered already
+			return nil, s.closeHist()
+		case cb.nextSeqNum() <= next:
+			// consumed already
+			continue
+		}
+
+		return cb, nil
+	}
+}
+
+func (s *Subscription) closeHist() error {
+	err := s.hist.Close()
+	s.hist = nil
+	return err
+}
+
+// Close closes the subscription, and releases the WALs it retains.
+func (s *Subscription) Close() error {
+	s.mu.Lock()
+	if s.mu.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.closed = true
+	s.mu.buffered = nil
+	s.mu.Unlock()
+
+	if s.stopCtx != nil {
+		s.stopCtx()
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	d := s.db
+	d.subscriptions.Lock()
+	delete(d.subscriptions.set, s)
+	d.subscriptions.Unlock()
+
+	d.mu.Lock()
+	d.obsoleteWALs()
+	d.mu.Unlock()
+
+	// Note: the WAL reader, if any, is owned by the consumer goroutine, it's
+	// closed by the next Next call
+	return nil
+}
+
+// publishToSubscriptions buffers the committed batch for each subscription.
+// commit.mu must be held when calling this, so that the batches are buffered
+// in the commit order.
+func (d *DB) publishToSubscriptions(b *Batch) {
+	d.subscriptions.Lock()
+	defer d.subscriptions.Unlock()
+
+	if len(d.subscriptions.set) == 0 {
+		return
+	}
+
+	// the batch buffer is reused once the batch is closed
+	cb, err := decodeCommittedBatch(slices.Clone(b.buf))
+	if err != nil {
+		// unreachable, the batch has been applied already
+		d.opts.Logger.Errorf("nogodb: failed to decode the committed batch %d: %v", b.SeqNum(), err)
+		return
+	}
+
+	for s := range d.subscriptions.set {
+		s.mu.Lock()
+		switch {
+		case s.mu.closed, s.mu.lagging:
+		case len(s.mu.buffered) >= d.opts.CDC.SubscriberBufferSize:
+			// the subscriber doesn't keep up, drop its buffer, it catches up
+			// from the WALs on the next Next call
+			s.mu.lagging = true
+			s.mu.buffered = nil
+		default:
+			s.mu.buffered = append(s.mu.buffered, cb)
+		}
+		s.mu.Unlock()
+
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// walHolding returns the WAL holding the batch seqNum.
+// d.mu must be held when calling this.
+func (d *DB) walHolding(seqNum nogodb_common.SeqNum) nogodb_common.DiskfileNum {
+	i, _ := slices.BinarySearchFunc(d.mu.log.files, seqNum, func(f walFile, seqNum nogodb_common.SeqNum) int {
+		return cmp.Compare(f.seqNum, seqNum)
+	})
+	if i == len(d.mu.log.files) || d.mu.log.files[i].seqNum > seqNum {
+		// the batch is held by the previous WAL
+		i = max(i-1, 0)
+	}
+
+	return d.mu.log.files[i].fileNum
+}
+
+// obsoleteWALs recycles or deletes the WALs which are neither holding
+// unflushed batches, nor batches not yet consumed by a subscription.
+// d.mu must be held when calling this.
+func (d *DB) obsoleteWALs() {
+	minLogFileNum := d.mu.mem.flushQueue[0].logFileNum
+
+	d.subscriptions.Lock()
+	for s := range d.subscriptions.set {
+		// TODO(low): A subscription which is never consumed retains the WALs
+		// forever, consider a retention limit
+		minLogFileNum = min(minLogFileNum, d.walHolding(nogodb_common.SeqNum(s.next.Load())))
+	}
+	d.subscriptions.Unlock()
+
+	if _, err := d.mu.log.writerManager.Obsolete(minLogFileNum); err != nil {
+		d.opts.Logger.Errorf("nogodb: failed to delete the obsolete WALs < %d: %v", minLogFileNum, err)
+	}
+
+	i := slices.IndexFunc(d.mu.log.files, func(f walFile) bool { return f.fileNum >= minLogFileNum })
+	if i < 0 {
+		i = len(d.mu.log.files)
+	}
+	d.mu.log.files = d.mu.log.files[i:]
+}
+
+var _ io.Closer = (*Subscription)(nil)
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commitTestBatch commits a batch setting key_i to value_i for i in
+// [from, to), and returns its seqNum.
+func commitTestBatch(t *testing.T, d *DB, from, to int) nogodb_common.SeqNum {
+	t.Helper()
+	b := newBatch(d, false)
+	for i := from; i < to; i++ {
+		require.NoError(t, b.Set([]byte(fmt.Sprintf("key_%d", i)), []byte(fmt.Sprintf("value_%d", i))))
+	}
+	require.NoError(t, d.apply(b))
+	seqNum := b.SeqNum()
+	require.NoError(t, b.Close())
+	return seqNum
+}
+
+// requireNextBatch consumes the next batch of s, and checks that it is the
+// one committed at seqNum, setting key_i for i in [from, to).
+func requireNextBatch(t *testing.T, s *Subscription, seqNum nogodb_common.SeqNum, from, to int) {
+	t.Helper()
+	cb, err := s.Next()
+	require.NoError(t, err)
+	require.Equal(t, seqNum, cb.SeqNum)
+	require.Len(t, cb.Ops, to-from)
+	for i, op := range cb.Ops {
+		assert.Equal(t, seqNum+nogodb_common.SeqNum(i), op.SeqNum)
+		assert.Equal(t, nogodb_common.KeyKindSet, op.Kind)
+		assert.Equal(t, fmt.Sprintf("key_%d", from+i), string(op.Key))
+		assert.Equal(t, fmt.Sprintf("value_%d", from+i), string(op.Value))
+	}
+}
+
+// rotateTestWAL rotates the mutable memtable, and so the WAL.
+func rotateTestWAL(t *testing.T, d *DB) {
+	t.Helper()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	holdFlushes(d)
+	require.NoError(t, d.rotateMemTable(nil))
+}
+
+// flushTestMemTables drops the immutable memtables from the flush queue as
+// if they were flushed, then obsoletes their WALs.
+func flushTestMemTables(d *DB) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mu.mem.flushQueue = d.mu.mem.flushQueue[len(d.mu.mem.flushQueue)-1:]
+	d.obsoleteWALs()
+}
+
+func Test_Subscribe_Catches_Up_Across_WAL_Rotations(t *testing.T) {
+	d := openTestDB(t, nil)
+
+	seq0 := commitTestBatch(t, d, 0, 2)
+	seq1 := commitTestBatch(t, d, 2, 5)
+	rotateTestWAL(t, d)
+	seq2 := commitTestBatch(t, d, 5, 6)
+	rotateTestWAL(t, d)
+	seq3 := commitTestBatch(t, d, 6, 8)
+
+	d.mu.Lock()
+	require.Len(t, d.mu.log.files, 3)
+	d.mu.Unlock()
+
+	// start from the middle of the first batch, the batch is streamed as a whole
+	s, err := d.Subscribe(context.Background(), seq0+1)
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	// a batch committed while the subscription is catching up
+	seq4 := commitTestBatch(t, d, 8, 9)
+
+	requireNextBatch(t, s, seq0, 0, 2)
+	requireNextBatch(t, s, seq1, 2, 5)
+	requireNextBatch(t, s, seq2, 5, 6)
+	requireNextBatch(t, s, seq3, 6, 8)
+	requireNextBatch(t, s, seq4, 8, 9)
+
+	// then tails the live commits
+	seq5 := commitTestBatch(t, d, 9, 12)
+	requireNextBatch(t, s, seq5, 9, 12)
+}
+
+func Test_Subscribe_Ahead_Of_The_Commits(t *testing.T) {
+	d := openTestDB(t, nil)
+	seq0 := commitTestBatch(t, d, 0, 2)
+
+	// the subscription starts 3 seqNums ahead of the next commit
+	s, err := d.Subscribe(context.Background(), seq0+5)
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	// the batch below the position is dropped, the next one holds it
+	commitTestBatch(t, d, 2, 4)
+	seq2 := commitTestBatch(t, d, 4, 7)
+	seq3 := commitTestBatch(t, d, 7, 8)
+	requireNextBatch(t, s, seq2, 4, 7)
+	requireNextBatch(t, s, seq3, 7, 8)
+}
+
+func Test_Subscribe_Lagging_Subscriber_Catches_Up_From_The_WALs(t *testing.T) {
+	d := openTestDB(t, func(opt *options.DBOption) {
+		opt.CDC.SubscriberBufferSize = 2
+	})
+
+	s, err := d.Subscribe(context.Background(), commitTestBatch(t, d, 0, 1))
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	var seqNums []nogodb_common.SeqNum
+	for i := 1; i < 8; i++ {
+		seqNums = append(seqNums, commitTestBatch(t, d, i, i+1))
+		if i == 4 {
+			rotateTestWAL(t, d)
+		}
+	}
+
+	s.mu.Lock()
+	assert.True(t, s.mu.lagging, "the buffer has overflowed")
+	s.mu.Unlock()
+
+	requireNextBatch(t, s, seqNums[0]-1, 0, 1)
+	for i, seqNum := range seqNums {
+		requireNextBatch(t, s, seqNum, i+1, i+2)
+	}
+}
+
+func Test_Subscribe_Retains_The_WALs_Until_Closed(t *testing.T) {
+	d := openTestDB(t, nil)
+
+	seq0 := commitTestBatch(t, d, 0, 1)
+	rotateTestWAL(t, d)
+	seq1 := commitTestBatch(t, d, 1, 2)
+	rotateTestWAL(t, d)
+	seq2 := commitTestBatch(t, d, 2, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := d.Subscribe(ctx, seq0)
+	require.NoError(t, err)
+
+	retainedFrom := func() nogodb_common.SeqNum {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.mu.log.files[0].seqNum
+	}
+
+	// the memtables are flushed, but the subscription hasn't consumed
+	// their batches yet
+	flushTestMemTables(d)
+	assert.Equal(t, seq0, retainedFrom())
+
+	// the WAL of a consumed batch is released on the next flush
+	requireNextBatch(t, s, seq0, 0, 1)
+	flushTestMemTables(d)
+	assert.Equal(t, seq1, retainedFrom())
+
+	// the other subscriptions can still read from the retained WALs
+	s2, err := d.Subscribe(context.Background(), seq1)
+	require.NoError(t, err)
+	requireNextBatch(t, s2, seq1, 1, 2)
+	requireNextBatch(t, s2, seq2, 2, 3)
+	require.NoError(t, s2.Close())
+
+	_, err = d.Subscribe(context.Background(), seq0)
+	assert.ErrorIs(t, err, ErrSeqNumGarbageCollected)
+
+	// cancelling the subscription context closes it, and releases its WALs
+	cancel()
+	require.Eventually(t, func() bool { return retainedFrom() == seq2 }, time.Second, time.Millisecond)
+	_, err = s.Next()
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = d.Subscribe(context.Background(), seq1)
+	assert.ErrorIs(t, err, ErrSeqNumGarbageCollected)
+
+	d.subscriptions.Lock()
+	assert.Empty(t, d.subscriptions.set)
+	d.subscriptions.Unlock()
+}
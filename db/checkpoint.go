@@ -0,0 +1,324 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/datnguyenzzz/nogodb/db/manifest"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_record "github.com/datnguyenzzz/nogodb/lib/common/record"
+	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	nogodb_wal "github.com/datnguyenzzz/nogodb/lib/go-wal"
+)
+
+// checkpointSecondaryWALDir is the directory of a checkpoint holding the
+// WALs failed over to the secondary directory, see options.DBOption.WAL
+const checkpointSecondaryWALDir = "wal-secondary"
+
+var (
+	errCheckpointDirExists = errors.New("nogodb: the checkpoint directory exists already")
+	errCheckpointManifest  = errors.New("nogodb: the checkpoint must hold a single manifest")
+	errCheckpointComparer  = errors.New("nogodb: the checkpoint is ordered by another comparer")
+)
+
+// Checkpoint writes an on-disk checkpoint of the DB into dir, which mustn't
+// exist yet. The checkpoint holds:
+//   - the live sstables, hard-linked, or copied if they can't be linked
+//   - a manifest describing them
+//   - a copy of the WALs which haven't been flushed yet
+//
+// It returns the seqnum of the checkpoint: it holds the batches committed
+// below it. The checkpoint is used to bootstrap an empty replication
+// follower, see DB.ApplyCheckpoint.
+func (d *DB) Checkpoint(dir string) (nogodb_common.SeqNum, error) {
+	// neither the commits nor the flushes move the DB while the checkpoint is
+	// taken, so the WALs hold exactly the batches the sstables miss
+	// TODO(med): Only block the writes while the WALs are copied, the
+	// sstables are immutable
+	d.commit.mu.Lock()
+	defer d.commit.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	seqNum := d.commit.visibleSeqNum
+	minUnflushedLogNum := d.mu.mem.flushQueue[0].logFileNum
+	version := d.mu.versions.currentVersion()
+
+	fs := d.opts.FS
+	if _, err := fs.Stat(dir); !os.IsNotExist(err) {
+		if err == nil {
+			err = fmt.Errorf("%w: %s", errCheckpointDirExists, dir)
+		}
+		return 0, err
+	}
+	dirFile, err := mkdirAll(dir, fs)
+	if err != nil {
+		return 0, err
+	}
+	defer dirFile.Close()
+
+	// the batches below seqNum are in the WAL files once synced
+	if err := d.mu.log.writer.Sync(); err != nil {
+		return 0, err
+	}
+
+	edit := d.mu.versions.snapshotEdit(version)
+	edit.MinUnflushedLogNum = minUnflushedLogNum
+	edit.LastSeqNum = seqNum
+	for _, table := range edit.NewTables {
+		name := nogodb_common.GetFileName(nogodb_common.TypeTable, table.Meta.TableNum)
+		if err := nogodb_fs.LinkOrCopy(fs, fs.PathJoin(d.opts.SST.Dir, name), fs.PathJoin(dir, name), nogodb_common.TypeTable); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := d.writeCheckpointManifest(dir, edit); err != nil {
+		return 0, err
+	}
+
+	// the WALs are copied rather than linked, as the last one is still being
+	// written and the obsolete ones might be recycled in place
+	walDirs := [][2]string{{d.opts.WAL.Dir, dir}}
+	if d.opts.WAL.SecondaryDir != "" {
+		walDirs = append(walDirs, [2]string{d.opts.WAL.SecondaryDir, fs.PathJoin(dir, checkpointSecondaryWALDir)})
+		if err := fs.MkdirAll(walDirs[1][1], 0o755); err != nil {
+			return 0, err
+		}
+	}
+	for _, wal := range d.mu.log.files {
+		if wal.fileNum < minUnflushedLogNum {
+			// retained by a CDC subscription, it's flushed already
+			continue
+		}
+
+		name := nogodb_common.GetFileName(nogodb_common.TypeWAL, wal.fileNum)
+		for _, dirs := range walDirs {
+			// a WAL which has failed over has a segment in each directory,
+			// the others are in one of them only
+			src := fs.PathJoin(dirs[0], name)
+			if _, err := fs.Stat(src); os.IsNotExist(err) {
+				continue
+			}
+			if err := nogodb_fs.Copy(fs, src, fs.PathJoin(dirs[1], name), nogodb_common.TypeWAL); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := dirFile.Sync(); err != nil {
+		return 0, err
+	}
+
+	return seqNum, nil
+}
+
+// writeCheckpointManifest writes the manifest of a checkpoint into dir, it
+// holds a single edit creating the version of the checkpoint
+func (d *DB) writeCheckpointManifest(dir string, edit *manifest.VersionEdit) (err error) {
+	fs := d.opts.FS
+	name := nogodb_common.GetFileName(nogodb_common.TypeManifest, d.mu.versions.manifestFileNum)
+	f, err := fs.Create(fs.PathJoin(dir, name), nogodb_common.TypeManifest)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	rw := nogodb_record.NewWriter(f)
+	w, err := rw.Next()
+	if err != nil {
+		return err
+	}
+	if err := edit.Encode(w); err != nil {
+		return err
+	}
+	if err := rw.Close(); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// ApplyCheckpoint bootstraps an empty follower from a checkpoint of its
+// primary, written into dir by DB.Checkpoint. The sstables of the checkpoint
+// are linked into the DB, and the batches of its WALs are applied in order,
+// with their original seqnums. The seqnums of the follower then jump to the
+// one of the checkpoint, so the batches committed by the primary from then
+// on can be replicated, see DB.ApplyReplicated.
+//
+// dir can be removed once the checkpoint is applied.
+func (d *DB) ApplyCheckpoint(dir string) error {
+	d.commit.mu.Lock()
+	d.mu.Lock()
+	empty := d.commit.visibleSeqNum == 0
+	for _, level := range d.mu.versions.currentVersion().Levels {
+		empty = empty && level.Len() == 0
+	}
+	d.mu.Unlock()
+	d.commit.mu.Unlock()
+	if !empty {
+		return errFollowerNotEmpty
+	}
+
+	edit, err := d.readCheckpointManifest(dir)
+	if err != nil {
+		return err
+	}
+	if edit.ComparerName != d.cmp.Name() {
+		return fmt.Errorf("%w: %s", errCheckpointComparer, edit.ComparerName)
+	}
+
+	if err := d.linkCheckpointTables(dir, edit); err != nil {
+		return err
+	}
+
+	// the WALs hold the batches committed since the last flush of the
+	// primary, the seqnums of the failed commits are skipped
+	var walOpts []nogodb_wal.ReaderOptionFn
+	if secondary := d.opts.FS.PathJoin(dir, checkpointSecondaryWALDir); d.exists(secondary) {
+		walOpts = append(walOpts, nogodb_wal.WithReaderSecondaryDir(secondary))
+	}
+	wr, err := nogodb_wal.NewWalReader(dir, d.opts.FS, edit.MinUnflushedLogNum, walOpts...)
+	if err != nil {
+		return err
+	}
+	defer wr.Close()
+
+	for {
+		r, _, err := wr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("nogodb: failed to read the WALs of the checkpoint: %w", err)
+		}
+
+		repr, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_, h, err := newBatchReader(repr)
+		if err != nil {
+			return err
+		}
+		if h.SeqNum >= edit.LastSeqNum {
+			// written to the WAL, but not committed when the checkpoint was
+			// taken
+			break
+		}
+
+		if h.SeqNum > d.VisibleSeqNum() {
+			if err := d.commit.jumpTo(h.SeqNum); err != nil {
+				return err
+			}
+		}
+		if err := d.ApplyReplicated(repr); err != nil {
+			return err
+		}
+	}
+
+	if edit.LastSeqNum > d.VisibleSeqNum() {
+		return d.commit.jumpTo(edit.LastSeqNum)
+	}
+	return nil
+}
+
+// readCheckpointManifest reads the manifest of the checkpoint in dir, it
+// returns the edit merging all its records
+func (d *DB) readCheckpointManifest(dir string) (*manifest.VersionEdit, error) {
+	fs := d.opts.FS
+	names, err := fs.List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifestName string
+	for _, name := range names {
+		if objType, _, ok := nogodb_common.ParseFileName(name); ok && objType == nogodb_common.TypeManifest {
+			if manifestName != "" {
+				return nil, errCheckpointManifest
+			}
+			manifestName = name
+		}
+	}
+	if manifestName == "" {
+		return nil, errCheckpointManifest
+	}
+
+	f, err := fs.Open(fs.PathJoin(dir, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	edit := &manifest.VersionEdit{}
+	rr := nogodb_record.NewReader(f, 0)
+	for {
+		r, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			return edit, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		next := &manifest.VersionEdit{}
+		if err := next.Decode(r); err != nil {
+			return nil, err
+		}
+		if next.ComparerName != "" {
+			edit.ComparerName = next.ComparerName
+		}
+		edit.NextFileNum = max(edit.NextFileNum, next.NextFileNum)
+		edit.MinUnflushedLogNum = max(edit.MinUnflushedLogNum, next.MinUnflushedLogNum)
+		edit.LastSeqNum = max(edit.LastSeqNum, next.LastSeqNum)
+		edit.NewTables = append(edit.NewTables, next.NewTables...)
+	}
+}
+
+// linkCheckpointTables links the sstables of the checkpoint into the DB, with
+// the next file numbers of the DB, and installs them in a new version
+func (d *DB) linkCheckpointTables(dir string, edit *manifest.VersionEdit) error {
+	if len(edit.NewTables) == 0 {
+		return nil
+	}
+
+	ve := &manifest.VersionEdit{}
+	for _, table := range edit.NewTables {
+		name := nogodb_common.GetFileName(nogodb_common.TypeTable, table.Meta.TableNum)
+		num := d.mu.versions.GetNextFileNum()
+		if _, err := d.sstStorager.Link(d.opts.FS.PathJoin(dir, name), nogodb_common.TypeTable, num); err != nil {
+			return err
+		}
+
+		meta := *table.Meta
+		meta.TableNum = num
+		ve.NewTables = append(ve.NewTables, manifest.NewTableEntry{Level: table.Level, Meta: &meta})
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ve.MinUnflushedLogNum = d.mu.mem.flushQueue[0].logFileNum
+	if err := d.mu.versions.UpdateVersion(ve); err != nil {
+		return err
+	}
+
+	// failing to pin the meta blocks of the L0 tables only makes their
+	// lookups slower
+	if err := d.pinL0MetaBlocks(); err != nil {
+		d.opts.Logger.Errorf("nogodb: failed to pin the meta blocks of the L0 tables: %v", err)
+	}
+	return nil
+}
+
+// exists returns true if the file or directory exists
+func (d *DB) exists(path string) bool {
+	_, err := d.opts.FS.Stat(path)
+	return err == nil
+}
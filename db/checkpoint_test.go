@@ -0,0 +1,114 @@
+package db
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dbEntries returns the live entries of d, as "key=value"
+func dbEntries(t *testing.T, d *DB) []string {
+	t.Helper()
+	iter := d.NewIter()
+	defer func() { require.NoError(t, iter.Close()) }()
+	return iterEntries(t, iter, iter.First())
+}
+
+func Test_Checkpoint(t *testing.T) {
+	primary := openTestDB(t, func(opt *options.DBOption) {
+		opt.WAL.MaxRecycledFiles = -1
+	})
+
+	// the first batch is flushed, its WAL deleted
+	writeTestBatch(t, primary, []byte("a"), []byte("a_0"), []byte("b"), []byte("b_0"), []byte("c"), []byte("c_0"))
+	require.NoError(t, primary.Flush())
+	// the next ones are in 2 WALs
+	writeTestBatch(t, primary, []byte("b"), []byte("b_1"), []byte("c"), nil, []byte("d"), []byte("d_1"))
+	rotateTestWAL(t, primary)
+	writeTestBatch(t, primary, []byte("e"), []byte("e_2"))
+
+	dir := filepath.Join(t.TempDir(), "checkpoint")
+	seqNum, err := primary.Checkpoint(dir)
+	require.NoError(t, err)
+	assert.Equal(t, primary.VisibleSeqNum(), seqNum)
+	want := dbEntries(t, primary)
+	assert.Equal(t, []string{"a=a_0", "b=b_1", "d=d_1", "e=e_2"}, want)
+
+	_, err = primary.Checkpoint(dir)
+	assert.ErrorIs(t, err, errCheckpointDirExists)
+
+	// the checkpoint holds the sstable, hard-linked, a manifest and the 2
+	// unflushed WALs
+	l0 := primary.mu.versions.currentVersion().Levels[0]
+	require.Equal(t, 1, l0.Len())
+	var tableName string
+	for table := range l0.All() {
+		tableName = nogodb_common.GetFileName(nogodb_common.TypeTable, table.TableNum)
+	}
+	names := map[nogodb_common.ObjectType]int{}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		objType, _, ok := nogodb_common.ParseFileName(e.Name())
+		require.True(t, ok, e.Name())
+		names[objType]++
+	}
+	assert.Equal(t, map[nogodb_common.ObjectType]int{
+		nogodb_common.TypeTable:    1,
+		nogodb_common.TypeManifest: 1,
+		nogodb_common.TypeWAL:      2,
+	}, names)
+	linked, err := os.Stat(filepath.Join(dir, tableName))
+	require.NoError(t, err)
+	live, err := os.Stat(filepath.Join(primary.opts.SST.Dir, tableName))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(live, linked))
+
+	// the batches committed once the checkpoint is taken are not part of it
+	b := newBatch(primary, false)
+	require.NoError(t, b.Set([]byte("a"), []byte("a_3")))
+	require.NoError(t, primary.apply(b))
+	repr := bytes.Clone(b.buf)
+	require.NoError(t, b.Close())
+
+	follower := openTestDB(t, nil)
+	follower.SetFollowerMode(true)
+	require.NoError(t, follower.ApplyCheckpoint(dir))
+	// the follower doesn't depend on the checkpoint once applied
+	require.NoError(t, os.RemoveAll(dir))
+
+	assert.Equal(t, seqNum, follower.VisibleSeqNum())
+	assert.Equal(t, 1, follower.mu.versions.currentVersion().Levels[0].Len())
+	assert.Equal(t, want, dbEntries(t, follower))
+
+	// the replication resumes from the seqnum of the checkpoint
+	require.NoError(t, follower.ApplyReplicated(repr))
+	assert.Equal(t, primary.VisibleSeqNum(), follower.VisibleSeqNum())
+	assert.Equal(t, dbEntries(t, primary), dbEntries(t, follower))
+
+	// a checkpoint isn't applied to a follower which isn't empty
+	dir = filepath.Join(t.TempDir(), "checkpoint")
+	_, err = primary.Checkpoint(dir)
+	require.NoError(t, err)
+	assert.ErrorIs(t, follower.ApplyCheckpoint(dir), errFollowerNotEmpty)
+}
+
+func Test_Checkpoint_Empty_DB(t *testing.T) {
+	primary := openTestDB(t, nil)
+	dir := filepath.Join(t.TempDir(), "checkpoint")
+	seqNum, err := primary.Checkpoint(dir)
+	require.NoError(t, err)
+	assert.Zero(t, seqNum)
+
+	follower := openTestDB(t, nil)
+	follower.SetFollowerMode(true)
+	require.NoError(t, follower.ApplyCheckpoint(dir))
+	assert.Zero(t, follower.VisibleSeqNum())
+	assert.Empty(t, dbEntries(t, follower))
+}
@@ -1,8 +1,11 @@
 package db
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
 
+	"github.com/datnguyenzzz/nogodb/db/options"
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 )
 
@@ -38,9 +41,11 @@ import (
 //   * Add batch to commit queue
 //   * Assign batch sequence number
 //   * Write batch to the WAL
+//   * Sync the WAL, depending on options.DBOption.WAL.SyncMode
 // 2. Unlock commit mutex
 // 3. Apply batch to memtable (concurrently)
 // 4. Publish batch sequence number
+//   * Stream the batch to the CDC subscriptions, see DB.Subscribe
 
 type commit struct {
 	mu sync.Mutex
@@ -50,6 +55,11 @@ type commit struct {
 	// The visible sequence number at which reads should be performed. Ratcheted
 	// upwards atomically as batches are applied to the memtable.
 	visibleSeqNum nogodb_common.SeqNum
+	// The durable sequence number, all the batches whose sequence numbers are
+	// below it have been synced to the WAL. Ratcheted upwards atomically as
+	// the WAL is synced, see DB.SyncWAL, and as it is closed on rotation or
+	// on DB.Close.
+	durableSeqNum atomic.Uint64
 }
 
 func (c *commit) Commit(b *Batch) error {
@@ -57,17 +67,51 @@ func (c *commit) Commit(b *Batch) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if b.replicated {
+		// the seqNum has been assigned by the primary, the batches must be
+		// applied in order
+		switch seqNum := b.SeqNum(); {
+		case seqNum < c.nextSeqNum:
+			return errReplicatedAlready
+		case seqNum > c.nextSeqNum:
+			return fmt.Errorf("%w: got seqnum %d, expected %d", ErrReplicationGap, seqNum, c.nextSeqNum)
+		}
+	}
+
 	b.SetSeqNumToHeader(uint64(c.nextSeqNum))
 	b.SetCountToHeader()
 
-	c.nextSeqNum += nogodb_common.SeqNum(b.Count())
-
 	if err := c.writeToWal(b); err != nil {
 		return err
 	}
+	// the seqnums are consumed once the batch is in the WAL, a failed batch
+	// leaves no gap, which matters to the replicated batches in particular
+	c.nextSeqNum += nogodb_common.SeqNum(b.Count())
+
+	newSeqNum := b.SeqNum() + nogodb_common.SeqNum(b.Count())
+	if b.db.opts.WAL.SyncMode.Kind == options.SyncModeKindEveryCommit {
+		// TODO(med): Group the syncs of the concurrent commits, instead of
+		// syncing each of them under the commit mutex
+		if err := b.db.mu.log.writer.Sync(); err != nil {
+			// the batch is in the WAL with its seqnums consumed, it can be
+			// neither applied, as it isn't durable, nor dropped, as it might
+			// be replayed once re-opened. Stop the writes until then.
+			b.db.mu.Lock()
+			b.db.setBackgroundError(fmt.Errorf("failed syncing the WAL: %w", err), severityFatal)
+			b.db.mu.Unlock()
+			return err
+		}
+		c.ratchetDurableSeqNum(newSeqNum)
+	}
 
-	// apply the mutations from batch to the memtable
-	if err := c.applyToMem(b); err != nil {
+	if b.flushable != nil {
+		// the large batch is queued as is, instead of being
+		// copied into the memtable
+		if err := c.queueFlushable(b); err != nil {
+			return err
+		}
+	} else if err := c.applyToMem(b); err != nil {
+		// apply the mutations from batch to the memtable
 		return err
 	}
 
@@ -76,12 +120,47 @@ func (c *commit) Commit(b *Batch) error {
 	// because we synchnously writes to the WAL then apply
 	// the batch records to the memtable. So the request batch
 	// is considered as finished wholly already here
-	newSeqNum := b.SeqNum() + nogodb_common.SeqNum(b.Count())
 	c.visibleSeqNum = newSeqNum
+	// acknowledge the batch with the durable sequence number, the batch is
+	// durable if its sequence numbers are below it
+	b.durableSeqNum = c.loadDurableSeqNum()
+
+	// stream the batch to the CDC subscribers, in the commit order
+	b.db.publishToSubscriptions(b)
 
 	return nil
 }
 
+// jumpTo moves the seqnums of a follower up to the one of the checkpoint it
+// is bootstrapped from, see DB.ApplyCheckpoint
+func (c *commit) jumpTo(seqNum nogodb_common.SeqNum) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if seqNum < c.nextSeqNum {
+		return fmt.Errorf("%w: the checkpoint at %d is behind the follower at %d", errFollowerNotEmpty, seqNum, c.nextSeqNum)
+	}
+
+	c.nextSeqNum = seqNum
+	c.visibleSeqNum = seqNum
+	return nil
+}
+
+func (c *commit) loadDurableSeqNum() nogodb_common.SeqNum {
+	return nogodb_common.SeqNum(c.durableSeqNum.Load())
+}
+
+// ratchetDurableSeqNum moves the durable sequence number up to seqNum,
+// it never moves it backward.
+func (c *commit) ratchetDurableSeqNum(seqNum nogodb_common.SeqNum) {
+	for {
+		curr := c.durableSeqNum.Load()
+		if uint64(seqNum) <= curr || c.durableSeqNum.CompareAndSwap(curr, uint64(seqNum)) {
+			return
+		}
+	}
+}
+
 func (c *commit) applyToMem(b *Batch) error {
 	// the current mutable memtable has been prepared for applying
 	// such as prevent it being flushed, etc. in the writeToWal() step
@@ -102,9 +181,24 @@ func (c *commit) applyToMem(b *Batch) error {
 	return nil
 }
 
+// queueFlushable rotates the mutable memtable and queues the large
+// batch as a flushable
+func (c *commit) queueFlushable(b *Batch) error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+
+	return b.db.rotateMemTable(b)
+}
+
 func (c *commit) writeToWal(b *Batch) error {
-	mem := b.db.mu.mem.mutable
-	mem.prepare(b)
+	if uint64(len(b.buf)) >= b.db.largeBatchThreshold {
+		var err error
+		if b.flushable, err = newFlushableBatch(b, b.db.cmp); err != nil {
+			return err
+		}
+	} else if err := b.db.makeRoomForWrite(b); err != nil {
+		return err
+	}
 
 	_, err := b.db.mu.log.writer.Write(b.buf)
 	if err != nil {
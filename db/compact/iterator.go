@@ -1,26 +1,204 @@
 package compact
 
 import (
+	"slices"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 )
 
+type IterOptFn func(*Iter)
+
+// WithCompactionFilter invokes the filter for each surviving key, the
+// compaction outputs to the given level
+func WithCompactionFilter(filter options.CompactionFilter, level int, bottomMost bool) IterOptFn {
+	return func(i *Iter) {
+		i.filter = filter
+		i.filterCtx = options.CompactionFilterContext{Level: level, BottomMost: bottomMost}
+	}
+}
+
+// WithSnapshots keeps the latest version of each key visible to each of the
+// open snapshots, given their seqnums. A snapshot sees the versions whose
+// seqnums are below its own.
+func WithSnapshots(snapshots []nogodb_common.SeqNum) IterOptFn {
+	return func(i *Iter) {
+		i.snapshots = slices.Clone(snapshots)
+		slices.Sort(i.snapshots)
+	}
+}
+
 // Iter provides a forward-only iterator that encapsulates the logic for
 // collapsing entries during compaction. The high-level structure for
-// compact.Iter is to iterate over its internal iterator and output 1 entry
-// for every user-key.
+// compact.Iter is to iterate over its internal iterator and output, for every
+// user-key, the latest version in each snapshot stripe, newest first.
+//
+// A snapshot stripe is the range of seqnums between 2 consecutive snapshots,
+// the versions of a stripe are seen by the same snapshots. Only the latest
+// one of them can be read, the older ones are dropped. Then:
+//  1. The latest version, once visible to no snapshot, is filtered by the
+//     options.CompactionFilter if any. The versions kept for the snapshots
+//     are not, so the snapshots keep reading a consistent view.
+//  2. The oldest DELs are dropped by the bottom-most compactions, there is
+//     nothing left to shadow.
+//
 // In the future, this Iter will have to handle some complications
-//  1. Omit redudant DEL: Such as the entries a.DEL.2 and a.PUT.1
-//  2. Merges
-//  3. Range deletion
+//  1. Merges
+//  2. Range deletion
 type Iter struct {
 	cmp   nogodb_common.IComparer
 	iters nogodb_common.InternalIterator[nogodb_common.InternalKV]
-	kv    *nogodb_common.InternalKV
+
+	// next is the latest version of the next user key
+	next *nogodb_common.InternalKV
+	// userKey is the user key of the output entries, it's owned by the Iter
+	// since the internal iterator might reuse its buffers
+	userKey []byte
+	// out holds the output entries of userKey, out[pos] is the current one.
+	// Their values are owned by the Iter as well, they're copied to vals.
+	out  []nogodb_common.InternalKV
+	vals []byte
+	pos  int
+
+	snapshots []nogodb_common.SeqNum
+
+	filter    options.CompactionFilter
+	filterCtx options.CompactionFilterContext
 }
 
 func NewIter(
 	cmp nogodb_common.IComparer,
 	iter nogodb_common.InternalIterator[nogodb_common.InternalKV],
+	opts ...IterOptFn,
 ) *Iter {
-	panic("")
+	i := &Iter{
+		cmp:   cmp,
+		iters: iter,
+	}
+	for _, o := range opts {
+		o(i)
+	}
+
+	return i
+}
+
+// First moves the iterator to the first surviving entry
+func (i *Iter) First() *nogodb_common.InternalKV {
+	i.next = i.iters.First()
+	return i.nextUserKey()
+}
+
+// Next moves the iterator to the next surviving entry, either an older version
+// of the same user key kept for a snapshot, or the next user key
+func (i *Iter) Next() *nogodb_common.InternalKV {
+	if i.pos >= len(i.out) {
+		return nil
+	}
+
+	i.pos++
+	if i.pos < len(i.out) {
+		return &i.out[i.pos]
+	}
+
+	return i.nextUserKey()
+}
+
+// Close closes the internal iterator
+func (i *Iter) Close() error {
+	i.next = nil
+	i.out = i.out[:0]
+	i.pos = 0
+	return i.iters.Close()
+}
+
+// nextUserKey moves to the first surviving entry of the next user keys
+func (i *Iter) nextUserKey() *nogodb_common.InternalKV {
+	for i.next != nil {
+		i.collapse()
+		if len(i.out) > 0 {
+			i.pos = 0
+			return &i.out[0]
+		}
+	}
+
+	i.out = i.out[:0]
+	i.pos = 0
+	return nil
+}
+
+// stripe returns the snapshot stripe of seqNum, i.e. the number of snapshots
+// which don't see it. The stripe of the latest versions, visible to no
+// snapshot, is len(i.snapshots).
+func (i *Iter) stripe(seqNum nogodb_common.SeqNum) int {
+	n, _ := slices.BinarySearch(i.snapshots, seqNum+1)
+	return n
+}
+
+// collapse fills i.out with the surviving entries of the user key of i.next,
+// and moves i.next to the next user key
+func (i *Iter) collapse() {
+	i.userKey = append(i.userKey[:0], i.next.K.UserKey...)
+	i.out = i.out[:0]
+	i.vals = i.vals[:0]
+
+	// the latest version of each stripe, the values are referenced by their
+	// end offset in vals, which might be reallocated
+	var ends []int
+	prevStripe := -1
+	kv := i.next
+	for ; kv != nil && i.cmp.Compare(kv.K.UserKey, i.userKey) == 0; kv = i.iters.Next() {
+		stripe := i.stripe(kv.K.SeqNum())
+		if stripe == prevStripe {
+			// shadowed by a newer version of the stripe
+			continue
+		}
+		prevStripe = stripe
+
+		if kv.K.KeyKind() != nogodb_common.KeyKindDelete {
+			i.vals = append(i.vals, kv.V.Value()...)
+		}
+		i.out = append(i.out, nogodb_common.InternalKV{K: nogodb_common.MakeKey(i.userKey, kv.K.SeqNum(), kv.K.KeyKind())})
+		ends = append(ends, len(i.vals))
+	}
+	i.next = kv
+
+	start := 0
+	for j, end := range ends {
+		if i.out[j].K.KeyKind() == nogodb_common.KeyKindDelete {
+			i.out[j].V = nogodb_common.NewBlankInternalLazyValue(nogodb_common.ValueInPlace)
+		} else {
+			i.out[j].V = nogodb_common.NewInPlaceInternalLazyValue(i.vals[start:end:end])
+		}
+		start = end
+	}
+
+	if i.stripe(i.out[0].K.SeqNum()) == len(i.snapshots) {
+		i.applyFilter(&i.out[0])
+	}
+
+	for i.filterCtx.BottomMost && len(i.out) > 0 && i.out[len(i.out)-1].K.KeyKind() == nogodb_common.KeyKindDelete {
+		// there is nothing left to shadow
+		i.out = i.out[:len(i.out)-1]
+	}
+}
+
+// applyFilter filters kv, the latest version of its user key, visible to no
+// snapshot
+func (i *Iter) applyFilter(kv *nogodb_common.InternalKV) {
+	// TODO(med): Support the merges, the filter would have to be invoked
+	// on the merged value
+	if i.filter == nil || kv.K.KeyKind() != nogodb_common.KeyKindSet {
+		return
+	}
+
+	decision, newValue := i.filter.Filter(i.filterCtx, kv.K.UserKey, kv.V.Value())
+	switch decision {
+	case options.CompactionFilterRemove:
+		// shadow the older versions, unless it's dropped as the oldest
+		// tombstone of a bottom-most compaction
+		kv.K = nogodb_common.MakeKey(kv.K.UserKey, kv.K.SeqNum(), nogodb_common.KeyKindDelete)
+		kv.V = nogodb_common.NewBlankInternalLazyValue(nogodb_common.ValueInPlace)
+	case options.CompactionFilterChangeValue:
+		kv.V = nogodb_common.NewInPlaceInternalLazyValue(newValue)
+	}
 }
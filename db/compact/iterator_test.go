@@ -0,0 +1,277 @@
+package compact
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceIter is a synthetic internal iterator over sorted entries. Like the
+// real ones, it reuses its key and value buffers once moved.
+type sliceIter struct {
+	kvs    []nogodb_common.InternalKV
+	pos    int
+	key    []byte
+	val    []byte
+	cur    nogodb_common.InternalKV
+	closed bool
+}
+
+var _ nogodb_common.InternalIterator[nogodb_common.InternalKV] = (*sliceIter)(nil)
+
+func (s *sliceIter) at(pos int) *nogodb_common.InternalKV {
+	s.pos = pos
+	if pos < 0 || pos >= len(s.kvs) {
+		return nil
+	}
+
+	kv := s.kvs[pos]
+	s.key = append(s.key[:0], kv.K.UserKey...)
+	s.val = append(s.val[:0], kv.V.Value()...)
+	s.cur = nogodb_common.InternalKV{
+		K: nogodb_common.MakeKey(s.key, kv.K.SeqNum(), kv.K.KeyKind()),
+		V: nogodb_common.NewInPlaceInternalLazyValue(s.val),
+	}
+	return &s.cur
+}
+
+func (s *sliceIter) First() *nogodb_common.InternalKV { return s.at(0) }
+func (s *sliceIter) Last() *nogodb_common.InternalKV  { return s.at(len(s.kvs) - 1) }
+func (s *sliceIter) Next() *nogodb_common.InternalKV  { return s.at(s.pos + 1) }
+func (s *sliceIter) Prev() *nogodb_common.InternalKV  { return s.at(s.pos - 1) }
+
+func (s *sliceIter) SeekGTE(key []byte) *nogodb_common.InternalKV {
+	panic("unimplemented")
+}
+
+func (s *sliceIter) SeekPrefixGTE(prefix, key []byte) *nogodb_common.InternalKV {
+	panic("unimplemented")
+}
+
+func (s *sliceIter) SeekLTE(key []byte) *nogodb_common.InternalKV {
+	panic("unimplemented")
+}
+
+func (s *sliceIter) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *sliceIter) IsClosed() bool { return s.closed }
+
+var kindNames = map[nogodb_common.KeyKind]string{
+	nogodb_common.KeyKindDelete: "DEL",
+	nogodb_common.KeyKindSet:    "SET",
+	nogodb_common.KeyKindMerge:  "MERGE",
+}
+
+// entry describes an internal key/value pair as "key#seqNum,KIND=value"
+func entry(kv *nogodb_common.InternalKV) string {
+	s := fmt.Sprintf("%s#%d,%s", kv.K.UserKey, kv.K.SeqNum(), kindNames[kv.K.KeyKind()])
+	if kv.K.KeyKind() != nogodb_common.KeyKindDelete {
+		s += "=" + string(kv.V.Value())
+	}
+	return s
+}
+
+func set(key string, seqNum nogodb_common.SeqNum, value string) nogodb_common.InternalKV {
+	return nogodb_common.InternalKV{
+		K: nogodb_common.MakeKey([]byte(key), seqNum, nogodb_common.KeyKindSet),
+		V: nogodb_common.NewInPlaceInternalLazyValue([]byte(value)),
+	}
+}
+
+func del(key string, seqNum nogodb_common.SeqNum) nogodb_common.InternalKV {
+	return nogodb_common.InternalKV{
+		K: nogodb_common.MakeKey([]byte(key), seqNum, nogodb_common.KeyKindDelete),
+		V: nogodb_common.NewBlankInternalLazyValue(nogodb_common.ValueInPlace),
+	}
+}
+
+// prefixFilter removes the keys prefixed by "rm", and uppercases the values
+// of the ones prefixed by "up"
+type prefixFilter struct{}
+
+func (prefixFilter) Name() string { return "prefixFilter" }
+
+func (prefixFilter) Filter(_ options.CompactionFilterContext, key, value []byte) (options.CompactionFilterDecision, []byte) {
+	switch {
+	case len(key) >= 2 && string(key[:2]) == "rm":
+		return options.CompactionFilterRemove, nil
+	case len(key) >= 2 && string(key[:2]) == "up":
+		return options.CompactionFilterChangeValue, []byte(fmt.Sprintf("%X", value))
+	}
+	return options.CompactionFilterKeep, nil
+}
+
+func Test_Iter(t *testing.T) {
+	now := time.Unix(1_000, 0)
+	ttlFilter := options.NewTTLCompactionFilter(options.WithTTLClock(func() time.Time { return now }))
+	ttl := func(key string, seqNum nogodb_common.SeqNum, expireAt time.Time) nogodb_common.InternalKV {
+		return nogodb_common.InternalKV{
+			K: nogodb_common.MakeKey([]byte(key), seqNum, nogodb_common.KeyKindSet),
+			V: nogodb_common.NewInPlaceInternalLazyValue(options.EncodeTTLValue([]byte("v"), expireAt)),
+		}
+	}
+	ttlEntry := func(key string, seqNum nogodb_common.SeqNum, expireAt time.Time) string {
+		kv := ttl(key, seqNum, expireAt)
+		return entry(&kv)
+	}
+
+	tests := []struct {
+		name       string
+		kvs        []nogodb_common.InternalKV
+		snapshots  []nogodb_common.SeqNum
+		filter     options.CompactionFilter
+		bottomMost bool
+		want       []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "the latest version of each key",
+			kvs: []nogodb_common.InternalKV{
+				set("a", 3, "a3"), set("a", 2, "a2"), set("a", 1, "a1"),
+				set("b", 4, "b4"),
+				del("c", 6), set("c", 5, "c5"),
+			},
+			want: []string{"a#3,SET=a3", "b#4,SET=b4", "c#6,DEL"},
+		},
+		{
+			name: "the tombstones are kept above the bottom-most level",
+			kvs: []nogodb_common.InternalKV{
+				del("a", 2), set("a", 1, "a1"),
+				del("b", 3),
+			},
+			want: []string{"a#2,DEL", "b#3,DEL"},
+		},
+		{
+			name: "the tombstones are elided in the bottom-most level",
+			kvs: []nogodb_common.InternalKV{
+				del("a", 2), set("a", 1, "a1"),
+				set("b", 4, "b4"), del("b", 3),
+				del("c", 5),
+			},
+			bottomMost: true,
+			want:       []string{"b#4,SET=b4"},
+		},
+		{
+			name: "the latest version of each snapshot stripe",
+			kvs: []nogodb_common.InternalKV{
+				set("a", 9, "a9"), set("a", 8, "a8"), set("a", 5, "a5"), set("a", 4, "a4"), set("a", 1, "a1"),
+				set("b", 7, "b7"), set("b", 6, "b6"),
+				set("c", 2, "c2"),
+			},
+			// the stripes are [0, 3), [3, 7) and [7, ∞)
+			snapshots: []nogodb_common.SeqNum{7, 3},
+			want: []string{
+				"a#9,SET=a9", "a#5,SET=a5", "a#1,SET=a1",
+				"b#7,SET=b7", "b#6,SET=b6",
+				"c#2,SET=c2",
+			},
+		},
+		{
+			name: "the tombstones visible to a snapshot are kept in the bottom-most level",
+			kvs: []nogodb_common.InternalKV{
+				set("a", 5, "a5"), del("a", 4), set("a", 2, "a2"),
+				del("b", 5), set("b", 1, "b1"),
+				set("c", 6, "c6"), del("c", 2),
+			},
+			snapshots:  []nogodb_common.SeqNum{3, 5},
+			bottomMost: true,
+			want: []string{
+				"a#5,SET=a5", "a#4,DEL", "a#2,SET=a2",
+				"b#5,DEL", "b#1,SET=b1",
+				"c#6,SET=c6",
+			},
+		},
+		{
+			name: "the filter removes and changes the values",
+			kvs: []nogodb_common.InternalKV{
+				set("keep", 2, "v2"), set("keep", 1, "v1"),
+				set("rm", 4, "v4"), set("rm", 3, "v3"),
+				set("up", 5, "ab"),
+				del("up2", 7), set("up2", 6, "cd"),
+			},
+			filter: prefixFilter{},
+			want: []string{
+				"keep#2,SET=v2",
+				"rm#4,DEL",
+				"up#5,SET=6162",
+				"up2#7,DEL",
+			},
+		},
+		{
+			name: "the keys removed by the filter are elided in the bottom-most level",
+			kvs: []nogodb_common.InternalKV{
+				set("rm", 4, "v4"), set("rm", 3, "v3"),
+				set("up", 5, "ab"),
+			},
+			filter:     prefixFilter{},
+			bottomMost: true,
+			want:       []string{"up#5,SET=6162"},
+		},
+		{
+			name: "the filter skips the versions visible to a snapshot",
+			kvs: []nogodb_common.InternalKV{
+				set("rm", 4, "v4"), set("rm", 2, "v2"),
+				set("rm2", 1, "v1"),
+				set("up", 5, "ab"), set("up", 3, "cd"),
+			},
+			snapshots:  []nogodb_common.SeqNum{4},
+			filter:     prefixFilter{},
+			bottomMost: true,
+			want: []string{
+				"rm#4,DEL", "rm#2,SET=v2",
+				"rm2#1,SET=v1",
+				"up#5,SET=6162", "up#3,SET=cd",
+			},
+		},
+		{
+			name: "the TTL filter removes the expired values",
+			kvs: []nogodb_common.InternalKV{
+				ttl("expired", 2, now.Add(-time.Second)),
+				ttl("expiring", 3, now),
+				ttl("live", 4, now.Add(time.Second)),
+				ttl("never", 5, time.Time{}),
+				set("short", 6, "v"),
+			},
+			filter: ttlFilter,
+			want: []string{
+				"expired#2,DEL",
+				"expiring#3,DEL",
+				ttlEntry("live", 4, now.Add(time.Second)),
+				ttlEntry("never", 5, time.Time{}),
+				"short#6,SET=v",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []IterOptFn{WithCompactionFilter(tc.filter, 1, tc.bottomMost)}
+			if tc.snapshots != nil {
+				opts = append(opts, WithSnapshots(tc.snapshots))
+			}
+
+			internal := &sliceIter{kvs: tc.kvs}
+			iter := NewIter(nogodb_common.NewComparer(), internal, opts...)
+
+			var got []string
+			for kv := iter.First(); kv != nil; kv = iter.Next() {
+				got = append(got, entry(kv))
+			}
+			assert.Equal(t, tc.want, got)
+			assert.Nil(t, iter.Next(), "the iterator is exhausted")
+
+			require.NoError(t, iter.Close())
+			assert.True(t, internal.IsClosed())
+		})
+	}
+}
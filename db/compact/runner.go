@@ -1,39 +1,92 @@
 package compact
 
 import (
+	"slices"
+
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
 	nogodb_sst "github.com/datnguyenzzz/nogodb/lib/go-sstable"
 )
 
+// Runner writes the surviving entries of a compaction iterator into the
+// output tables. The caller creates a table as long as HasMore, and hands it
+// over to DoWrite.
 type Runner struct {
 	bound nogodb_common.UserKeyBound
 	iter  *Iter
+	// kv is the next entry to write, nil once the iterator is exhausted
+	kv     *nogodb_common.InternalKV
+	result Result
+}
+
+// Table is an output table of a compaction
+type Table struct {
+	FileDesc   nogodb_fs.FileDesc
+	LowSeqNum  nogodb_common.SeqNum
+	HighSeqNum nogodb_common.SeqNum
+	// Smallest and Largest are the user key bounds of the table, inclusive
+	Smallest []byte
+	Largest  []byte
+	// Size is the size of the table in bytes, it's left to the caller which
+	// owns the file
+	Size uint64
 }
 
 // Result stores the result of a compaction - more specifically, the "data" part
 // where we use the compaction iterator to write output tables.
 type Result struct {
 	Err    error
-	Tables []struct {
-		FileDesc   nogodb_fs.FileDesc
-		LowSeqNum  nogodb_common.SeqNum
-		HighSeqNum nogodb_common.SeqNum
-	}
+	Tables []Table
 }
 
+// NewRunner positions the iterator on its first surviving entry, the keys of
+// the compaction are within bound. The iterator is left to the caller to be
+// closed.
 func NewRunner(iter *Iter, bound nogodb_common.UserKeyBound) *Runner {
-	panic("")
+	return &Runner{
+		bound: bound,
+		iter:  iter,
+		kv:    iter.First(),
+	}
 }
 
+// HasMore returns true if there are entries left to write, and no table has
+// failed to be written.
 func (r *Runner) HasMore() bool {
-	panic("")
+	return r.kv != nil && r.result.Err == nil
 }
 
+// DoWrite writes the entries left into the table, then closes it.
+// TODO(med): Cut the output tables at a target size, so that the compactions
+// of the next levels don't have to rewrite a whole flush at once. The versions
+// of a user key must stay in the same table.
 func (r *Runner) DoWrite(fd *nogodb_fs.FileDesc, tw nogodb_sst.IWriter) {
-	panic("")
+	table := Table{FileDesc: *fd, LowSeqNum: nogodb_common.SeqNum(^uint64(0))}
+	for ; r.kv != nil; r.kv = r.iter.Next() {
+		if err := tw.Add(r.kv.K, r.kv.V.Value()); err != nil {
+			r.result.Err = err
+			_ = tw.Close()
+			return
+		}
+
+		if table.Smallest == nil {
+			table.Smallest = slices.Clone(r.kv.K.UserKey)
+		}
+		// the iterator might reuse the buffer of the key once moved
+		table.Largest = append(table.Largest[:0], r.kv.K.UserKey...)
+		table.LowSeqNum = min(table.LowSeqNum, r.kv.K.SeqNum())
+		table.HighSeqNum = max(table.HighSeqNum, r.kv.K.SeqNum())
+	}
+
+	if err := tw.Close(); err != nil {
+		r.result.Err = err
+		return
+	}
+
+	r.result.Tables = append(r.result.Tables, table)
 }
 
+// Finish returns the result of the compaction
 func (r *Runner) Finish() *Result {
-	return nil
+	return &r.result
 }
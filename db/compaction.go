@@ -13,9 +13,10 @@ import (
 	nogodb_pool "github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool/predictable_size"
 	nogodb_sst "github.com/datnguyenzzz/nogodb/lib/go-sstable"
 	sst_common "github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	sst_options "github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
 )
 
-var flushLabels = pprof.Labels("flush", "to-level", "L0")
+var flushLabels = pprof.Labels("job", "flush", "to-level", "L0")
 
 type compaction struct {
 	cmp        nogodb_common.IComparer
@@ -38,10 +39,10 @@ func newCompaction() *compaction {
 }
 
 // newFlush flushs memtables to SST L0.
-func newFlush(o *options.DBOption, memTables []*memTable) *compaction {
-	flushList := make([]flushable, 0, len(memTables))
-	for _, t := range memTables {
-		flushList = append(flushList, t)
+func newFlush(o *options.DBOption, entries []*flushableEntry) *compaction {
+	flushList := make([]flushable, 0, len(entries))
+	for _, e := range entries {
+		flushList = append(flushList, e.flushable)
 	}
 	c := &compaction{
 		cmp:        o.Comparer,
@@ -74,14 +75,66 @@ func newFlush(o *options.DBOption, memTables []*memTable) *compaction {
 	return c
 }
 
+// Flush flushes the memtables to L0 sstables, and waits until they're
+// flushed. The mutable memtable is rotated first, unless it's empty.
+func (d *DB) Flush() error {
+	// the memtable is rotated under the commit mutex, as on a write
+	d.commit.mu.Lock()
+	d.mu.Lock()
+	if d.mu.mem.mutable.inuseBytes() > 0 {
+		if err := d.rotateMemTable(nil); err != nil {
+			d.mu.Unlock()
+			d.commit.mu.Unlock()
+			return err
+		}
+	}
+
+	immutables := d.mu.mem.flushQueue[:len(d.mu.mem.flushQueue)-1]
+	if len(immutables) == 0 {
+		d.mu.Unlock()
+		d.commit.mu.Unlock()
+		return nil
+	}
+	for _, entry := range immutables {
+		entry.flushForced = true
+	}
+	// the flushables are flushed in order
+	flushed := immutables[len(immutables)-1].flushed
+	d.maybeScheduleFlush()
+	d.commit.mu.Unlock()
+	defer d.mu.Unlock()
+
+	for {
+		select {
+		case <-flushed:
+			return nil
+		case <-d.closedCh:
+			return ErrClosed
+		default:
+		}
+
+		// a failed flush is retried in background, unless the writes are
+		// stopped until DB.Resume
+		if bgErr := d.bgErr.Load(); bgErr.stopsWrites() {
+			return bgErr.err
+		}
+		d.mu.compact.cond.Wait()
+	}
+}
+
 func (d *DB) flush() {
 	pprof.Do(context.Background(), flushLabels, func(ctx context.Context) {
 		d.mu.Lock()
 		defer d.mu.Unlock()
 
-		if err := d.__flush(); err != nil {
-			// uhmm what do to if flushing failed :thinking:
+		err := d.__flush()
+		d.mu.compact.flushing = false
+		defer d.mu.compact.cond.Broadcast()
+		if err != nil {
+			d.onFlushError(err)
+			return
 		}
+		d.clearRetryableError()
 
 		// More flush work may have arrived while we were flushing, so schedule
 		// another flush if needed.
@@ -136,16 +189,33 @@ func (d *DB) __flush() (err error) {
 	}
 
 	d.mu.mem.flushQueue = d.mu.mem.flushQueue[n:]
+
+	// the flush is committed, failing to pin the meta blocks of the new L0
+	// tables only makes their lookups slower
+	if err := d.pinL0MetaBlocks(); err != nil {
+		d.opts.Logger.Errorf("nogodb: failed to pin the meta blocks of the L0 tables: %v", err)
+	}
+
+	// the WALs of the flushed memtables are either recycled or deleted.
+	// Note: the flush has already been committed to the manifest at this point,
+	// so a failure here must not fail it, the files are simply leaked.
+	d.obsoleteWALs()
+
 	return nil
 }
 
 func (d *DB) runCompaction(c *compaction) (ve *manifest.VersionEdit, err error) {
+	filterBitsPerKey := d.filterBitsPerKey(c.outLevel.level)
+	snapshots := d.snapshotSeqNums()
+	bottomMost := d.isBottomMost(c)
+
 	// release the db.mu.Lock while doing I/O
 	d.mu.Unlock()
 	defer d.mu.Lock()
 
 	// Note: Compactions shoud avoids polluting the block cache with
-	// blocks that won't likely be read again
+	// blocks that won't likely be read again, hence the input tables are
+	// read without filling the cache
 
 	var iters []nogodb_common.InternalIterator[nogodb_common.InternalKV]
 
@@ -154,19 +224,17 @@ func (d *DB) runCompaction(c *compaction) (ve *manifest.VersionEdit, err error)
 		iters = make([]nogodb_common.InternalIterator[nogodb_common.InternalKV], 0, len(c.flushList))
 	} else {
 		// compact Li tables to Li+1
-		iters = append(iters, newLevelIter(d.opts, &c.startLevel.tables))
+		iters = append(iters, d.newLevelIter(&c.startLevel.tables, sst_options.WithDontFillCache(), sst_options.WithSequentialReads()))
 	}
 	// TODO: support Range Queries (list, delete) iteration
 
 	defer func() {
-		if err != nil {
-			for _, iter := range iters {
-				if iter == nil {
-					continue
-				}
-
-				_ = iter.Close()
+		for _, iter := range iters {
+			if iter == nil {
+				continue
 			}
+
+			_ = iter.Close()
 		}
 	}()
 
@@ -175,11 +243,19 @@ func (d *DB) runCompaction(c *compaction) (ve *manifest.VersionEdit, err error)
 	}
 
 	// TODO: Support merging all iters into one single iteration
-	dfns := make([]nogodb_common.DiskfileNum, 16)
-	results := make([]*compact.Result, 16)
-
-	for _, iter := range iters {
-		cIter := compact.NewIter(c.cmp, iter)
+	dfns := make([]nogodb_common.DiskfileNum, 0, 16)
+	results := make([]*compact.Result, 0, len(iters))
+
+	for i, iter := range iters {
+		// The memtables are flushed one by one, from the oldest one. The
+		// DELs of a newer memtable shadow the older ones, which aren't
+		// merged with it, hence only the oldest one might be bottom-most.
+		// TODO(med): Drop this once the iters are merged into one
+		iterOpts := []compact.IterOptFn{
+			compact.WithSnapshots(snapshots),
+			compact.WithCompactionFilter(d.opts.CompactionFilter, c.outLevel.level, bottomMost && i == 0),
+		}
+		cIter := compact.NewIter(c.cmp, iter, iterOpts...)
 		cRunner := compact.NewRunner(cIter, c.bound)
 		dfns = dfns[:0]
 
@@ -197,6 +273,14 @@ func (d *DB) runCompaction(c *compaction) (ve *manifest.VersionEdit, err error)
 				nogodb_sst.WithBlockSize(d.opts.SST.BlockSize),
 				nogodb_sst.WithBlockSizeThreshold(float32(d.opts.SST.BlockSizeThreshold) / 100.0),
 				nogodb_sst.WithCompression(compression.SnappyCompression),
+				nogodb_sst.WithFilterMethod(d.opts.FilterMethod(c.outLevel.level)),
+				nogodb_sst.WithFilterBitsPerKey(filterBitsPerKey),
+			}
+			if d.opts.SST.PartitionedFilter {
+				opts = append(opts, nogodb_sst.WithPartitionedFilter())
+			}
+			if d.opts.SST.PrefixExtractor != nil {
+				opts = append(opts, nogodb_sst.WithPrefixExtractor(d.opts.SST.PrefixExtractor))
 			}
 			writer := nogodb_sst.NewWriter(writable, sst_common.TableV2, opts...)
 
@@ -208,15 +292,19 @@ func (d *DB) runCompaction(c *compaction) (ve *manifest.VersionEdit, err error)
 		}
 		if res.Err == nil {
 			for _, dfn := range dfns {
-				_ = d.sstStorager.Sync(nogodb_common.TypeTable, dfn)
+				if err := d.sstStorager.Sync(nogodb_common.TypeTable, dfn); err != nil {
+					res.Err = err
+					break
+				}
 			}
 		}
+		for j := 0; res.Err == nil && j < len(res.Tables); j++ {
+			res.Tables[j].Size, res.Err = d.tableSize(res.Tables[j].FileDesc.Num)
+		}
 
 		results = append(results, res)
 	}
 
-	ve = makeVersionEdit(c, results)
-
 	for _, res := range results {
 		if res.Err == nil {
 			continue
@@ -225,11 +313,36 @@ func (d *DB) runCompaction(c *compaction) (ve *manifest.VersionEdit, err error)
 		// TODO(high): Delete any created zombie tables, aka the tables
 		// are created (with iterator is still accessible) but got any
 		// error
+
+		// The memtables must not be dropped if any of their output
+		// tables is missing, so fail the whole job and let it be retried
+		return nil, res.Err
 	}
 
+	ve = makeVersionEdit(c, results)
+
 	return ve, nil
 }
 
+// isBottomMost returns true if no table is older than the inputs of the
+// compaction, i.e. there is no table either in the levels below the output
+// level, or in the output level itself outside the inputs. The flushed
+// memtables are newer than any table, so they're bottom-most only if the
+// DB holds no table at all.
+// d.mu must be held when calling this.
+func (d *DB) isBottomMost(c *compaction) bool {
+	version := d.mu.versions.currentVersion()
+	for level := c.outLevel.level + 1; level < manifest.NumLevels; level++ {
+		if version.Levels[level].Len() > 0 {
+			return false
+		}
+	}
+
+	// TODO(med): Subtract the output level tables which are the inputs of
+	// the compaction, once newCompaction picks them
+	return version.Levels[c.outLevel.level].Len() == 0
+}
+
 func makeVersionEdit(c *compaction, results []*compact.Result) *manifest.VersionEdit {
 	ve := &manifest.VersionEdit{}
 
@@ -243,8 +356,11 @@ func makeVersionEdit(c *compaction, results []*compact.Result) *manifest.Version
 				Level: c.outLevel.level,
 				Meta: &manifest.TableMetadata{
 					TableNum:   table.FileDesc.Num,
+					Size:       table.Size,
 					LowSeqNum:  table.LowSeqNum,
 					HighSeqNum: table.HighSeqNum,
+					Smallest:   table.Smallest,
+					Largest:    table.Largest,
 				},
 			}
 			ve.NewTables = append(ve.NewTables, *entry)
@@ -253,3 +369,14 @@ func makeVersionEdit(c *compaction, results []*compact.Result) *manifest.Version
 
 	return ve
 }
+
+// tableSize returns the size of the table in bytes
+func (d *DB) tableSize(num nogodb_common.DiskfileNum) (uint64, error) {
+	r, _, err := d.sstStorager.Open(nogodb_common.TypeTable, num)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	return r.Size(), nil
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tableEntries returns the entries of the table at pos in the level, as
+// "key#seqNum,kind=value"
+func tableEntries(t *testing.T, d *DB, level, pos int) []string {
+	t.Helper()
+	d.mu.Lock()
+	tables := d.mu.versions.currentVersion().Levels[level].Iter(pos, pos+1)
+	d.mu.Unlock()
+
+	iter := d.newLevelIter(tables)
+	defer func() { require.NoError(t, iter.Close()) }()
+
+	var entries []string
+	for kv := iter.First(); kv != nil; kv = iter.Next() {
+		entries = append(entries, fmt.Sprintf("%s#%d,%d=%s", kv.K.UserKey, kv.K.SeqNum(), kv.K.KeyKind(), kv.V.Value()))
+	}
+	require.NoError(t, iter.Error())
+	return entries
+}
+
+func Test_Flush_Writes_The_MemTables_To_L0(t *testing.T) {
+	d := openTestDB(t, func(opt *options.DBOption) {
+		// the obsolete WALs are deleted rather than recycled
+		opt.WAL.MaxRecycledFiles = -1
+	})
+
+	seq0 := commitTestBatch(t, d, 0, 3)
+	b := newBatch(d, false)
+	require.NoError(t, b.Set([]byte("key_1"), []byte("new_value")))
+	require.NoError(t, b.Delete([]byte("key_2")))
+	require.NoError(t, d.apply(b))
+	seq1 := b.SeqNum()
+	require.NoError(t, b.Close())
+
+	d.mu.Lock()
+	flushedWAL := d.mu.log.files[0].fileNum
+	d.mu.Unlock()
+
+	require.NoError(t, d.Flush())
+
+	d.mu.Lock()
+	l0 := d.mu.versions.currentVersion().Levels[0]
+	require.Equal(t, 1, l0.Len())
+	table := l0.Iter(0, 1).First()
+	assert.Equal(t, "key_0", string(table.Smallest))
+	assert.Equal(t, "key_1", string(table.Largest))
+	assert.Equal(t, seq0, table.LowSeqNum)
+	assert.Equal(t, seq1, table.HighSeqNum)
+	assert.Positive(t, table.Size)
+
+	// the flushed memtable is dropped, and its WAL is deleted
+	assert.Len(t, d.mu.mem.flushQueue, 1)
+	assert.Greater(t, d.mu.log.files[0].fileNum, flushedWAL)
+	d.mu.Unlock()
+	wals, err := d.opts.FS.List(d.opts.WAL.Dir)
+	require.NoError(t, err)
+	assert.False(t, slices.Contains(wals, nogodb_common.GetFileName(nogodb_common.TypeWAL, flushedWAL)))
+
+	// only the latest version of each key is flushed, the DB holds no
+	// table yet so the DEL of key_2 has nothing to shadow
+	assert.Equal(t, []string{
+		fmt.Sprintf("key_0#%d,%d=value_0", seq0, nogodb_common.KeyKindSet),
+		fmt.Sprintf("key_1#%d,%d=new_value", seq1, nogodb_common.KeyKindSet),
+	}, tableEntries(t, d, 0, 0))
+
+	// the memtables are empty, there is nothing to flush
+	require.NoError(t, d.Flush())
+	d.mu.Lock()
+	assert.Equal(t, 1, d.mu.versions.currentVersion().Levels[0].Len())
+	d.mu.Unlock()
+
+	// the next flush is ordered after the first one
+	seq2 := commitTestBatch(t, d, 0, 1)
+	require.NoError(t, d.Flush())
+	assert.Equal(t, []string{
+		fmt.Sprintf("key_0#%d,%d=value_0", seq2, nogodb_common.KeyKindSet),
+	}, tableEntries(t, d, 0, 1))
+}
+
+func Test_Flush_Keeps_The_DELs_Shadowing_Older_Tables(t *testing.T) {
+	d := openTestDB(t, nil)
+
+	seq0 := commitTestBatch(t, d, 0, 2)
+	require.NoError(t, d.Flush())
+
+	b := newBatch(d, false)
+	require.NoError(t, b.Delete([]byte("key_0")))
+	require.NoError(t, d.apply(b))
+	seq1 := b.SeqNum()
+	require.NoError(t, b.Close())
+	require.NoError(t, d.Flush())
+
+	assert.Equal(t, []string{
+		fmt.Sprintf("key_0#%d,%d=value_0", seq0, nogodb_common.KeyKindSet),
+		fmt.Sprintf("key_1#%d,%d=value_1", seq0+1, nogodb_common.KeyKindSet),
+	}, tableEntries(t, d, 0, 0))
+	// the DEL shadows key_0 of the older table
+	assert.Equal(t, []string{
+		fmt.Sprintf("key_0#%d,%d=", seq1, nogodb_common.KeyKindDelete),
+	}, tableEntries(t, d, 0, 1))
+}
+
+func Test_Flush_Keeps_The_Versions_Of_The_Open_Snapshots(t *testing.T) {
+	d := openTestDB(t, nil)
+
+	seq0 := commitTestBatch(t, d, 0, 1)
+	snap := d.NewSnapshot()
+	assert.Equal(t, seq0+1, snap.SeqNum())
+
+	b := newBatch(d, false)
+	require.NoError(t, b.Set([]byte("key_0"), []byte("new_value")))
+	require.NoError(t, b.Delete([]byte("key_1")))
+	require.NoError(t, d.apply(b))
+	seq1 := b.SeqNum()
+	require.NoError(t, b.Close())
+
+	// a snapshot taken after the writes reads the same versions as the DB
+	latest := d.NewSnapshot()
+	defer func() { require.NoError(t, latest.Close()) }()
+
+	require.NoError(t, d.Flush())
+	// the snapshot reads the older version of key_0
+	assert.Equal(t, []string{
+		fmt.Sprintf("key_0#%d,%d=new_value", seq1, nogodb_common.KeyKindSet),
+		fmt.Sprintf("key_0#%d,%d=value_0", seq0, nogodb_common.KeyKindSet),
+	}, tableEntries(t, d, 0, 0))
+
+	// once the snapshot is closed, the older version is compacted away
+	require.NoError(t, snap.Close())
+	require.NoError(t, snap.Close())
+	commitTestBatch(t, d, 0, 1)
+	b = newBatch(d, false)
+	require.NoError(t, b.Set([]byte("key_0"), []byte("newer_value")))
+	require.NoError(t, d.apply(b))
+	seq3 := b.SeqNum()
+	require.NoError(t, b.Close())
+	require.NoError(t, d.Flush())
+
+	assert.Equal(t, []string{
+		fmt.Sprintf("key_0#%d,%d=newer_value", seq3, nogodb_common.KeyKindSet),
+	}, tableEntries(t, d, 0, 1))
+}
+
+func Test_Flush_Pins_The_Meta_Blocks_Of_The_L0_Tables(t *testing.T) {
+	for _, pin := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pin=%v", pin), func(t *testing.T) {
+			d := openTestDB(t, func(opt *options.DBOption) {
+				opt.Cache.PinL0MetaBlocks = pin
+			})
+
+			commitTestBatch(t, d, 0, 3)
+			require.NoError(t, d.Flush())
+
+			d.mu.Lock()
+			var l0 []nogodb_common.DiskfileNum
+			for tm := range d.mu.versions.currentVersion().Levels[0].All() {
+				l0 = append(l0, tm.TableNum)
+			}
+			var pinned []nogodb_common.DiskfileNum
+			for num := range d.mu.l0Pins {
+				pinned = append(pinned, num)
+			}
+			d.mu.Unlock()
+
+			// the blocks read are cached, shrinking the cache evicts all of
+			// them except the pinned ones
+			assert.Len(t, tableEntries(t, d, 0, 0), 3)
+			assert.Positive(t, d.cache.GetInUsed())
+			d.cache.SetCapacity(1)
+			if !pin {
+				assert.Empty(t, pinned)
+				assert.Zero(t, d.cache.GetInUsed())
+				return
+			}
+			assert.Equal(t, l0, pinned)
+			assert.Positive(t, d.cache.GetInUsed())
+
+			// the pins are released once the tables leave L0, or the DB is
+			// closed
+			d.mu.Lock()
+			require.NoError(t, d.unpinL0MetaBlocks())
+			d.mu.Unlock()
+			d.cache.SetCapacity(1)
+			assert.Zero(t, d.cache.GetInUsed())
+		})
+	}
+}
@@ -2,15 +2,19 @@ package db
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/datnguyenzzz/nogodb/db/options"
 	"github.com/datnguyenzzz/nogodb/lib/common"
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	nogodb_block_cache "github.com/datnguyenzzz/nogodb/lib/go-block-cache"
+	nogodb_pool "github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool/predictable_size"
 	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	nogodb_sst "github.com/datnguyenzzz/nogodb/lib/go-sstable"
 	nogodb_wal "github.com/datnguyenzzz/nogodb/lib/go-wal"
 )
 
@@ -99,6 +103,9 @@ type DB struct {
 	closedCh    chan struct{}
 
 	commit *commit
+	// largeBatchThreshold is the size (in bytes) from which a committed batch
+	// is not copied into the memtable, see flushableBatch
+	largeBatchThreshold uint64
 
 	sstStorager nogodb_fs.Storage
 
@@ -107,20 +114,55 @@ type DB struct {
 		versions *VersionSet
 		log      struct { // Write ahead log
 			writerManager nogodb_wal.IWalWriter
-			writer        io.WriteCloser
+			writer        nogodb_wal.ILogWriter
+			// files are the WALs which haven't been obsoleted yet, in
+			// ascending order. See DB.obsoleteWALs
+			files []walFile
 		}
 		mem struct { // Mem table
 			mutable *memTable
+			// nextSize is the size of the next memtable. It starts at
+			// min(256KB, MemTable.Size) and doubles up to MemTable.Size
+			nextSize uint64
 			// Queue of flushables (the mutable memtable is at end)
-			flushQueue []*memTable
+			flushQueue []*flushableEntry
 		}
 		compact struct { // Compactions
+			// cond is broadcast once a flush is done, either successfully
+			// or not, see DB.Flush
+			cond sync.Cond
 			// True when a flush is in progress.
 			flushing bool
+			// Number of consecutive retries of a failed flush.
+			flushRetries int
 		}
+		// snapshots are the open snapshots, their versions are kept by the
+		// compactions, see DB.NewSnapshot
+		snapshots map[*Snapshot]struct{}
+		// l0Pins holds an sstable iterator per L0 table, pinning its meta
+		// blocks in the cache, see DB.pinL0MetaBlocks
+		l0Pins map[nogodb_common.DiskfileNum]nogodb_sst.IIterator
 	}
 
+	// subscriptions are the registered CDC consumers, see DB.Subscribe.
+	// The mutex is a leaf, it can be acquired while holding either commit.mu
+	// or mu.
+	subscriptions struct {
+		sync.Mutex
+		set map[*Subscription]struct{}
+	}
+
+	// follower is set when the DB is a replication follower, it then only
+	// accepts the batches replicated from its primary, see DB.ApplyReplicated
+	follower atomic.Bool
+
+	// bgErr is the latest error raised by a background job, nil if
+	// there is none. See error_handler.go
+	bgErr atomic.Pointer[backgroundError]
+
 	cache nogodb_block_cache.IBlockCache
+	// bpool is the buffer pool shared by the sstable iterators
+	bpool *nogodb_pool.PredictablePool
 
 	// TODO. Write batch with index
 	// https://github.com/facebook/rocksdb/wiki/Write-Batch-With-Index
@@ -138,11 +180,20 @@ var (
 	_ IWriter = (*DB)(nil)
 )
 
-func Open(opt options.DBOption) (*DB, error) {
-	var err error
+// ErrClosed is returned when the DB is used once closed
+var ErrClosed = errors.New("nogodb: closed")
+
+func Open(opt options.DBOption) (_ *DB, err error) {
 	opt.SetDefault()
+
 	db := &DB{
+		opts:     &opt,
+		cmp:      opt.Comparer,
 		closedCh: make(chan struct{}),
+		// Batches above this threshold would take a too large part of the
+		// memtable, they're queued as flushables on their own instead
+		largeBatchThreshold: opt.MemTable.Size / 2,
+		bpool:               nogodb_pool.NewPredictablePool(),
 	}
 
 	// TODO(high): reads the named database directory and recovers
@@ -150,26 +201,45 @@ func Open(opt options.DBOption) (*DB, error) {
 	// the previous process exited.
 	// For now assume we open a new DB from a fresh state
 
+	db.mu.compact.cond.L = &db.mu.Mutex
+
 	db.dirLocks, err = prepareDirs(opt)
 	if err != nil {
 		return nil, err
 	}
 
-	db.cache = nogodb_block_cache.NewMap(
+	cacheOpts := []nogodb_block_cache.CacheOpt{
 		nogodb_block_cache.WithCacheType(opt.Cache.Type),
-		nogodb_block_cache.WithMaxSize(int64(opt.Cache.Type)),
-	)
-	defer db.cache.Close()
+		nogodb_block_cache.WithMaxSize(opt.Cache.Size),
+	}
+	if opt.Cache.HighPriorityPoolRatio > 0 {
+		cacheOpts = append(cacheOpts, nogodb_block_cache.WithHighPriorityPoolRatio(opt.Cache.HighPriorityPoolRatio))
+	}
+	if len(opt.Cache.PersistentDir) > 0 {
+		persistent, err := nogodb_block_cache.OpenPersistentCache(
+			opt.FS,
+			opt.Cache.PersistentDir,
+			nogodb_block_cache.WithPersistentCacheCapacity(opt.Cache.PersistentSize),
+		)
+		if err != nil {
+			return nil, err
+		}
+		db.cache = nogodb_block_cache.NewTieredCache(persistent, cacheOpts...)
+	} else {
+		db.cache = nogodb_block_cache.NewMap(cacheOpts...)
+	}
+	// the cache lives as long as the DB, see DB.Close. Closing it also closes
+	// the persistent cache, if any
+	defer func() {
+		if err != nil {
+			db.cache.Close()
+		}
+	}()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	db.bgCtx = ctx
 	db.bgCtxCancel = cancel
 
-	db.commit = &commit{
-		nextSeqNum:    nogodb_common.SeqNum(db.mu.versions.GetLogSeqNum()),
-		visibleSeqNum: nogodb_common.SeqNum(db.mu.versions.GetVisibleSeqNum()),
-	}
-
 	db.sstStorager, err = nogodb_fs.OpenVfsProvider(
 		nogodb_fs.WithFS(opt.FS),
 		nogodb_fs.WithDirName(opt.SST.Dir),
@@ -181,9 +251,8 @@ func Open(opt options.DBOption) (*DB, error) {
 
 	defer func() {
 		if r := recover(); db == nil {
-			for _, mem := range db.mu.mem.flushQueue {
-				mem.art = nil
-			}
+			db.mu.mem.mutable = nil
+			db.mu.mem.flushQueue = nil
 
 			if r != nil {
 				panic(r)
@@ -198,16 +267,23 @@ func Open(opt options.DBOption) (*DB, error) {
 		return nil, err
 	}
 
+	db.commit = &commit{
+		nextSeqNum:    nogodb_common.SeqNum(db.mu.versions.GetLogSeqNum()),
+		visibleSeqNum: nogodb_common.SeqNum(db.mu.versions.GetVisibleSeqNum()),
+	}
+
 	walWriterManager, err := nogodb_wal.NewWalManager(
 		opt.WAL.Dir,
 		nogodb_wal.WithFS(opt.FS),
 		nogodb_wal.WithBytesPerSync(uint32(opt.WAL.BytesPerSync)),
+		nogodb_wal.WithMaxRecycledFiles(max(opt.WAL.MaxRecycledFiles, 0)),
+		nogodb_wal.WithSecondaryDir(opt.WAL.SecondaryDir),
+		nogodb_wal.WithFailoverThreshold(opt.WAL.FailoverThreshold),
 		nogodb_wal.WithLogger(opt.Logger),
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer walWriterManager.Close()
 	db.mu.log.writerManager = walWriterManager
 
 	db.mu.versions.SetVisibleSeqNum(db.mu.versions.GetLogSeqNum())
@@ -217,17 +293,106 @@ func Open(opt options.DBOption) (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	db.mu.log.files = append(db.mu.log.files, walFile{
+		fileNum: newLogFileNum,
+		seqNum:  nogodb_common.SeqNum(db.mu.versions.GetLogSeqNum()),
+	})
+	db.subscriptions.set = make(map[*Subscription]struct{})
+	db.mu.snapshots = make(map[*Snapshot]struct{})
+	db.mu.l0Pins = make(map[nogodb_common.DiskfileNum]nogodb_sst.IIterator)
+
+	if opt.WAL.SyncMode.Kind == options.SyncModeKindInterval {
+		go db.syncWALPeriodically(opt.WAL.SyncMode.Interval)
+	}
 
-	db.mu.mem.mutable = newMemTable(
-		*db.opts,
+	db.mu.mem.nextSize = min(memTableInitialSize, opt.MemTable.Size)
+	db.newMutableMemTable(
 		nogodb_common.SeqNum(db.mu.versions.GetLogSeqNum()),
 		newLogFileNum,
+		0,
 	)
-	db.mu.mem.flushQueue = append(db.mu.mem.flushQueue, db.mu.mem.mutable)
 
 	return db, nil
 }
 
+// rotateMemTable makes the current mutable memtable immutable and switches
+// the writes to a new memtable backed by a new WAL file. If a large batch is
+// given, it is queued right after the rotated memtable, sharing its WAL.
+// Otherwise the new memtable is made large enough to hold the batch, if any.
+// d.mu must be held when calling this.
+func (d *DB) rotateMemTable(b *Batch) error {
+	prev := d.mu.mem.flushQueue[len(d.mu.mem.flushQueue)-1]
+	// nextSeqNum moves past the batch once it's written to the WAL: a large
+	// batch is in the rotated WAL already, the others are written to the new one
+	nextSeqNum := d.commit.nextSeqNum
+
+	newLogFileNum := d.mu.versions.GetNextFileNum()
+	newWriter, err := d.mu.log.writerManager.Create(newLogFileNum)
+	if err != nil {
+		return err
+	}
+
+	if err := d.mu.log.writer.Close(); err != nil {
+		_ = newWriter.Close()
+		return err
+	}
+	// closing the writer has synced the batches below nextSeqNum
+	d.commit.ratchetDurableSeqNum(nextSeqNum)
+	d.mu.log.writer = newWriter
+	d.mu.log.files = append(d.mu.log.files, walFile{fileNum: newLogFileNum, seqNum: nextSeqNum})
+
+	var minSize uint64
+	if b != nil && b.flushable != nil {
+		entry := newFlushableEntry(b.flushable, prev.logFileNum, b.SeqNum())
+		d.mu.mem.flushQueue = append(d.mu.mem.flushQueue, entry)
+	} else if b != nil {
+		minSize = b.memTableSize
+	}
+
+	// drop the ref held by the memtable while it was mutable
+	d.mu.mem.mutable.writerUnref()
+	d.newMutableMemTable(nextSeqNum, newLogFileNum, minSize)
+
+	d.maybeScheduleFlush()
+	return nil
+}
+
+// newMutableMemTable creates a new mutable memtable, and puts it at the
+// end of the flush queue. The memtable size ramps up to MemTable.Size, but
+// is at least minSize.
+// d.mu must be held when calling this.
+func (d *DB) newMutableMemTable(
+	seqNum nogodb_common.SeqNum,
+	logFileNum nogodb_common.DiskfileNum,
+	minSize uint64,
+) {
+	size := d.mu.mem.nextSize
+	d.mu.mem.nextSize = min(2*size, d.opts.MemTable.Size)
+	size = max(size, minSize)
+
+	var entry *flushableEntry
+	d.mu.mem.mutable, entry = newMemTable(*d.opts, size, seqNum, logFileNum)
+	d.mu.mem.flushQueue = append(d.mu.mem.flushQueue, entry)
+}
+
+// makeRoomForWrite prepares the mutable memtable for the batch, rotating
+// the memtable as long as it is full.
+func (d *DB) makeRoomForWrite(b *Batch) error {
+	for {
+		err := d.mu.mem.mutable.prepare(b)
+		if !errors.Is(err, errMemTableFull) {
+			return err
+		}
+
+		d.mu.Lock()
+		err = d.rotateMemTable(b)
+		d.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
 // maybeScheduleFlush schedules a flush if necessary.
 // d.mu must be held when calling this.
 func (d *DB) maybeScheduleFlush() {
@@ -235,6 +400,11 @@ func (d *DB) maybeScheduleFlush() {
 		return
 	}
 
+	if d.bgErr.Load().stopsWrites() {
+		// background work is halted until DB.Resume()
+		return
+	}
+
 	if len(d.mu.mem.flushQueue) <= 1 {
 		// the flushQueue is made by 1 mutable memTable
 		// and ≥0 immutable memTables that are ready
@@ -267,7 +437,10 @@ func (d *DB) readyForFlush() bool {
 		if !d.mu.mem.flushQueue[i].readyForFlush() {
 			break
 		}
-		size += d.mu.mem.mutable.totalBytes()
+		if d.mu.mem.flushQueue[i].flushForced {
+			return true
+		}
+		size += d.mu.mem.flushQueue[i].totalBytes()
 	}
 
 	// Only flush once the sum of the queued memtable sizes exceeds half the
@@ -319,6 +492,14 @@ func prepareDirs(opt options.DBOption) (l dirLock, err error) {
 			if err != nil {
 				return nil, err
 			}
+		case nogodb_common.TypeCache:
+			if len(opt.Cache.PersistentDir) == 0 {
+				continue
+			}
+			lock, err = mkdirThenLock(opt.Cache.PersistentDir)
+			if err != nil {
+				return nil, err
+			}
 		default:
 			continue
 		}
@@ -1 +1,229 @@
 package db
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/datnguyenzzz/nogodb/db/manifest"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// Iterator iterates forward over the live keys of the DB, in ascending order,
+// as they are at a seqnum: the latest version of each key committed below it,
+// the deleted keys being skipped. It merges the memtables, each of the L0
+// sstables, which overlap, and each of the other levels.
+//
+// An Iterator must not be used concurrently, and must be closed once it's no
+// longer used.
+type Iterator struct {
+	cmp    nogodb_common.IComparer
+	seqNum nogodb_common.SeqNum
+
+	iters []nogodb_common.InternalIterator[nogodb_common.InternalKV]
+	// heads holds the current entry of each iterator, nil once exhausted
+	heads []*nogodb_common.InternalKV
+
+	// key and value are the current entry, they're owned by the Iterator
+	// since the merged iterators might reuse their buffers. key is the last
+	// visible user key, even if it's deleted, if hasKey
+	key, value []byte
+	hasKey     bool
+	valid      bool
+
+	// prefix is set by SeekPrefixGE, the iterator is then exhausted once the
+	// keys don't have the prefix
+	prefix []byte
+
+	// err is the error which has exhausted the iterator early, see Error
+	err error
+}
+
+// NewIter returns an iterator over the DB as it is when called, i.e. the
+// batches committed afterward aren't read
+func (d *DB) NewIter() *Iterator {
+	d.commit.mu.Lock()
+	seqNum := d.commit.visibleSeqNum
+	d.commit.mu.Unlock()
+
+	return d.newIter(seqNum)
+}
+
+// NewIter returns an iterator over the DB as it is when the snapshot is
+// taken
+func (s *Snapshot) NewIter() *Iterator {
+	return s.db.newIter(s.seqNum)
+}
+
+func (d *DB) newIter(seqNum nogodb_common.SeqNum) *Iterator {
+	// the flushed memtables and their sstables are swapped under the mutex,
+	// so the iterator reads either of them
+	d.mu.Lock()
+	flushQueue := d.mu.mem.flushQueue
+	version := d.mu.versions.currentVersion()
+	d.mu.Unlock()
+
+	it := &Iterator{
+		cmp:    d.cmp,
+		seqNum: seqNum,
+	}
+	for _, entry := range flushQueue {
+		it.iters = append(it.iters, entry.newFlushIter())
+	}
+	for i := range version.Levels[0].Len() {
+		it.iters = append(it.iters, d.newLevelIter(version.Levels[0].Iter(i, i+1)))
+	}
+	for level := 1; level < manifest.NumLevels; level++ {
+		if version.Levels[level].Len() > 0 {
+			it.iters = append(it.iters, d.newLevelIter(version.Levels[level].Iter(-1, -1)))
+		}
+	}
+	it.heads = make([]*nogodb_common.InternalKV, len(it.iters))
+
+	return it
+}
+
+// First moves the iterator to the first live key, it returns false if there
+// is none
+func (it *Iterator) First() bool {
+	return it.seek(nil, func(iter nogodb_common.InternalIterator[nogodb_common.InternalKV]) *nogodb_common.InternalKV {
+		return iter.First()
+	})
+}
+
+// SeekGE moves the iterator to the first live key ≥ key, it returns false if
+// there is none
+func (it *Iterator) SeekGE(key []byte) bool {
+	return it.seek(nil, func(iter nogodb_common.InternalIterator[nogodb_common.InternalKV]) *nogodb_common.InternalKV {
+		return iter.SeekGTE(key)
+	})
+}
+
+// SeekPrefixGE moves the iterator to the first live key ≥ key with the given
+// prefix, key must have the prefix. The iterator is then exhausted once the
+// keys don't have the prefix, e.g. to scan all the rows of a table.
+//
+// The sstables whose filter rules the prefix out are skipped without being
+// read. The filters hold the whole keys (without their MVCC suffix, see
+// nogodb_common.IComparer.Split) and the prefixes extracted by
+// options.DBOption.SST.PrefixExtractor, hence the prefix is filtered if it's
+// a whole key or in the domain of the extractor.
+func (it *Iterator) SeekPrefixGE(prefix, key []byte) bool {
+	prefix = append(it.prefix[:0], prefix...)
+	return it.seek(prefix, func(iter nogodb_common.InternalIterator[nogodb_common.InternalKV]) *nogodb_common.InternalKV {
+		return iter.SeekPrefixGTE(prefix, key)
+	})
+}
+
+// Next moves the iterator to the next live key, it returns false if there is
+// none
+func (it *Iterator) Next() bool {
+	if !it.valid {
+		return false
+	}
+	return it.findNext()
+}
+
+// Valid returns true if the iterator is positioned on a live key
+func (it *Iterator) Valid() bool {
+	return it.valid
+}
+
+// Key returns the key of the current entry, it's only valid until the next
+// move of the iterator
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value of the current entry, it's only valid until the
+// next move of the iterator
+func (it *Iterator) Value() []byte {
+	return it.value
+}
+
+// Error returns the error which has exhausted the iterator early, e.g. an
+// sstable which failed to be read. It's reset by the seeks.
+func (it *Iterator) Error() error {
+	return it.err
+}
+
+// Close closes the merged iterators
+func (it *Iterator) Close() error {
+	var err error
+	for _, iter := range it.iters {
+		err = errors.Join(err, iter.Close())
+	}
+	it.iters, it.heads, it.valid = nil, nil, false
+	return err
+}
+
+func (it *Iterator) seek(
+	prefix []byte,
+	seek func(nogodb_common.InternalIterator[nogodb_common.InternalKV]) *nogodb_common.InternalKV,
+) bool {
+	it.prefix, it.hasKey, it.err = prefix, false, nil
+	for i, iter := range it.iters {
+		it.heads[i] = seek(iter)
+		if it.heads[i] == nil && !it.checkError(iter) {
+			it.valid = false
+			return false
+		}
+	}
+	return it.findNext()
+}
+
+// findNext moves the iterator to the latest version of the next user key
+// committed below the seqnum of the iterator, skipping the deleted keys
+func (it *Iterator) findNext() bool {
+	it.valid = false
+	for {
+		// there are a few memtables and L0 sstables, a heap isn't worth it
+		// TODO(low): Merge them with a heap, once the L0 sstables are
+		// compacted in the background
+		minIdx := -1
+		for i, kv := range it.heads {
+			if kv != nil && (minIdx < 0 || kv.K.Compare(it.cmp, &it.heads[minIdx].K) < 0) {
+				minIdx = i
+			}
+		}
+		if minIdx < 0 {
+			return false
+		}
+
+		kv := it.heads[minIdx]
+		if it.prefix != nil && !bytes.HasPrefix(kv.K.UserKey, it.prefix) {
+			// the keys are beyond the prefix
+			return false
+		}
+
+		visible := kv.K.SeqNum() < it.seqNum && (!it.hasKey || it.cmp.Compare(kv.K.UserKey, it.key) != 0)
+		if visible {
+			// a version committed after the iterator, or shadowed by a
+			// newer one, is skipped
+			it.key = append(it.key[:0], kv.K.UserKey...)
+			it.hasKey = true
+			it.valid = kv.K.KeyKind() == nogodb_common.KeyKindSet
+			if it.valid {
+				it.value = append(it.value[:0], kv.V.Value()...)
+			}
+		}
+
+		it.heads[minIdx] = it.iters[minIdx].Next()
+		if it.heads[minIdx] == nil && !it.checkError(it.iters[minIdx]) {
+			it.valid = false
+			return false
+		}
+		if it.valid {
+			return true
+		}
+	}
+}
+
+// checkError records the error which has exhausted iter early, if any. It
+// returns false if there is one.
+func (it *Iterator) checkError(iter nogodb_common.InternalIterator[nogodb_common.InternalKV]) bool {
+	if l, ok := iter.(*levelIter); ok && l.Error() != nil {
+		it.err = l.Error()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	sst_options "github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// iterEntries returns the entries read by iter from its current position, as
+// "key=value"
+func iterEntries(t *testing.T, iter *Iterator, valid bool) []string {
+	t.Helper()
+	var entries []string
+	for ; valid; valid = iter.Next() {
+		entries = append(entries, fmt.Sprintf("%s=%s", iter.Key(), iter.Value()))
+	}
+	require.NoError(t, iter.Error())
+	return entries
+}
+
+// writeTestBatch commits a batch setting the given keys to their values, or
+// deleting them if their value is nil
+func writeTestBatch(t *testing.T, d *DB, kvs ...[]byte) {
+	t.Helper()
+	b := newBatch(d, false)
+	for i := 0; i < len(kvs); i += 2 {
+		if kvs[i+1] == nil {
+			require.NoError(t, b.Delete(kvs[i]))
+			continue
+		}
+		require.NoError(t, b.Set(kvs[i], kvs[i+1]))
+	}
+	require.NoError(t, d.apply(b))
+	require.NoError(t, b.Close())
+}
+
+func Test_Iterator_Merges_The_MemTables_And_The_SSTables(t *testing.T) {
+	d := openTestDB(t, nil)
+
+	writeTestBatch(t, d, []byte("a"), []byte("a_0"), []byte("b"), []byte("b_0"), []byte("c"), []byte("c_0"))
+	require.NoError(t, d.Flush())
+	snap := d.NewSnapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+
+	writeTestBatch(t, d, []byte("b"), []byte("b_1"), []byte("c"), nil, []byte("d"), []byte("d_1"))
+	require.NoError(t, d.Flush())
+	// the latest writes are in the mutable memtable
+	writeTestBatch(t, d, []byte("a"), nil, []byte("e"), []byte("e_2"))
+
+	iter := d.NewIter()
+	defer func() { require.NoError(t, iter.Close()) }()
+	// the writes committed after the iterator is created aren't read
+	writeTestBatch(t, d, []byte("f"), []byte("f_3"))
+
+	assert.Equal(t, []string{"b=b_1", "d=d_1", "e=e_2"}, iterEntries(t, iter, iter.First()))
+	assert.Equal(t, []string{"d=d_1", "e=e_2"}, iterEntries(t, iter, iter.SeekGE([]byte("c"))))
+	assert.False(t, iter.SeekGE([]byte("f")))
+
+	// the snapshot reads the versions of the first flush
+	snapIter := snap.NewIter()
+	defer func() { require.NoError(t, snapIter.Close()) }()
+	assert.Equal(t, []string{"a=a_0", "b=b_0", "c=c_0"}, iterEntries(t, snapIter, snapIter.First()))
+}
+
+func Test_Iterator_SeekPrefixGE(t *testing.T) {
+	d := openTestDB(t, func(opt *options.DBOption) {
+		opt.SST.PrefixExtractor = sst_options.NewDelimitedPrefixExtractor('/', 1)
+	})
+
+	writeTestBatch(t, d, []byte("T1/a"), []byte("1a"), []byte("T3/a"), []byte("3a"))
+	require.NoError(t, d.Flush())
+	writeTestBatch(t, d, []byte("T2/a"), []byte("2a"), []byte("T2/b"), []byte("2b"), []byte("T3/b"), []byte("3b"))
+	require.NoError(t, d.Flush())
+	writeTestBatch(t, d, []byte("T2/c"), []byte("2c"), []byte("T3/a"), nil)
+
+	iter := d.NewIter()
+	defer func() { require.NoError(t, iter.Close()) }()
+
+	// the keys are read across the sstables and the memtable, until the
+	// prefix is exhausted
+	assert.Equal(t, []string{"T2/a=2a", "T2/b=2b", "T2/c=2c"}, iterEntries(t, iter, iter.SeekPrefixGE([]byte("T2/"), []byte("T2/"))))
+	assert.Equal(t, []string{"T2/b=2b", "T2/c=2c"}, iterEntries(t, iter, iter.SeekPrefixGE([]byte("T2/"), []byte("T2/b"))))
+	assert.Equal(t, []string{"T3/b=3b"}, iterEntries(t, iter, iter.SeekPrefixGE([]byte("T3/"), []byte("T3/"))))
+	assert.False(t, iter.SeekPrefixGE([]byte("T0/"), []byte("T0/")))
+
+	// the prefix mode is left by the other seeks
+	assert.Equal(t, []string{"T2/c=2c", "T3/b=3b"}, iterEntries(t, iter, iter.SeekGE([]byte("T2/c"))))
+}
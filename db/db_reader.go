@@ -1,11 +1,50 @@
 package db
 
 import (
+	"errors"
 	"io"
 )
 
+// Close stops the background jobs, closes the WAL and releases the block
+// cache and the directory locks. The DB mustn't be used once closed.
 func (d *DB) Close() error {
-	panic("unimplemented")
+	// wait for the in-flight commit, the WAL holds all the batches below
+	// nextSeqNum once it is closed
+	d.commit.mu.Lock()
+	defer d.commit.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	select {
+	case <-d.closedCh:
+		return ErrClosed
+	default:
+	}
+	close(d.closedCh)
+	d.bgCtxCancel()
+	d.mu.compact.cond.Broadcast()
+
+	var err error
+	if d.mu.log.writer != nil {
+		if closeErr := d.mu.log.writer.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		} else {
+			d.commit.ratchetDurableSeqNum(d.commit.nextSeqNum)
+		}
+	}
+	if d.mu.log.writerManager != nil {
+		err = errors.Join(err, d.mu.log.writerManager.Close())
+	}
+
+	// the pinned blocks are released before the cache is closed
+	err = errors.Join(err, d.unpinL0MetaBlocks())
+	d.cache.Close()
+
+	for _, l := range d.dirLocks {
+		err = errors.Join(err, l.Close())
+	}
+
+	return err
 }
 
 func (d *DB) Get(key []byte) (value []byte, closer io.Closer, err error) {
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_block_cache "github.com/datnguyenzzz/nogodb/lib/go-block-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB opens a fresh DB in a temporary directory, which is closed at
+// the end of the test. configure, if not nil, tunes the options beforehand.
+func openTestDB(t *testing.T, configure func(opt *options.DBOption)) *DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	opt := options.DBOption{}
+	opt.SST.Dir = filepath.Join(dir, "sst")
+	opt.WAL.Dir = filepath.Join(dir, "wal")
+	opt.Manifest.Dir = filepath.Join(dir, "manifest")
+	if configure != nil {
+		configure(&opt)
+	}
+
+	d, err := Open(opt)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+
+	return d
+}
+
+// holdFlushes prevents the flushes from being scheduled, as if one was in
+// progress, so the immutable memtables stay in the flush queue.
+// d.mu must be held when calling this.
+func holdFlushes(d *DB) {
+	d.mu.compact.flushing = true
+}
+
+func Test_DB_Keeps_The_Persistent_Cache_Open(t *testing.T) {
+	persistentDir := filepath.Join(t.TempDir(), "cache")
+	d := openTestDB(t, func(opt *options.DBOption) {
+		opt.Cache.Size = 4 * nogodb_block_cache.KiB
+		opt.Cache.PersistentDir = persistentDir
+	})
+
+	// the memory only holds a few blocks, the others spill into the
+	// persistent cache
+	block := func(key uint64) []byte {
+		return bytes.Repeat([]byte{byte(key)}, int(nogodb_block_cache.KiB))
+	}
+	const numBlocks = 64
+	for key := range uint64(numBlocks) {
+		require.True(t, d.cache.Set(1, key, block(key)))
+	}
+	for key := range uint64(numBlocks) {
+		v, ok := d.cache.Get(1, key)
+		require.True(t, ok, "block %d", key)
+		assert.True(t, bytes.Equal(block(key), v.Load()), "block %d", key)
+	}
+
+	require.NoError(t, d.Close())
+	assert.ErrorIs(t, d.Close(), ErrClosed)
+}
+
+func Test_Open_Comparer(t *testing.T) {
+	tests := []struct {
+		name string
+		cmp  nogodb_common.IComparer
+	}{
+		{name: "default", cmp: nil},
+		{name: "byte-wise", cmp: nogodb_common.NewComparer()},
+		{name: "byte-wise value", cmp: nogodb_common.DefaultComparer{}},
+		{name: "MVCC", cmp: nogodb_common.NewMVCCComparer()},
+		{name: "byte-wise suffixes", cmp: suffixComparer{nogodb_common.NewComparer()}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opt := options.DBOption{Comparer: tc.cmp}
+			opt.SST.Dir = filepath.Join(dir, "sst")
+			opt.WAL.Dir = filepath.Join(dir, "wal")
+			opt.Manifest.Dir = filepath.Join(dir, "manifest")
+
+			d, err := Open(opt)
+			require.NoError(t, err)
+			require.NoError(t, d.Close())
+		})
+	}
+}
+
+func Test_MVCCComparer_Orders_The_MemTables(t *testing.T) {
+	for _, kind := range []options.MemTableKind{options.MemTableKindART, options.MemTableKindSkiplist} {
+		t.Run(fmt.Sprintf("kind %d", kind), func(t *testing.T) {
+			d := openTestDB(t, func(opt *options.DBOption) {
+				opt.Comparer = nogodb_common.NewMVCCComparer()
+				opt.MemTable.Kind = kind
+			})
+
+			key := nogodb_common.EncodeMVCCKey
+			writeTestBatch(t, d,
+				key([]byte("b"), 1), []byte("b@1"),
+				key([]byte("a"), 2), []byte("a@2"),
+				key([]byte("a"), 0), []byte("a"),
+			)
+			require.NoError(t, d.Flush())
+			writeTestBatch(t, d,
+				key([]byte("a"), 10), []byte("a@10"),
+				key([]byte("a\x00"), 1), []byte("a\x00@1"),
+			)
+
+			// the latest versions come first, after the bare key
+			want := []string{"a", "a@10", "a@2", "a\x00@1", "b@1"}
+			read := func() []string {
+				iter := d.NewIter()
+				defer func() { require.NoError(t, iter.Close()) }()
+				var values []string
+				for valid := iter.First(); valid; valid = iter.Next() {
+					values = append(values, string(iter.Value()))
+				}
+				require.NoError(t, iter.Error())
+				return values
+			}
+			assert.Equal(t, want, read())
+
+			// the memtables and the sstables are ordered alike
+			require.NoError(t, d.Flush())
+			assert.Equal(t, want, read())
+		})
+	}
+}
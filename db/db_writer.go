@@ -1,20 +1,41 @@
 package db
 
+import nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+
+// WriteResult acknowledges a write, see DB.SetWithResult
+type WriteResult struct {
+	// SeqNum is the sequence number of the first operation of the write
+	SeqNum nogodb_common.SeqNum
+	// DurableSeqNum is the durable sequence number of the DB when the write
+	// was acknowledged. The write is durable if its sequence numbers are
+	// below it, which is always the case with options.SyncEveryCommit.
+	// Otherwise it becomes durable once DB.DurableSeqNum passes it.
+	DurableSeqNum nogodb_common.SeqNum
+}
+
 func (d *DB) Delete(key []byte) error {
 	panic("implement Delete, and read about Range Tombstone deletion article")
 }
 
 func (d *DB) Set(key, value []byte) error {
+	_, err := d.SetWithResult(key, value)
+	return err
+}
+
+// SetWithResult is Set, and returns the acknowledgement of the write, e.g.
+// to wait until it is durable
+func (d *DB) SetWithResult(key, value []byte) (WriteResult, error) {
 	b := newBatch(d, true)
 	if err := b.Set(key, value); err != nil {
-		return err
+		return WriteResult{}, err
 	}
 
 	if err := d.apply(b); err != nil {
-		return err
+		return WriteResult{}, err
 	}
 
-	return b.Close()
+	res := WriteResult{SeqNum: b.SeqNum(), DurableSeqNum: b.durableSeqNum}
+	return res, b.Close()
 }
 
 func (d *DB) apply(b *Batch) error {
@@ -26,6 +47,14 @@ func (d *DB) apply(b *Batch) error {
 		panic("batch is applied")
 	}
 
+	if d.follower.Load() {
+		return errFollowerWrite
+	}
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+
 	b.committing.Store(true)
 	return d.commit.Commit(b)
 }
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_record "github.com/datnguyenzzz/nogodb/lib/common/record"
+	sst_common "github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+)
+
+var (
+	// ErrReadOnly is returned by the write paths once a background job has
+	// failed in a way that stops the DB from accepting new writes.
+	ErrReadOnly = errors.New("nogodb: database is in read-only mode")
+
+	// errManifestWrite marks a failure while persisting a version edit to the
+	// MANIFEST. The in-memory version and the on-disk MANIFEST might have
+	// diverged, so it is never safe to retry it.
+	errManifestWrite = errors.New("nogodb: manifest write failed")
+)
+
+// errorSeverity classifies an error raised by a background job (flush, compaction)
+type errorSeverity uint8
+
+const (
+	severityNone errorSeverity = iota
+	// severityRetryable errors are transient, the background job will be
+	// retried with backoff and the writes are still accepted meanwhile.
+	severityRetryable
+	// severityHard errors are retryable errors that have exhausted their
+	// retries. The writes are stopped until DB.Resume() is called.
+	severityHard
+	// severityFatal errors are sticky, the DB stays read-only until it is
+	// re-opened.
+	severityFatal
+)
+
+var severityToString = map[errorSeverity]string{
+	severityNone:      "none",
+	severityRetryable: "retryable",
+	severityHard:      "hard",
+	severityFatal:     "fatal",
+}
+
+func (s errorSeverity) String() string {
+	return severityToString[s]
+}
+
+// classifyError decides how a background error must be handled.
+// Transient I/O errors and out of space are retryable, corruptions and
+// manifest write failures are fatal. Any unknown error is considered
+// fatal, since we can't prove that retrying it is safe.
+func classifyError(err error) errorSeverity {
+	switch {
+	case err == nil:
+		return severityNone
+	case errors.Is(err, errManifestWrite),
+		errors.Is(err, sst_common.MismatchedChecksumError),
+		errors.Is(err, nogodb_record.ErrInvalidChunk),
+		errors.Is(err, nogodb_record.ErrZeroedChunk),
+		errors.Is(err, nogodb_record.ErrUnexpectedEOF):
+		return severityFatal
+	case errors.Is(err, syscall.ENOSPC),
+		errors.Is(err, syscall.EDQUOT),
+		errors.Is(err, syscall.EIO),
+		errors.Is(err, syscall.EAGAIN),
+		errors.Is(err, syscall.EINTR),
+		errors.Is(err, syscall.EBUSY),
+		errors.Is(err, syscall.ETIMEDOUT):
+		return severityRetryable
+	default:
+		return severityFatal
+	}
+}
+
+// backgroundError is the latest error raised by a background job
+type backgroundError struct {
+	err      error
+	severity errorSeverity
+}
+
+// stopsWrites returns true if the DB must reject the incoming writes
+func (e *backgroundError) stopsWrites() bool {
+	return e != nil && e.severity >= severityHard
+}
+
+// checkWritable returns a non-nil error if the DB doesn't accept writes
+func (d *DB) checkWritable() error {
+	if bgErr := d.bgErr.Load(); bgErr.stopsWrites() {
+		return fmt.Errorf("%w: %w", ErrReadOnly, bgErr.err)
+	}
+
+	return nil
+}
+
+// setBackgroundError records err as the current background error. An error
+// never downgrades the severity of the recorded one, so once the DB is in a
+// fatal state it stays there.
+// d.mu must be held when calling this.
+func (d *DB) setBackgroundError(err error, severity errorSeverity) {
+	if curr := d.bgErr.Load(); curr != nil && curr.severity > severity {
+		return
+	}
+
+	d.opts.Logger.Errorf("nogodb: background error (severity: %s): %v", severity, err)
+	d.bgErr.Store(&backgroundError{err: err, severity: severity})
+}
+
+// clearRetryableError drops the recorded background error once the
+// failed job has been retried successfully.
+// d.mu must be held when calling this.
+func (d *DB) clearRetryableError() {
+	d.mu.compact.flushRetries = 0
+	if curr := d.bgErr.Load(); curr != nil && curr.severity == severityRetryable {
+		d.bgErr.Store(nil)
+	}
+}
+
+// onFlushError decides what to do after a failed flush, either retrying it
+// with backoff or stopping the writes.
+// d.mu must be held when calling this.
+func (d *DB) onFlushError(err error) {
+	severity := classifyError(err)
+	retryOpt := d.opts.BackgroundErrorRetry
+
+	if severity == severityRetryable && d.mu.compact.flushRetries < retryOpt.MaxRetries {
+		backoff := retryBackoff(d.opts, d.mu.compact.flushRetries)
+		d.mu.compact.flushRetries++
+		d.setBackgroundError(err, severityRetryable)
+
+		// keep the flushing flag on, so no other flush can be scheduled
+		// while we're waiting for the retry
+		d.mu.compact.flushing = true
+		go d.retryFlushAfter(backoff)
+		return
+	}
+
+	if severity == severityRetryable {
+		// out of retries, hand it over to the user via DB.Resume()
+		severity = severityHard
+	}
+
+	d.setBackgroundError(err, severity)
+}
+
+// retryBackoff returns the delay before the given retry (0-based) of a failed
+// background job. It doubles from InitialBackoff on each retry, up to
+// MaxBackoff.
+func retryBackoff(o *options.DBOption, retry int) time.Duration {
+	backoff := o.BackgroundErrorRetry.InitialBackoff
+	for range retry {
+		if backoff >= o.BackgroundErrorRetry.MaxBackoff {
+			break
+		}
+		backoff <<= 1
+	}
+
+	return min(backoff, o.BackgroundErrorRetry.MaxBackoff)
+}
+
+func (d *DB) retryFlushAfter(backoff time.Duration) {
+	t := time.NewTimer(backoff)
+	defer t.Stop()
+
+	select {
+	case <-d.bgCtx.Done():
+		d.mu.Lock()
+		d.mu.compact.flushing = false
+		d.mu.Unlock()
+		return
+	case <-t.C:
+	}
+
+	d.flush()
+}
+
+// Resume clears a recoverable background error and resumes the background
+// work. It returns an error if the DB is in a fatal state, which can only
+// be cleared by re-opening the DB.
+func (d *DB) Resume() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	bgErr := d.bgErr.Load()
+	if bgErr == nil {
+		return nil
+	}
+
+	if bgErr.severity == severityFatal {
+		return fmt.Errorf("%w: can not resume from a fatal error: %w", ErrReadOnly, bgErr.err)
+	}
+
+	d.opts.Logger.Infof("nogodb: resuming from background error: %v", bgErr.err)
+	d.bgErr.Store(nil)
+	d.mu.compact.flushRetries = 0
+	d.maybeScheduleFlush()
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/datnguyenzzz/nogodb/db/compact"
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_record "github.com/datnguyenzzz/nogodb/lib/common/record"
+	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	sst_common "github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newErrorHandlerTestDB creates a DB holding only what the error handler
+// needs. Its background context is cancelled, so the scheduled retries are
+// dropped instead of running a flush.
+func newErrorHandlerTestDB(maxRetries int) *DB {
+	opt := options.DBOption{}
+	opt.BackgroundErrorRetry.MaxRetries = maxRetries
+	opt.SetDefault()
+
+	d := &DB{opts: &opt}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.bgCtx, d.bgCtxCancel = ctx, cancel
+
+	return d
+}
+
+func Test_ClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorSeverity
+	}{
+		{name: "no error", err: nil, want: severityNone},
+		{name: "out of space", err: syscall.ENOSPC, want: severityRetryable},
+		{name: "wrapped out of space", err: fmt.Errorf("write sst: %w", syscall.ENOSPC), want: severityRetryable},
+		{name: "quota exceeded", err: syscall.EDQUOT, want: severityRetryable},
+		{name: "I/O error", err: syscall.EIO, want: severityRetryable},
+		{name: "timeout", err: syscall.ETIMEDOUT, want: severityRetryable},
+		{name: "manifest write", err: fmt.Errorf("%w: sync: %w", errManifestWrite, syscall.ENOSPC), want: severityFatal},
+		{name: "checksum mismatch", err: sst_common.MismatchedChecksumError, want: severityFatal},
+		{name: "invalid WAL chunk", err: nogodb_record.ErrInvalidChunk, want: severityFatal},
+		{name: "zeroed WAL chunk", err: nogodb_record.ErrZeroedChunk, want: severityFatal},
+		{name: "unknown", err: errors.New("unknown"), want: severityFatal},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, classifyError(tc.err))
+		})
+	}
+}
+
+func Test_RetryBackoff(t *testing.T) {
+	opt := options.DBOption{}
+	opt.BackgroundErrorRetry.InitialBackoff = 100 * time.Millisecond
+	opt.BackgroundErrorRetry.MaxBackoff = time.Second
+	opt.SetDefault()
+
+	var got []time.Duration
+	for retry := range 6 {
+		got = append(got, retryBackoff(&opt, retry))
+	}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	assert.Equal(t, want, got)
+
+	// the backoff never overflows
+	assert.Equal(t, time.Second, retryBackoff(&opt, 1000))
+}
+
+func Test_OnFlushError_Retries_Then_Stops_Writes(t *testing.T) {
+	const maxRetries = 3
+	d := newErrorHandlerTestDB(maxRetries)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := range maxRetries {
+		d.onFlushError(syscall.ENOSPC)
+		assert.Equal(t, i+1, d.mu.compact.flushRetries)
+		assert.Equal(t, severityRetryable, d.bgErr.Load().severity)
+		assert.NoError(t, d.checkWritable(), "the writes are accepted while retrying")
+	}
+
+	// the retries are exhausted
+	d.onFlushError(syscall.ENOSPC)
+	assert.Equal(t, severityHard, d.bgErr.Load().severity)
+	err := d.checkWritable()
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, err, syscall.ENOSPC)
+
+	// a successful flush doesn't clear a hard error
+	d.clearRetryableError()
+	assert.ErrorIs(t, d.checkWritable(), ErrReadOnly)
+}
+
+func Test_OnFlushError_Fatal(t *testing.T) {
+	d := newErrorHandlerTestDB(3)
+
+	d.mu.Lock()
+	d.onFlushError(sst_common.MismatchedChecksumError)
+	assert.Equal(t, severityFatal, d.bgErr.Load().severity)
+	assert.Zero(t, d.mu.compact.flushRetries, "a fatal error is never retried")
+
+	// a fatal error is never downgraded
+	d.onFlushError(syscall.ENOSPC)
+	assert.Equal(t, severityFatal, d.bgErr.Load().severity)
+	d.mu.Unlock()
+
+	assert.ErrorIs(t, d.checkWritable(), ErrReadOnly)
+	assert.ErrorIs(t, d.Resume(), ErrReadOnly)
+	assert.ErrorIs(t, d.checkWritable(), ErrReadOnly)
+}
+
+func Test_Resume(t *testing.T) {
+	d := newErrorHandlerTestDB(1)
+	require.NoError(t, d.Resume(), "nothing to resume from")
+
+	d.mu.Lock()
+	d.onFlushError(syscall.EIO)
+	d.onFlushError(syscall.EIO)
+	d.mu.Unlock()
+	require.ErrorIs(t, d.checkWritable(), ErrReadOnly)
+
+	require.NoError(t, d.Resume())
+	assert.NoError(t, d.checkWritable())
+	assert.Nil(t, d.bgErr.Load())
+
+	// the retries start over
+	d.mu.Lock()
+	assert.Zero(t, d.mu.compact.flushRetries)
+	d.onFlushError(syscall.EIO)
+	assert.Equal(t, severityRetryable, d.bgErr.Load().severity)
+	d.mu.Unlock()
+}
+
+func Test_MakeVersionEdit_Skips_Failed_Results(t *testing.T) {
+	c := &compaction{outLevel: &compactionLevel{level: 0}}
+
+	ok := &compact.Result{}
+	ok.Tables = append(ok.Tables, compact.Table{FileDesc: nogodb_fs.FileDesc{Num: 7}, LowSeqNum: 1, HighSeqNum: 5})
+	failed := &compact.Result{Err: syscall.ENOSPC}
+	failed.Tables = ok.Tables
+
+	ve := makeVersionEdit(c, []*compact.Result{ok, failed})
+	require.Len(t, ve.NewTables, 1)
+	assert.Equal(t, 0, ve.NewTables[0].Level)
+	assert.Equal(t, nogodb_common.DiskfileNum(7), ve.NewTables[0].Meta.TableNum)
+	assert.Equal(t, nogodb_common.SeqNum(5), ve.NewTables[0].Meta.HighSeqNum)
+}
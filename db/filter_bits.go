@@ -0,0 +1,128 @@
+package db
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/datnguyenzzz/nogodb/db/manifest"
+)
+
+const (
+	minFilterBitsPerKey = 1
+	// maxFilterBitsPerKey caps the bits per key of the small levels, past it
+	// the false positive rate is negligible
+	maxFilterBitsPerKey = 24
+)
+
+// filterBitsPerKey returns the filter bits per key of the tables written to
+// the given level, see options.DBOption.SST.MonkeyFilterBits
+// Note: Must call this function with db.mu.Lock held
+func (d *DB) filterBitsPerKey(level int) int {
+	if !d.opts.SST.MonkeyFilterBits {
+		return d.opts.SST.FilterBitsPerKey
+	}
+
+	var levelSizes [manifest.NumLevels]uint64
+	for l, levelMeta := range d.mu.versions.currentVersion().Levels {
+		levelSizes[l] = levelMeta.AggregateSize()
+	}
+	return monkeyFilterBitsPerKey(levelSizes, d.opts.SST.FilterBitsPerKey)[level]
+}
+
+// monkeyFilterBitsPerKey allocates the filter bits per key of each level as in
+// Monkey (https://dl.acm.org/doi/10.1145/3035918.3064054). It minimizes the sum
+// of the false positive rates of the levels, aka the wasted I/Os of a point
+// lookup, for a budget of avgBitsPerKey bits per key across the levels.
+//
+// With b bits per key, the false positive rate is p = e^(-b*ln(2)^2). The
+// optimum sets p proportional to the level size n, hence
+//
+//	b(level) = c - ln(n(level)) / ln(2)^2
+//
+// clamped within [minFilterBitsPerKey, maxFilterBitsPerKey], where the
+// constant c spends the whole budget, it's found by bisection. The budget a
+// clamped level can't use is thus spread over the other levels. It's only
+// left unspent if all the levels are capped at maxFilterBitsPerKey, and
+// exceeded if avgBitsPerKey is below minFilterBitsPerKey. The bits are
+// rounded down, then the rest of the budget rounds up the largest remainders
+// first, less than a bit per key is left unspent.
+//
+// The level sizes are in bytes, assuming the same average key size at each
+// level. The empty levels, if any table is written to them, get
+// maxFilterBitsPerKey.
+func monkeyFilterBitsPerKey(levelSizes [manifest.NumLevels]uint64, avgBitsPerKey int) (bitsPerKey [manifest.NumLevels]int) {
+	var total float64
+	lo, hi := math.Inf(1), math.Inf(-1)
+	ln2Squared := math.Ln2 * math.Ln2
+	for _, size := range levelSizes {
+		if size == 0 {
+			continue
+		}
+		total += float64(size)
+		lo = min(lo, minFilterBitsPerKey+math.Log(float64(size))/ln2Squared)
+		hi = max(hi, maxFilterBitsPerKey+math.Log(float64(size))/ln2Squared)
+	}
+
+	if total == 0 {
+		for l := range bitsPerKey {
+			bitsPerKey[l] = avgBitsPerKey
+		}
+		return bitsPerKey
+	}
+
+	bitsAt := func(c float64, size uint64) float64 {
+		return min(max(c-math.Log(float64(size))/ln2Squared, minFilterBitsPerKey), maxFilterBitsPerKey)
+	}
+	spent := func(c float64) float64 {
+		var bits float64
+		for _, size := range levelSizes {
+			if size > 0 {
+				bits += bitsAt(c, size) * float64(size)
+			}
+		}
+		return bits
+	}
+
+	// all the levels are at minFilterBitsPerKey with c = lo, and at
+	// maxFilterBitsPerKey with c = hi
+	budget := float64(avgBitsPerKey) * total
+	for range 64 {
+		mid := (lo + hi) / 2
+		if spent(mid) > budget {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	var (
+		remainders [manifest.NumLevels]float64
+		levels     []int
+	)
+	remaining := budget
+	for l, size := range levelSizes {
+		if size == 0 {
+			bitsPerKey[l] = maxFilterBitsPerKey
+			continue
+		}
+
+		bits := bitsAt(lo, size)
+		bitsPerKey[l] = int(bits)
+		remainders[l] = bits - float64(bitsPerKey[l])
+		remaining -= float64(bitsPerKey[l]) * float64(size)
+		levels = append(levels, l)
+	}
+
+	slices.SortStableFunc(levels, func(a, b int) int {
+		return cmp.Compare(remainders[b], remainders[a])
+	})
+	for _, l := range levels {
+		if size := float64(levelSizes[l]); bitsPerKey[l] < maxFilterBitsPerKey && size <= remaining {
+			bitsPerKey[l]++
+			remaining -= size
+		}
+	}
+
+	return bitsPerKey
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/db/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MonkeyFilterBitsPerKey(t *testing.T) {
+	const (
+		kb = uint64(1) << 10
+		mb = kb << 10
+		gb = mb << 10
+	)
+	type levelSizes = [manifest.NumLevels]uint64
+	type levelBits = [manifest.NumLevels]int
+
+	tests := []struct {
+		name          string
+		levelSizes    levelSizes
+		avgBitsPerKey int
+		want          levelBits
+	}{
+		{
+			name:          "empty DB",
+			avgBitsPerKey: 10,
+			want:          levelBits{10, 10, 10, 10, 10, 10, 10},
+		},
+		{
+			name:          "single level",
+			levelSizes:    levelSizes{6: gb},
+			avgBitsPerKey: 10,
+			want:          levelBits{24, 24, 24, 24, 24, 24, 10},
+		},
+		{
+			name:          "the smaller levels get more bits",
+			levelSizes:    levelSizes{0, mb, 10 * mb, 100 * mb, gb, 10 * gb, 100 * gb},
+			avgBitsPerKey: 10,
+			want:          levelBits{24, 24, 24, 24, 20, 15, 9},
+		},
+		{
+			name:          "the last level is pinned at the min bits",
+			levelSizes:    levelSizes{0, mb, 10 * mb, 100 * mb, gb, 10 * gb, 100 * gb},
+			avgBitsPerKey: 2,
+			want:          levelBits{24, 24, 21, 16, 12, 7, minFilterBitsPerKey},
+		},
+		{
+			name:          "a tiny level is capped at the max bits",
+			levelSizes:    levelSizes{0: kb, 6: gb},
+			avgBitsPerKey: 10,
+			want:          levelBits{maxFilterBitsPerKey, 24, 24, 24, 24, 24, 9},
+		},
+		{
+			name:          "a budget above the max bits",
+			levelSizes:    levelSizes{mb, mb},
+			avgBitsPerKey: 30,
+			want:          levelBits{24, 24, 24, 24, 24, 24, 24},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := monkeyFilterBitsPerKey(tc.levelSizes, tc.avgBitsPerKey)
+			assert.Equal(t, tc.want, got)
+
+			var total, spent uint64
+			for l, size := range tc.levelSizes {
+				assert.GreaterOrEqual(t, got[l], minFilterBitsPerKey, "level %d", l)
+				assert.LessOrEqual(t, got[l], maxFilterBitsPerKey, "level %d", l)
+				total += size
+				spent += uint64(got[l]) * size
+			}
+			assert.LessOrEqual(t, spent, uint64(tc.avgBitsPerKey)*total, "within the budget")
+		})
+	}
+}
+
+func Test_MonkeyFilterBitsPerKey_Spends_The_Budget(t *testing.T) {
+	const mb = uint64(1) << 20
+	for avgBitsPerKey := minFilterBitsPerKey; avgBitsPerKey <= maxFilterBitsPerKey; avgBitsPerKey++ {
+		var sizes [manifest.NumLevels]uint64
+		size := 3 * mb
+		for l := range sizes {
+			sizes[l] = size
+			size *= 7
+		}
+
+		got := monkeyFilterBitsPerKey(sizes, avgBitsPerKey)
+		var total, spent uint64
+		for l, size := range sizes {
+			total += size
+			spent += uint64(got[l]) * size
+			if l > 0 {
+				assert.LessOrEqual(t, got[l], got[l-1], "avg %d, level %d", avgBitsPerKey, l)
+			}
+		}
+
+		budget := uint64(avgBitsPerKey) * total
+		assert.LessOrEqual(t, spent, budget, "avg %d", avgBitsPerKey)
+		// the rounding leaves less than a bit per key unspent, unless all
+		// the levels are capped
+		if slices.Min(got[:]) < maxFilterBitsPerKey {
+			assert.Greater(t, spent+total, budget, "avg %d", avgBitsPerKey)
+		}
+	}
+}
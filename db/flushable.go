@@ -0,0 +1,39 @@
+package db
+
+import (
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// flushableEntry wraps a flushable (a memTable or a large batch) and
+// holds the state needed to queue it in <db.mu.mem.flushQueue>
+type flushableEntry struct {
+	flushable
+	// Channel which is closed when the flushable has been flushed.
+	flushed chan struct{}
+
+	// logFileNum corresponds to the WAL file which holds the mutations of
+	// the flushable. A large batch shares the WAL of the memtable preceding
+	// it in the queue, so both of them must be flushed together.
+	logFileNum nogodb_common.DiskfileNum
+
+	// seqNum is guaranteed to be less than or equal to any seqnum stored
+	// in the flushable.
+	seqNum nogodb_common.SeqNum
+
+	// flushForced is set by DB.Flush, the flushable is flushed regardless
+	// of the size of the queue.
+	flushForced bool
+}
+
+func newFlushableEntry(
+	f flushable,
+	logFileNum nogodb_common.DiskfileNum,
+	seqNum nogodb_common.SeqNum,
+) *flushableEntry {
+	return &flushableEntry{
+		flushable:  f,
+		flushed:    make(chan struct{}),
+		logFileNum: logFileNum,
+		seqNum:     seqNum,
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"slices"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// flushableBatch wraps a committed batch, which is too large to be copied
+// into the memtable, as an immutable flushable. Rather than indexing the
+// records in a memtable, the batch keeps a sorted list of its records, which
+// are pointing into the batch buffer.
+type flushableBatch struct {
+	cmp  nogodb_common.IComparer
+	data []byte
+
+	// records sorted by user key, then by descending seqNum, so that
+	// the newer records for the same key appear first.
+	records []kvRecord
+}
+
+// newFlushableBatch decodes and sorts the records of a batch, whose
+// seqNum has already been assigned.
+func newFlushableBatch(b *Batch, cmp nogodb_common.IComparer) (*flushableBatch, error) {
+	r, h, err := newBatchReader(b.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	fb := &flushableBatch{
+		cmp:     cmp,
+		data:    b.buf,
+		records: make([]kvRecord, 0, h.Count),
+	}
+
+	for seqNum := h.SeqNum; ; seqNum++ {
+		kind, key, value, ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		fb.records = append(fb.records, kvRecord{
+			key:   nogodb_common.MakeKey(key, seqNum, kind),
+			value: value,
+		})
+	}
+
+	if len(fb.records) != int(h.Count) {
+		return nil, errInvalidBatch
+	}
+
+	slices.SortFunc(fb.records, func(a, b kvRecord) int {
+		return a.key.Compare(cmp, &b.key)
+	})
+
+	return fb, nil
+}
+
+func (fb *flushableBatch) newFlushIter() nogodb_common.InternalIterator[nogodb_common.InternalKV] {
+	return newSortedIter(fb.cmp, fb.records)
+}
+
+func (fb *flushableBatch) inuseBytes() uint64 {
+	return uint64(len(fb.data))
+}
+
+func (fb *flushableBatch) totalBytes() uint64 {
+	return uint64(cap(fb.data))
+}
+
+// readyForFlush is always true, the batch is immutable and has been
+// fully applied once it is queued.
+func (fb *flushableBatch) readyForFlush() bool {
+	return true
+}
+
+var _ flushable = (*flushableBatch)(nil)
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBatchOfSize returns a batch holding a single record, whose encoded
+// size is exactly n bytes.
+func newBatchOfSize(t *testing.T, d *DB, key []byte, n int) *Batch {
+	t.Helper()
+	for valueLen := n; valueLen > 0; valueLen-- {
+		b := newBatch(d, false)
+		require.NoError(t, b.Set(key, bytes.Repeat([]byte{'v'}, valueLen)))
+		if len(b.buf) == n {
+			return b
+		}
+		require.NoError(t, b.Close())
+	}
+
+	require.FailNow(t, "can't build the batch", "size %d", n)
+	return nil
+}
+
+func Test_Commit_Large_Batch_As_Flushable(t *testing.T) {
+	const memTableSize = 64 << 10
+
+	tests := []struct {
+		name          string
+		size          int
+		wantFlushable bool
+	}{
+		{name: "below the threshold", size: memTableSize/2 - 1, wantFlushable: false},
+		{name: "at the threshold", size: memTableSize / 2, wantFlushable: true},
+		{name: "larger than the memtable", size: 2 * memTableSize, wantFlushable: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := openTestDB(t, func(opt *options.DBOption) {
+				opt.MemTable.Size = memTableSize
+			})
+			require.Equal(t, uint64(memTableSize/2), d.largeBatchThreshold)
+
+			d.mu.Lock()
+			holdFlushes(d)
+			prev := d.mu.mem.flushQueue[0]
+			d.mu.Unlock()
+
+			b := newBatchOfSize(t, d, []byte("large"), tc.size)
+			require.NoError(t, d.apply(b))
+			seqNum, count := b.SeqNum(), nogodb_common.SeqNum(b.Count())
+			require.NoError(t, b.Close())
+
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			assert.Equal(t, seqNum+count, d.commit.visibleSeqNum)
+
+			if !tc.wantFlushable {
+				require.Len(t, d.mu.mem.flushQueue, 1, "the memtable is not rotated")
+				assert.NotZero(t, d.mu.mem.mutable.inuseBytes())
+				return
+			}
+
+			// [rotated memtable, large batch, new mutable memtable]
+			require.Len(t, d.mu.mem.flushQueue, 3)
+			assert.Same(t, prev, d.mu.mem.flushQueue[0])
+			assert.True(t, prev.readyForFlush(), "the rotated memtable has dropped its ref")
+			assert.Zero(t, prev.inuseBytes(), "the batch is not copied into the memtable")
+
+			entry := d.mu.mem.flushQueue[1]
+			fb, ok := entry.flushable.(*flushableBatch)
+			require.True(t, ok)
+			assert.Equal(t, prev.logFileNum, entry.logFileNum, "the batch shares the WAL of the rotated memtable")
+			assert.Equal(t, seqNum, entry.seqNum)
+			assert.True(t, fb.readyForFlush())
+
+			iter := fb.newFlushIter()
+			kv := iter.First()
+			require.NotNil(t, kv)
+			assert.Equal(t, []byte("large"), kv.K.UserKey)
+			assert.Equal(t, seqNum, kv.K.SeqNum())
+			// the batch buffer is not recycled once the batch is closed
+			value := kv.V.Value()
+			assert.Greater(t, len(value), tc.size/2)
+			assert.True(t, bytes.Equal(bytes.Repeat([]byte{'v'}, len(value)), value))
+			assert.Nil(t, iter.Next())
+			require.NoError(t, iter.Close())
+
+			mutable := d.mu.mem.flushQueue[2]
+			assert.Same(t, d.mu.mem.mutable, mutable.flushable)
+			assert.NotEqual(t, prev.logFileNum, mutable.logFileNum)
+			assert.Equal(t, seqNum+count, mutable.seqNum)
+			require.Len(t, d.mu.log.files, 2)
+			assert.Equal(t, mutable.logFileNum, d.mu.log.files[1].fileNum)
+			assert.Equal(t, seqNum+count, d.mu.log.files[1].seqNum)
+		})
+	}
+}
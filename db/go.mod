@@ -20,6 +20,10 @@ replace github.com/datnguyenzzz/nogodb/lib/go-sstable => ../lib/go-sstable
 
 replace github.com/datnguyenzzz/nogodb/lib/go-blocked-bloom-filter => ../lib/go-blocked-bloom-filter
 
+replace github.com/datnguyenzzz/nogodb/lib/go-ribbon-filter => ../lib/go-ribbon-filter
+
+replace github.com/datnguyenzzz/nogodb/lib/go-skiplist => ../lib/go-skiplist
+
 require (
 	github.com/datnguyenzzz/nogodb/lib/common v0.0.0-00010101000000-000000000000
 	github.com/datnguyenzzz/nogodb/lib/go-adaptive-radix-tree v0.0.0-00010101000000-000000000000
@@ -32,16 +36,20 @@ require (
 	golang.org/x/sync v0.19.0
 )
 
-require github.com/datnguyenzzz/nogodb/lib/go-sstable v0.0.0-00010101000000-000000000000
+require (
+	github.com/datnguyenzzz/nogodb/lib/go-skiplist v0.0.0-00010101000000-000000000000
+	github.com/datnguyenzzz/nogodb/lib/go-sstable v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.11.1
+)
 
 require (
 	github.com/DataDog/zstd v1.5.7 // indirect
 	github.com/datnguyenzzz/nogodb/lib/go-blocked-bloom-filter v0.0.0-00010101000000-000000000000 // indirect
+	github.com/datnguyenzzz/nogodb/lib/go-ribbon-filter v0.0.0-00010101000000-000000000000 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/go-faker/faker/v4 v4.7.0 // indirect
 	github.com/golang/snappy v1.0.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.11.1 // indirect
 	github.com/twmb/murmur3 v1.1.8 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
@@ -1,55 +1,303 @@
 package db
 
 import (
+	"bytes"
+	"errors"
+
 	"github.com/datnguyenzzz/nogodb/db/manifest"
-	"github.com/datnguyenzzz/nogodb/db/options"
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_sst "github.com/datnguyenzzz/nogodb/lib/go-sstable"
+	sst_options "github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
 )
 
-// levelIter provides a merged view of the sstables in a level
-type levelIter struct{}
+// levelIter provides a merged view of the sstables in a level. The tables are
+// read one after the other, so they mustn't overlap: an L0 table, which
+// overlaps with the others, is read by a levelIter of its own.
+type levelIter struct {
+	d        *DB
+	tables   *manifest.LevelIterator
+	iterOpts []sst_options.IteratorOptsFunc
+
+	// iter reads the table tableNum, it's nil if no table is opened. It
+	// owns the readable of the table, which is closed along with it
+	iter     nogodb_sst.IIterator
+	tableNum nogodb_common.DiskfileNum
+
+	// prefix is set by SeekPrefixGTE, the iterator is then exhausted once the
+	// tables are beyond it, and the filters of the next tables are probed
+	// before they're read. It's reset by the other seeks, First or Last.
+	prefix []byte
+
+	// err is the error which has exhausted the iterator early, e.g. a table
+	// which failed to be opened or read
+	err    error
+	closed bool
+}
 
 func (l *levelIter) Close() error {
-	panic("unimplemented")
+	err := l.closeTable()
+	l.closed = true
+	return errors.Join(err, l.tables.Close())
 }
 
 func (l *levelIter) First() *nogodb_common.InternalKV {
-	panic("unimplemented")
+	l.err, l.prefix = nil, nil
+	return l.forward(l.tables.First(), nogodb_sst.IIterator.First)
 }
 
 func (l *levelIter) IsClosed() bool {
-	panic("unimplemented")
+	return l.closed
 }
 
 func (l *levelIter) Last() *nogodb_common.InternalKV {
-	panic("unimplemented")
+	l.err, l.prefix = nil, nil
+	return l.backward(l.tables.Last(), nogodb_sst.IIterator.Last)
 }
 
 func (l *levelIter) Next() *nogodb_common.InternalKV {
-	panic("unimplemented")
+	if l.iter == nil || l.err != nil {
+		return nil
+	}
+	if kv := l.iter.Next(); kv != nil {
+		return kv
+	}
+	if l.err = l.iter.Error(); l.err != nil {
+		return nil
+	}
+	return l.forward(l.tables.Next(), l.seekNextTable)
 }
 
 func (l *levelIter) Prev() *nogodb_common.InternalKV {
-	panic("unimplemented")
+	if l.iter == nil || l.err != nil {
+		return nil
+	}
+	if kv := l.iter.Prev(); kv != nil {
+		return kv
+	}
+	if l.err = l.iter.Error(); l.err != nil {
+		return nil
+	}
+	return l.backward(l.tables.Prev(), nogodb_sst.IIterator.Last)
 }
 
 func (l *levelIter) SeekGTE(key []byte) *nogodb_common.InternalKV {
-	panic("unimplemented")
+	l.err, l.prefix = nil, nil
+	return l.forward(l.tables.SeekGTE(key), func(iter nogodb_sst.IIterator) *nogodb_common.InternalKV {
+		return iter.SeekGTE(key)
+	})
 }
 
 func (l *levelIter) SeekLTE(key []byte) *nogodb_common.InternalKV {
-	panic("unimplemented")
+	l.err, l.prefix = nil, nil
+	return l.backward(l.tables.SeekLTE(key), func(iter nogodb_sst.IIterator) *nogodb_common.InternalKV {
+		return iter.SeekLTE(key)
+	})
 }
 
+// SeekPrefixGTE seeks the first key ≥ key with the given prefix, key must
+// have the prefix. The tables whose filter rules the prefix out are skipped
+// without being read, see nogodb_sst.IIterator.SeekPrefixGTE, and the
+// iterator is exhausted once the tables are beyond the prefix. The prefix
+// mustn't be modified until the next seek.
 func (l *levelIter) SeekPrefixGTE(prefix []byte, key []byte) *nogodb_common.InternalKV {
-	panic("unimplemented")
+	l.err, l.prefix = nil, prefix
+	return l.forward(l.tables.SeekGTE(key), func(iter nogodb_sst.IIterator) *nogodb_common.InternalKV {
+		return iter.SeekPrefixGTE(prefix, key)
+	})
+}
+
+// Error returns the error which has exhausted the iterator early, it's reset
+// by a seek, First or Last
+func (l *levelIter) Error() error {
+	return l.err
+}
+
+// forward positions the iterator on the table t with seek, then on the first
+// entry of the next tables until an entry is found
+func (l *levelIter) forward(
+	t *manifest.TableMetadata,
+	seek func(nogodb_sst.IIterator) *nogodb_common.InternalKV,
+) *nogodb_common.InternalKV {
+	for ; t != nil; t = l.tables.Next() {
+		if l.prefix != nil && l.beyondPrefix(t) {
+			// the tables of the level don't overlap, the next ones are
+			// beyond the prefix as well
+			l.err = l.closeTable()
+			return nil
+		}
+		if l.err = l.openTable(t); l.err != nil {
+			return nil
+		}
+		if kv := seek(l.iter); kv != nil {
+			return kv
+		}
+		if l.err = l.iter.Error(); l.err != nil {
+			return nil
+		}
+		seek = l.seekNextTable
+	}
+
+	if l.prefix != nil {
+		// the table whose filter has ruled the prefix out isn't positioned
+		l.err = l.closeTable()
+	}
+	return nil
+}
+
+// seekNextTable positions the iterator on the first entry of the table
+// following the current one. On a prefix scan, the table is skipped if its
+// filter rules the prefix out: its keys are greater than the ones already
+// read, hence than the prefix, so seeking the prefix lands on its first
+// entry otherwise.
+func (l *levelIter) seekNextTable(iter nogodb_sst.IIterator) *nogodb_common.InternalKV {
+	if l.prefix != nil {
+		return iter.SeekPrefixGTE(l.prefix, l.prefix)
+	}
+	return iter.First()
+}
+
+// beyondPrefix returns true if the keys of the table t are greater than the
+// keys with the prefix of the current scan
+func (l *levelIter) beyondPrefix(t *manifest.TableMetadata) bool {
+	return !bytes.HasPrefix(t.Smallest, l.prefix) && l.d.cmp.Compare(t.Smallest, l.prefix) > 0
 }
 
-func newLevelIter(
-	opt *options.DBOption,
+// backward positions the iterator on the table t with seek, then on the last
+// entry of the previous tables until an entry is found
+func (l *levelIter) backward(
+	t *manifest.TableMetadata,
+	seek func(nogodb_sst.IIterator) *nogodb_common.InternalKV,
+) *nogodb_common.InternalKV {
+	for ; t != nil; t = l.tables.Prev() {
+		if l.err = l.openTable(t); l.err != nil {
+			return nil
+		}
+		if kv := seek(l.iter); kv != nil {
+			return kv
+		}
+		if l.err = l.iter.Error(); l.err != nil {
+			return nil
+		}
+		seek = nogodb_sst.IIterator.Last
+	}
+
+	return nil
+}
+
+// openTable opens the iterator of the table t, unless it's the current one
+func (l *levelIter) openTable(t *manifest.TableMetadata) error {
+	if l.iter != nil && l.tableNum == t.TableNum {
+		return nil
+	}
+	if err := l.closeTable(); err != nil {
+		return err
+	}
+
+	iter, err := l.d.openTable(t.TableNum, l.iterOpts...)
+	if err != nil {
+		return err
+	}
+
+	l.iter, l.tableNum = iter, t.TableNum
+	return nil
+}
+
+func (l *levelIter) closeTable() error {
+	if l.iter == nil {
+		return nil
+	}
+
+	err := l.iter.Close()
+	l.iter = nil
+	return err
+}
+
+// newLevelIter returns an iterator over the given tables of a level, their
+// sstable iterators are opened with the given options, e.g.
+// sst_options.WithDontFillCache for the compactions.
+func (d *DB) newLevelIter(
 	tables *manifest.LevelIterator,
+	iterOpts ...sst_options.IteratorOptsFunc,
 ) *levelIter {
-	panic("unimplemented")
+	return &levelIter{
+		d:        d,
+		tables:   tables,
+		iterOpts: iterOpts,
+	}
+}
+
+// openTable opens an iterator over the table num, reading its blocks through
+// the block cache. The iterator owns the readable of the table.
+func (d *DB) openTable(num nogodb_common.DiskfileNum, iterOpts ...sst_options.IteratorOptsFunc) (nogodb_sst.IIterator, error) {
+	readable, fd, err := d.sstStorager.Open(nogodb_common.TypeTable, num)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := make([]sst_options.IteratorOptsFunc, 0, len(iterOpts)+3)
+	opts = append(opts, sst_options.WithComparer(d.cmp))
+	if d.opts.SST.PrefixExtractor != nil {
+		opts = append(opts, sst_options.WithPrefixExtractor(d.opts.SST.PrefixExtractor))
+	}
+	if d.cache != nil {
+		opts = append(opts, sst_options.WithBlockCache(d.cache, fd))
+	}
+	opts = append(opts, iterOpts...)
+
+	iter, err := nogodb_sst.NewSingularIterator(d.bpool, readable, opts...)
+	if err != nil {
+		_ = readable.Close()
+		return nil, err
+	}
+	return iter, nil
+}
+
+// pinL0MetaBlocks pins the index and filter blocks of the L0 tables in the
+// block cache if options.DBOption.Cache.PinL0MetaBlocks, through an sstable
+// iterator kept open per table, and unpins the ones of the tables which have
+// left L0.
+// d.mu must be held when calling this.
+func (d *DB) pinL0MetaBlocks() error {
+	if !d.opts.Cache.PinL0MetaBlocks {
+		return nil
+	}
+
+	var err error
+	l0 := make(map[nogodb_common.DiskfileNum]struct{})
+	for t := range d.mu.versions.currentVersion().Levels[0].All() {
+		l0[t.TableNum] = struct{}{}
+		if _, pinned := d.mu.l0Pins[t.TableNum]; pinned {
+			continue
+		}
+
+		// the index and filter blocks are read, hence pinned, once the
+		// iterator is opened
+		iter, openErr := d.openTable(t.TableNum, sst_options.WithPinnedMetaBlocks())
+		if openErr != nil {
+			err = errors.Join(err, openErr)
+			continue
+		}
+		d.mu.l0Pins[t.TableNum] = iter
+	}
+
+	for num, iter := range d.mu.l0Pins {
+		if _, ok := l0[num]; !ok {
+			err = errors.Join(err, iter.Close())
+			delete(d.mu.l0Pins, num)
+		}
+	}
+	return err
+}
+
+// unpinL0MetaBlocks unpins the meta blocks pinned by pinL0MetaBlocks.
+// d.mu must be held when calling this.
+func (d *DB) unpinL0MetaBlocks() error {
+	var err error
+	for num, iter := range d.mu.l0Pins {
+		err = errors.Join(err, iter.Close())
+		delete(d.mu.l0Pins, num)
+	}
+	return err
 }
 
 var _ nogodb_common.InternalIterator[nogodb_common.InternalKV] = (*levelIter)(nil)
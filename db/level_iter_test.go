@@ -0,0 +1,60 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/db/manifest"
+	"github.com/datnguyenzzz/nogodb/db/options"
+	sst_options "github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_levelIter_SeekPrefixGTE_Skips_The_Filtered_Tables(t *testing.T) {
+	d := openTestDB(t, func(opt *options.DBOption) {
+		opt.SST.PrefixExtractor = sst_options.NewDelimitedPrefixExtractor('/', 1)
+	})
+
+	// 2 tables which don't overlap, [T1/a, T3/a] and [T4/a, T5/a]
+	writeTestBatch(t, d, []byte("T1/a"), []byte("1a"), []byte("T3/a"), []byte("3a"))
+	require.NoError(t, d.Flush())
+	writeTestBatch(t, d, []byte("T4/a"), []byte("4a"), []byte("T5/a"), []byte("5a"))
+	require.NoError(t, d.Flush())
+
+	// they're moved to L1, so that they're read by the same levelIter
+	d.mu.Lock()
+	l1 := manifest.NewLevelMetadata(d.cmp, 1)
+	for tm := range d.mu.versions.currentVersion().Levels[0].All() {
+		require.NoError(t, l1.Insert(&tm))
+	}
+	d.mu.Unlock()
+
+	iter := d.newLevelIter(l1.Iter(-1, -1))
+	defer func() { require.NoError(t, iter.Close()) }()
+
+	kv := iter.SeekPrefixGTE([]byte("T3/"), []byte("T3/"))
+	require.NotNil(t, kv)
+	assert.Equal(t, "T3/a", string(kv.K.UserKey))
+	// the next table is beyond the prefix, it isn't opened
+	assert.Nil(t, iter.Next())
+	assert.Nil(t, iter.iter)
+	require.NoError(t, iter.Error())
+
+	// the filter of the 1st table rules T2/ out, a plain seek would land
+	// on T3/a
+	assert.Nil(t, iter.SeekPrefixGTE([]byte("T2/"), []byte("T2/")))
+	assert.Nil(t, iter.iter)
+	require.NoError(t, iter.Error())
+
+	kv = iter.SeekPrefixGTE([]byte("T5/"), []byte("T5/"))
+	require.NotNil(t, kv)
+	assert.Equal(t, "T5/a", string(kv.K.UserKey))
+
+	// the other seeks don't check the prefix
+	kv = iter.SeekGTE([]byte("T2/"))
+	require.NotNil(t, kv)
+	assert.Equal(t, "T3/a", string(kv.K.UserKey))
+	kv = iter.Next()
+	require.NotNil(t, kv)
+	assert.Equal(t, "T4/a", string(kv.K.UserKey))
+}
@@ -1,7 +1,9 @@
 package manifest
 
 import (
+	"fmt"
 	"iter"
+	"slices"
 
 	// TODO(low): having nogodb_btree
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
@@ -15,15 +17,30 @@ const (
 // LevelMetadata contains metadata for all of the tables within a level of the LSM.
 type levelMetadata struct {
 	level int
+	cmp   nogodb_common.IComparer
 	tree  *btree.BTreeG[TableMetadata]
 }
 
-func NewLevelMetadata(level int) *levelMetadata {
+// NewLevelMetadata returns an empty level. The L0 tables are ordered from the
+// oldest to the newest one (see TableMetadata.Compare), the tables of the
+// other levels don't overlap, they're ordered by their smallest keys.
+func NewLevelMetadata(cmp nogodb_common.IComparer, level int) *levelMetadata {
+	less := func(a, b TableMetadata) bool {
+		return a.Compare(b) < 0
+	}
+	if level > 0 {
+		less = func(a, b TableMetadata) bool {
+			if c := cmp.Compare(a.Smallest, b.Smallest); c != 0 {
+				return c < 0
+			}
+			return a.TableNum < b.TableNum
+		}
+	}
+
 	return &levelMetadata{
 		level: level,
-		tree: btree.NewG(degree, func(a, b TableMetadata) bool {
-			return a.Compare(b) <= 0
-		}),
+		cmp:   cmp,
+		tree:  btree.NewG(degree, less),
 	}
 }
 
@@ -34,68 +51,156 @@ func (l *levelMetadata) All() iter.Seq[TableMetadata] {
 	}
 }
 
+// Len returns the number of tables within the level
+func (l *levelMetadata) Len() int {
+	return l.tree.Len()
+}
+
+// Clone returns a copy of the level, which can be modified without
+// affecting the level, e.g. to build the next version
 func (l *levelMetadata) Clone() *levelMetadata {
-	panic("")
+	return &levelMetadata{
+		level: l.level,
+		cmp:   l.cmp,
+		tree:  l.tree.Clone(),
+	}
 }
 
+// Insert adds the table to the level, it fails if the level holds the
+// table already
 func (l *levelMetadata) Insert(tm *TableMetadata) error {
-	panic("")
+	if _, found := l.tree.Get(*tm); found {
+		return fmt.Errorf("manifest: the table %d is in L%d already", tm.TableNum, l.level)
+	}
+
+	l.tree.ReplaceOrInsert(*tm)
+	return nil
 }
 
+// AggregateSize returns the total size of the tables within the level, in bytes.
 func (l *levelMetadata) AggregateSize() uint64 {
-	panic("")
+	var size uint64
+	for tm := range l.All() {
+		size += tm.Size
+	}
+	return size
 }
 
+// Iter returns an iterator over the tables of the level at the positions
+// [start, end), a negative bound is the boundary of the level.
 func (l *levelMetadata) Iter(start, end int) *LevelIterator {
-	panic("implement me")
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 || end > l.tree.Len() {
+		end = l.tree.Len()
+	}
+
+	it := &LevelIterator{cmp: l.cmp, pos: -1}
+	if start >= end {
+		return it
+	}
+
+	// the tables of a version are immutable, they're copied once
+	it.tables = make([]TableMetadata, 0, end-start)
+	var i int
+	l.tree.Ascend(func(tm TableMetadata) bool {
+		if i >= start {
+			it.tables = append(it.tables, tm)
+		}
+		i++
+		return i < end
+	})
+
+	return it
 }
 
-// LevelIterator iterates over a set of tables' metadata within a range [start, end)
+// LevelIterator iterates over a set of tables' metadata within a range [start, end).
+// The seeks rely on the tables being ordered by keys, they're only meaningful
+// on the levels below L0.
 type LevelIterator struct {
-	iter            btree.ItemIterator
-	cmp             nogodb_common.IComparer
-	tree            *btree.BTreeG[TableMetadata]
-	start, end, pos int // -1 means at the boundary
+	cmp    nogodb_common.IComparer
+	tables []TableMetadata
+	pos    int // -1 or len(tables) means at the boundary
+	closed bool
+}
+
+// Len returns the number of tables of the iterator
+func (l *LevelIterator) Len() int {
+	return len(l.tables)
 }
 
 func (l *LevelIterator) Curr() *TableMetadata {
-	panic("unimplemented")
+	return l.load()
 }
 
 func (l *LevelIterator) Close() error {
-	panic("unimplemented")
+	l.closed = true
+	return nil
 }
 
 func (l *LevelIterator) First() *TableMetadata {
-	panic("unimplemented")
+	l.pos = 0
+	return l.load()
 }
 
 func (l *LevelIterator) IsClosed() bool {
-	panic("unimplemented")
+	return l.closed
 }
 
 func (l *LevelIterator) Last() *TableMetadata {
-	panic("unimplemented")
+	l.pos = len(l.tables) - 1
+	return l.load()
 }
 
 func (l *LevelIterator) Next() *TableMetadata {
-	panic("unimplemented")
+	if l.pos < len(l.tables) {
+		l.pos++
+	}
+	return l.load()
 }
 
 func (l *LevelIterator) Prev() *TableMetadata {
-	panic("unimplemented")
+	if l.pos >= 0 {
+		l.pos--
+	}
+	return l.load()
 }
 
+// SeekGTE moves to the first table whose largest key is ≥ key
 func (l *LevelIterator) SeekGTE(key []byte) *TableMetadata {
-	panic("unimplemented")
+	l.pos, _ = slices.BinarySearchFunc(l.tables, key, func(tm TableMetadata, key []byte) int {
+		if l.cmp.Compare(tm.Largest, key) < 0 {
+			return -1
+		}
+		return 1
+	})
+	return l.load()
 }
 
+// SeekLTE moves to the last table whose smallest key is ≤ key
 func (l *LevelIterator) SeekLTE(key []byte) *TableMetadata {
-	panic("unimplemented")
+	pos, _ := slices.BinarySearchFunc(l.tables, key, func(tm TableMetadata, key []byte) int {
+		if l.cmp.Compare(tm.Smallest, key) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	l.pos = pos - 1
+	return l.load()
+}
+
+// SeekPrefixGTE moves to the first table whose largest key is ≥ key, the
+// tables don't keep any filter, the prefix is checked by the table iterators
+func (l *LevelIterator) SeekPrefixGTE(prefix []byte, key []byte) *TableMetadata {
+	return l.SeekGTE(key)
 }
 
-func (l *LevelIterator) SeekPrefixGTE(prefix []byte, key []byte) *TableMetadata {
-	panic("unimplemented")
+func (l *LevelIterator) load() *TableMetadata {
+	if l.pos < 0 || l.pos >= len(l.tables) {
+		return nil
+	}
+	return &l.tables[l.pos]
 }
 
 var _ nogodb_common.InternalIterator[TableMetadata] = (*LevelIterator)(nil)
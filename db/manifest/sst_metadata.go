@@ -1,6 +1,10 @@
 package manifest
 
-import nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+import (
+	"cmp"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
 
 // CompactionState is the compaction state of a file.
 //
@@ -40,12 +44,30 @@ type TableMetadata struct {
 	// sequence numbers in the table, across both point and range keys
 	LowSeqNum  nogodb_common.SeqNum
 	HighSeqNum nogodb_common.SeqNum
+
+	// Smallest and Largest are the smallest and the largest user keys of the
+	// table, both inclusive
+	Smallest []byte
+	Largest  []byte
 }
 
+// Compare orders the tables from the oldest to the newest one, by their
+// seqnums then by their table numbers. It's the order of the L0 tables, whose
+// key ranges overlap.
 func (t *TableMetadata) Compare(t2 TableMetadata) int {
-	panic("implement me")
+	if c := cmp.Compare(t.HighSeqNum, t2.HighSeqNum); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(t.LowSeqNum, t2.LowSeqNum); c != 0 {
+		return c
+	}
+	return cmp.Compare(t.TableNum, t2.TableNum)
 }
 
+// UserKeyBound returns the range of the user keys of the table
 func (t *TableMetadata) UserKeyBound() nogodb_common.UserKeyBound {
-	panic("unimplemented")
+	return nogodb_common.UserKeyBound{
+		Start: t.Smallest,
+		End:   nogodb_common.UserKeyBoundary{Key: t.Largest, Kind: nogodb_common.Inclusive},
+	}
 }
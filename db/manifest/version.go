@@ -29,7 +29,7 @@ func NewVersion(
 		Cmp: comparer,
 	}
 	for i := range NumLevels {
-		v.Levels[i] = NewLevelMetadata(i)
+		v.Levels[i] = NewLevelMetadata(comparer, i)
 	}
 	return v
 }
@@ -1,8 +1,11 @@
 package manifest
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
@@ -12,6 +15,9 @@ import (
 const (
 	tagComparator = iota
 	tagNextFileNumber
+	tagNewTable
+	tagMinUnflushedLogNum
+	tagLastSeqNum
 )
 
 // VersionEdit holds the state for an delta edit to a Version
@@ -34,6 +40,9 @@ type VersionEdit struct {
 	LastSeqNum nogodb_common.SeqNum
 }
 
+// errCorruptVersionEdit is returned when a versionEdit can't be decoded
+var errCorruptVersionEdit = errors.New("manifest: corrupt versionEdit")
+
 type versionEditEncoder struct {
 	*bytes.Buffer
 }
@@ -43,6 +52,11 @@ func (e versionEditEncoder) writeString(s string) {
 	e.WriteString(s)
 }
 
+func (e versionEditEncoder) writeBytes(b []byte) {
+	e.writeUvarint(uint64(len(b)))
+	e.Write(b)
+}
+
 func (e versionEditEncoder) writeUvarint(u uint64) {
 	var buf [binary.MaxVarintLen64]byte
 	n := binary.PutUvarint(buf[:], u)
@@ -62,12 +76,138 @@ func (ve *VersionEdit) Encode(w io.Writer) error {
 		enc.writeUvarint(uint64(ve.NextFileNum))
 	}
 
+	if ve.MinUnflushedLogNum > 0 {
+		enc.writeUvarint(tagMinUnflushedLogNum)
+		enc.writeUvarint(uint64(ve.MinUnflushedLogNum))
+	}
+
+	if ve.LastSeqNum > 0 {
+		enc.writeUvarint(tagLastSeqNum)
+		enc.writeUvarint(uint64(ve.LastSeqNum))
+	}
+
 	for _, table := range ve.NewTables {
+		enc.writeUvarint(tagNewTable)
 		enc.writeUvarint(uint64(table.Level))
 		enc.writeUvarint(uint64(table.Meta.TableNum))
 		enc.writeUvarint(table.Meta.Size)
+		enc.writeUvarint(uint64(table.Meta.LowSeqNum))
+		enc.writeUvarint(uint64(table.Meta.HighSeqNum))
+		enc.writeBytes(table.Meta.Smallest)
+		enc.writeBytes(table.Meta.Largest)
 	}
 
 	_, err := w.Write(enc.Bytes())
 	return err
 }
+
+type versionEditDecoder struct {
+	*bufio.Reader
+}
+
+func (d versionEditDecoder) readUvarint() (uint64, error) {
+	u, err := binary.ReadUvarint(d)
+	if err != nil {
+		if err == io.EOF {
+			return 0, fmt.Errorf("%w: truncated", errCorruptVersionEdit)
+		}
+		return 0, err
+	}
+	return u, nil
+}
+
+func (d versionEditDecoder) readBytes() ([]byte, error) {
+	n, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: truncated", errCorruptVersionEdit)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// Decode decodes a versionEdit written by Encode, r holding it whole, e.g. a
+// record of a manifest
+func (ve *VersionEdit) Decode(r io.Reader) error {
+	d := versionEditDecoder{bufio.NewReader(r)}
+	for {
+		tag, err := binary.ReadUvarint(d)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch tag {
+		case tagComparator:
+			s, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			ve.ComparerName = string(s)
+		case tagNextFileNumber:
+			n, err := d.readUvarint()
+			if err != nil {
+				return err
+			}
+			ve.NextFileNum = int64(n)
+		case tagMinUnflushedLogNum:
+			n, err := d.readUvarint()
+			if err != nil {
+				return err
+			}
+			ve.MinUnflushedLogNum = nogodb_common.DiskfileNum(n)
+		case tagLastSeqNum:
+			n, err := d.readUvarint()
+			if err != nil {
+				return err
+			}
+			ve.LastSeqNum = nogodb_common.SeqNum(n)
+		case tagNewTable:
+			table, err := d.readNewTable()
+			if err != nil {
+				return err
+			}
+			ve.NewTables = append(ve.NewTables, table)
+		default:
+			return fmt.Errorf("%w: unknown tag %d", errCorruptVersionEdit, tag)
+		}
+	}
+}
+
+func (d versionEditDecoder) readNewTable() (NewTableEntry, error) {
+	var fields [5]uint64
+	for i := range fields {
+		u, err := d.readUvarint()
+		if err != nil {
+			return NewTableEntry{}, err
+		}
+		fields[i] = u
+	}
+	smallest, err := d.readBytes()
+	if err != nil {
+		return NewTableEntry{}, err
+	}
+	largest, err := d.readBytes()
+	if err != nil {
+		return NewTableEntry{}, err
+	}
+
+	return NewTableEntry{
+		Level: int(fields[0]),
+		Meta: &TableMetadata{
+			TableNum:   nogodb_common.DiskfileNum(fields[1]),
+			Size:       fields[2],
+			LowSeqNum:  nogodb_common.SeqNum(fields[3]),
+			HighSeqNum: nogodb_common.SeqNum(fields[4]),
+			Smallest:   smallest,
+			Largest:    largest,
+		},
+	}, nil
+}
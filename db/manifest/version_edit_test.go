@@ -0,0 +1,33 @@
+package manifest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_VersionEdit_Encode_Decode(t *testing.T) {
+	ve := &VersionEdit{
+		ComparerName:       "nogodb.BytewiseComparer",
+		NextFileNum:        12,
+		MinUnflushedLogNum: 7,
+		LastSeqNum:         1024,
+		NewTables: []NewTableEntry{
+			{Level: 0, Meta: &TableMetadata{TableNum: 4, Size: 4096, LowSeqNum: 1, HighSeqNum: 512, Smallest: []byte("a"), Largest: []byte("m")}},
+			{Level: 6, Meta: &TableMetadata{TableNum: 9, Size: 1, LowSeqNum: 513, HighSeqNum: 1023, Smallest: []byte("n"), Largest: []byte("z")}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, ve.Encode(&buf))
+
+	got := &VersionEdit{}
+	require.NoError(t, got.Decode(bytes.NewReader(buf.Bytes())))
+	assert.Equal(t, ve, got)
+
+	// a truncated versionEdit is corrupt
+	err := (&VersionEdit{}).Decode(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.ErrorIs(t, err, errCorruptVersionEdit)
+}
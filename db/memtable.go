@@ -1,81 +1,273 @@
 package db
 
 import (
-	"context"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync/atomic"
 
 	"github.com/datnguyenzzz/nogodb/db/options"
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
-	nogodb_art "github.com/datnguyenzzz/nogodb/lib/go-adaptive-radix-tree"
+	nogodb_skl "github.com/datnguyenzzz/nogodb/lib/go-skiplist"
 )
 
+// memTableInitialSize is the size of the first memtable, the size of the
+// following memtables doubles up to options.DBOption.MemTable.Size
+const memTableInitialSize = 256 << 10 // 256 KB
+
+var errMemTableFull = errors.New("nogodb: memtable is full")
+
 // memTable implements an in-memory layer of the LSM. A memTable is mutable,
 // but append-only. Records are added, but never removed. Deletion is supported
 // via tombstones, but it is up to higher level code to support processing those
 // tombstones.
+//
+// The flush related state (WAL file, seqNum, flushed signal) is kept by
+// the wrapping flushableEntry, see newMemTable.
+//
+// The records are indexed (by an ART or a skiplist, see options.MemTableKind)
+// by their encoded internal key, so that multiple versions of a same user key
+// coexist.
 type memTable struct {
-	cmp nogodb_common.IComparer
-	// Channel which is closed when the flushable has been flushed.
-	flushed chan struct{}
-	art     nogodb_art.ITree[[]byte]
+	keys  memKeyEncoder
+	index memTableIndex
 
-	// The current SeqNum at the time the memtable was created. This is
-	// guaranteed to be less than or equal to any seqnum stored in the
-	// memtable. Can be represented as a smallest SeqNum of all keys that
-	// are stored in the memTable
-	seqNum nogodb_common.SeqNum
+	// size is the capacity in bytes of the memtable
+	size uint64
+	// reserved is the number of bytes that have been reserved by the
+	// prepared batches. It plays the role of an arena allocated offset.
+	reserved atomic.Uint64
 
-	// logFileNum corresponds to the corresponsding WAL file to this
-	// memtable
-	logFileNum nogodb_common.DiskfileNum
+	// writerRefs counts the batches that have been prepared but not yet
+	// applied, plus 1 ref which is held while the memtable is mutable.
+	// The memtable can be flushed only once all of its refs are dropped.
+	writerRefs atomic.Int32
 }
 
+// newMemTable creates a new mutable memTable of <size> bytes, whose mutations
+// are written to the WAL <logFileNum>, and the entry to queue it for flushing.
 func newMemTable(
 	opt options.DBOption,
-	segNum nogodb_common.SeqNum,
+	size uint64,
+	seqNum nogodb_common.SeqNum,
 	logFileNum nogodb_common.DiskfileNum,
-) *memTable {
+) (*memTable, *flushableEntry) {
 	m := &memTable{
-		cmp:        opt.Comparer,
-		seqNum:     segNum,
-		logFileNum: logFileNum,
-		art:        nogodb_art.NewTree[[]byte](context.Background()),
+		keys:  newMemKeyEncoder(opt.Comparer),
+		index: newMemTableIndex(opt.MemTable.Kind, size),
+		size:  size,
 	}
+	m.writerRefs.Store(1)
 
-	return m
+	return m, newFlushableEntry(m, logFileNum, seqNum)
 }
 
 // Prepare reserves space for the batch in the memtable and references the
 // memtable preventing it from being flushed until the batch is applied. Note
 // that prepare is not thread-safe, while apply is. The caller must call
 // writerUnref() after the batch has been applied.
-func (m *memTable) prepare(b *Batch) {
-	panic("implement me!")
+//
+// It returns errMemTableFull if there is not enough room left for the batch,
+// then the memtable must be rotated, see DB.makeRoomForWrite.
+func (m *memTable) prepare(b *Batch) error {
+	if m.reserved.Load()+b.memTableSize > m.size {
+		return errMemTableFull
+	}
+
+	m.reserved.Add(b.memTableSize)
+	m.writerRefs.Add(1)
+	return nil
 }
 
 // apply applies the mutations in the batch to the memtable
 func (m *memTable) apply(b *Batch, seqNum nogodb_common.SeqNum) error {
-	panic("implement me!")
+	r, _, err := newBatchReader(b.buf)
+	if err != nil {
+		return err
+	}
+
+	for ; ; seqNum++ {
+		kind, key, value, ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		ik := nogodb_common.MakeKey(key, seqNum, kind)
+		if err := m.index.add(m.keys.encode(&ik), value); err != nil {
+			return err
+		}
+	}
 }
 
 // writerUnref drops a ref on the memtable. Returns true if this was
 // the last ref.
 func (m *memTable) writerUnref() (wasLastRef bool) {
-	panic("implement me")
+	switch v := m.writerRefs.Add(-1); {
+	case v < 0:
+		panic("memTable: inconsistent writer reference count")
+	case v == 0:
+		return true
+	default:
+		return false
+	}
 }
 
 // Flush to L0
 
+// newFlushIter returns an ordered iterator over the memtable.
 func (m *memTable) newFlushIter() nogodb_common.InternalIterator[nogodb_common.InternalKV] {
-	return nil
+	return m.index.newIter(m.keys)
 }
 
 // inuseBytes returns the number of inuse bytes by the flushable.
-func (m *memTable) inuseBytes() uint64 { return 0 }
+func (m *memTable) inuseBytes() uint64 { return m.reserved.Load() }
 
 // totalBytes returns the total number of bytes allocated by the flushable.
-func (m *memTable) totalBytes() uint64 { return 0 }
+func (m *memTable) totalBytes() uint64 { return m.size }
 
 // readyForFlush returns true when the flushable is ready for flushing.
-func (m *memTable) readyForFlush() bool { return false }
+func (m *memTable) readyForFlush() bool { return m.writerRefs.Load() == 0 }
 
 var _ flushable = (*memTable)(nil)
+
+// memTableEntrySize returns an upper bound of the number of bytes needed by
+// the memtable to store a record. It is exact for the skiplist, since its
+// arena must never overflow, and an estimation for the ART.
+func memTableEntrySize(keyLen, valueLen int) uint64 {
+	// in the worst case, every byte of the user key is escaped, and both its
+	// prefix and its suffix are terminated
+	encodedKeyLen := 2*keyLen + 2*memKeyTerminatorLen + nogodb_common.InternalKeyTrailerLen
+	return uint64(nogodb_skl.MaxNodeSize(uint32(encodedKeyLen), uint32(valueLen)))
+}
+
+// Memtable key encoding \\
+
+// The ART orders its keys byte-wise, so the internal keys are encoded in a
+// way that the byte-wise order of the encoded keys follows the internal key
+// order, i.e. user keys in the order of the comparer, then trailers in
+// descending order. The user key is split into its prefix and its suffix (see
+// nogodb_common.IComparer.Split):
+//
+//	+------------------------+----------------+----------------+----------------------+
+//	| Escaped Prefix (N + e) | Terminator (2) | Suffix (S + e) | Inverted Trailer (8) |
+//	+------------------------+----------------+----------------+----------------------+
+//
+// The inverted trailer is encoded in big-endian. Each 0x00 of the prefix is
+// escaped as 0x00 0xFF, and the prefix is terminated by 0x00 0x01. Thus no
+// encoded prefix is the prefix of another one, and a prefix sorts before all
+// of its extensions, which is the order of the prefixes of any comparer.
+//
+// The suffix is encoded by the comparer if it implements
+// nogodb_common.ISuffixEncoder, e.g. nogodb_common.MVCCComparer sorts the
+// timestamps in descending order. Otherwise the suffixes are ordered
+// byte-wise, they're escaped and terminated like the prefix.
+//
+// The encoded keys are compared with compareMemKeys.
+
+const (
+	memKeyEscape        = 0xFF
+	memKeyTerminator    = 0x01
+	memKeyTerminatorLen = 2
+)
+
+func compareMemKeys(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
+// memKeyEncoder encodes the internal keys of the memtables ordered by cmp
+type memKeyEncoder struct {
+	cmp    nogodb_common.IComparer
+	suffix nogodb_common.ISuffixEncoder
+}
+
+func newMemKeyEncoder(cmp nogodb_common.IComparer) memKeyEncoder {
+	suffix, ok := cmp.(nogodb_common.ISuffixEncoder)
+	if !ok {
+		suffix = bytewiseSuffixEncoder{}
+	}
+
+	return memKeyEncoder{cmp: cmp, suffix: suffix}
+}
+
+func (e memKeyEncoder) encode(k *nogodb_common.InternalKey) []byte {
+	buf := make([]byte, 0, len(k.UserKey)+2*memKeyTerminatorLen+nogodb_common.InternalKeyTrailerLen)
+	buf = e.appendUserKey(buf, k.UserKey)
+	return binary.BigEndian.AppendUint64(buf, ^uint64(k.Trailer))
+}
+
+// encodeBound encodes a user key without trailer. The lower bound sorts
+// before all the versions of the user key, and the upper one sorts after all
+// of them.
+func (e memKeyEncoder) encodeBound(userKey []byte, upper bool) []byte {
+	buf := e.appendUserKey(make([]byte, 0, len(userKey)+2*memKeyTerminatorLen+nogodb_common.InternalKeyTrailerLen+1), userKey)
+	if upper {
+		// the encoded user keys are prefix-free, so the other user keys are
+		// ordered before the trailer, which is never longer
+		buf = append(buf, bytes.Repeat([]byte{0xFF}, nogodb_common.InternalKeyTrailerLen+1)...)
+	}
+	return buf
+}
+
+func (e memKeyEncoder) appendUserKey(buf, userKey []byte) []byte {
+	prefixLen := e.cmp.Split(userKey)
+	buf = appendEscaped(buf, userKey[:prefixLen])
+	return e.suffix.AppendEncodedSuffix(buf, userKey[prefixLen:])
+}
+
+func (e memKeyEncoder) decode(buf []byte) nogodb_common.InternalKey {
+	trailerOffset := len(buf) - nogodb_common.InternalKeyTrailerLen
+	userKey, n := appendUnescaped(make([]byte, 0, trailerOffset), buf[:trailerOffset])
+	userKey, _ = e.suffix.AppendDecodedSuffix(userKey, buf[n:trailerOffset])
+
+	return nogodb_common.InternalKey{
+		UserKey: userKey,
+		Trailer: nogodb_common.InternalKeyTrailer(^binary.BigEndian.Uint64(buf[trailerOffset:])),
+	}
+}
+
+// bytewiseSuffixEncoder orders the suffixes byte-wise, as the prefixes are
+type bytewiseSuffixEncoder struct{}
+
+func (bytewiseSuffixEncoder) AppendEncodedSuffix(dst, suffix []byte) []byte {
+	return appendEscaped(dst, suffix)
+}
+
+func (bytewiseSuffixEncoder) AppendDecodedSuffix(dst, src []byte) ([]byte, int) {
+	return appendUnescaped(dst, src)
+}
+
+var _ nogodb_common.ISuffixEncoder = bytewiseSuffixEncoder{}
+
+// appendEscaped appends b, whose 0x00 are escaped, then the terminator
+func appendEscaped(buf, b []byte) []byte {
+	for _, c := range b {
+		buf = append(buf, c)
+		if c == 0x00 {
+			buf = append(buf, memKeyEscape)
+		}
+	}
+	return append(buf, 0x00, memKeyTerminator)
+}
+
+// appendUnescaped appends the bytes escaped at the start of src, it returns
+// the length of their encoding, terminator included
+func appendUnescaped(dst, src []byte) ([]byte, int) {
+	i := 0
+	for ; i < len(src); i++ {
+		if src[i] != 0x00 {
+			dst = append(dst, src[i])
+			continue
+		}
+
+		i++
+		if src[i] == memKeyTerminator {
+			return dst, i + 1
+		}
+		dst = append(dst, 0x00)
+	}
+
+	return dst, i
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_art "github.com/datnguyenzzz/nogodb/lib/go-adaptive-radix-tree"
+	nogodb_skl "github.com/datnguyenzzz/nogodb/lib/go-skiplist"
+)
+
+// memTableIndex indexes the records of a memtable by their encoded internal
+// key (see memKeyEncoder). add is safe to be called concurrently.
+type memTableIndex interface {
+	add(key, value []byte) error
+	newIter(keys memKeyEncoder) nogodb_common.InternalIterator[nogodb_common.InternalKV]
+}
+
+func newMemTableIndex(kind options.MemTableKind, size uint64) memTableIndex {
+	switch kind {
+	case options.MemTableKindSkiplist:
+		// the head and tail nodes of the skiplist take their share of the arena
+		arenaSize := size + 2*uint64(nogodb_skl.MaxNodeSize(0, 0))
+		return &skiplistIndex{
+			list: nogodb_skl.NewSkiplist(nogodb_skl.NewArena(make([]byte, arenaSize)), compareMemKeys),
+		}
+	case options.MemTableKindART:
+		return &artIndex{tree: nogodb_art.NewTree[[]byte](context.Background())}
+	default:
+		panic("memTableIndex: unknown memtable kind")
+	}
+}
+
+// Implementations \\
+
+type artIndex struct {
+	tree nogodb_art.ITree[[]byte]
+}
+
+func (a *artIndex) add(key, value []byte) error {
+	// the batch buffer is recycled once the batch is closed,
+	// so the value must be copied
+	_, err := a.tree.Insert(context.Background(), key, append([]byte(nil), value...))
+	return err
+}
+
+func (a *artIndex) newIter(keys memKeyEncoder) nogodb_common.InternalIterator[nogodb_common.InternalKV] {
+	return &memIndexIter{keys: keys, cursor: artCursor{a.tree.NewIterator(context.Background())}}
+}
+
+var _ memTableIndex = (*artIndex)(nil)
+
+type skiplistIndex struct {
+	list *nogodb_skl.Skiplist
+}
+
+func (s *skiplistIndex) add(key, value []byte) error {
+	// the key and value are copied into the skiplist arena
+	return s.list.Add(key, value)
+}
+
+func (s *skiplistIndex) newIter(keys memKeyEncoder) nogodb_common.InternalIterator[nogodb_common.InternalKV] {
+	return &memIndexIter{keys: keys, cursor: s.list.NewIter()}
+}
+
+var _ memTableIndex = (*skiplistIndex)(nil)
+
+// memIndexCursor walks the encoded keys of a memtable index in order
+type memIndexCursor interface {
+	SeekGE(key []byte) bool
+	SeekLT(key []byte) bool
+	First() bool
+	Last() bool
+	Next() bool
+	Prev() bool
+	Key() []byte
+	Value() []byte
+}
+
+var _ memIndexCursor = (*nogodb_skl.Iterator)(nil)
+
+// artCursor adapts the ART iterator to a memIndexCursor
+type artCursor struct {
+	nogodb_art.Iterator[[]byte]
+}
+
+func (c artCursor) SeekGE(key []byte) bool { return c.Iterator.SeekGE(key) }
+func (c artCursor) SeekLT(key []byte) bool { return c.Iterator.SeekLT(key) }
+func (c artCursor) Key() []byte            { return c.Iterator.Key() }
+
+var _ memIndexCursor = artCursor{}
+
+// memIndexIter iterates lazily over a memtable index. It observes (some of)
+// the records added after its creation, which are filtered out by their
+// seqNum anyway.
+type memIndexIter struct {
+	keys   memKeyEncoder
+	cursor memIndexCursor
+	kv     nogodb_common.InternalKV
+	closed bool
+}
+
+func (i *memIndexIter) SeekGTE(key []byte) *nogodb_common.InternalKV {
+	return i.load(i.cursor.SeekGE(i.keys.encodeBound(key, false)))
+}
+
+func (i *memIndexIter) SeekPrefixGTE(prefix, key []byte) *nogodb_common.InternalKV {
+	// the memtable doesn't keep any filter, fall back to a plain seek
+	return i.SeekGTE(key)
+}
+
+func (i *memIndexIter) SeekLTE(key []byte) *nogodb_common.InternalKV {
+	return i.load(i.cursor.SeekLT(i.keys.encodeBound(key, true)))
+}
+
+func (i *memIndexIter) First() *nogodb_common.InternalKV {
+	return i.load(i.cursor.First())
+}
+
+func (i *memIndexIter) Last() *nogodb_common.InternalKV {
+	return i.load(i.cursor.Last())
+}
+
+func (i *memIndexIter) Next() *nogodb_common.InternalKV {
+	return i.load(i.cursor.Next())
+}
+
+func (i *memIndexIter) Prev() *nogodb_common.InternalKV {
+	return i.load(i.cursor.Prev())
+}
+
+func (i *memIndexIter) Close() error {
+	i.closed = true
+	return nil
+}
+
+func (i *memIndexIter) IsClosed() bool {
+	return i.closed
+}
+
+func (i *memIndexIter) load(valid bool) *nogodb_common.InternalKV {
+	if !valid {
+		return nil
+	}
+
+	i.kv = nogodb_common.InternalKV{
+		K: i.keys.decode(i.cursor.Key()),
+		V: nogodb_common.NewInPlaceInternalLazyValue(i.cursor.Value()),
+	}
+	return &i.kv
+}
+
+var _ nogodb_common.InternalIterator[nogodb_common.InternalKV] = (*memIndexIter)(nil)
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// suffixComparer splits the keys at their last '@', the suffixes are ordered
+// byte-wise. It doesn't implement nogodb_common.ISuffixEncoder.
+type suffixComparer struct {
+	nogodb_common.IComparer
+}
+
+func (c suffixComparer) Split(b []byte) int {
+	if i := bytes.LastIndexByte(b, '@'); i >= 0 {
+		return i
+	}
+	return len(b)
+}
+
+func (c suffixComparer) Compare(a, b []byte) int {
+	prefixA, prefixB := c.Split(a), c.Split(b)
+	if r := bytes.Compare(a[:prefixA], b[:prefixB]); r != 0 {
+		return r
+	}
+	return bytes.Compare(a[prefixA:], b[prefixB:])
+}
+
+func Test_MemKeyEncoder_Follows_InternalKey_Order(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmp      nogodb_common.IComparer
+		userKeys [][]byte
+	}{
+		{
+			name: "default",
+			cmp:  nogodb_common.NewComparer(),
+			userKeys: [][]byte{
+				{}, {0x00}, {0x00, 0x00}, {0x00, 0x01}, {0x00, 0xff}, {0x01},
+				[]byte("a"), []byte("a\x00"), []byte("a\x00b"), []byte("a\x01"), []byte("ab"),
+				{0xff}, {0xff, 0x00}, {0xff, 0xff},
+			},
+		},
+		{
+			name: "MVCC",
+			cmp:  nogodb_common.NewMVCCComparer(),
+			userKeys: [][]byte{
+				{}, []byte("a"), []byte("a\x05"),
+				nogodb_common.EncodeMVCCKey([]byte("a"), 0),
+				nogodb_common.EncodeMVCCKey([]byte("a"), 1),
+				nogodb_common.EncodeMVCCKey([]byte("a"), 256),
+				nogodb_common.EncodeMVCCKey([]byte("a"), 1<<40),
+				nogodb_common.EncodeMVCCKey([]byte("a\x00"), 1),
+				nogodb_common.EncodeMVCCKey([]byte("ab"), 0),
+				nogodb_common.EncodeMVCCKey([]byte("ab"), 7),
+				nogodb_common.EncodeMVCCKey([]byte{0xff}, 2),
+			},
+		},
+		{
+			name: "byte-wise suffixes",
+			cmp:  suffixComparer{nogodb_common.NewComparer()},
+			userKeys: [][]byte{
+				{}, []byte("@"), []byte("@\x00"), []byte("a"), []byte("a@"), []byte("a@\x00"),
+				[]byte("a@1"), []byte("a@10"), []byte("a@2"), []byte("a\x00@1"), []byte("ab@"),
+			},
+		},
+	}
+	seqNums := []nogodb_common.SeqNum{0, 1, 255, 256, 1 << 40, nogodb_common.SeqNumMax}
+	kinds := []nogodb_common.KeyKind{nogodb_common.KeyKindDelete, nogodb_common.KeyKindSet}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cmp, enc := tc.cmp, newMemKeyEncoder(tc.cmp)
+			var keys []nogodb_common.InternalKey
+			for _, uk := range tc.userKeys {
+				for _, seqNum := range seqNums {
+					for _, kind := range kinds {
+						keys = append(keys, nogodb_common.MakeKey(uk, seqNum, kind))
+					}
+				}
+			}
+
+			for i := range keys {
+				a := &keys[i]
+				encA := enc.encode(a)
+
+				decoded := enc.decode(encA)
+				require.True(t, bytes.Equal(a.UserKey, decoded.UserKey), "decode %q", a.UserKey)
+				require.Equal(t, a.Trailer, decoded.Trailer)
+
+				for j := range keys {
+					b := &keys[j]
+					require.Equal(t, sign(a.Compare(cmp, b)), sign(compareMemKeys(encA, enc.encode(b))),
+						"%q#%d vs %q#%d", a.UserKey, a.Trailer, b.UserKey, b.Trailer)
+				}
+
+				// the bounds enclose all the versions of the user key, and only them
+				lower := enc.encodeBound(a.UserKey, false)
+				upper := enc.encodeBound(a.UserKey, true)
+				for j := range keys {
+					b := &keys[j]
+					encB := enc.encode(b)
+					switch sign(cmp.Compare(a.UserKey, b.UserKey)) {
+					case 0:
+						require.Equal(t, -1, compareMemKeys(lower, encB))
+						require.Equal(t, 1, compareMemKeys(upper, encB))
+					case -1:
+						require.Equal(t, -1, compareMemKeys(upper, encB), "%q vs %q", a.UserKey, b.UserKey)
+					case 1:
+						require.Equal(t, 1, compareMemKeys(lower, encB), "%q vs %q", a.UserKey, b.UserKey)
+					}
+				}
+			}
+		})
+	}
+}
+
+func newTestBatch(t *testing.T, kvs ...string) *Batch {
+	t.Helper()
+	b := &Batch{}
+	for i := 0; i < len(kvs); i += 2 {
+		require.NoError(t, b.Set([]byte(kvs[i]), []byte(kvs[i+1])))
+	}
+	b.SetCountToHeader()
+	return b
+}
+
+func Test_MemTable_WriterRefs(t *testing.T) {
+	opt := options.DBOption{}
+	opt.SetDefault()
+
+	b := newTestBatch(t, "a", "1", "b", "2")
+	m, _ := newMemTable(opt, 2*b.memTableSize, 1, 1)
+	assert.False(t, m.readyForFlush(), "a mutable memtable is never flushed")
+
+	require.NoError(t, m.prepare(b))
+	require.NoError(t, m.prepare(b))
+	assert.Equal(t, 2*b.memTableSize, m.inuseBytes())
+	assert.ErrorIs(t, m.prepare(b), errMemTableFull)
+	assert.Equal(t, 2*b.memTableSize, m.inuseBytes(), "a rejected batch doesn't reserve anything")
+	assert.Equal(t, int32(3), m.writerRefs.Load())
+
+	// the memtable is rotated while the batches are being applied
+	assert.False(t, m.writerUnref())
+	assert.False(t, m.readyForFlush())
+
+	require.NoError(t, m.apply(b, 1))
+	assert.False(t, m.writerUnref())
+	assert.False(t, m.readyForFlush(), "a batch is still being applied")
+
+	require.NoError(t, m.apply(b, 3))
+	assert.True(t, m.writerUnref(), "the last applied batch drops the last ref")
+	assert.True(t, m.readyForFlush())
+
+	assert.Panics(t, func() { m.writerUnref() })
+}
+
+func Test_MemTable_Size_Ramp(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      uint64
+		wantSizes []uint64
+	}{
+		{
+			name:      "ramps up to the memtable size",
+			size:      2 << 20,
+			wantSizes: []uint64{256 << 10, 512 << 10, 1 << 20, 2 << 20, 2 << 20},
+		},
+		{
+			name:      "not a power of 2",
+			size:      600 << 10,
+			wantSizes: []uint64{256 << 10, 512 << 10, 600 << 10, 600 << 10},
+		},
+		{
+			name:      "smaller than the initial size",
+			size:      64 << 10,
+			wantSizes: []uint64{64 << 10, 64 << 10},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := openTestDB(t, func(opt *options.DBOption) {
+				opt.MemTable.Size = tc.size
+			})
+
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			holdFlushes(d)
+			for i, want := range tc.wantSizes {
+				if i > 0 {
+					require.NoError(t, d.rotateMemTable(nil))
+				}
+				assert.Equal(t, want, d.mu.mem.mutable.totalBytes(), "memtable #%d", i)
+			}
+			assert.Len(t, d.mu.mem.flushQueue, len(tc.wantSizes))
+		})
+	}
+}
+
+func Test_MemTable_Iter(t *testing.T) {
+	tests := []struct {
+		name string
+		kind options.MemTableKind
+	}{
+		{name: "ART", kind: options.MemTableKindART},
+		{name: "skiplist", kind: options.MemTableKindSkiplist},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opt := options.DBOption{}
+			opt.MemTable.Kind = tc.kind
+			opt.SetDefault()
+
+			// a few versions of random user keys, sharing their prefixes
+			rnd := rand.New(rand.NewPCG(1, 2))
+			b := &Batch{}
+			for range 500 {
+				uk := make([]byte, 1+rnd.IntN(4))
+				for i := range uk {
+					uk[i] = []byte{0x00, 'a', 'b', 0xff}[rnd.IntN(4)]
+				}
+				require.NoError(t, b.Set(uk, uk))
+			}
+			b.SetCountToHeader()
+			m, _ := newMemTable(opt, 1<<20, 1, 1)
+			require.NoError(t, m.prepare(b))
+			require.NoError(t, m.apply(b, 1))
+			m.writerUnref()
+
+			r, _, err := newBatchReader(b.buf)
+			require.NoError(t, err)
+			var want []nogodb_common.InternalKey
+			for seqNum := nogodb_common.SeqNum(1); ; seqNum++ {
+				kind, key, _, ok, err := r.next()
+				require.NoError(t, err)
+				if !ok {
+					break
+				}
+				want = append(want, nogodb_common.MakeKey(key, seqNum, kind))
+			}
+			slices.SortFunc(want, func(a, b nogodb_common.InternalKey) int {
+				return a.Compare(opt.Comparer, &b)
+			})
+
+			iter := m.newFlushIter()
+			defer func() { _ = iter.Close() }()
+
+			var got []nogodb_common.InternalKey
+			for kv := iter.First(); kv != nil; kv = iter.Next() {
+				got = append(got, kv.K)
+				assert.True(t, bytes.Equal(kv.K.UserKey, kv.V.Value()))
+			}
+			require.Equal(t, want, got)
+
+			got = got[:0]
+			for kv := iter.Last(); kv != nil; kv = iter.Prev() {
+				got = append(got, kv.K)
+			}
+			slices.Reverse(got)
+			require.Equal(t, want, got)
+
+			// SeekGTE lands on the latest version of the user key, SeekLTE
+			// on the oldest one
+			for i, k := range want {
+				kv := iter.SeekGTE(k.UserKey)
+				require.NotNil(t, kv)
+				first := slices.IndexFunc(want, func(w nogodb_common.InternalKey) bool {
+					return bytes.Equal(w.UserKey, k.UserKey)
+				})
+				require.Equal(t, want[first], kv.K)
+
+				kv = iter.SeekLTE(k.UserKey)
+				require.NotNil(t, kv)
+				last := i
+				for last+1 < len(want) && bytes.Equal(want[last+1].UserKey, k.UserKey) {
+					last++
+				}
+				require.Equal(t, want[last], kv.K)
+			}
+			assert.Nil(t, iter.SeekGTE([]byte{0xff, 0xff, 0xff, 0xff, 0xff}))
+			assert.Nil(t, iter.SeekLTE([]byte{}))
+		})
+	}
+}
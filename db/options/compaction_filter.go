@@ -0,0 +1,137 @@
+package options
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// CompactionFilterDecision is the decision of a CompactionFilter on a key
+type CompactionFilterDecision uint8
+
+const (
+	// CompactionFilterKeep keeps the key as is
+	CompactionFilterKeep CompactionFilterDecision = iota
+	// CompactionFilterRemove removes the key. Unless the compaction is
+	// bottom-most, the key is replaced by a tombstone, so that its older
+	// versions in the lower levels are shadowed.
+	CompactionFilterRemove
+	// CompactionFilterChangeValue replaces the value of the key
+	CompactionFilterChangeValue
+)
+
+var compactionFilterDecisionToString = map[CompactionFilterDecision]string{
+	CompactionFilterKeep:        "keep",
+	CompactionFilterRemove:      "remove",
+	CompactionFilterChangeValue: "change-value",
+}
+
+func (d CompactionFilterDecision) String() string {
+	return compactionFilterDecisionToString[d]
+}
+
+// CompactionFilterContext describes the compaction invoking the filter
+type CompactionFilterContext struct {
+	// Level is the output level of the compaction, 0 for a flush
+	Level int
+	// BottomMost is true if there is no older data below the output level,
+	// i.e. a removed key doesn't need a tombstone
+	BottomMost bool
+}
+
+// CompactionFilter is invoked by the compactions (flushes included) for the
+// latest version of each key surviving them, it allows to garbage collect
+// the keys (e.g. TTL) or to rewrite their values, at no extra I/O cost. The
+// versions still visible to an open snapshot are not filtered.
+//
+// A filter is invoked concurrently by the compactions, it must be
+// thread-safe. Its decisions should be deterministic, since a key might be
+// filtered several times as it moves through the levels.
+type CompactionFilter interface {
+	// Name identifies the filter in the logs
+	Name() string
+	// Filter decides what to do with the key. newValue is only used with
+	// CompactionFilterChangeValue.
+	Filter(ctx CompactionFilterContext, key, value []byte) (decision CompactionFilterDecision, newValue []byte)
+}
+
+// TTL \\
+
+// The values written with a TTL are prefixed by their expiry time, in unix
+// seconds (big-endian). A zero expiry time never expires.
+//
+//	+--------------+---------+
+//	| ExpireAt (8) |  Value  |
+//	+--------------+---------+
+const ttlValuePrefixLen = 8
+
+// EncodeTTLValue prefixes the value with its expiry time, to be garbage
+// collected by the TTL compaction filter. A zero expireAt never expires.
+func EncodeTTLValue(value []byte, expireAt time.Time) []byte {
+	var ts uint64
+	if !expireAt.IsZero() {
+		ts = uint64(expireAt.Unix())
+	}
+
+	buf := make([]byte, ttlValuePrefixLen, ttlValuePrefixLen+len(value))
+	binary.BigEndian.PutUint64(buf, ts)
+	return append(buf, value...)
+}
+
+// DecodeTTLValue returns the value and the expiry time encoded by
+// EncodeTTLValue. ok is false if v is too short to be a TTL value.
+//
+// Note: the expired values are visible until they're compacted, the readers
+// should check the expiry time.
+func DecodeTTLValue(v []byte) (value []byte, expireAt time.Time, ok bool) {
+	if len(v) < ttlValuePrefixLen {
+		return nil, time.Time{}, false
+	}
+
+	if ts := binary.BigEndian.Uint64(v); ts > 0 {
+		expireAt = time.Unix(int64(ts), 0)
+	}
+
+	return v[ttlValuePrefixLen:], expireAt, true
+}
+
+type TTLFilterOptionFn func(*ttlCompactionFilter)
+
+// WithTTLClock sets the clock the expiry times are compared with
+func WithTTLClock(now func() time.Time) TTLFilterOptionFn {
+	return func(f *ttlCompactionFilter) {
+		f.now = now
+	}
+}
+
+// ttlCompactionFilter removes the values whose expiry time, encoded by
+// EncodeTTLValue, has passed
+type ttlCompactionFilter struct {
+	now func() time.Time
+}
+
+// NewTTLCompactionFilter returns a CompactionFilter removing the expired
+// values, see EncodeTTLValue. All the values of the DB must be encoded with
+// EncodeTTLValue.
+func NewTTLCompactionFilter(opts ...TTLFilterOptionFn) CompactionFilter {
+	f := &ttlCompactionFilter{now: time.Now}
+	for _, o := range opts {
+		o(f)
+	}
+
+	return f
+}
+
+func (f *ttlCompactionFilter) Name() string {
+	return "nogodb.TTLCompactionFilter"
+}
+
+func (f *ttlCompactionFilter) Filter(_ CompactionFilterContext, _, value []byte) (CompactionFilterDecision, []byte) {
+	_, expireAt, ok := DecodeTTLValue(value)
+	if !ok || expireAt.IsZero() || f.now().Before(expireAt) {
+		return CompactionFilterKeep, nil
+	}
+
+	return CompactionFilterRemove, nil
+}
+
+var _ CompactionFilter = (*ttlCompactionFilter)(nil)
@@ -0,0 +1,70 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TTLValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    []byte
+		expireAt time.Time
+	}{
+		{name: "expiring", value: []byte("value"), expireAt: time.Unix(1_000, 0)},
+		{name: "never expiring", value: []byte("value"), expireAt: time.Time{}},
+		{name: "empty value", value: []byte{}, expireAt: time.Unix(1_000, 0)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := EncodeTTLValue(tc.value, tc.expireAt)
+			assert.Len(t, encoded, ttlValuePrefixLen+len(tc.value))
+
+			value, expireAt, ok := DecodeTTLValue(encoded)
+			assert.True(t, ok)
+			assert.Equal(t, tc.value, value)
+			assert.True(t, tc.expireAt.Equal(expireAt))
+			assert.Equal(t, tc.expireAt.IsZero(), expireAt.IsZero())
+		})
+	}
+
+	_, _, ok := DecodeTTLValue([]byte("short"))
+	assert.False(t, ok, "too short to be a TTL value")
+}
+
+func Test_TTLCompactionFilter(t *testing.T) {
+	now := time.Unix(1_000, 0)
+	filter := NewTTLCompactionFilter(WithTTLClock(func() time.Time { return now }))
+	assert.Equal(t, "nogodb.TTLCompactionFilter", filter.Name())
+
+	tests := []struct {
+		name  string
+		value []byte
+		want  CompactionFilterDecision
+	}{
+		{name: "expired", value: EncodeTTLValue([]byte("v"), now.Add(-time.Second)), want: CompactionFilterRemove},
+		{name: "expiring now", value: EncodeTTLValue([]byte("v"), now), want: CompactionFilterRemove},
+		{name: "not expired", value: EncodeTTLValue([]byte("v"), now.Add(time.Second)), want: CompactionFilterKeep},
+		{name: "never expiring", value: EncodeTTLValue([]byte("v"), time.Time{}), want: CompactionFilterKeep},
+		{name: "too short", value: []byte("v"), want: CompactionFilterKeep},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, ctx := range []CompactionFilterContext{{Level: 0}, {Level: 6, BottomMost: true}} {
+				decision, newValue := filter.Filter(ctx, []byte("key"), tc.value)
+				assert.Equal(t, tc.want, decision)
+				assert.Nil(t, newValue)
+			}
+		})
+	}
+}
+
+func Test_CompactionFilterDecision_String(t *testing.T) {
+	assert.Equal(t, "keep", CompactionFilterKeep.String())
+	assert.Equal(t, "remove", CompactionFilterRemove.String())
+	assert.Equal(t, "change-value", CompactionFilterChangeValue.String())
+}
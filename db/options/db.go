@@ -1,12 +1,64 @@
 package options
 
 import (
+	"time"
+
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	nogodb_block_cache "github.com/datnguyenzzz/nogodb/lib/go-block-cache"
 	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/filter"
+	sst_options "github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
 	"golang.org/x/sync/semaphore"
 )
 
+// MemTableKind is the data structure indexing the records of a memtable
+type MemTableKind byte
+
+const (
+	MemTableKindUnknown MemTableKind = iota
+	// MemTableKindART uses the adaptive radix tree (lib/go-adaptive-radix-tree)
+	MemTableKindART
+	// MemTableKindSkiplist uses the lock-free, arena-allocated skiplist
+	// (lib/go-skiplist)
+	MemTableKindSkiplist
+)
+
+// SyncModeKind is the durability mode of the WAL, see SyncMode
+type SyncModeKind byte
+
+const (
+	SyncModeKindUnknown SyncModeKind = iota
+	// SyncModeKindEveryCommit syncs the WAL before acknowledging each commit
+	SyncModeKindEveryCommit
+	// SyncModeKindInterval syncs the WAL periodically in background
+	SyncModeKindInterval
+	// SyncModeKindNone never syncs the WAL on the commit path
+	SyncModeKindNone
+)
+
+// SyncMode controls when the WAL is synced, trading the durability of the
+// acknowledged commits for their latency
+type SyncMode struct {
+	Kind SyncModeKind
+	// Interval is the period of the background syncs, with SyncModeKindInterval
+	Interval time.Duration
+}
+
+var (
+	// SyncEveryCommit syncs the WAL before acknowledging each commit, an
+	// acknowledged commit is always durable.
+	SyncEveryCommit = SyncMode{Kind: SyncModeKindEveryCommit}
+	// NoSync doesn't sync the WAL on the commit path, it's only synced when
+	// rotated, or by DB.SyncWAL(). It suits the bulk loads.
+	NoSync = SyncMode{Kind: SyncModeKindNone}
+)
+
+// SyncInterval syncs the WAL every d in background. The commits acknowledged
+// within the last d might be lost on a crash, unless DB.SyncWAL() is called.
+func SyncInterval(d time.Duration) SyncMode {
+	return SyncMode{Kind: SyncModeKindInterval, Interval: d}
+}
+
 // Options holds the optional parameters for configuring nogodb, at db level
 type DBOption struct {
 	SST struct {
@@ -28,12 +80,64 @@ type DBOption struct {
 		// specified percentage of the target block size and adding the next entry
 		// would cause the block to be larger than the target block size.
 		BlockSizeThreshold int // Default: 90
+
+		// FilterMethodByLevel is the filtering method of the sstables written
+		// to each level, the levels past the end use the last method, e.g.
+		// {BloomFilter, BloomFilter, RibbonFilter} bloom filters L0 and L1,
+		// and ribbon filters the deeper levels.
+		FilterMethodByLevel []filter.Method // Default: {BloomFilter}
+
+		// FilterBitsPerKey is the number of bits per key spent by the filter
+		// of each sstable, the more bits the lower the false positive rate.
+		FilterBitsPerKey int // Default: 10
+
+		// MonkeyFilterBits allocates the filter bits per key of each level
+		// from the level size, as in Monkey. FilterBitsPerKey is then the
+		// average across the levels: the small upper levels get more bits per
+		// key and the bottom level gets fewer, which minimizes the false
+		// positives of a point lookup for the same filter memory.
+		MonkeyFilterBits bool
+
+		// PartitionedFilter partitions the filter of each sstable along with
+		// its index blocks, the partitions are then loaded on demand through
+		// the block cache. It suits the large tables.
+		PartitionedFilter bool
+
+		// PrefixExtractor, if set, adds the extracted prefixes of the keys to
+		// the filter of each sstable, e.g. the table and partition IDs of the
+		// rows, so that the prefix scans of an sstable (see its SeekPrefixGTE)
+		// skip it if it doesn't have the prefix. It mustn't be changed over the
+		// lifetime of the DB, the sstables written with another extractor
+		// wouldn't be filtered.
+		PrefixExtractor sst_options.PrefixExtractor
 	}
 
 	// Cache is used to cache uncompressed blocks from sstables.
 	Cache struct {
+		// Type is the replacement policy of the block cache, e.g. TinyLFU
+		// keeps the frequently read blocks through the long range scans.
 		Type nogodb_block_cache.CacheType
 		Size int64
+
+		// HighPriorityPoolRatio is the share of the cache reserved to the
+		// index and filter blocks, which are cached with a high priority.
+		// Defaults to the go-block-cache default if zero.
+		HighPriorityPoolRatio float64
+
+		// PinL0MetaBlocks pins the index and filter blocks of the L0 sstables
+		// in the cache for the lifetime of the tables. Every lookup probes all
+		// the L0 sstables, hence their meta blocks mustn't be evicted.
+		PinL0MetaBlocks bool
+
+		// PersistentDir, if set, is the local directory of the secondary
+		// block cache: the blocks evicted from the memory spill into it, and
+		// are read from it before the sstables. It's essential once the
+		// sstables live on a remote object storage. The directory must be
+		// dedicated to the DB, it's recovered on restart.
+		PersistentDir string
+		// PersistentSize is the capacity of the secondary block cache.
+		// Defaults to 1 GiB if zero.
+		PersistentSize int64
 	}
 
 	// LoadBlockSema, if set, is used to limit the number of blocks that can be
@@ -42,7 +146,10 @@ type DBOption struct {
 
 	// Comparer defines a total ordering over the space of []byte keys: a 'less
 	// than' relationship. The same comparison algorithm must be used for reads
-	// and writes over the lifetime of the DB.
+	// and writes over the lifetime of the DB. The comparers which don't order
+	// the suffixes of the keys byte-wise must implement
+	// nogodb_common.ISuffixEncoder, e.g. nogodb_common.MVCCComparer, so that
+	// the memtables order them alike.
 	Comparer nogodb_common.IComparer
 
 	// FS provides the interface for persistent file storage.
@@ -66,11 +173,52 @@ type DBOption struct {
 		// MemTableSize. This reduces the memory pressure caused by MemTables for
 		// short lived (test) DB instances.
 		Size uint64 // The default value is 4MB.
+
+		// Kind selects the data structure indexing the memtable records.
+		Kind MemTableKind // Default: MemTableKindART
 	}
 
 	WAL struct {
 		Dir          string
 		BytesPerSync int64 // Default: 256 KiB
+
+		// MaxRecycledFiles is the maximum number of obsolete WAL files kept for
+		// being reused by the next WALs, which saves the file allocation and the
+		// metadata syncs on each memtable rotation. A negative value disables
+		// the recycling, since 0 is taken for the default.
+		MaxRecycledFiles int // Default: 4
+
+		// SecondaryDir, if set, is the directory the WAL fails over to when the
+		// primary directory (Dir) stalls or fails, e.g. on a degraded disk.
+		SecondaryDir string
+		// FailoverThreshold is the latency of a WAL write from which the primary
+		// directory is considered stalled. Only used with SecondaryDir.
+		FailoverThreshold time.Duration // Default: 200ms
+
+		// SyncMode controls when the WAL is synced, see SyncEveryCommit,
+		// SyncInterval and NoSync.
+		SyncMode SyncMode // Default: SyncEveryCommit
+	}
+
+	// CompactionFilter, if set, is invoked by the compactions for each
+	// surviving key, see CompactionFilter and NewTTLCompactionFilter.
+	CompactionFilter CompactionFilter
+
+	// CDC configures the change data capture streams, see DB.Subscribe
+	CDC struct {
+		// SubscriberBufferSize is the number of committed batches buffered for
+		// a subscriber which doesn't keep up with the commits. Once it's full,
+		// the subscriber falls back to reading the batches from the WALs.
+		SubscriberBufferSize int // Default: 1024
+	}
+
+	// BackgroundErrorRetry controls how the retryable errors raised by the
+	// background jobs (e.g. ENOSPC while flushing) are retried. Once the retries
+	// are exhausted, the DB stops accepting writes until DB.Resume() is called.
+	BackgroundErrorRetry struct {
+		MaxRetries     int           // Default: 8
+		InitialBackoff time.Duration // Default: 100ms
+		MaxBackoff     time.Duration // Default: 10s
 	}
 
 	Logger nogodb_common.Logger
@@ -108,6 +256,10 @@ func (o *DBOption) SetDefault() {
 		o.MemTable.Size = 4 << 20 // 4 MB
 	}
 
+	if o.MemTable.Kind == MemTableKindUnknown {
+		o.MemTable.Kind = MemTableKindART
+	}
+
 	if len(o.SST.Dir) == 0 {
 		o.SST.Dir = "./nogodb/sst"
 	}
@@ -128,6 +280,14 @@ func (o *DBOption) SetDefault() {
 		o.SST.BlockSizeThreshold = 90
 	}
 
+	if len(o.SST.FilterMethodByLevel) == 0 {
+		o.SST.FilterMethodByLevel = []filter.Method{filter.BloomFilter}
+	}
+
+	if o.SST.FilterBitsPerKey == 0 {
+		o.SST.FilterBitsPerKey = 10
+	}
+
 	if len(o.WAL.Dir) == 0 {
 		o.WAL.Dir = "./nogodb/wal"
 	}
@@ -136,7 +296,52 @@ func (o *DBOption) SetDefault() {
 		o.WAL.BytesPerSync = 256 * 1024
 	}
 
+	if o.WAL.MaxRecycledFiles == 0 {
+		o.WAL.MaxRecycledFiles = 4
+	}
+
+	if o.WAL.FailoverThreshold == 0 {
+		o.WAL.FailoverThreshold = 200 * time.Millisecond
+	}
+
+	if o.WAL.SyncMode.Kind == SyncModeKindUnknown {
+		o.WAL.SyncMode = SyncEveryCommit
+	}
+
+	if o.WAL.SyncMode.Kind == SyncModeKindInterval && o.WAL.SyncMode.Interval <= 0 {
+		o.WAL.SyncMode.Interval = 100 * time.Millisecond
+	}
+
+	if o.CDC.SubscriberBufferSize <= 0 {
+		o.CDC.SubscriberBufferSize = 1024
+	}
+
+	if o.BackgroundErrorRetry.MaxRetries == 0 {
+		o.BackgroundErrorRetry.MaxRetries = 8
+	}
+
+	if o.BackgroundErrorRetry.InitialBackoff == 0 {
+		o.BackgroundErrorRetry.InitialBackoff = 100 * time.Millisecond
+	}
+
+	if o.BackgroundErrorRetry.MaxBackoff == 0 {
+		o.BackgroundErrorRetry.MaxBackoff = 10 * time.Second
+	}
+
 	if o.Logger == nil {
 		o.Logger = nogodb_common.DefaultLogger
 	}
 }
+
+// FilterMethod returns the filtering method of the sstables written to the
+// given level, see SST.FilterMethodByLevel
+func (o *DBOption) FilterMethod(level int) filter.Method {
+	methods := o.SST.FilterMethodByLevel
+	if len(methods) == 0 {
+		return filter.BloomFilter
+	}
+	if level >= len(methods) {
+		return methods[len(methods)-1]
+	}
+	return methods[level]
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+var (
+	// ErrReplicationGap is returned by DB.ApplyReplicated when a replicated
+	// batch doesn't follow the last applied one
+	ErrReplicationGap = errors.New("nogodb: replicated batch is not contiguous")
+
+	errFollowerWrite     = fmt.Errorf("%w: the DB is a replication follower", ErrReadOnly)
+	errReplicatedAlready = errors.New("nogodb: replicated batch is applied already")
+	errFollowerNotEmpty  = errors.New("nogodb: a checkpoint is only applied to an empty follower")
+)
+
+// SetFollowerMode switches the DB to the follower mode, or promotes it back to
+// a writable DB. In follower mode, the writes are rejected with ErrReadOnly,
+// the DB only applies the batches replicated from its primary.
+func (d *DB) SetFollowerMode(follower bool) {
+	d.follower.Store(follower)
+}
+
+// IsFollower returns true if the DB is in follower mode
+func (d *DB) IsFollower() bool {
+	return d.follower.Load()
+}
+
+// VisibleSeqNum returns the sequence number following the last committed batch
+func (d *DB) VisibleSeqNum() nogodb_common.SeqNum {
+	d.commit.mu.Lock()
+	defer d.commit.mu.Unlock()
+
+	return d.commit.visibleSeqNum
+}
+
+// ApplyReplicated applies a batch committed by the primary, given its
+// representation (see CommittedBatch.Repr), with the same seqnum. It goes
+// through the commit path, so the batch is written to the WAL of the follower
+// then applied to its memtable. The batches must be applied in order: a
+// batch applied already is ignored, and ErrReplicationGap is returned if
+// some batches are missing in between.
+func (d *DB) ApplyReplicated(repr []byte) error {
+	r, h, err := newBatchReader(repr)
+	if err != nil {
+		return err
+	}
+
+	if h.Count == 0 {
+		return nil
+	}
+
+	b := newBatch(d, false)
+	defer b.Close()
+
+	for {
+		kind, key, value, ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		b.put(key, value, kind)
+	}
+
+	if b.count != h.Count {
+		return errInvalidBatch
+	}
+	b.SetSeqNumToHeader(uint64(h.SeqNum))
+	b.replicated = true
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+
+	b.committing.Store(true)
+	if err := d.commit.Commit(b); err != nil && !errors.Is(err, errReplicatedAlready) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	nogodb_wal "github.com/datnguyenzzz/nogodb/lib/go-wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingLogWriter fails the writes to the WAL
+type failingLogWriter struct {
+	nogodb_wal.ILogWriter
+}
+
+func (failingLogWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("injected WAL failure")
+}
+
+func Test_ApplyReplicated_Failed_WAL_Write_Leaves_No_Gap(t *testing.T) {
+	primary := openTestDB(t, nil)
+	follower := openTestDB(t, nil)
+	follower.SetFollowerMode(true)
+
+	var reprs [][]byte
+	for i := range 3 {
+		b := newBatch(primary, false)
+		require.NoError(t, b.Set([]byte(fmt.Sprintf("key_%d", i)), []byte("value")))
+		require.NoError(t, primary.apply(b))
+		reprs = append(reprs, bytes.Clone(b.buf))
+		require.NoError(t, b.Close())
+	}
+
+	require.NoError(t, follower.ApplyReplicated(reprs[0]))
+
+	follower.mu.Lock()
+	writer := follower.mu.log.writer
+	follower.mu.log.writer = failingLogWriter{writer}
+	follower.mu.Unlock()
+
+	require.Error(t, follower.ApplyReplicated(reprs[1]))
+	h, _ := readHeader(reprs[1])
+	assert.Equal(t, h.SeqNum, follower.VisibleSeqNum(), "the failed batch is not visible")
+
+	// the batch is applied once the WAL recovers, instead of being taken
+	// for one applied already
+	follower.mu.Lock()
+	follower.mu.log.writer = writer
+	follower.mu.Unlock()
+	require.NoError(t, follower.ApplyReplicated(reprs[1]))
+	require.NoError(t, follower.ApplyReplicated(reprs[2]))
+	assert.Equal(t, primary.VisibleSeqNum(), follower.VisibleSeqNum())
+
+	assert.Equal(t, dbEntries(t, primary), dbEntries(t, follower))
+}
@@ -0,0 +1,218 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	nogodb "github.com/datnguyenzzz/nogodb/db"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+type FollowerOptionFn func(*Follower)
+
+// WithFollowerCheckpointDir sets the directory under which the checkpoint
+// bootstrapping the follower is received before being applied, os.TempDir()
+// by default. The sstables are hard-linked into the DB, rather than copied,
+// if it's on the same file system as the DB.
+func WithFollowerCheckpointDir(dir string) FollowerOptionFn {
+	return func(f *Follower) {
+		f.checkpointDir = dir
+	}
+}
+
+// Follower applies the batches streamed by a primary to a DB, with the same
+// seqnums. The batches go through the commit path of the follower DB, so they
+// are written to its own WAL then applied to its memtable. The follower DB is
+// read-only until it's promoted, which makes it a warm standby.
+type Follower struct {
+	db            *nogodb.DB
+	checkpointDir string
+
+	// primarySeqNum is the latest visible seqnum reported by the primary
+	primarySeqNum atomic.Uint64
+	// lastContact is the time (unix nano) of the latest message from the primary
+	lastContact atomic.Int64
+}
+
+// FollowerStats reports the replication lag of a follower
+type FollowerStats struct {
+	AppliedSeqNum nogodb_common.SeqNum
+	PrimarySeqNum nogodb_common.SeqNum
+	// Lag is the number of seqnums committed by the primary, but not yet
+	// applied by the follower
+	Lag uint64
+	// SinceLastContact is the time elapsed since the latest message from the
+	// primary, zero if it has never been contacted
+	SinceLastContact time.Duration
+}
+
+// NewFollower switches the DB to the follower mode, see DB.SetFollowerMode
+func NewFollower(db *nogodb.DB, opts ...FollowerOptionFn) *Follower {
+	db.SetFollowerMode(true)
+	f := &Follower{
+		db:            db,
+		checkpointDir: os.TempDir(),
+	}
+	for _, o := range opts {
+		o(f)
+	}
+
+	return f
+}
+
+// Run replicates the primary connected with rw, from the last batch applied
+// by the follower, until ctx is done or the transport fails. It can be called
+// again to reconnect. An empty follower is bootstrapped from a checkpoint of
+// the primary if needed, ErrSnapshotRequired is returned if a follower which
+// isn't empty is too far behind the primary.
+func (f *Follower) Run(ctx context.Context, rw io.ReadWriter) error {
+	defer unblockOnDone(ctx, rw)()
+
+	// cpDir receives the checkpoint bootstrapping the follower, if any
+	var cpDir string
+	defer func() {
+		if cpDir != "" {
+			_ = os.RemoveAll(cpDir)
+		}
+	}()
+
+	c := newConn(rw)
+	if err := c.writeSeqNum(msgTypeHello, f.db.VisibleSeqNum()); err != nil {
+		return f.runErr(ctx, err)
+	}
+
+	for {
+		t, payload, err := c.read()
+		if err != nil {
+			return f.runErr(ctx, err)
+		}
+		f.lastContact.Store(time.Now().UnixNano())
+
+		switch t {
+		case msgTypeBatch:
+			if err := f.db.ApplyReplicated(payload); err != nil {
+				return err
+			}
+
+			applied := f.db.VisibleSeqNum()
+			f.ratchetPrimarySeqNum(applied)
+			if err := c.writeSeqNum(msgTypeAck, applied); err != nil {
+				return f.runErr(ctx, err)
+			}
+		case msgTypeCheckpointFile:
+			if cpDir == "" {
+				if cpDir, err = os.MkdirTemp(f.checkpointDir, "nogodb-checkpoint-"); err != nil {
+					return err
+				}
+			}
+			if err := appendCheckpointChunk(cpDir, payload); err != nil {
+				return err
+			}
+		case msgTypeCheckpoint:
+			seqNum, err := decodeSeqNum(t, payload)
+			if err != nil {
+				return err
+			}
+			if cpDir == "" {
+				return fmt.Errorf("%w: empty checkpoint at %d", errInvalidMessage, seqNum)
+			}
+			if err := f.db.ApplyCheckpoint(cpDir); err != nil {
+				return err
+			}
+			_ = os.RemoveAll(cpDir)
+			cpDir = ""
+
+			applied := f.db.VisibleSeqNum()
+			f.ratchetPrimarySeqNum(applied)
+			if err := c.writeSeqNum(msgTypeAck, applied); err != nil {
+				return f.runErr(ctx, err)
+			}
+		case msgTypeHeartbeat:
+			seqNum, err := decodeSeqNum(t, payload)
+			if err != nil {
+				return err
+			}
+			f.ratchetPrimarySeqNum(seqNum)
+		case msgTypeError:
+			return decodeError(payload)
+		default:
+			return fmt.Errorf("%w: unexpected %s message", errInvalidMessage, t)
+		}
+	}
+}
+
+// appendCheckpointChunk appends a chunk of a checkpoint file to the file of
+// the checkpoint received into dir
+func appendCheckpointChunk(dir string, payload []byte) error {
+	name, chunk, err := decodeCheckpointChunk(payload)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	// the sstables are linked into the DB as they are, they must be durable
+	if _, err := file.Write(chunk); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// runErr returns the context error if the transport has been interrupted
+// because ctx is done
+func (f *Follower) runErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+func (f *Follower) ratchetPrimarySeqNum(seqNum nogodb_common.SeqNum) {
+	for {
+		curr := f.primarySeqNum.Load()
+		if uint64(seqNum) <= curr || f.primarySeqNum.CompareAndSwap(curr, uint64(seqNum)) {
+			return
+		}
+	}
+}
+
+// Promote makes the follower DB writable, e.g. when its primary is lost. Run
+// must not be called anymore.
+func (f *Follower) Promote() {
+	f.db.SetFollowerMode(false)
+}
+
+// Stats returns the replication lag of the follower
+func (f *Follower) Stats() FollowerStats {
+	applied := f.db.VisibleSeqNum()
+	primary := nogodb_common.SeqNum(f.primarySeqNum.Load())
+
+	stats := FollowerStats{
+		AppliedSeqNum: applied,
+		PrimarySeqNum: primary,
+		Lag:           uint64(primary - min(applied, primary)),
+	}
+	if lastContact := f.lastContact.Load(); lastContact > 0 {
+		stats.SinceLastContact = time.Since(time.Unix(0, lastContact))
+	}
+
+	return stats
+}
@@ -0,0 +1,312 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	nogodb "github.com/datnguyenzzz/nogodb/db"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+const defaultHeartbeatInterval = time.Second
+
+type PrimaryOptionFn func(*Primary)
+
+// WithHeartbeatInterval sets the interval at which the primary sends its
+// visible seqnum to the followers, so that they can report their lag
+func WithHeartbeatInterval(d time.Duration) PrimaryOptionFn {
+	return func(p *Primary) {
+		p.heartbeatInterval = d
+	}
+}
+
+// WithPrimaryCheckpointDir sets the directory under which the checkpoints
+// bootstrapping the followers are written before being sent, os.TempDir() by
+// default. The sstables are hard-linked into the checkpoints, rather than
+// copied, if it's on the same file system as the DB.
+func WithPrimaryCheckpointDir(dir string) PrimaryOptionFn {
+	return func(p *Primary) {
+		p.checkpointDir = dir
+	}
+}
+
+// Primary streams the batches committed to a DB to its followers. Each
+// follower is served by a Serve call, over its own transport.
+type Primary struct {
+	db                *nogodb.DB
+	heartbeatInterval time.Duration
+	checkpointDir     string
+
+	mu struct {
+		sync.Mutex
+		sessions map[*session]struct{}
+	}
+}
+
+// session is the state of a follower served by the primary
+type session struct {
+	// sentSeqNum is the seqnum following the last batch sent to the follower
+	sentSeqNum atomic.Uint64
+	// ackedSeqNum is the seqnum following the last batch applied by the follower
+	ackedSeqNum atomic.Uint64
+}
+
+// SessionStats reports the replication lag of a follower, as seen by the
+// primary
+type SessionStats struct {
+	SentSeqNum  nogodb_common.SeqNum
+	AckedSeqNum nogodb_common.SeqNum
+	// Lag is the number of seqnums committed by the primary, but not yet
+	// applied by the follower
+	Lag uint64
+}
+
+func NewPrimary(db *nogodb.DB, opts ...PrimaryOptionFn) *Primary {
+	p := &Primary{
+		db:                db,
+		heartbeatInterval: defaultHeartbeatInterval,
+		checkpointDir:     os.TempDir(),
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	p.mu.sessions = make(map[*session]struct{})
+
+	return p
+}
+
+// Serve streams the committed batches to the follower connected with rw,
+// until ctx is done or the transport fails. An empty follower is bootstrapped
+// from a checkpoint if the batches it needs have been garbage collected. A
+// follower which isn't empty is told to be rebuilt, and ErrSnapshotRequired
+// is returned.
+func (p *Primary) Serve(ctx context.Context, rw io.ReadWriter) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer unblockOnDone(ctx, rw)()
+
+	c := newConn(rw)
+	t, payload, err := c.read()
+	if err != nil {
+		return err
+	}
+	if t != msgTypeHello {
+		return fmt.Errorf("%w: expected %s, got %s", errInvalidMessage, msgTypeHello, t)
+	}
+	from, err := decodeSeqNum(t, payload)
+	if err != nil {
+		return err
+	}
+
+	var cpSeqNum nogodb_common.SeqNum
+	var cpDir string
+	sub, err := p.db.Subscribe(ctx, from)
+	if errors.Is(err, nogodb.ErrSeqNumGarbageCollected) && from == 0 {
+		sub, cpDir, cpSeqNum, err = p.checkpoint(ctx)
+	}
+	if errors.Is(err, nogodb.ErrSeqNumGarbageCollected) {
+		_ = c.writeError(errCodeSnapshotRequired, err)
+		return fmt.Errorf("%w: %w", ErrSnapshotRequired, err)
+	}
+	if err != nil {
+		_ = c.writeError(errCodeInternal, err)
+		return err
+	}
+	defer sub.Close()
+
+	s := &session{}
+	s.sentSeqNum.Store(uint64(from))
+	s.ackedSeqNum.Store(uint64(from))
+	p.mu.Lock()
+	p.mu.sessions[s] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.mu.sessions, s)
+		p.mu.Unlock()
+	}()
+
+	// the acks are read in background, the reads don't stop with ctx unless
+	// the transport supports deadlines
+	go func() {
+		cancel(p.readAcks(c, s))
+	}()
+	go p.heartbeat(ctx, cancel, c)
+
+	if cpDir != "" {
+		// the batches are streamed from the subscription from then on
+		err := p.sendCheckpoint(c, cpDir, cpSeqNum, s)
+		_ = os.RemoveAll(cpDir)
+		if err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				return cause
+			}
+			return err
+		}
+	}
+
+	for {
+		cb, err := sub.Next()
+		if err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				return cause
+			}
+			return err
+		}
+		if cb.SeqNum < cpSeqNum {
+			// part of the checkpoint already
+			continue
+		}
+
+		if err := c.write(msgTypeBatch, cb.Repr()); err != nil {
+			return err
+		}
+		s.sentSeqNum.Store(uint64(cb.SeqNum) + uint64(len(cb.Ops)))
+	}
+}
+
+// checkpoint subscribes to the batches committed from the visible seqnum of
+// the DB on, so that they are retained, then writes a checkpoint of the DB.
+// The checkpoint is at least at the seqnum of the subscription, the batches
+// below it must be skipped. It returns the directory holding the checkpoint,
+// which must be removed once sent.
+func (p *Primary) checkpoint(ctx context.Context) (*nogodb.Subscription, string, nogodb_common.SeqNum, error) {
+	sub, err := p.db.Subscribe(ctx, p.db.VisibleSeqNum())
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	dir, err := os.MkdirTemp(p.checkpointDir, "nogodb-checkpoint-")
+	if err != nil {
+		_ = sub.Close()
+		return nil, "", 0, err
+	}
+
+	// the checkpoint is written into a directory which doesn't exist yet
+	seqNum, err := p.db.Checkpoint(filepath.Join(dir, "checkpoint"))
+	if err != nil {
+		_ = sub.Close()
+		_ = os.RemoveAll(dir)
+		return nil, "", 0, err
+	}
+
+	return sub, dir, seqNum, nil
+}
+
+// sendCheckpoint streams the files of the checkpoint written into dir to the
+// follower, in chunks, then its seqnum
+func (p *Primary) sendCheckpoint(c *conn, dir string, seqNum nogodb_common.SeqNum, s *session) error {
+	root := filepath.Join(dir, "checkpoint")
+	err := filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return p.sendCheckpointFile(c, path, filepath.ToSlash(name))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.writeSeqNum(msgTypeCheckpoint, seqNum); err != nil {
+		return err
+	}
+	s.sentSeqNum.Store(uint64(seqNum))
+	return nil
+}
+
+// sendCheckpointFile streams the file at path in chunks, an empty file is
+// sent as an empty chunk
+func (p *Primary) sendCheckpointFile(c *conn, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, checkpointChunkSize)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(f, buf)
+		if errors.Is(err, io.EOF) && !first {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+
+		if err := c.writeCheckpointChunk(name, buf[:n]); err != nil {
+			return err
+		}
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
+
+func (p *Primary) readAcks(c *conn, s *session) error {
+	for {
+		t, payload, err := c.read()
+		if err != nil {
+			return err
+		}
+		if t != msgTypeAck {
+			return fmt.Errorf("%w: expected %s, got %s", errInvalidMessage, msgTypeAck, t)
+		}
+
+		seqNum, err := decodeSeqNum(t, payload)
+		if err != nil {
+			return err
+		}
+		s.ackedSeqNum.Store(uint64(seqNum))
+	}
+}
+
+func (p *Primary) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, c *conn) {
+	t := time.NewTicker(p.heartbeatInterval)
+	defer t.Stop()
+
+	for {
+		if err := c.writeSeqNum(msgTypeHeartbeat, p.db.VisibleSeqNum()); err != nil {
+			cancel(err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Stats returns the replication lag of each connected follower
+func (p *Primary) Stats() []SessionStats {
+	visible := uint64(p.db.VisibleSeqNum())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]SessionStats, 0, len(p.mu.sessions))
+	for s := range p.mu.sessions {
+		acked := s.ackedSeqNum.Load()
+		stats = append(stats, SessionStats{
+			SentSeqNum:  nogodb_common.SeqNum(s.sentSeqNum.Load()),
+			AckedSeqNum: nogodb_common.SeqNum(acked),
+			Lag:         visible - min(acked, visible),
+		})
+	}
+
+	return stats
+}
@@ -0,0 +1,223 @@
+package replication
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// The primary and the follower exchange messages over any io.ReadWriter
+// (e.g. a TCP connection). Each message is framed as:
+//
+//	+----------+------------+-------------------+
+//	| Type (1) | Length (4) | Payload (Length)  |
+//	+----------+------------+-------------------+
+//
+// The follower opens the stream with a hello message, holding the seqnum of
+// the next batch it needs. Then the primary streams the committed batches
+// from it, and heartbeats periodically with its own visible seqnum. The
+// follower acknowledges each applied batch.
+//
+//	follower                       primary
+//	   | ---- hello(nextSeqNum) ------> |
+//	   | <--- batch(repr) ------------- |
+//	   | ---- ack(appliedSeqNum) -----> |
+//	   | <--- heartbeat(seqNum) ------- |
+//	   | <--- error(code, msg) -------- |  (e.g. the seqnum is GC-ed)
+//
+// If the batches needed by an empty follower have been garbage collected, the
+// primary bootstraps it from an on-disk checkpoint first (see DB.Checkpoint).
+// The files of the checkpoint are streamed in chunks, each one holding the
+// path of its file within the checkpoint. The last message holds the
+// checkpoint seqnum, from which the batches are streamed afterward. The
+// follower applies the checkpoint once it's received whole.
+//
+//	follower                       primary
+//	   | ---- hello(0) ---------------> |
+//	   | <--- checkpoint file(chunk) -- |  (for each chunk of each file)
+//	   | <--- checkpoint(seqNum) ------ |
+//	   | ---- ack(seqNum) ------------> |
+//	   | <--- batch(repr) ------------- |  (from seqNum)
+
+type msgType uint8
+
+const (
+	msgTypeUnknown msgType = iota
+	msgTypeHello
+	msgTypeBatch
+	msgTypeHeartbeat
+	msgTypeAck
+	msgTypeError
+	msgTypeCheckpointFile
+	msgTypeCheckpoint
+)
+
+var msgTypeToString = map[msgType]string{
+	msgTypeUnknown:        "unknown",
+	msgTypeHello:          "hello",
+	msgTypeBatch:          "batch",
+	msgTypeHeartbeat:      "heartbeat",
+	msgTypeAck:            "ack",
+	msgTypeError:          "error",
+	msgTypeCheckpointFile: "checkpoint file",
+	msgTypeCheckpoint:     "checkpoint",
+}
+
+func (t msgType) String() string {
+	return msgTypeToString[t]
+}
+
+const (
+	msgHeaderSize = 1 + 4
+	// maxPayloadSize bounds the memory allocated for a message, a batch can't
+	// be larger than a memtable anyway
+	maxPayloadSize = 1 << 30 // 1 GB
+	// checkpointChunkSize is the size of the chunks of the checkpoint files
+	checkpointChunkSize = 1 << 20 // 1 MB
+)
+
+// errCode is the code of an error message
+type errCode uint8
+
+const (
+	errCodeInternal errCode = iota
+	errCodeSnapshotRequired
+)
+
+var (
+	// ErrSnapshotRequired is returned to a follower which is too far behind:
+	// the batches it needs have been garbage collected by the primary, so it
+	// must be rebuilt from an empty DB, which the primary bootstraps from a
+	// checkpoint.
+	ErrSnapshotRequired = errors.New("replication: the follower must be bootstrapped from a snapshot")
+
+	// ErrPrimary wraps the errors reported by the primary
+	ErrPrimary = errors.New("replication: primary error")
+
+	errInvalidMessage = errors.New("replication: invalid message")
+)
+
+// conn frames the messages over a transport. Writes are serialized, since
+// the messages are sent from several goroutines (e.g. batches and heartbeats).
+type conn struct {
+	rw io.ReadWriter
+
+	wmu  sync.Mutex
+	wbuf []byte
+
+	header [msgHeaderSize]byte
+}
+
+func newConn(rw io.ReadWriter) *conn {
+	return &conn{rw: rw}
+}
+
+func (c *conn) write(t msgType, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	c.wbuf = append(c.wbuf[:0], byte(t))
+	c.wbuf = binary.LittleEndian.AppendUint32(c.wbuf, uint32(len(payload)))
+	c.wbuf = append(c.wbuf, payload...)
+
+	_, err := c.rw.Write(c.wbuf)
+	return err
+}
+
+func (c *conn) writeSeqNum(t msgType, seqNum nogodb_common.SeqNum) error {
+	return c.write(t, binary.LittleEndian.AppendUint64(nil, uint64(seqNum)))
+}
+
+func (c *conn) writeError(code errCode, err error) error {
+	return c.write(msgTypeError, append([]byte{byte(code)}, err.Error()...))
+}
+
+// read reads the next message, it must not be called concurrently
+func (c *conn) read() (msgType, []byte, error) {
+	if _, err := io.ReadFull(c.rw, c.header[:]); err != nil {
+		return msgTypeUnknown, nil, err
+	}
+
+	t := msgType(c.header[0])
+	n := binary.LittleEndian.Uint32(c.header[1:])
+	if n > maxPayloadSize {
+		return msgTypeUnknown, nil, fmt.Errorf("%w: %s payload of %d bytes", errInvalidMessage, t, n)
+	}
+
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return msgTypeUnknown, nil, err
+	}
+
+	return t, payload, nil
+}
+
+func decodeSeqNum(t msgType, payload []byte) (nogodb_common.SeqNum, error) {
+	if len(payload) != 8 {
+		return 0, fmt.Errorf("%w: %s payload of %d bytes", errInvalidMessage, t, len(payload))
+	}
+
+	return nogodb_common.SeqNum(binary.LittleEndian.Uint64(payload)), nil
+}
+
+// writeCheckpointChunk writes a chunk of the file of the checkpoint at name,
+// a relative slash-separated path
+func (c *conn) writeCheckpointChunk(name string, chunk []byte) error {
+	payload := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(name)+len(chunk)), uint64(len(name)))
+	payload = append(payload, name...)
+	return c.write(msgTypeCheckpointFile, append(payload, chunk...))
+}
+
+// decodeCheckpointChunk decodes a chunk written by writeCheckpointChunk, it
+// returns the local path of its file
+func decodeCheckpointChunk(payload []byte) (string, []byte, error) {
+	n, l := binary.Uvarint(payload)
+	if l <= 0 || uint64(len(payload)-l) < n {
+		return "", nil, fmt.Errorf("%w: %s payload of %d bytes", errInvalidMessage, msgTypeCheckpointFile, len(payload))
+	}
+
+	name := filepath.FromSlash(string(payload[l : l+int(n)]))
+	if !filepath.IsLocal(name) {
+		return "", nil, fmt.Errorf("%w: checkpoint file %q", errInvalidMessage, name)
+	}
+
+	return name, payload[l+int(n):], nil
+}
+
+func decodeError(payload []byte) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("%w: empty error payload", errInvalidMessage)
+	}
+
+	if errCode(payload[0]) == errCodeSnapshotRequired {
+		return fmt.Errorf("%w: %s", ErrSnapshotRequired, payload[1:])
+	}
+
+	return fmt.Errorf("%w: %s", ErrPrimary, payload[1:])
+}
+
+// deadliner is implemented by the transports supporting deadlines, such as
+// net.Conn
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+// unblockOnDone interrupts the pending reads and writes on rw once the
+// context is done, if the transport supports deadlines. Otherwise the caller
+// must close the transport to interrupt them. It returns a function
+// releasing the resources.
+func unblockOnDone(ctx context.Context, rw io.ReadWriter) (stop func() bool) {
+	dl, ok := rw.(deadliner)
+	if !ok {
+		return func() bool { return false }
+	}
+
+	return context.AfterFunc(ctx, func() { _ = dl.SetDeadline(time.Now()) })
+}
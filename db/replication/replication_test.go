@@ -0,0 +1,265 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	nogodb "github.com/datnguyenzzz/nogodb/db"
+	"github.com/datnguyenzzz/nogodb/db/options"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDB(t *testing.T) *nogodb.DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	opt := options.DBOption{}
+	opt.SST.Dir = filepath.Join(dir, "sst")
+	opt.WAL.Dir = filepath.Join(dir, "wal")
+	opt.Manifest.Dir = filepath.Join(dir, "manifest")
+	// the flushed WALs are deleted, the batches they hold are then garbage
+	// collected
+	opt.WAL.MaxRecycledFiles = -1
+
+	d, err := nogodb.Open(opt)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+
+	return d
+}
+
+func setKeys(t *testing.T, d *nogodb.DB, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("key_%03d", i)), []byte(fmt.Sprintf("value_%d", i))))
+	}
+}
+
+// contentsOf returns the live keys of d with their values
+func contentsOf(t *testing.T, d *nogodb.DB) map[string]string {
+	t.Helper()
+	iter := d.NewIter()
+	defer func() { require.NoError(t, iter.Close()) }()
+
+	kvs := make(map[string]string)
+	for valid := iter.First(); valid; valid = iter.Next() {
+		kvs[string(iter.Key())] = string(iter.Value())
+	}
+	require.NoError(t, iter.Error())
+	return kvs
+}
+
+// requireReplicated waits for the follower to catch up with the primary, then
+// checks that they hold the same keys
+func requireReplicated(t *testing.T, primary, follower *nogodb.DB) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return follower.VisibleSeqNum() == primary.VisibleSeqNum()
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, contentsOf(t, primary), contentsOf(t, follower))
+}
+
+var transports = []struct {
+	name    string
+	connect func(t *testing.T) (primary, follower net.Conn)
+}{
+	{
+		name: "pipe",
+		connect: func(t *testing.T) (net.Conn, net.Conn) {
+			return net.Pipe()
+		},
+	},
+	{
+		name: "tcp",
+		connect: func(t *testing.T) (net.Conn, net.Conn) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer ln.Close()
+
+			accepted := make(chan net.Conn, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					close(accepted)
+					return
+				}
+				accepted <- conn
+			}()
+
+			follower, err := net.Dial("tcp", ln.Addr().String())
+			require.NoError(t, err)
+			primary, ok := <-accepted
+			require.True(t, ok)
+			return primary, follower
+		},
+	},
+}
+
+// replicate serves the follower from the primary until ctx is done, it
+// returns the errors of Primary.Serve and Follower.Run
+func replicate(
+	ctx context.Context,
+	t *testing.T,
+	connect func(t *testing.T) (net.Conn, net.Conn),
+	p *Primary,
+	f *Follower,
+) (serveErr, runErr <-chan error) {
+	primaryConn, followerConn := connect(t)
+	t.Cleanup(func() {
+		_ = primaryConn.Close()
+		_ = followerConn.Close()
+	})
+
+	serveCh, runCh := make(chan error, 1), make(chan error, 1)
+	go func() { serveCh <- p.Serve(ctx, primaryConn) }()
+	go func() { runCh <- f.Run(ctx, followerConn) }()
+	return serveCh, runCh
+}
+
+func requireErr(t *testing.T, errCh <-chan error, target error) {
+	t.Helper()
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, target)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out")
+	}
+}
+
+func Test_Replication_Streams_The_Batches(t *testing.T) {
+	for _, tc := range transports {
+		t.Run(tc.name, func(t *testing.T) {
+			primaryDB, followerDB := openTestDB(t), openTestDB(t)
+			p := NewPrimary(primaryDB, WithHeartbeatInterval(time.Millisecond))
+			f := NewFollower(followerDB)
+			assert.ErrorIs(t, followerDB.Set([]byte("key"), []byte("value")), nogodb.ErrReadOnly)
+
+			setKeys(t, primaryDB, 0, 10)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			serveErr, runErr := replicate(ctx, t, tc.connect, p, f)
+
+			requireReplicated(t, primaryDB, followerDB)
+			setKeys(t, primaryDB, 5, 20)
+			requireReplicated(t, primaryDB, followerDB)
+
+			visible := primaryDB.VisibleSeqNum()
+			require.Eventually(t, func() bool {
+				stats := p.Stats()
+				return len(stats) == 1 && stats[0].AckedSeqNum == visible
+			}, 5*time.Second, time.Millisecond)
+			stats := p.Stats()[0]
+			assert.Equal(t, visible, stats.SentSeqNum)
+			assert.Zero(t, stats.Lag)
+
+			require.Eventually(t, func() bool {
+				return f.Stats().PrimarySeqNum == visible
+			}, 5*time.Second, time.Millisecond)
+			fStats := f.Stats()
+			assert.Equal(t, visible, fStats.AppliedSeqNum)
+			assert.Zero(t, fStats.Lag)
+			assert.Positive(t, fStats.SinceLastContact)
+
+			cancel()
+			requireErr(t, serveErr, context.Canceled)
+			requireErr(t, runErr, context.Canceled)
+			assert.Empty(t, p.Stats())
+		})
+	}
+}
+
+func Test_Replication_Reconnects(t *testing.T) {
+	for _, tc := range transports {
+		t.Run(tc.name, func(t *testing.T) {
+			primaryDB, followerDB := openTestDB(t), openTestDB(t)
+			p := NewPrimary(primaryDB)
+			f := NewFollower(followerDB)
+
+			setKeys(t, primaryDB, 0, 10)
+			ctx, cancel := context.WithCancel(context.Background())
+			serveErr, runErr := replicate(ctx, t, tc.connect, p, f)
+			requireReplicated(t, primaryDB, followerDB)
+			cancel()
+			requireErr(t, serveErr, context.Canceled)
+			requireErr(t, runErr, context.Canceled)
+
+			// the follower resumes from its last applied batch
+			setKeys(t, primaryDB, 10, 20)
+			ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+			_, _ = replicate(ctx, t, tc.connect, p, f)
+			requireReplicated(t, primaryDB, followerDB)
+
+			// then it's promoted
+			f.Promote()
+			require.NoError(t, followerDB.Set([]byte("key"), []byte("value")))
+		})
+	}
+}
+
+func Test_Replication_Bootstraps_An_Empty_Follower(t *testing.T) {
+	for _, tc := range transports {
+		t.Run(tc.name, func(t *testing.T) {
+			primaryDB, followerDB := openTestDB(t), openTestDB(t)
+			primaryCheckpoints, followerCheckpoints := t.TempDir(), t.TempDir()
+			p := NewPrimary(primaryDB, WithPrimaryCheckpointDir(primaryCheckpoints))
+			f := NewFollower(followerDB, WithFollowerCheckpointDir(followerCheckpoints))
+
+			setKeys(t, primaryDB, 0, 10)
+			// the flush deletes the WAL of the first batches
+			require.NoError(t, primaryDB.Flush())
+			// the checkpoint holds the flushed sstable and the tail of the
+			// batches in the WAL, overwriting some keys
+			setKeys(t, primaryDB, 3, 6)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			_, _ = replicate(ctx, t, tc.connect, p, f)
+			requireReplicated(t, primaryDB, followerDB)
+
+			// then the batches committed from the checkpoint on are streamed
+			setKeys(t, primaryDB, 8, 15)
+			requireReplicated(t, primaryDB, followerDB)
+
+			// the checkpoint has been removed from both sides once applied
+			for _, dir := range []string{primaryCheckpoints, followerCheckpoints} {
+				entries, err := os.ReadDir(dir)
+				require.NoError(t, err)
+				assert.Empty(t, entries)
+			}
+		})
+	}
+}
+
+func Test_Replication_Lagging_Follower_Must_Be_Rebuilt(t *testing.T) {
+	for _, tc := range transports {
+		t.Run(tc.name, func(t *testing.T) {
+			primaryDB, followerDB := openTestDB(t), openTestDB(t)
+			p := NewPrimary(primaryDB)
+			f := NewFollower(followerDB)
+
+			setKeys(t, primaryDB, 0, 5)
+			ctx, cancel := context.WithCancel(context.Background())
+			serveErr, runErr := replicate(ctx, t, tc.connect, p, f)
+			requireReplicated(t, primaryDB, followerDB)
+			cancel()
+			requireErr(t, serveErr, context.Canceled)
+			requireErr(t, runErr, context.Canceled)
+
+			// the batches the follower needs are GC-ed, it isn't empty so it
+			// can't be bootstrapped from a checkpoint
+			setKeys(t, primaryDB, 5, 10)
+			require.NoError(t, primaryDB.Flush())
+
+			serveErr, runErr = replicate(context.Background(), t, tc.connect, p, f)
+			requireErr(t, serveErr, ErrSnapshotRequired)
+			requireErr(t, runErr, ErrSnapshotRequired)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// Snapshot is a point-in-time view of the DB, it sees the batches committed
+// before it's taken. The compactions keep the versions of the keys read by
+// the open snapshots, hence a snapshot must be closed once it's no longer
+// used.
+type Snapshot struct {
+	db *DB
+	// seqNum is the visible sequence number of the DB when the snapshot is
+	// taken, the snapshot sees the versions whose seqnums are below it
+	seqNum nogodb_common.SeqNum
+	closed bool
+}
+
+// NewSnapshot takes a snapshot of the DB
+func (d *DB) NewSnapshot() *Snapshot {
+	// the visible sequence number is ratcheted under the commit mutex
+	d.commit.mu.Lock()
+	defer d.commit.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := &Snapshot{db: d, seqNum: d.commit.visibleSeqNum}
+	d.mu.snapshots[s] = struct{}{}
+	return s
+}
+
+// SeqNum returns the sequence number of the snapshot, it sees the versions
+// whose seqnums are below it
+func (s *Snapshot) SeqNum() nogodb_common.SeqNum {
+	return s.seqNum
+}
+
+// Close releases the snapshot, the versions it reads can be compacted away
+// from now on. Closing it more than once is a no-op.
+func (s *Snapshot) Close() error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	delete(s.db.mu.snapshots, s)
+	return nil
+}
+
+// snapshotSeqNums returns the seqnums of the open snapshots.
+// d.mu must be held when calling this.
+func (d *DB) snapshotSeqNums() []nogodb_common.SeqNum {
+	if len(d.mu.snapshots) == 0 {
+		return nil
+	}
+
+	seqNums := make([]nogodb_common.SeqNum, 0, len(d.mu.snapshots))
+	for s := range d.mu.snapshots {
+		seqNums = append(seqNums, s.seqNum)
+	}
+	return seqNums
+}
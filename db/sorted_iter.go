@@ -0,0 +1,103 @@
+package db
+
+import (
+	"slices"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// kvRecord is an internal key and its in-memory value
+type kvRecord struct {
+	key   nogodb_common.InternalKey
+	value []byte
+}
+
+// sortedIter iterates over a list of records, which are sorted by user key,
+// then by descending seqNum.
+type sortedIter struct {
+	cmp     nogodb_common.IComparer
+	records []kvRecord
+	pos     int
+	kv      nogodb_common.InternalKV
+	closed  bool
+}
+
+func newSortedIter(cmp nogodb_common.IComparer, records []kvRecord) *sortedIter {
+	return &sortedIter{cmp: cmp, records: records, pos: -1}
+}
+
+func (i *sortedIter) SeekGTE(key []byte) *nogodb_common.InternalKV {
+	i.pos, _ = slices.BinarySearchFunc(i.records, key, func(r kvRecord, k []byte) int {
+		if i.cmp.Compare(r.key.UserKey, k) < 0 {
+			return -1
+		}
+		return 1
+	})
+	return i.load()
+}
+
+func (i *sortedIter) SeekPrefixGTE(prefix, key []byte) *nogodb_common.InternalKV {
+	// the in-memory records don't keep any filter, fall back to a plain seek
+	return i.SeekGTE(key)
+}
+
+func (i *sortedIter) SeekLTE(key []byte) *nogodb_common.InternalKV {
+	pos, _ := slices.BinarySearchFunc(i.records, key, func(r kvRecord, k []byte) int {
+		if i.cmp.Compare(r.key.UserKey, k) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	i.pos = pos - 1
+	return i.load()
+}
+
+func (i *sortedIter) First() *nogodb_common.InternalKV {
+	i.pos = 0
+	return i.load()
+}
+
+func (i *sortedIter) Last() *nogodb_common.InternalKV {
+	i.pos = len(i.records) - 1
+	return i.load()
+}
+
+func (i *sortedIter) Next() *nogodb_common.InternalKV {
+	if i.pos >= len(i.records) {
+		return nil
+	}
+	i.pos++
+	return i.load()
+}
+
+func (i *sortedIter) Prev() *nogodb_common.InternalKV {
+	if i.pos < 0 {
+		return nil
+	}
+	i.pos--
+	return i.load()
+}
+
+func (i *sortedIter) Close() error {
+	i.closed = true
+	return nil
+}
+
+func (i *sortedIter) IsClosed() bool {
+	return i.closed
+}
+
+func (i *sortedIter) load() *nogodb_common.InternalKV {
+	if i.pos < 0 || i.pos >= len(i.records) {
+		return nil
+	}
+
+	r := &i.records[i.pos]
+	i.kv = nogodb_common.InternalKV{
+		K: r.key,
+		V: nogodb_common.NewInPlaceInternalLazyValue(r.value),
+	}
+	return &i.kv
+}
+
+var _ nogodb_common.InternalIterator[nogodb_common.InternalKV] = (*sortedIter)(nil)
@@ -46,6 +46,8 @@ type VersionSet struct {
 
 	manifestWriter   *nogodb_record.Writer
 	manifestStorager nogodb_fs.Storage
+	// manifestFileNum is the file number of the current manifest
+	manifestFileNum nogodb_common.DiskfileNum
 
 	lock nogodb_lock.ICtxLock
 
@@ -58,6 +60,7 @@ func (vs *VersionSet) init(
 ) (err error) {
 	vs.dbOpt = dbOpt
 	vs.mu = mu
+	vs.versions = &manifest.VersionList{}
 	vs.versions.Init(mu)
 	atomic.StoreUint64((*uint64)(&vs.logSeqNum), 0)
 	atomic.StoreInt64((*int64)(&vs.nextFileNum), 1)
@@ -88,13 +91,13 @@ func (vs *VersionSet) NewDB(
 
 	var err error
 	if err = vs.createManifest(vs.GetNextFileNum()); err != nil {
-		return err
+		return fmt.Errorf("%w: failed creating manifest. %w", errManifestWrite, err)
 	}
 	if err = vs.manifestWriter.Flush(); err != nil {
-		vs.dbOpt.Logger.Fatalf("manifest flushed failed: %v", err)
+		return fmt.Errorf("%w: failed flushing manifest. %w", errManifestWrite, err)
 	}
-	if err = vs.manifestStorager.Sync(nogodb_common.TypeManifest, vs.GetCurrentFileNum()); err != nil {
-		vs.dbOpt.Logger.Fatalf("manifest sync failed: %v", err)
+	if err = vs.manifestStorager.Sync(nogodb_common.TypeManifest, vs.manifestFileNum); err != nil {
+		return fmt.Errorf("%w: failed syncing manifest. %w", errManifestWrite, err)
 	}
 
 	return nil
@@ -138,25 +141,27 @@ func (vs *VersionSet) UpdateVersion(ve *manifest.VersionEdit) (err error) {
 					continue
 				}
 
-				newVersion.Levels[i].Insert(tableEntry.Meta)
+				if err := newVersion.Levels[i].Insert(tableEntry.Meta); err != nil {
+					return err
+				}
 			}
 		}
 
 		w, err := vs.manifestWriter.Next()
 		if err != nil {
-			return err
+			return fmt.Errorf("%w: %w", errManifestWrite, err)
 		}
 
 		if err := ve.Encode(w); err != nil {
-			return fmt.Errorf("failed encoding versionEdit. %w", err)
+			return fmt.Errorf("%w: failed encoding versionEdit. %w", errManifestWrite, err)
 		}
 
 		if err := vs.manifestWriter.Flush(); err != nil {
-			return fmt.Errorf("failed flushing versionEdit. %w", err)
+			return fmt.Errorf("%w: failed flushing versionEdit. %w", errManifestWrite, err)
 		}
 
-		if err := vs.manifestStorager.Sync(nogodb_common.TypeManifest, vs.GetCurrentFileNum()); err != nil {
-			return fmt.Errorf("failed Syncing versionEdit. %w", err)
+		if err := vs.manifestStorager.Sync(nogodb_common.TypeManifest, vs.manifestFileNum); err != nil {
+			return fmt.Errorf("%w: failed Syncing versionEdit. %w", errManifestWrite, err)
 		}
 
 		return nil
@@ -192,19 +197,7 @@ func (vs *VersionSet) createManifest(fileNum nogodb_common.DiskfileNum) error {
 	manifestWriter = nogodb_record.NewWriter(writable)
 
 	// Add all existing SSTables meta to the current version
-	edit := &manifest.VersionEdit{
-		ComparerName: vs.dbOpt.Comparer.Name(),
-		NextFileNum:  int64(vs.nextFileNum),
-	}
-
-	for lvl, levelMeta := range vs.currentVersion().Levels {
-		for tableMeta := range levelMeta.All() {
-			edit.NewTables = append(edit.NewTables, manifest.NewTableEntry{
-				Level: lvl,
-				Meta:  &tableMeta,
-			})
-		}
-	}
+	edit := vs.snapshotEdit(vs.currentVersion())
 
 	w, err := manifestWriter.Next()
 	if err != nil {
@@ -224,16 +217,37 @@ func (vs *VersionSet) createManifest(fileNum nogodb_common.DiskfileNum) error {
 	}
 
 	vs.manifestWriter, manifestWriter = manifestWriter, vs.manifestWriter
+	vs.manifestFileNum = fileNum
 
 	return nil
 }
 
+// snapshotEdit returns the versionEdit which creates the version from
+// scratch, i.e. which adds all its tables
+func (vs *VersionSet) snapshotEdit(version *manifest.Version) *manifest.VersionEdit {
+	edit := &manifest.VersionEdit{
+		ComparerName: vs.dbOpt.Comparer.Name(),
+		NextFileNum:  int64(atomic.LoadInt64((*int64)(&vs.nextFileNum))),
+	}
+
+	for lvl, levelMeta := range version.Levels {
+		for tableMeta := range levelMeta.All() {
+			edit.NewTables = append(edit.NewTables, manifest.NewTableEntry{
+				Level: lvl,
+				Meta:  &tableMeta,
+			})
+		}
+	}
+
+	return edit
+}
+
 func (vs *VersionSet) AcquireLock(ctx context.Context) {
-	vs.AcquireLock(ctx)
+	vs.lock.AcquireCtx(ctx)
 }
 
 func (vs *VersionSet) ReleaseLock(ctx context.Context) {
-	vs.ReleaseLock(ctx)
+	vs.lock.ReleaseCtx(ctx)
 }
 
 func (vs *VersionSet) GetLogSeqNum() uint64 {
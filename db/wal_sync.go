@@ -0,0 +1,52 @@
+package db
+
+import (
+	"time"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// SyncWAL syncs the WAL, so that all the batches committed so far are
+// durable. It is mostly useful with options.SyncInterval and options.NoSync.
+func (d *DB) SyncWAL() error {
+	// The WAL is rotated under the commit mutex, so the writer holds all the
+	// batches below nextSeqNum, or the previous writers have synced them when
+	// they were closed.
+	d.commit.mu.Lock()
+	writer := d.mu.log.writer
+	upTo := d.commit.nextSeqNum
+	d.commit.mu.Unlock()
+
+	if err := writer.Sync(); err != nil {
+		return err
+	}
+
+	d.commit.ratchetDurableSeqNum(upTo)
+	return nil
+}
+
+// DurableSeqNum returns the durable sequence number, all the batches whose
+// sequence numbers are below it have been synced to the WAL.
+func (d *DB) DurableSeqNum() nogodb_common.SeqNum {
+	return d.commit.loadDurableSeqNum()
+}
+
+// syncWALPeriodically syncs the WAL every interval, until the DB is closed
+func (d *DB) syncWALPeriodically(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-d.bgCtx.Done():
+			return
+		case <-t.C:
+		}
+
+		if err := d.SyncWAL(); err != nil {
+			d.mu.Lock()
+			d.setBackgroundError(err, classifyError(err))
+			d.mu.Unlock()
+		}
+	}
+}
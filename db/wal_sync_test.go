@@ -0,0 +1,120 @@
+package db
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/datnguyenzzz/nogodb/db/options"
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_wal "github.com/datnguyenzzz/nogodb/lib/go-wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DurableSeqNum(t *testing.T) {
+	tests := []struct {
+		name     string
+		syncMode options.SyncMode
+		// whether a commit is durable once acknowledged
+		wantDurableOnCommit bool
+	}{
+		{name: "every commit", syncMode: options.SyncEveryCommit, wantDurableOnCommit: true},
+		{name: "interval", syncMode: options.SyncInterval(time.Hour), wantDurableOnCommit: false},
+		{name: "none", syncMode: options.NoSync, wantDurableOnCommit: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := openTestDB(t, func(opt *options.DBOption) {
+				opt.WAL.SyncMode = tc.syncMode
+			})
+			initial := d.DurableSeqNum()
+
+			// commit sets the keys [from, to), returns the end of the last
+			// write, and checks the durable sequence number the writes have
+			// been acknowledged with
+			commit := func(from, to int) nogodb_common.SeqNum {
+				var end nogodb_common.SeqNum
+				for i := from; i < to; i++ {
+					res, err := d.SetWithResult([]byte{byte(i)}, []byte{byte(i)})
+					require.NoError(t, err)
+					end = res.SeqNum + 1
+					if tc.wantDurableOnCommit {
+						assert.Equal(t, end, res.DurableSeqNum)
+					} else {
+						assert.Less(t, res.DurableSeqNum, end)
+					}
+				}
+				return end
+			}
+
+			end := commit(0, 3)
+			if tc.wantDurableOnCommit {
+				assert.Equal(t, end, d.DurableSeqNum())
+			} else {
+				assert.Equal(t, initial, d.DurableSeqNum())
+			}
+
+			// the rotated WAL is synced when closed
+			rotateTestWAL(t, d)
+			assert.Equal(t, end, d.DurableSeqNum())
+
+			end = commit(3, 5)
+			require.NoError(t, d.SyncWAL())
+			assert.Equal(t, end, d.DurableSeqNum())
+
+			// the WAL is synced when the DB is closed
+			end = commit(5, 6)
+			require.NoError(t, d.Close())
+			assert.Equal(t, end, d.DurableSeqNum())
+		})
+	}
+}
+
+func Test_DurableSeqNum_Synced_Periodically(t *testing.T) {
+	d := openTestDB(t, func(opt *options.DBOption) {
+		opt.WAL.SyncMode = options.SyncInterval(time.Millisecond)
+	})
+
+	for i := range 3 {
+		b := newBatch(d, false)
+		require.NoError(t, b.Set([]byte{byte(i)}, []byte{byte(i)}))
+		require.NoError(t, d.apply(b))
+		end := b.SeqNum() + nogodb_common.SeqNum(b.Count())
+		require.NoError(t, b.Close())
+
+		require.Eventually(t, func() bool { return d.DurableSeqNum() == end }, time.Second, time.Millisecond)
+	}
+}
+
+// failingSyncWriter fails the syncs of the WAL
+type failingSyncWriter struct {
+	nogodb_wal.ILogWriter
+}
+
+func (w failingSyncWriter) Sync() error {
+	return syscall.EIO
+}
+
+func Test_WAL_Sync_Failure_Stops_The_Writes(t *testing.T) {
+	d := openTestDB(t, func(opt *options.DBOption) {
+		opt.WAL.SyncMode = options.SyncEveryCommit
+	})
+	require.NoError(t, d.Set([]byte("a"), []byte("a")))
+	durable := d.DurableSeqNum()
+
+	d.commit.mu.Lock()
+	d.mu.log.writer = failingSyncWriter{ILogWriter: d.mu.log.writer}
+	d.commit.mu.Unlock()
+
+	// the batch is in the WAL but not durable, it can't be applied
+	err := d.Set([]byte("b"), []byte("b"))
+	require.ErrorIs(t, err, syscall.EIO)
+	assert.Equal(t, durable, d.DurableSeqNum())
+	assert.Equal(t, durable, d.VisibleSeqNum())
+
+	// the DB must be re-opened
+	require.ErrorIs(t, d.Set([]byte("c"), []byte("c")), ErrReadOnly)
+	require.ErrorIs(t, d.Resume(), ErrReadOnly)
+}
@@ -8,11 +8,19 @@ const (
 	BlockKindIndex
 	BlockKindFilter
 	BlockKindMetaIntex
+	// BlockKindProperties is the table properties block, e.g. the properties
+	// collected by the block property collectors over the whole table
+	BlockKindProperties
+	// BlockKindFilterIndex is the top-level index of the partitioned filters,
+	// it locates the filter partition of each 1st level index block
+	BlockKindFilterIndex
 )
 
 var BlockKindStrings = map[BlockKind]string{
-	BlockKindData:      "data",
-	BlockKindIndex:     "index",
-	BlockKindFilter:    "filter",
-	BlockKindMetaIntex: "meta-index",
+	BlockKindData:        "data",
+	BlockKindIndex:       "index",
+	BlockKindFilter:      "filter",
+	BlockKindMetaIntex:   "meta-index",
+	BlockKindProperties:  "properties",
+	BlockKindFilterIndex: "filter-index",
 }
@@ -52,8 +52,25 @@ type IComparer interface {
 	AbbreviatedKey(key []byte) []byte
 }
 
-// TODO(high): Implement separated comparer for the MVVC key
+// ISuffixEncoder is implemented by the comparers which don't order the
+// suffixes of the keys (see IComparer.Split) byte-wise. The suffixes are then
+// encoded in a way that their encodings are ordered byte-wise, e.g. to index
+// the keys in a radix tree. The prefixes are ordered byte-wise by any
+// comparer, see IComparer.Compare.
+type ISuffixEncoder interface {
+	// AppendEncodedSuffix appends the encoding of the suffix to dst. The
+	// encodings of the suffixes of a same prefix are ordered byte-wise as the
+	// keys are by Compare, none of them is the prefix of another one, and
+	// each of them is at most 2*len(suffix)+2 bytes long.
+	AppendEncodedSuffix(dst, suffix []byte) []byte
+
+	// AppendDecodedSuffix appends to dst the suffix whose encoding starts src,
+	// it returns the length of the encoding as well
+	AppendDecodedSuffix(dst, src []byte) ([]byte, int)
+}
 
+// DefaultComparer orders the keys byte-wise, they have no suffix. See
+// MVCCComparer for the keys suffixed by a timestamp.
 type DefaultComparer struct{}
 
 func (c DefaultComparer) Name() string {
@@ -103,6 +120,15 @@ func (c DefaultComparer) Split(b []byte) int {
 	return len(b)
 }
 
+// AppendEncodedSuffix appends nothing, the keys have no suffix
+func (c DefaultComparer) AppendEncodedSuffix(dst, suffix []byte) []byte {
+	return dst
+}
+
+func (c DefaultComparer) AppendDecodedSuffix(dst, src []byte) ([]byte, int) {
+	return dst, 0
+}
+
 func (c DefaultComparer) AbbreviatedKey(key []byte) []byte {
 	if len(key) >= 8 {
 		return key[:8]
@@ -116,4 +142,7 @@ func NewComparer() *DefaultComparer {
 	return &DefaultComparer{}
 }
 
-var _ IComparer = (*DefaultComparer)(nil)
+var (
+	_ IComparer      = (*DefaultComparer)(nil)
+	_ ISuffixEncoder = (*DefaultComparer)(nil)
+)
@@ -10,6 +10,19 @@ const (
 	ZstdCompression
 )
 
+var compressionTypeToString = map[CompressionType]string{
+	NoCompression:     "none",
+	SnappyCompression: "snappy",
+	ZstdCompression:   "zstd",
+}
+
+func (ct CompressionType) String() string {
+	if s, ok := compressionTypeToString[ct]; ok {
+		return s
+	}
+	return "unknown"
+}
+
 type ICompression interface {
 	GetType() CompressionType
 	// Compress a block, appending the compressed data to dst[:0].
@@ -12,6 +12,9 @@ const (
 	ValueFromUnknown ValueSource = iota
 	ValueFromBuffer
 	ValueFromCache
+	// ValueInPlace is a value that lives in memory owned by the producer,
+	// e.g. a memtable or a batch, so there is nothing to load or release
+	ValueInPlace
 )
 
 // Fetcher uses to fetch and/or release the data
@@ -59,6 +62,7 @@ type InternalLazyValue struct {
 	ValueSource   ValueSource
 	BufferFetcher *BufferPoolFetcher
 	CacheFetcher  Fetcher
+	InPlaceValue  []byte
 }
 
 func NewBlankInternalLazyValue(s ValueSource) InternalLazyValue {
@@ -67,6 +71,15 @@ func NewBlankInternalLazyValue(s ValueSource) InternalLazyValue {
 	}
 }
 
+// NewInPlaceInternalLazyValue wraps an in-memory value. The caller must
+// ensure the value outlives the InternalLazyValue
+func NewInPlaceInternalLazyValue(val []byte) InternalLazyValue {
+	return InternalLazyValue{
+		ValueSource:  ValueInPlace,
+		InPlaceValue: val,
+	}
+}
+
 type InternalKV struct {
 	K InternalKey
 	V InternalLazyValue
@@ -87,6 +100,8 @@ func (iv *InternalLazyValue) Value() []byte {
 		return iv.BufferFetcher.Load()
 	case ValueFromCache:
 		return iv.CacheFetcher.Load()
+	case ValueInPlace:
+		return iv.InPlaceValue
 	default:
 		panic(fmt.Sprintf("InternalLazyValue.Value: unknown value source: %d", iv.ValueSource))
 	}
@@ -99,6 +114,8 @@ func (iv *InternalLazyValue) Release() {
 		iv.BufferFetcher.Release()
 	case ValueFromCache:
 		iv.CacheFetcher.Release()
+	case ValueInPlace:
+		iv.InPlaceValue = nil
 	default:
 		panic(fmt.Sprintf("InternalLazyValue.Value: unknown value source: %d", iv.ValueSource))
 	}
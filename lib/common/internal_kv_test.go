@@ -38,6 +38,20 @@ func TestValueSource_Constants(t *testing.T) {
 	assert.Equal(t, ValueSource(0), ValueFromUnknown)
 	assert.Equal(t, ValueSource(1), ValueFromBuffer)
 	assert.Equal(t, ValueSource(2), ValueFromCache)
+	assert.Equal(t, ValueSource(3), ValueInPlace)
+}
+
+func TestInternalLazyValue_InPlace(t *testing.T) {
+	val := []byte("in-place-value")
+	lz := NewInPlaceInternalLazyValue(val)
+
+	assert.Equal(t, ValueInPlace, lz.ValueSource)
+	assert.Equal(t, val, lz.Value())
+
+	lz.Release()
+	assert.Nil(t, lz.Value())
+	// Release can be called multiple times
+	lz.Release()
 }
 
 func TestBufferPoolFetcher_Reserve(t *testing.T) {
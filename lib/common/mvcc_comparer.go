@@ -0,0 +1,205 @@
+package common
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+)
+
+// MVCC keys \\
+
+// An MVCC key is made of a prefix (the actual user key), followed by the
+// version of the key, its timestamp. The suffix (timestamp + length byte) is
+// ended by its own length, so that the prefix can be split from the end:
+//
+//	+------------+---------------+---------------+
+//	| Prefix (N) | Timestamp (8) | SuffixLen (1) |
+//	+------------+---------------+---------------+
+//
+// The timestamp is encoded in big-endian. A bare key (i.e. without timestamp,
+// SuffixLen = 1) is encoded as:
+//
+//	+------------+---------------+
+//	| Prefix (N) | SuffixLen (1) |
+//	+------------+---------------+
+//
+// The versions of a same prefix are sorted by descending timestamp, so that
+// the latest version comes first, and a bare key sorts before all of them.
+const (
+	mvccTimestampLen     = 8
+	mvccBareSuffixLen    = 1
+	mvccVersionSuffixLen = mvccTimestampLen + 1
+)
+
+// EncodeMVCCKey appends the timestamp to the prefix. A zero timestamp
+// encodes a bare key.
+func EncodeMVCCKey(prefix []byte, ts uint64) []byte {
+	if ts == 0 {
+		key := make([]byte, 0, len(prefix)+mvccBareSuffixLen)
+		key = append(key, prefix...)
+		return append(key, mvccBareSuffixLen)
+	}
+
+	key := make([]byte, 0, len(prefix)+mvccVersionSuffixLen)
+	key = append(key, prefix...)
+	key = binary.BigEndian.AppendUint64(key, ts)
+	return append(key, mvccVersionSuffixLen)
+}
+
+// DecodeMVCCKey splits the key encoded by EncodeMVCCKey. ts is zero for a
+// bare key. ok is false if the key isn't a valid MVCC key.
+func DecodeMVCCKey(key []byte) (prefix []byte, ts uint64, ok bool) {
+	prefixLen, ok := splitMVCCKey(key)
+	if !ok {
+		return nil, 0, false
+	}
+
+	if len(key)-prefixLen == mvccVersionSuffixLen {
+		ts = binary.BigEndian.Uint64(key[prefixLen:])
+	}
+
+	return key[:prefixLen], ts, true
+}
+
+// splitMVCCKey returns the prefix length of the key
+func splitMVCCKey(key []byte) (prefixLen int, ok bool) {
+	if len(key) == 0 {
+		return 0, false
+	}
+
+	suffixLen := int(key[len(key)-1])
+	if suffixLen != mvccBareSuffixLen && suffixLen != mvccVersionSuffixLen || suffixLen > len(key) {
+		return len(key), false
+	}
+
+	return len(key) - suffixLen, true
+}
+
+// MVCCComparer orders the MVCC keys, see EncodeMVCCKey: by ascending prefix,
+// then by descending timestamp. The keys which aren't valid MVCC keys are
+// handled as bare keys, whose prefix is the whole key, they sort right before
+// the actual bare key of the same prefix.
+type MVCCComparer struct{}
+
+func (c MVCCComparer) Name() string {
+	return "MVCCComparer"
+}
+
+func (c MVCCComparer) Compare(a, b []byte) int {
+	prefixA, prefixB := c.Split(a), c.Split(b)
+	if r := bytes.Compare(a[:prefixA], b[:prefixB]); r != 0 {
+		return r
+	}
+
+	// The timestamps have a fixed length, the empty one (bare key) sorts
+	// first, then the timestamps are sorted in descending order
+	tsA, tsB := mvccTimestamp(a, prefixA), mvccTimestamp(b, prefixB)
+	switch {
+	case len(tsA) == 0 && len(tsB) == 0:
+		// a key which isn't a valid MVCC key sorts before the bare key of
+		// the same prefix, they only differ by its SuffixLen
+		return bytes.Compare(a, b)
+	case len(tsA) == 0 || len(tsB) == 0:
+		return cmp.Compare(len(tsA), len(tsB))
+	default:
+		return bytes.Compare(tsB, tsA)
+	}
+}
+
+// mvccTimestamp returns the encoded timestamp of the key, empty for a bare key
+func mvccTimestamp(key []byte, prefixLen int) []byte {
+	if len(key)-prefixLen != mvccVersionSuffixLen {
+		return nil
+	}
+
+	return key[prefixLen : prefixLen+mvccTimestampLen]
+}
+
+func (c MVCCComparer) Split(b []byte) int {
+	prefixLen, _ := splitMVCCKey(b)
+	return prefixLen
+}
+
+// The suffixes are encoded by their kind, then the inverted timestamp for the
+// versions, so that the byte-wise order of the encodings is the order of
+// Compare:
+//
+//	+----------+--------------------------+
+//	| Kind (1) | Inverted Timestamp (0|8) |
+//	+----------+--------------------------+
+//
+// The kind sorts the empty suffix (of the keys which aren't valid MVCC keys)
+// first, then the bare key, then the versions.
+const (
+	mvccSuffixKindEmpty byte = iota
+	mvccSuffixKindBare
+	mvccSuffixKindVersion
+)
+
+func (c MVCCComparer) AppendEncodedSuffix(dst, suffix []byte) []byte {
+	switch len(suffix) {
+	case 0:
+		return append(dst, mvccSuffixKindEmpty)
+	case mvccVersionSuffixLen:
+		dst = append(dst, mvccSuffixKindVersion)
+		return binary.BigEndian.AppendUint64(dst, ^binary.BigEndian.Uint64(suffix))
+	default:
+		return append(dst, mvccSuffixKindBare)
+	}
+}
+
+func (c MVCCComparer) AppendDecodedSuffix(dst, src []byte) ([]byte, int) {
+	switch src[0] {
+	case mvccSuffixKindEmpty:
+		return dst, 1
+	case mvccSuffixKindVersion:
+		dst = binary.BigEndian.AppendUint64(dst, ^binary.BigEndian.Uint64(src[1:]))
+		return append(dst, mvccVersionSuffixLen), 1 + mvccTimestampLen
+	default:
+		return append(dst, mvccBareSuffixLen), 1
+	}
+}
+
+// Separator shortens the prefix of a, the returned separator is a bare key
+func (c MVCCComparer) Separator(a, b []byte) []byte {
+	prefixA, prefixB := a[:c.Split(a)], b[:c.Split(b)]
+	if bytes.Compare(prefixA, prefixB) >= 0 {
+		// the keys are versions of a same prefix, or a >= b
+		return a
+	}
+
+	sep := DefaultComparer{}.Separator(prefixA, prefixB)
+	if bytes.Equal(sep, prefixA) {
+		return a
+	}
+
+	// prefixA < sep < prefixB, so the bare key sep sorts in between
+	return EncodeMVCCKey(sep, 0)
+}
+
+// Successor shortens the prefix of b, the returned successor is a bare key
+func (c MVCCComparer) Successor(b []byte) []byte {
+	prefix := b[:c.Split(b)]
+	succ := DefaultComparer{}.Successor(prefix)
+	if bytes.Equal(succ, prefix) {
+		return b
+	}
+
+	// prefix < succ, so the bare key succ sorts after all the versions of b
+	return EncodeMVCCKey(succ, 0)
+}
+
+// AbbreviatedKey returns the first eight bytes of the prefix, the timestamp
+// is ignored
+func (c MVCCComparer) AbbreviatedKey(key []byte) []byte {
+	return DefaultComparer{}.AbbreviatedKey(key[:c.Split(key)])
+}
+
+func NewMVCCComparer() *MVCCComparer {
+	return &MVCCComparer{}
+}
+
+var (
+	_ IComparer      = (*MVCCComparer)(nil)
+	_ ISuffixEncoder = (*MVCCComparer)(nil)
+)
@@ -0,0 +1,286 @@
+package common
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMVCCComparer_Compare(t *testing.T) {
+	// sorted in the expected order
+	keys := [][]byte{
+		// not a valid MVCC key, its prefix is the whole key
+		[]byte("a"),
+		EncodeMVCCKey([]byte("a"), 0),
+		EncodeMVCCKey([]byte("a"), 300),
+		EncodeMVCCKey([]byte("a"), 20),
+		EncodeMVCCKey([]byte("a"), 1),
+		EncodeMVCCKey([]byte("ab"), 0),
+		EncodeMVCCKey([]byte("ab"), 1<<40),
+		EncodeMVCCKey([]byte("ab"), 5),
+		EncodeMVCCKey([]byte("b\x00"), 7),
+		EncodeMVCCKey([]byte("b\x01"), 0),
+		EncodeMVCCKey([]byte("b\x01"), 2),
+		EncodeMVCCKey([]byte("c"), 1),
+	}
+
+	comparer := NewMVCCComparer()
+	shuffled := slices.Clone(keys)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	slices.SortFunc(shuffled, comparer.Compare)
+	assert.Equal(t, keys, shuffled)
+
+	for i := range keys {
+		assert.Equal(t, 0, comparer.Compare(keys[i], slices.Clone(keys[i])))
+		for j := i + 1; j < len(keys); j++ {
+			assert.Equal(t, -1, comparer.Compare(keys[i], keys[j]), "Compare(%q, %q)", keys[i], keys[j])
+			assert.Equal(t, 1, comparer.Compare(keys[j], keys[i]), "Compare(%q, %q)", keys[j], keys[i])
+		}
+	}
+}
+
+func TestMVCCComparer_Compare_Bare_Keys(t *testing.T) {
+	comparer := NewMVCCComparer()
+	tests := []struct {
+		name string
+		a    []byte
+		b    []byte
+		want int
+	}{
+		{
+			name: "invalid key before the bare key",
+			a:    []byte("abc"),
+			b:    EncodeMVCCKey([]byte("abc"), 0),
+			want: -1,
+		},
+		{
+			name: "invalid key before the versions",
+			a:    []byte("abc"),
+			b:    EncodeMVCCKey([]byte("abc"), 1),
+			want: -1,
+		},
+		{
+			name: "bare key before the versions",
+			a:    EncodeMVCCKey([]byte("abc"), 0),
+			b:    EncodeMVCCKey([]byte("abc"), 1),
+			want: -1,
+		},
+		{
+			name: "bare keys",
+			a:    EncodeMVCCKey([]byte("abc"), 0),
+			b:    EncodeMVCCKey([]byte("abc"), 0),
+			want: 0,
+		},
+		{
+			name: "invalid keys",
+			a:    []byte("abc"),
+			b:    []byte("abc"),
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, comparer.Compare(tt.a, tt.b))
+			assert.Equal(t, -tt.want, comparer.Compare(tt.b, tt.a))
+		})
+	}
+}
+
+func TestMVCCComparer_Split(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    []byte
+		prefix []byte
+		ts     uint64
+		ok     bool
+	}{
+		{
+			name:   "versioned key",
+			key:    EncodeMVCCKey([]byte("hello"), 42),
+			prefix: []byte("hello"),
+			ts:     42,
+			ok:     true,
+		},
+		{
+			name:   "bare key",
+			key:    EncodeMVCCKey([]byte("hello"), 0),
+			prefix: []byte("hello"),
+			ok:     true,
+		},
+		{
+			name:   "empty prefix",
+			key:    EncodeMVCCKey(nil, 7),
+			prefix: []byte{},
+			ts:     7,
+			ok:     true,
+		},
+		{
+			name: "empty key",
+			key:  []byte{},
+		},
+		{
+			name: "invalid suffix length",
+			key:  []byte("hello"),
+		},
+		{
+			name: "suffix longer than the key",
+			key:  []byte{0x01, 0x02, mvccVersionSuffixLen},
+		},
+	}
+
+	comparer := NewMVCCComparer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, ts, ok := DecodeMVCCKey(tt.key)
+			assert.Equal(t, tt.ok, ok)
+			if !ok {
+				// the whole key is the prefix
+				assert.Equal(t, len(tt.key), comparer.Split(tt.key))
+				return
+			}
+
+			assert.Equal(t, tt.prefix, prefix)
+			assert.Equal(t, tt.ts, ts)
+			assert.Equal(t, len(tt.prefix), comparer.Split(tt.key))
+		})
+	}
+}
+
+func TestMVCCComparer_Separator(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []byte
+		b    []byte
+		want []byte
+	}{
+		{
+			name: "versions of a same prefix",
+			a:    EncodeMVCCKey([]byte("apple"), 9),
+			b:    EncodeMVCCKey([]byte("apple"), 3),
+			want: EncodeMVCCKey([]byte("apple"), 9),
+		},
+		{
+			name: "shortened prefix",
+			a:    EncodeMVCCKey([]byte("apple"), 9),
+			b:    EncodeMVCCKey([]byte("cherry"), 3),
+			want: EncodeMVCCKey([]byte("b"), 0),
+		},
+		{
+			name: "a prefix of b",
+			a:    EncodeMVCCKey([]byte("foo"), 1),
+			b:    EncodeMVCCKey([]byte("foobar"), 1),
+			want: EncodeMVCCKey([]byte("foo"), 1),
+		},
+		{
+			name: "consecutive prefixes",
+			a:    EncodeMVCCKey([]byte("abc"), 5),
+			b:    EncodeMVCCKey([]byte("abd"), 5),
+			want: EncodeMVCCKey([]byte("abc"), 5),
+		},
+		{
+			name: "a > b",
+			a:    EncodeMVCCKey([]byte("b"), 1),
+			b:    EncodeMVCCKey([]byte("a"), 1),
+			want: EncodeMVCCKey([]byte("b"), 1),
+		},
+	}
+
+	comparer := NewMVCCComparer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := comparer.Separator(tt.a, tt.b)
+			assert.Equal(t, tt.want, got, "Separator(%q, %q)", tt.a, tt.b)
+
+			if comparer.Compare(tt.a, tt.b) < 0 {
+				assert.LessOrEqual(t, comparer.Compare(tt.a, got), 0, "Expected a <= separator")
+				assert.Less(t, comparer.Compare(got, tt.b), 0, "Expected separator < b")
+			}
+		})
+	}
+}
+
+func TestMVCCComparer_Successor(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want []byte
+	}{
+		{
+			name: "versioned key",
+			b:    EncodeMVCCKey([]byte("hello"), 42),
+			want: EncodeMVCCKey([]byte("i"), 0),
+		},
+		{
+			name: "bare key",
+			b:    EncodeMVCCKey([]byte{0xFF, 0x01}, 0),
+			want: EncodeMVCCKey([]byte{0xFF, 0x02}, 0),
+		},
+		{
+			name: "no successor",
+			b:    EncodeMVCCKey([]byte{0xFF, 0xFF}, 3),
+			want: EncodeMVCCKey([]byte{0xFF, 0xFF}, 3),
+		},
+	}
+
+	comparer := NewMVCCComparer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := comparer.Successor(tt.b)
+			assert.Equal(t, tt.want, got, "Successor(%q)", tt.b)
+			assert.LessOrEqual(t, comparer.Compare(tt.b, got), 0, "Expected b <= successor")
+		})
+	}
+}
+
+func TestMVCCComparer_AbbreviatedKey(t *testing.T) {
+	comparer := NewMVCCComparer()
+
+	// the versions of a prefix share the same abbreviated key
+	assert.Equal(t,
+		comparer.AbbreviatedKey(EncodeMVCCKey([]byte("abc"), 1)),
+		comparer.AbbreviatedKey(EncodeMVCCKey([]byte("abc"), 1000)),
+	)
+	assert.Equal(t, []byte("abc\x00\x00\x00\x00\x00"), comparer.AbbreviatedKey(EncodeMVCCKey([]byte("abc"), 0)))
+	assert.Equal(t, []byte("abcdefgh"), comparer.AbbreviatedKey(EncodeMVCCKey([]byte("abcdefghij"), 7)))
+	assert.Less(t, 0, bytes.Compare(
+		comparer.AbbreviatedKey(EncodeMVCCKey([]byte("b"), 1)),
+		comparer.AbbreviatedKey(EncodeMVCCKey([]byte("a"), 9)),
+	))
+}
+
+func TestMVCCComparer_Suffix_Encoding(t *testing.T) {
+	// sorted in the expected order, with the same prefix
+	keys := [][]byte{
+		[]byte("ab"),
+		EncodeMVCCKey([]byte("ab"), 0),
+		EncodeMVCCKey([]byte("ab"), 1<<40),
+		EncodeMVCCKey([]byte("ab"), 300),
+		EncodeMVCCKey([]byte("ab"), 1),
+	}
+
+	comparer := NewMVCCComparer()
+	var encodings [][]byte
+	for _, key := range keys {
+		suffix := key[comparer.Split(key):]
+		enc := comparer.AppendEncodedSuffix([]byte("x"), suffix)
+		assert.LessOrEqual(t, len(enc)-1, 2*len(suffix)+2)
+
+		decoded, n := comparer.AppendDecodedSuffix([]byte("ab"), append(enc[1:], "trailer"...))
+		assert.Equal(t, len(enc)-1, n)
+		assert.Equal(t, key, decoded)
+		encodings = append(encodings, enc)
+	}
+
+	for i := range encodings {
+		for j := i + 1; j < len(encodings); j++ {
+			assert.Equal(t, -1, bytes.Compare(encodings[i], encodings[j]), "%q vs %q", keys[i], keys[j])
+			assert.False(t, bytes.HasPrefix(encodings[j], encodings[i]))
+		}
+	}
+}
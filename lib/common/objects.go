@@ -15,6 +15,7 @@ const (
 	TypeTable
 	TypeWAL
 	TypeLock
+	TypeCache
 )
 
 var (
@@ -23,12 +24,14 @@ var (
 		"sst":      TypeTable,
 		"wal":      TypeWAL,
 		"LOCK":     TypeLock,
+		"cache":    TypeCache,
 	}
 	ObjectTypeToString = map[ObjectType]string{
 		TypeManifest: "manifest",
 		TypeTable:    "sst",
 		TypeWAL:      "wal",
 		TypeLock:     "LOCK",
+		TypeCache:    "cache",
 	}
 )
 
@@ -28,16 +28,31 @@ const (
 	firstChunkEncoding
 	middleChunkEncoding
 	lastChunkEncoding
+
+	// The recyclable chunks embed the log number in their header
+	recyclableFullChunkEncoding
+	recyclableFirstChunkEncoding
+	recyclableMiddleChunkEncoding
+	recyclableLastChunkEncoding
 )
 
 const (
-	BlockSize  = 32 * 1024
-	headerSize = 7
+	BlockSize            = 32 * 1024
+	legacyHeaderSize     = 7
+	recyclableHeaderSize = legacyHeaderSize + 4
 )
 
 type Reader struct {
 	r          io.Reader
 	checksumer nogodb_common.IChecksum
+	// logNum is the log number that the recyclable chunks must embed, the
+	// chunks of another log number are the stale content of a recycled file.
+	logNum uint32
+	// recyclable is whether the log is written in the recyclable format, i.e.
+	// a recyclable chunk of logNum has been read. From then on, the reader
+	// can't read any legacy chunk, and the garbage ending the log is the stale
+	// content of a recycled file. A legacy log never sets it.
+	recyclable bool
 	// blockNum is the block number (0-indexed) currently held in buf
 	blockNum int64
 	// seq is the sequence number of the current record
@@ -64,13 +79,33 @@ type Reader struct {
 // and reading the next block into the buffer if necessary.
 func (r *Reader) nextChunk(wantFirst bool) error {
 	for {
-		if r.end+headerSize <= r.n {
+		if r.end+legacyHeaderSize <= r.n {
 			// Slide the [r.begin:r.end] window to the next record within r.buf[]
 			checksum := binary.LittleEndian.Uint32(r.buf[r.end : r.end+4])
 			length := binary.LittleEndian.Uint16(r.buf[r.end+4 : r.end+6])
 			chunkEncoding := r.buf[r.end+6]
 
-			if chunkEncoding > lastChunkEncoding {
+			if checksum == 0 && length == 0 && chunkEncoding == invalidChunkEncoding && r.end+recyclableHeaderSize > r.n {
+				// The writer pads the tail of a block which is too short
+				// for a recyclable header, skip to the next block.
+				r.end = r.n
+				continue
+			}
+
+			isRecyclable := chunkEncoding >= recyclableFullChunkEncoding && chunkEncoding <= recyclableLastChunkEncoding
+			if r.recyclable && !isRecyclable {
+				// a legacy chunk can't follow a recyclable one, it must be the
+				// stale content of a recycled file, or the zeroes of a
+				// preallocated one
+				if wantFirst {
+					return io.EOF
+				}
+
+				r.invalidOffset = uint64(r.blockNum)*BlockSize + uint64(r.begin)
+				return ErrInvalidChunk
+			}
+
+			if chunkEncoding > recyclableLastChunkEncoding {
 				r.invalidOffset = uint64(r.blockNum)*BlockSize + uint64(r.begin)
 				return ErrInvalidChunk
 			}
@@ -80,6 +115,34 @@ func (r *Reader) nextChunk(wantFirst bool) error {
 				return ErrZeroedChunk
 			}
 
+			headerSize := legacyHeaderSize
+			if isRecyclable {
+				headerSize = recyclableHeaderSize
+				if r.end+headerSize > r.n {
+					if r.recyclable && wantFirst {
+						// the log number of the chunk can't even be read, it
+						// is the stale content of a recycled file
+						return io.EOF
+					}
+
+					r.invalidOffset = uint64(r.blockNum)*BlockSize + uint64(r.begin)
+					return ErrInvalidChunk
+				}
+
+				if logNum := binary.LittleEndian.Uint32(r.buf[r.end+7 : r.end+11]); logNum != r.logNum {
+					if wantFirst {
+						// the chunk was written by a previous incarnation of
+						// the recycled file, which is the end of this log
+						return io.EOF
+					}
+
+					// don't read a partial record
+					r.invalidOffset = uint64(r.blockNum)*BlockSize + uint64(r.begin)
+					return ErrInvalidChunk
+				}
+				chunkEncoding -= recyclableFullChunkEncoding - fullChunkEncoding
+			}
+
 			r.begin = r.end + headerSize
 			r.end = r.begin + int(length)
 
@@ -95,6 +158,10 @@ func (r *Reader) nextChunk(wantFirst bool) error {
 				return ErrInvalidChunk
 			}
 
+			if headerSize == recyclableHeaderSize {
+				r.recyclable = true
+			}
+
 			if wantFirst {
 				if chunkEncoding != fullChunkEncoding && chunkEncoding != firstChunkEncoding {
 					// skip the orphan chunk
@@ -110,6 +177,11 @@ func (r *Reader) nextChunk(wantFirst bool) error {
 
 		if r.n < BlockSize && r.n > 0 {
 			// already at the last block in a file
+			if wantFirst && r.recyclable {
+				// the bytes following the last record of a recycled file,
+				// too short for a chunk header, are its stale content
+				return io.EOF
+			}
 			if !wantFirst || r.end != r.n {
 				// This can happen if the previous instance of the log ended with a
 				// partial block at the same blockNum as the new log but extended
@@ -137,10 +209,11 @@ func (r *Reader) nextChunk(wantFirst bool) error {
 
 // NewReader returns a new reader. The log number in those records must
 // match the specified logNum
-func NewReader(r io.Reader) *Reader {
+func NewReader(r io.Reader, logNum nogodb_common.DiskfileNum) *Reader {
 	return &Reader{
 		r:          r,
 		checksumer: nogodb_common.NewChecksumer(nogodb_common.CRC32Checksum),
+		logNum:     uint32(logNum),
 		blockNum:   -1,
 	}
 }
@@ -152,6 +225,9 @@ func (r *Reader) Next() (io.Reader, error) {
 	}
 
 	r.begin = r.end
+	// A recycled file isn't truncated, the chunks following the last record of
+	// the log are the stale content of the file. nextChunk tells them apart
+	// by their log number, any other invalid chunk is a corruption.
 	r.err = r.nextChunk(true)
 	if r.err != nil {
 		return nil, r.err
@@ -2,11 +2,13 @@ package record
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"strings"
 	"testing"
 
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -38,7 +40,7 @@ func testWriter(
 
 	reset()
 
-	r := NewReader(buf)
+	r := NewReader(buf, 0)
 	for {
 		s, ok := gen()
 		if !ok {
@@ -115,7 +117,7 @@ func Test_Flush(t *testing.T) {
 	require.Equal(t, 50038, buf.Len())
 
 	expecteds := []int64{1, 2, 10_000, 40_000}
-	rr := NewReader(buf)
+	rr := NewReader(buf, 0)
 	for _, expected := range expecteds {
 		r, _ := rr.Next()
 		n, err := io.Copy(io.Discard, r)
@@ -124,6 +126,174 @@ func Test_Flush(t *testing.T) {
 	}
 }
 
+func Test_Recyclable_Happycase(t *testing.T) {
+	ss := []string{
+		strings.Repeat("a", 1000),
+		strings.Repeat("b", 90000),
+		"",
+		strings.Repeat("c", BlockSize-recyclableHeaderSize-3),
+		strings.Repeat("d", 8000),
+	}
+
+	buf := new(bytes.Buffer)
+	writeRecyclable(t, buf, 7, ss)
+
+	r := NewReader(buf, 7)
+	for _, s := range ss {
+		rr, err := r.Next()
+		require.NoError(t, err)
+		x, err := io.ReadAll(rr)
+		require.NoError(t, err)
+		require.Equal(t, s, string(x))
+	}
+
+	_, err := r.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func Test_Recyclable_StaleTail(t *testing.T) {
+	// the previous incarnation of the file, written by the log 1
+	old := new(bytes.Buffer)
+	writeRecyclable(t, old, 1, []string{
+		strings.Repeat("x", 500),
+		strings.Repeat("y", 70000),
+		strings.Repeat("z", 20000),
+	})
+
+	for _, tc := range []struct {
+		name string
+		ss   []string
+	}{
+		{name: "empty log", ss: nil},
+		{name: "tail within the first block", ss: []string{strings.Repeat("a", 100)}},
+		{name: "tail in the middle of a stale chunk", ss: []string{strings.Repeat("a", 700)}},
+		{name: "tail after a few blocks", ss: []string{strings.Repeat("a", 40000), strings.Repeat("b", 3)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// the log 2 overwrites the file in place
+			file := bytes.Clone(old.Bytes())
+			curr := new(bytes.Buffer)
+			writeRecyclable(t, curr, 2, tc.ss)
+			require.Less(t, curr.Len(), len(file))
+			copy(file, curr.Bytes())
+
+			r := NewReader(bytes.NewReader(file), 2)
+			for _, s := range tc.ss {
+				rr, err := r.Next()
+				require.NoError(t, err)
+				x, err := io.ReadAll(rr)
+				require.NoError(t, err)
+				require.Equal(t, s, string(x))
+			}
+
+			_, err := r.Next()
+			require.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func Test_Zeroed_Tail(t *testing.T) {
+	ss := []string{strings.Repeat("a", 100), strings.Repeat("b", 40000)}
+
+	for _, tc := range []struct {
+		name    string
+		write   func(w io.Writer)
+		wantErr error
+	}{
+		{
+			name: "legacy log",
+			write: func(w io.Writer) {
+				wr := NewWriter(w)
+				for _, s := range ss {
+					rw, err := wr.Next()
+					require.NoError(t, err)
+					_, err = rw.Write([]byte(s))
+					require.NoError(t, err)
+				}
+				require.NoError(t, wr.Close())
+			},
+			// a legacy log is never recycled, the zeroes are a corruption
+			wantErr: ErrZeroedChunk,
+		},
+		{
+			name:    "recyclable log",
+			write:   func(w io.Writer) { writeRecyclable(t, w, 3, ss) },
+			wantErr: io.EOF,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			tc.write(buf)
+			// e.g. a preallocated file
+			buf.Write(make([]byte, 2*BlockSize))
+
+			r := NewReader(buf, 3)
+			for _, s := range ss {
+				rr, err := r.Next()
+				require.NoError(t, err)
+				x, err := io.ReadAll(rr)
+				require.NoError(t, err)
+				require.Equal(t, s, string(x))
+			}
+
+			_, err := r.Next()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func Test_Recyclable_Corruption(t *testing.T) {
+	ss := []string{strings.Repeat("a", 100), strings.Repeat("b", 200), strings.Repeat("c", 300)}
+	// the second record begins right after the first one
+	second := recyclableHeaderSize + len(ss[0])
+
+	for _, tc := range []struct {
+		name    string
+		corrupt func(file []byte)
+	}{
+		{
+			name:    "checksum mismatch",
+			corrupt: func(file []byte) { file[second+recyclableHeaderSize] ^= 0xFF },
+		},
+		{
+			name: "chunk straddling the end of file",
+			corrupt: func(file []byte) {
+				binary.LittleEndian.PutUint16(file[second+4:second+6], uint16(BlockSize-second))
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			writeRecyclable(t, buf, 3, ss)
+			file := buf.Bytes()
+			tc.corrupt(file)
+
+			r := NewReader(bytes.NewReader(file), 3)
+			rr, err := r.Next()
+			require.NoError(t, err)
+			x, err := io.ReadAll(rr)
+			require.NoError(t, err)
+			require.Equal(t, ss[0], string(x))
+
+			// the chunk carries the log number, it isn't the stale content
+			// of a recycled file
+			_, err = r.Next()
+			require.ErrorIs(t, err, ErrInvalidChunk)
+		})
+	}
+}
+
+func writeRecyclable(t *testing.T, w io.Writer, logNum int64, ss []string) {
+	wr := NewRecyclableWriter(w, nogodb_common.DiskfileNum(logNum))
+	for _, s := range ss {
+		rw, err := wr.Next()
+		require.NoError(t, err)
+		_, err = rw.Write([]byte(s))
+		require.NoError(t, err)
+	}
+	require.NoError(t, wr.Close())
+}
+
 func testLiterals(t *testing.T, s []string) {
 	var i int
 
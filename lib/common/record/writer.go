@@ -19,6 +19,11 @@ type flusher interface {
 type Writer struct {
 	w          io.Writer
 	checksumer nogodb_common.IChecksum
+	// headerSize is the size of the chunk headers, either legacyHeaderSize or
+	// recyclableHeaderSize
+	headerSize int
+	// logNum is embedded in the header of the recyclable chunks
+	logNum uint32
 	// seq is the sequence number of the current record.
 	seq int
 	// f is w as a flusher.
@@ -48,7 +53,19 @@ type Writer struct {
 	buf [BlockSize]byte
 }
 
+// NewWriter returns a writer producing the legacy chunks, without log number
 func NewWriter(w io.Writer) *Writer {
+	return newWriter(w, legacyHeaderSize, 0)
+}
+
+// NewRecyclableWriter returns a writer producing the recyclable chunks, whose
+// header embeds the logNum. It must be used when w might overwrite the content
+// of a recycled log file, so that the reader can tell the stale chunks apart.
+func NewRecyclableWriter(w io.Writer, logNum nogodb_common.DiskfileNum) *Writer {
+	return newWriter(w, recyclableHeaderSize, uint32(logNum))
+}
+
+func newWriter(w io.Writer, headerSize int, logNum uint32) *Writer {
 	f, _ := w.(flusher)
 
 	var o int64
@@ -64,32 +81,41 @@ func NewWriter(w io.Writer) *Writer {
 		w:                w,
 		f:                f,
 		checksumer:       nogodb_common.NewChecksumer(nogodb_common.CRC32Checksum),
+		headerSize:       headerSize,
+		logNum:           logNum,
 		baseOffset:       o,
 		lastRecordOffset: -1,
 	}
 }
 
 func (w *Writer) fillHeader(isLast bool) {
-	if w.begin+headerSize > w.end || w.end > BlockSize {
+	if w.begin+w.headerSize > w.end || w.end > BlockSize {
 		panic("writer has a bad state")
 	}
 
+	var chunkEncoding byte
 	if isLast {
 		if w.first {
-			w.buf[w.begin+6] = fullChunkEncoding
+			chunkEncoding = fullChunkEncoding
 		} else {
-			w.buf[w.begin+6] = lastChunkEncoding
+			chunkEncoding = lastChunkEncoding
 		}
 	} else {
 		if w.first {
-			w.buf[w.begin+6] = firstChunkEncoding
+			chunkEncoding = firstChunkEncoding
 		} else {
-			w.buf[w.begin+6] = middleChunkEncoding
+			chunkEncoding = middleChunkEncoding
 		}
 	}
 
+	if w.headerSize == recyclableHeaderSize {
+		chunkEncoding += recyclableFullChunkEncoding - fullChunkEncoding
+		binary.LittleEndian.PutUint32(w.buf[w.begin+7:w.begin+11], w.logNum)
+	}
+	w.buf[w.begin+6] = chunkEncoding
+
 	binary.LittleEndian.PutUint32(w.buf[w.begin:w.begin+4], w.checksumer.Checksum(w.buf[w.begin+6:w.end], auxilaryByte))
-	binary.LittleEndian.PutUint16(w.buf[w.begin+4:w.begin+6], uint16(w.end-w.begin-headerSize))
+	binary.LittleEndian.PutUint16(w.buf[w.begin+4:w.begin+6], uint16(w.end-w.begin-w.headerSize))
 }
 
 // writeBlock writes a whole block to the underlying writer, only triggered
@@ -97,7 +123,7 @@ func (w *Writer) fillHeader(isLast bool) {
 func (w *Writer) writeBlock() {
 	_, w.err = w.w.Write(w.buf[w.written:])
 	w.begin = 0
-	w.end = headerSize
+	w.end = w.headerSize
 	w.written = 0
 	w.blockNumber++
 }
@@ -133,7 +159,7 @@ func (w *Writer) Next() (io.Writer, error) {
 	}
 
 	w.begin = w.end
-	w.end = w.end + headerSize
+	w.end = w.end + w.headerSize
 
 	if w.end > BlockSize {
 		// it is overflow, need to write with padding
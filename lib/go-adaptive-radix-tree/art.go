@@ -92,6 +92,26 @@ func (t *Tree[V]) WalkBackwards(ctx context.Context, fn WalkFn[V]) {
 	internal.Walk(ctx, t.root, cb, internal.DescOrder)
 }
 
+func (t *Tree[V]) NewIterator(ctx context.Context) Iterator[V] {
+	return &treeIterator[V]{iter: internal.NewIterator(ctx, t.loadRoot)}
+}
+
+// loadRoot returns the current root node, which is swapped by the writers
+// under the lock of the virtual root.
+func (t *Tree[V]) loadRoot() internal.INode[V] {
+	for {
+		version, obsolete := t.vRoot.GetLocker().RLock()
+		if obsolete {
+			continue
+		}
+		root := t.root
+		if t.vRoot.GetLocker().RUnlock(version) {
+			continue
+		}
+		return root
+	}
+}
+
 func (t *Tree[V]) Visualize(ctx context.Context) {
 	internal.Visualize[V](ctx, t.root)
 }
@@ -110,4 +130,19 @@ func errorCategorisation[V any](v V, err error) (V, error) {
 	return v, categorisedErr
 }
 
+type treeIterator[V any] struct {
+	iter *internal.Iterator[V]
+}
+
+func (i *treeIterator[V]) First() bool         { return i.iter.First() }
+func (i *treeIterator[V]) Last() bool          { return i.iter.Last() }
+func (i *treeIterator[V]) SeekGE(key Key) bool { return i.iter.SeekGE(key) }
+func (i *treeIterator[V]) SeekLT(key Key) bool { return i.iter.SeekLT(key) }
+func (i *treeIterator[V]) Next() bool          { return i.iter.Next() }
+func (i *treeIterator[V]) Prev() bool          { return i.iter.Prev() }
+func (i *treeIterator[V]) Valid() bool         { return i.iter.Valid() }
+func (i *treeIterator[V]) Key() Key            { return i.iter.Key() }
+func (i *treeIterator[V]) Value() V            { return i.iter.Value() }
+
 var _ ITree[any] = (*Tree[any])(nil)
+var _ Iterator[any] = (*treeIterator[any])(nil)
@@ -950,6 +950,27 @@ func Test_ART_NodeTransitions(t *testing.T) {
 		_, err := art.Get(ctx, keys[0])
 		assert.ErrorIs(t, err, NonExist)
 	})
+
+	t.Run("0xff child next to the null child", func(t *testing.T) {
+		art := NewTree[int](ctx)
+
+		// "p" is the null child of the inner node, and "p\xff" must not
+		// overwrite it once the node grows to a node48 and a node256
+		keys := [][]byte{[]byte("p"), []byte("p\xff")}
+		for i := range 100 {
+			keys = append(keys, []byte{'p', byte(i)})
+		}
+		for i, k := range keys {
+			_, err := art.Insert(ctx, k, i)
+			require.NoError(t, err)
+		}
+
+		for i, k := range keys {
+			val, err := art.Get(ctx, k)
+			require.NoError(t, err)
+			assert.Equal(t, i, val)
+		}
+	})
 }
 
 func Test_ART_PrefixHandling(t *testing.T) {
@@ -22,5 +22,30 @@ type ITree[V any] interface {
 	Walk(ctx context.Context, fn WalkFn[V])
 	// WalkBackwards is used to walk the tree in reverse order
 	WalkBackwards(ctx context.Context, fn WalkFn[V])
+	// NewIterator returns an unpositioned iterator over the tree
+	NewIterator(ctx context.Context) Iterator[V]
 	// ...
 }
+
+// Iterator walks the tree in the key order. An Iterator is not safe for concurrent use,
+// but the tree can still be modified while it is being iterated.
+type Iterator[V any] interface {
+	// First moves the iterator to the smallest key, and returns if it is valid
+	First() bool
+	// Last moves the iterator to the largest key, and returns if it is valid
+	Last() bool
+	// SeekGE moves the iterator to the smallest key greater than or equal to the given key
+	SeekGE(key Key) bool
+	// SeekLT moves the iterator to the largest key strictly less than the given key
+	SeekLT(key Key) bool
+	// Next moves the iterator to the next key, and returns if it is valid
+	Next() bool
+	// Prev moves the iterator to the previous key, and returns if it is valid
+	Prev() bool
+	// Valid returns whether the iterator is positioned at a key
+	Valid() bool
+	// Key returns the current key. It must not be modified.
+	Key() Key
+	// Value returns the current value
+	Value() V
+}
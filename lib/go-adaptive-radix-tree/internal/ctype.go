@@ -66,6 +66,8 @@ type iNodeChildrenManager[V any] interface {
 	getChild(ctx context.Context, key *nodeKey) (*INode[V], error)
 	getAllChildren(ctx context.Context, order Order) []*INode[V]
 	getChildByIndex(ctx context.Context, idx uint8) (*nodeKey, *INode[V], error)
+	// getChildrenWithKeys returns a copy of the children and their keys, in ascending order
+	getChildrenWithKeys(ctx context.Context) ([]*nodeKey, []*INode[V])
 }
 
 type INode[V any] interface {
@@ -0,0 +1,299 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+)
+
+// Iterator walks the leaves of a tree in the key order. It keeps the path
+// from the root to the current leaf as a stack of the visited inner nodes,
+// so moving to the neighbour leaf only re-reads the nodes that are left or
+// entered, instead of descending from the root again.
+//
+// The children of an inner node are copied once the node is entered, hence
+// a concurrent insertion is observed only if it lands in a node that has not
+// been entered yet. A node that is replaced (grown, split) after being
+// entered keeps the copied children reachable, so the existing keys are
+// never skipped.
+type Iterator[V any] struct {
+	ctx context.Context
+	// root returns the current root of the tree
+	root  func() INode[V]
+	stack []iterFrame[V]
+
+	valid bool
+	key   []byte
+	value V
+}
+
+// iterFrame is an entered inner node, pos is the index of the child
+// the iterator is positioned in.
+type iterFrame[V any] struct {
+	keys     []*nodeKey
+	children []*INode[V]
+	pos      int
+}
+
+// nodeSnapshot is a consistent view of a node, taken under its read lock.
+type nodeSnapshot[V any] struct {
+	leaf     bool
+	prefix   []byte
+	keys     []*nodeKey
+	children []*INode[V]
+	value    V
+}
+
+func NewIterator[V any](ctx context.Context, root func() INode[V]) *Iterator[V] {
+	return &Iterator[V]{ctx: ctx, root: root}
+}
+
+func (it *Iterator[V]) Valid() bool {
+	return it.valid
+}
+
+func (it *Iterator[V]) Key() []byte {
+	return it.key
+}
+
+func (it *Iterator[V]) Value() V {
+	return it.value
+}
+
+// First moves the iterator to the smallest key
+func (it *Iterator[V]) First() bool {
+	it.reset()
+	return it.descend(it.root(), 1)
+}
+
+// Last moves the iterator to the largest key
+func (it *Iterator[V]) Last() bool {
+	it.reset()
+	return it.descend(it.root(), -1)
+}
+
+// Next moves the iterator to the next key. It returns false once the
+// iterator is exhausted.
+func (it *Iterator[V]) Next() bool {
+	if !it.valid {
+		return false
+	}
+
+	return it.step(1)
+}
+
+// Prev moves the iterator to the previous key. It returns false once the
+// iterator is exhausted.
+func (it *Iterator[V]) Prev() bool {
+	if !it.valid {
+		return false
+	}
+
+	return it.step(-1)
+}
+
+// SeekGE moves the iterator to the smallest key greater than or equal to
+// the given key.
+func (it *Iterator[V]) SeekGE(key []byte) bool {
+	it.reset()
+
+	node, offset := it.root(), 0
+	for {
+		snap, ok := takeSnapshot(it.ctx, node)
+		if !ok {
+			return it.step(1)
+		}
+
+		if snap.leaf {
+			if bytes.Compare(snap.prefix, key) >= 0 {
+				return it.setLeaf(snap)
+			}
+			return it.step(1)
+		}
+
+		switch comparePrefix(snap.prefix, key, offset) {
+		case 1:
+			// all the keys of the subtree are greater
+			return it.descendFrom(snap, 1)
+		case -1:
+			// all the keys of the subtree are smaller
+			return it.step(1)
+		}
+
+		offset += len(snap.prefix)
+		target := GetNodeKey(key, offset)
+		pos := 0
+		for pos < len(snap.keys) && snap.keys[pos].Compare(target) < 0 {
+			pos++
+		}
+		if pos == len(snap.keys) {
+			return it.step(1)
+		}
+
+		it.stack = append(it.stack, iterFrame[V]{keys: snap.keys, children: snap.children, pos: pos})
+		if snap.keys[pos].Compare(target) != 0 || target.IsNull() {
+			// the child holds either greater keys, or the key itself
+			return it.descend(*snap.children[pos], 1)
+		}
+
+		node, offset = *snap.children[pos], offset+1
+	}
+}
+
+// SeekLT moves the iterator to the largest key strictly less than the
+// given key.
+func (it *Iterator[V]) SeekLT(key []byte) bool {
+	it.reset()
+
+	node, offset := it.root(), 0
+	for {
+		snap, ok := takeSnapshot(it.ctx, node)
+		if !ok {
+			return it.step(-1)
+		}
+
+		if snap.leaf {
+			if bytes.Compare(snap.prefix, key) < 0 {
+				return it.setLeaf(snap)
+			}
+			return it.step(-1)
+		}
+
+		switch comparePrefix(snap.prefix, key, offset) {
+		case 1:
+			// all the keys of the subtree are greater
+			return it.step(-1)
+		case -1:
+			// all the keys of the subtree are smaller
+			return it.descendFrom(snap, -1)
+		}
+
+		offset += len(snap.prefix)
+		target := GetNodeKey(key, offset)
+		pos := len(snap.keys) - 1
+		for pos >= 0 && snap.keys[pos].Compare(target) > 0 {
+			pos--
+		}
+		if pos < 0 {
+			return it.step(-1)
+		}
+
+		it.stack = append(it.stack, iterFrame[V]{keys: snap.keys, children: snap.children, pos: pos})
+		if snap.keys[pos].Compare(target) != 0 {
+			return it.descend(*snap.children[pos], -1)
+		}
+		if target.IsNull() {
+			// the child is the key itself, which is not strictly less
+			return it.step(-1)
+		}
+
+		node, offset = *snap.children[pos], offset+1
+	}
+}
+
+func (it *Iterator[V]) reset() {
+	it.stack = it.stack[:0]
+	it.valid = false
+	it.key = nil
+	it.value = *new(V)
+}
+
+// step moves to the neighbour leaf in the given direction (1 or -1), by
+// leaving the exhausted inner nodes and entering the next child.
+func (it *Iterator[V]) step(dir int) bool {
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		top.pos += dir
+		if top.pos < 0 || top.pos >= len(top.children) {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+
+		return it.descend(*top.children[top.pos], dir)
+	}
+
+	it.reset()
+	return false
+}
+
+// descend enters node and its first (dir = 1) or last (dir = -1) children
+// down to a leaf.
+func (it *Iterator[V]) descend(node INode[V], dir int) bool {
+	snap, ok := takeSnapshot(it.ctx, node)
+	if !ok {
+		return it.step(dir)
+	}
+
+	return it.descendFrom(snap, dir)
+}
+
+func (it *Iterator[V]) descendFrom(snap nodeSnapshot[V], dir int) bool {
+	for !snap.leaf {
+		if len(snap.children) == 0 {
+			return it.step(dir)
+		}
+
+		pos := 0
+		if dir < 0 {
+			pos = len(snap.children) - 1
+		}
+		it.stack = append(it.stack, iterFrame[V]{keys: snap.keys, children: snap.children, pos: pos})
+
+		var ok bool
+		if snap, ok = takeSnapshot(it.ctx, *snap.children[pos]); !ok {
+			return it.step(dir)
+		}
+	}
+
+	return it.setLeaf(snap)
+}
+
+func (it *Iterator[V]) setLeaf(snap nodeSnapshot[V]) bool {
+	it.valid = true
+	it.key = snap.prefix
+	it.value = snap.value
+	return true
+}
+
+// takeSnapshot reads the node under its read lock. It returns false if the
+// node doesn't exist anymore.
+func takeSnapshot[V any](ctx context.Context, node INode[V]) (nodeSnapshot[V], bool) {
+	for {
+		if node == nil || node.isDeleted(ctx) {
+			return nodeSnapshot[V]{}, false
+		}
+
+		version, obsolete := node.GetLocker().RLock()
+		if obsolete {
+			continue
+		}
+
+		// Note: the prefix is never modified in place (see setPrefix),
+		// so it's safe to keep a reference to it
+		var snap nodeSnapshot[V]
+		snap.prefix = node.getPrefix(ctx)
+		if node.GetKind(ctx) == KindNodeLeaf {
+			snap.leaf = true
+			snap.value = node.getValue(ctx)
+		} else {
+			snap.keys, snap.children = node.getChildrenWithKeys(ctx)
+		}
+
+		if node.GetLocker().RUnlock(version) {
+			continue
+		}
+
+		return snap, true
+	}
+}
+
+// comparePrefix compares the compressed path of an inner node with the
+// same portion of the key. A key that ends within the prefix is smaller
+// than all the keys of the node.
+func comparePrefix(prefix, key []byte, offset int) int {
+	end := min(len(key), offset+len(prefix))
+	if offset > end {
+		return 1
+	}
+
+	return bytes.Compare(prefix, key[offset:end])
+}
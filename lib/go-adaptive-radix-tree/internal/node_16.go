@@ -144,6 +144,15 @@ func (n *Node16[V]) getChildByIndex(ctx context.Context, idx uint8) (*nodeKey, *
 	return n.keys[pos], n.children[pos], nil
 }
 
+func (n *Node16[V]) getChildrenWithKeys(ctx context.Context) ([]*nodeKey, []*INode[V]) {
+	currLen := n.getChildrenLen(ctx)
+	keys := make([]*nodeKey, currLen)
+	children := make([]*INode[V], currLen)
+	copy(keys, n.keys[Node16KeysMax-currLen:])
+	copy(children, n.children[Node16KeysMax-currLen:])
+	return keys, children
+}
+
 // grow to node48
 func (n *Node16[V]) grow(ctx context.Context) (*INode[V], error) { //nolint:unused
 	if n.getChildrenLen(ctx) != Node16KeysMax {
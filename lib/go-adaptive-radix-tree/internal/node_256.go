@@ -43,7 +43,7 @@ func (n *Node256[V]) getIdx(key *nodeKey) int {
 		return 0
 	}
 
-	return int(key.b + 1)
+	return int(key.b) + 1
 }
 
 func (n *Node256[V]) getKeyFromIdx(idx int) *nodeKey {
@@ -132,6 +132,20 @@ func (n *Node256[V]) getChildByIndex(ctx context.Context, idx uint8) (*nodeKey,
 	return nil, nil, childNodeNotFound
 }
 
+func (n *Node256[V]) getChildrenWithKeys(ctx context.Context) ([]*nodeKey, []*INode[V]) {
+	keys := make([]*nodeKey, 0, n.getChildrenLen(ctx))
+	children := make([]*INode[V], 0, n.getChildrenLen(ctx))
+	for k := range int(Node256PointersMax) {
+		child := n.children[k]
+		if child == nil {
+			continue
+		}
+		keys = append(keys, n.getKeyFromIdx(k))
+		children = append(children, child)
+	}
+	return keys, children
+}
+
 func (n *Node256[V]) grow(ctx context.Context) (*INode[V], error) { //nolint:unused
 	return nil, fmt.Errorf("node256 can not grow anymore")
 }
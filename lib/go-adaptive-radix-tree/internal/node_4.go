@@ -142,6 +142,15 @@ func (n *Node4[V]) getChildByIndex(ctx context.Context, idx uint8) (*nodeKey, *I
 	return n.keys[pos], n.children[pos], nil
 }
 
+func (n *Node4[V]) getChildrenWithKeys(ctx context.Context) ([]*nodeKey, []*INode[V]) {
+	currLen := n.getChildrenLen(ctx)
+	keys := make([]*nodeKey, currLen)
+	children := make([]*INode[V], currLen)
+	copy(keys, n.keys[Node4KeysMax-currLen:])
+	copy(children, n.children[Node4KeysMax-currLen:])
+	return keys, children
+}
+
 // grow to Node16
 func (n *Node4[V]) grow(ctx context.Context) (*INode[V], error) { //nolint:unused
 	if n.getChildrenLen(ctx) != Node4KeysMax {
@@ -38,7 +38,7 @@ func (n *Node48[V]) getIdx(key *nodeKey) int {
 		return 0
 	}
 
-	return int(key.b + 1)
+	return int(key.b) + 1
 }
 
 func (n *Node48[V]) getKeyFromIdx(idx int) *nodeKey {
@@ -195,6 +195,20 @@ func (n *Node48[V]) getChildByIndex(ctx context.Context, idx uint8) (*nodeKey, *
 	return nil, nil, childNodeNotFound
 }
 
+func (n *Node48[V]) getChildrenWithKeys(ctx context.Context) ([]*nodeKey, []*INode[V]) {
+	keys := make([]*nodeKey, 0, n.getChildrenLen(ctx))
+	children := make([]*INode[V], 0, n.getChildrenLen(ctx))
+	for k := range int(Node48KeysLen) {
+		if n.keys[k] == 0 {
+			// Key k-th is not exist yet
+			continue
+		}
+		keys = append(keys, n.getKeyFromIdx(k))
+		children = append(children, n.children[n.keys[k]-1])
+	}
+	return keys, children
+}
+
 // grow to node256
 func (n *Node48[V]) grow(ctx context.Context) (*INode[V], error) { //nolint:unused
 	if n.getChildrenLen(ctx) != Node48PointersMax {
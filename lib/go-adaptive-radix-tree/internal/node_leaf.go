@@ -47,6 +47,10 @@ func (n *NodeLeaf[V]) getChildByIndex(ctx context.Context, idx uint8) (*nodeKey,
 	return nil, nil, nil // node leaf doesn't support this function
 }
 
+func (n *NodeLeaf[V]) getChildrenWithKeys(ctx context.Context) ([]*nodeKey, []*INode[V]) { //nolint:unused
+	return nil, nil // node leaf doesn't support this function
+}
+
 func (n *NodeLeaf[V]) grow(ctx context.Context) (*INode[V], error) { //nolint:unused
 	return nil, nil // node leaf doesn't support this function
 }
@@ -0,0 +1,177 @@
+//go:build !race
+
+package go_adaptive_radix_tree
+
+// NOTE: golang race detector won't be able to recognize correctness of the optimistic locking
+// and will report races if tests are executed with -race flag
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+// randomIterKeys returns distinct keys over a small alphabet, so that many
+// keys are prefixes of each other and all the node kinds are exercised.
+func randomIterKeys(r *rand.Rand, n int) [][]byte {
+	seen := make(map[string]struct{}, n)
+	keys := make([][]byte, 0, n)
+	for len(keys) < n {
+		key := make([]byte, 1+r.IntN(6))
+		for i := range key {
+			if r.IntN(4) == 0 {
+				key[i] = byte(r.IntN(256))
+			} else {
+				key[i] = byte('a' + r.IntN(3))
+			}
+		}
+		if _, ok := seen[string(key)]; ok {
+			continue
+		}
+		seen[string(key)] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func newIterTestTree(t *testing.T, keys [][]byte) *Tree[string] {
+	ctx := context.Background()
+	tree := NewTree[string](ctx)
+	for _, k := range keys {
+		_, err := tree.Insert(ctx, k, string(k))
+		require.NoError(t, err)
+	}
+	return tree
+}
+
+func Test_Iterator_Empty_Tree(t *testing.T) {
+	iter := NewTree[string](context.Background()).NewIterator(context.Background())
+	assert.False(t, iter.First())
+	assert.False(t, iter.Last())
+	assert.False(t, iter.SeekGE([]byte("a")))
+	assert.False(t, iter.SeekLT([]byte("a")))
+	assert.False(t, iter.Next())
+	assert.False(t, iter.Prev())
+	assert.False(t, iter.Valid())
+}
+
+func Test_Iterator_Walks_In_Order(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 17, 49, 300, 2000} {
+		t.Run(fmt.Sprintf("%d keys", n), func(t *testing.T) {
+			r := rand.New(rand.NewPCG(uint64(n), 0))
+			keys := randomIterKeys(r, n)
+			tree := newIterTestTree(t, keys)
+			slices.SortFunc(keys, bytes.Compare)
+
+			iter := tree.NewIterator(context.Background())
+			var got [][]byte
+			for ok := iter.First(); ok; ok = iter.Next() {
+				assert.Equal(t, string(iter.Key()), iter.Value())
+				got = append(got, []byte(iter.Key()))
+			}
+			assert.Equal(t, keys, got)
+			assert.False(t, iter.Valid())
+
+			got = got[:0]
+			for ok := iter.Last(); ok; ok = iter.Prev() {
+				got = append(got, []byte(iter.Key()))
+			}
+			slices.Reverse(got)
+			assert.Equal(t, keys, got)
+
+			// change the direction in the middle
+			require.True(t, iter.First())
+			for i := 1; i < len(keys); i++ {
+				require.True(t, iter.Next())
+				require.True(t, iter.Prev())
+				require.Equal(t, keys[i-1], []byte(iter.Key()))
+				require.True(t, iter.Next())
+				require.Equal(t, keys[i], []byte(iter.Key()))
+			}
+		})
+	}
+}
+
+func Test_Iterator_Seek(t *testing.T) {
+	r := rand.New(rand.NewPCG(42, 0))
+	keys := randomIterKeys(r, 1000)
+	tree := newIterTestTree(t, keys[:500])
+	inserted := slices.Clone(keys[:500])
+	slices.SortFunc(inserted, bytes.Compare)
+
+	// probe both the inserted keys and the missing ones
+	probes := append(slices.Clone(keys), []byte{}, []byte{0x00}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	iter := tree.NewIterator(context.Background())
+	for _, probe := range probes {
+		i := sort.Search(len(inserted), func(i int) bool { return bytes.Compare(inserted[i], probe) >= 0 })
+
+		if i < len(inserted) {
+			require.True(t, iter.SeekGE(probe), "SeekGE(%q)", probe)
+			require.Equal(t, inserted[i], []byte(iter.Key()), "SeekGE(%q)", probe)
+			if i+1 < len(inserted) {
+				require.True(t, iter.Next())
+				require.Equal(t, inserted[i+1], []byte(iter.Key()), "SeekGE(%q).Next()", probe)
+			}
+		} else {
+			require.False(t, iter.SeekGE(probe), "SeekGE(%q)", probe)
+		}
+
+		if i > 0 {
+			require.True(t, iter.SeekLT(probe), "SeekLT(%q)", probe)
+			require.Equal(t, inserted[i-1], []byte(iter.Key()), "SeekLT(%q)", probe)
+			if i > 1 {
+				require.True(t, iter.Prev())
+				require.Equal(t, inserted[i-2], []byte(iter.Key()), "SeekLT(%q).Prev()", probe)
+			}
+		} else {
+			require.False(t, iter.SeekLT(probe), "SeekLT(%q)", probe)
+		}
+	}
+}
+
+func Test_Iterator_Concurrent_Inserts(t *testing.T) {
+	ctx := context.Background()
+	r := rand.New(rand.NewPCG(7, 0))
+	keys := randomIterKeys(r, 4000)
+	existing, added := keys[:2000], keys[2000:]
+	tree := newIterTestTree(t, existing)
+	slices.SortFunc(existing, bytes.Compare)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		for _, k := range added {
+			if _, err := tree.Insert(ctx, k, string(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	// the keys inserted before the iteration are never skipped, and the
+	// iteration stays sorted
+	for range 5 {
+		iter := tree.NewIterator(ctx)
+		var prev []byte
+		pos := 0
+		for ok := iter.First(); ok; ok = iter.Next() {
+			if prev != nil {
+				require.Equal(t, -1, bytes.Compare(prev, iter.Key()))
+			}
+			prev = iter.Key()
+			if pos < len(existing) && bytes.Equal(existing[pos], iter.Key()) {
+				pos++
+			}
+		}
+		require.Equal(t, len(existing), pos)
+	}
+
+	require.NoError(t, eg.Wait())
+}
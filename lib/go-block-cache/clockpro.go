@@ -6,15 +6,29 @@ import (
 	"unsafe"
 )
 
+// clockPro is the CLOCK-Pro replacement, with the priority pools:
+//   - the PriorityHigh nodes are inserted as hot, and aren't turned cold, as
+//     long as they fit in highPoolRatio of the capacity
+//   - the PriorityBottom nodes never turn hot, and are evicted right away,
+//     without a test period, so that e.g. a long scan doesn't inflate the
+//     cold target
+//
+// The pinned nodes are taken out of the clock until they're unpinned.
 type clockPro struct {
 	mu sync.RWMutex
 
-	maxSize int64 // max (hot + cold) memory that the shard can hold
+	maxSize int64 // max (hot + cold + pinned) memory that the shard can hold
 	maxCold int64 // max cold memory that the shard can hold
+	maxHigh int64 // max hot memory of the high priority nodes that is protected
+
+	highPoolRatio float64
 
 	sizeHot  int64
 	sizeCold int64
 	sizeTest int64
+	// sizeHighHot is the part of sizeHot of the logs whose pool is PriorityHigh
+	sizeHighHot int64
+	sizePinned  int64
 
 	handHot  *log
 	handCold *log
@@ -26,7 +40,7 @@ func (c *clockPro) GetInUsed() int64 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	inUsed := c.sizeHot + c.sizeCold
+	inUsed := c.sizeHot + c.sizeCold + c.sizePinned
 
 	return inUsed
 }
@@ -43,9 +57,17 @@ func (c *clockPro) Promote(node *kv, diffSize int64, o op) bool {
 	case opSet:
 		switch {
 		case node.log == nil:
-			// new node that is not yet in the cache,
-			// move it to the cold node
+			// new node that is not yet in the cache, move it to the cold
+			// node, or to the hot node within the high priority pool
 			node.usedBit = false
+			if node.priority == PriorityHigh && c.highHotProtected(node.size) {
+				if !c.logAdd(node, hot) {
+					return false
+				}
+				c.addHot((*log)(node.log), node.size)
+				break
+			}
+
 			if !c.logAdd(node, cold) {
 				return false
 			}
@@ -54,28 +76,34 @@ func (c *clockPro) Promote(node *kv, diffSize int64, o op) bool {
 		case (*log)(atomic.LoadPointer(&node.log)).clockType == test:
 			// belongs to the test nodes
 			// revive it from test to hot node
+			oldSize := node.size - diffSize
 			if !c.logDel(node) {
 				return false
 			}
 
 			node.usedBit = false
 			if c.maxCold < c.maxSize {
-				c.maxCold += diffSize
+				c.maxCold += node.size
 			}
-			c.sizeTest -= diffSize
+			c.sizeTest -= oldSize
 
 			if !c.logAdd(node, hot) {
 				return false
 			}
-			c.sizeHot += diffSize
+			c.addHot((*log)(node.log), node.size)
 
 		default:
-			// belongs to the hot or cold nodes
+			// belongs to the hot, cold or pinned nodes
 			node.usedBit = true
 			l := (*log)(node.log)
-			if l.clockType == hot {
-				c.sizeHot += diffSize
-			} else {
+			switch {
+			case atomic.LoadInt32(&node.pinned) > 0:
+				c.sizePinned += diffSize
+			case l.clockType == hot:
+				// the priority might have changed along with the value
+				c.addHot(l, diffSize-node.size)
+				c.addHot(l, node.size)
+			default:
 				c.sizeCold += diffSize
 			}
 
@@ -95,10 +123,17 @@ func (c *clockPro) Evict(node *kv) {
 	if node.log == nil {
 		return
 	}
-	clockType := (*log)(atomic.LoadPointer(&node.log)).clockType
-	switch clockType {
+	l := (*log)(atomic.LoadPointer(&node.log))
+	if atomic.LoadInt32(&node.pinned) > 0 {
+		// not in the clock
+		c.sizePinned -= node.size
+		node.log = nil
+		return
+	}
+
+	switch l.clockType {
 	case hot:
-		c.sizeHot -= node.size
+		c.addHot(l, -node.size)
 	case cold:
 		c.sizeCold -= node.size
 	case test:
@@ -109,19 +144,101 @@ func (c *clockPro) Evict(node *kv) {
 	c.logDel(node)
 }
 
+func (c *clockPro) Pin(node *kv) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := (*log)(atomic.LoadPointer(&node.log))
+	if l == nil || l.clockType == test {
+		return false
+	}
+
+	if atomic.AddInt32(&node.pinned, 1) == 1 {
+		if l.clockType == hot {
+			c.addHot(l, -node.size)
+		} else {
+			c.sizeCold -= node.size
+		}
+		c.sizePinned += node.size
+		c.logUnlink(l)
+	}
+	return true
+}
+
+func (c *clockPro) Unpin(node *kv) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if atomic.AddInt32(&node.pinned, -1) > 0 {
+		return
+	}
+
+	l := (*log)(atomic.LoadPointer(&node.log))
+	if l == nil {
+		// evicted while pinned
+		return
+	}
+
+	c.sizePinned -= node.size
+	c.logLink(l)
+	if l.clockType == hot {
+		c.addHot(l, node.size)
+	} else {
+		c.sizeCold += node.size
+	}
+	c.evict()
+}
+
 func (c *clockPro) SetCapacity(capacity int64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.maxSize = capacity
+	c.maxHigh = int64(float64(capacity) * c.highPoolRatio)
 	c.evict()
 }
 
+// addHot accounts the size of a hot log, the log joins the pool of its node's
+// priority if it's added
+func (c *clockPro) addHot(l *log, size int64) {
+	if size > 0 {
+		l.pool = l.n.priority
+	}
+	c.sizeHot += size
+	if l.pool == PriorityHigh {
+		c.sizeHighHot += size
+	}
+}
+
+// highHotProtected returns whether the hot nodes of the high priority pool,
+// plus extra, fit in the pool, they're then not turned cold
+func (c *clockPro) highHotProtected(extra int64) bool {
+	return c.sizeHighHot+extra <= c.maxHigh && c.sizeHighHot+extra+c.sizePinned <= c.maxSize
+}
+
+// coldTarget is the max cold memory, leaving the room of the pinned nodes and
+// of the protected high priority nodes
+func (c *clockPro) coldTarget() int64 {
+	reserved := c.sizePinned
+	if c.highHotProtected(0) {
+		reserved += c.sizeHighHot
+	}
+	return min(c.maxCold, max(c.maxSize-reserved, 0))
+}
+
 // logAdd adds new log after the handHot
 // and evicts data if needed
 func (c *clockPro) logAdd(node *kv, ct clockType) bool {
 	c.evict()
 	l := &log{n: node, clockType: ct}
+	c.logLink(l)
+	node.log = unsafe.Pointer(l)
+
+	return true
+}
+
+// logLink links the log after the handHot
+func (c *clockPro) logLink(l *log) {
 	l.next = l
 	l.prev = l
 	if c.handHot == nil {
@@ -135,16 +252,18 @@ func (c *clockPro) logAdd(node *kv, ct clockType) bool {
 	if c.handCold == c.handHot {
 		c.handCold = c.handCold.prev
 	}
-
-	node.log = unsafe.Pointer(l)
-
-	return true
 }
 
 func (c *clockPro) logDel(node *kv) bool {
 	l := (*log)(node.log)
 	node.log = nil
+	c.logUnlink(l)
 
+	return true
+}
+
+// logUnlink unlinks the log from the clock, the node still points to it
+func (c *clockPro) logUnlink(l *log) {
 	switch l {
 	case c.handHot:
 		c.handHot = c.handHot.prev
@@ -160,12 +279,10 @@ func (c *clockPro) logDel(node *kv) bool {
 		c.handCold = nil
 		c.handTest = nil
 	}
-
-	return true
 }
 
 func (c *clockPro) evict() {
-	for c.handCold != nil && c.sizeHot+c.sizeCold > c.maxSize {
+	for c.handCold != nil && c.sizeHot+c.sizeCold > 0 && c.sizeHot+c.sizeCold+c.sizePinned > c.maxSize {
 		c.runHandCold()
 	}
 }
@@ -174,19 +291,35 @@ func (c *clockPro) runHandCold() {
 	if c.handCold.clockType == cold {
 		n := c.handCold.n
 		ref := n.usedBit
-		c.sizeCold -= n.size
 
-		if ref {
+		switch {
+		case n.priority == PriorityBottom && ref:
+			// second chance, the bottom priority nodes stay cold
+			n.usedBit = false
+		case n.priority == PriorityBottom:
+			// remove the node entirely, without a test period
+			c.mu.Unlock()
+			n.s.mu.Lock()
+			n.s.evict(n)
+			n.s.mu.Unlock()
+			c.mu.Lock()
+			if c.handCold == nil {
+				return
+			}
+		case ref:
 			// move to hot
 			n.usedBit = false
+			c.sizeCold -= n.size
 			c.handCold.clockType = hot
-			c.sizeHot += n.size
-		} else {
+			c.addHot(c.handCold, n.size)
+		default:
 			// move to test and de-allocate the node's value
 			// but still keep its meta
 			//
 			// Hacky: Keep the current node's size, so when the test node
 			// get removed, we can still calculate the size correctly
+			c.sizeCold -= n.size
+			n.s.notifyEvict(n)
 			n.SetValue(nil, n.size)
 			c.handCold.clockType = test
 			c.sizeTest += n.size
@@ -196,9 +329,12 @@ func (c *clockPro) runHandCold() {
 		}
 	}
 
+	if c.handCold == nil {
+		return
+	}
 	c.handCold = c.handCold.next
 
-	for c.handHot != nil && c.maxCold+c.sizeHot > c.maxSize {
+	for c.handHot != nil && c.coldTarget()+c.sizeHot+c.sizePinned > c.maxSize {
 		c.runHandHot()
 	}
 }
@@ -213,13 +349,15 @@ func (c *clockPro) runHandHot() {
 
 	n := c.handHot.n
 	if c.handHot.clockType == hot {
-		ref := n.usedBit
-		if ref {
+		switch {
+		case n.usedBit:
 			n.usedBit = false
-		} else {
+		case c.handHot.pool == PriorityHigh && c.highHotProtected(0):
+			// stay hot within the high priority pool
+		default:
 			// move to cold
 			c.handHot.clockType = cold
-			c.sizeHot -= n.size
+			c.addHot(c.handHot, -n.size)
 			c.sizeCold += n.size
 		}
 	}
@@ -251,8 +389,8 @@ func (c *clockPro) runHandTest() {
 	c.handTest = c.handTest.next
 }
 
-func NewClockPro(maxSize int64) *clockPro {
-	c := &clockPro{}
+func NewClockPro(maxSize int64, highPoolRatio float64) *clockPro {
+	c := &clockPro{highPoolRatio: highPoolRatio}
 	c.SetCapacity(maxSize)
 	return c
 }
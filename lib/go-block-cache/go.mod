@@ -2,7 +2,15 @@ module github.com/datnguyenzzz/nogodb/lib/go-block-cache
 
 go 1.26.0
 
+replace github.com/datnguyenzzz/nogodb/lib/go-fs => ../go-fs
+
+replace github.com/datnguyenzzz/nogodb/lib/common => ../common
+
+replace github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool => ../go-bytesbufferpool
+
 require (
+	github.com/datnguyenzzz/nogodb/lib/common v0.0.0-00010101000000-000000000000
+	github.com/datnguyenzzz/nogodb/lib/go-fs v0.0.0-00010101000000-000000000000
 	github.com/dgraph-io/ristretto/v2 v2.3.0
 	github.com/stretchr/testify v1.11.1
 	github.com/twmb/murmur3 v1.1.8
@@ -12,10 +20,11 @@ require (
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool v0.0.0-00010101000000-000000000000 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
-	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/sys v0.44.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -7,7 +7,14 @@ type LazyValue interface {
 
 type IBlockCache interface {
 	Get(fileNum, key uint64) (LazyValue, bool)
+	// Set sets the value with PriorityLow
 	Set(fileNum, key uint64, value Value) bool
+	// SetWithPriority sets the value with the given priority, see Priority
+	SetWithPriority(fileNum, key uint64, value Value, prio Priority) bool
+	// Pin gets the value and pins it in the cache, it's never evicted under
+	// memory pressure until the returned value is released. The pinned
+	// values still count in the cache usage.
+	Pin(fileNum, key uint64) (LazyValue, bool)
 	Delete(fileNum, key uint64) bool
 	Close()
 	SetCapacity(capacity int64)
@@ -26,5 +33,9 @@ type ICacher interface {
 	// diffSize is the size difference between the new value and the old value of the node
 	Promote(node *kv, diffSize int64, o op) bool
 	Evict(node *kv)
+	// Pin excludes the node from the evictions until it's unpinned as many
+	// times as it's pinned, it returns false if the node isn't cached
+	Pin(node *kv) bool
+	Unpin(node *kv)
 	SetCapacity(capacity int64)
 }
@@ -21,7 +21,8 @@ func (h *handle) Release() {
 	if atomic.CompareAndSwapPointer(&h.n, nPtr, nil) {
 		n := (*kv)(nPtr)
 
-		if atomic.AddInt32(&n.ref, -1) <= 0 {
+		// the pinned nodes are only evicted once unpinned
+		if atomic.AddInt32(&n.ref, -1) <= 0 && atomic.LoadInt32(&n.pinned) == 0 {
 			n.s.mu.Lock()
 			_ = n.s.evict(n)
 			n.s.mu.Unlock()
@@ -37,7 +38,36 @@ func (h *handle) Load() Value {
 	return n.value
 }
 
-var _ LazyValue = (*handle)(nil)
+// pinHandle is the handle of a pinned kv, its release unpins the kv
+type pinHandle struct {
+	// point to *kv, nil once released
+	n unsafe.Pointer
+}
+
+func (h *pinHandle) Release() {
+	nPtr := atomic.LoadPointer(&h.n)
+	if nPtr == nil {
+		return
+	}
+
+	if atomic.CompareAndSwapPointer(&h.n, nPtr, nil) {
+		n := (*kv)(nPtr)
+		n.s.cacher.Unpin(n)
+	}
+}
+
+func (h *pinHandle) Load() Value {
+	n := (*kv)(atomic.LoadPointer(&h.n))
+	if n == nil {
+		return nil
+	}
+	return n.value
+}
+
+var (
+	_ LazyValue = (*handle)(nil)
+	_ LazyValue = (*pinHandle)(nil)
+)
 
 type kv struct {
 	mu sync.Mutex
@@ -58,6 +88,12 @@ type kv struct {
 	// that information is used for CLOCK-pro eviction algorithm
 	usedBit bool
 
+	// priority is the priority the kv was set with, see Priority
+	priority Priority
+	// pinned counts the pins of the kv, it's not evicted while pinned. It's
+	// updated by the ICacher, under its lock
+	pinned int32
+
 	// log points to the metadata log in the cache eviction list
 	log unsafe.Pointer
 }
@@ -15,6 +15,13 @@ type log struct {
 	n          *kv
 	prev, next *log
 	clockType  clockType
+
+	// pool is the priority pool whose LRU list holds the log
+	pool Priority
+	// charge is the size of the node accounted in the LRU
+	charge int64
+	// segment is the segment of the tinyLFU whose LRU list holds the log
+	segment lfuSegment
 }
 
 func (l *log) remove() {
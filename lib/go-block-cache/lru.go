@@ -8,71 +8,92 @@ import (
 	"go.uber.org/zap"
 )
 
+// lru is an LRU split into the priority pools, inspired by RocksDB. Each pool
+// is an LRU list, the values are inserted into the pool of their priority:
+//   - the high priority pool holds up to highPoolRatio of the capacity, its
+//     least recent values overflow into the low priority pool
+//   - the low priority pool holds up to lowPoolRatio of the capacity, its
+//     least recent values overflow into the bottom priority pool
+//   - the evictions start from the least recent values of the bottom pool,
+//     then of the low pool, then of the high pool
+//
+// Hence, e.g. the blocks of a long scan, set with PriorityBottom, only evict
+// each other and the overflowed values, but not the index and filter blocks.
+// The pinned nodes are taken out of the pools until they're unpinned.
 type lru struct {
 	inUse    int64
 	capacity int64
 
+	highPoolRatio, lowPoolRatio float64
+
 	mu sync.Mutex
 
-	// recent dummy node.
+	// recent dummy node of each pool.
 	//   dummy recent <--> 1st most recent  <--> 2nd most recent
 	//   ^                                                     ^
 	//   |                                                     |
 	//   v                                                     v
 	//   least recent <-->       ...       <--> K-th most recent
-	recent *log
+	recent    [numPriorities]*log
+	poolSizes [numPriorities]int64
 }
 
-func newLRU(maxSize int64) *lru {
-	dummy := new(log)
-	dummy.next = dummy
-	dummy.prev = dummy
-	return &lru{
-		capacity: maxSize,
-		recent:   dummy,
+func newLRU(maxSize int64, highPoolRatio, lowPoolRatio float64) *lru {
+	l := &lru{
+		capacity:      maxSize,
+		highPoolRatio: highPoolRatio,
+		lowPoolRatio:  lowPoolRatio,
+	}
+	for pool := range l.recent {
+		dummy := new(log)
+		dummy.next = dummy
+		dummy.prev = dummy
+		l.recent[pool] = dummy
 	}
+	return l
 }
 
 func (l *lru) SetCapacity(capacity int64) {
 	l.mu.Lock()
 	l.capacity = capacity
+	l.balancePools()
 	evicted := l.balance()
 	l.mu.Unlock()
 
-	for _, n := range evicted {
-		n.s.mu.Lock()
-		n.s.evict(n)
-		n.s.mu.Unlock()
-	}
+	evictFromShards(evicted)
 }
 
 func (l *lru) Promote(node *kv, diffSize int64, o op) bool {
 	l.mu.Lock()
-	if node.log == nil {
+	lruLog := (*log)(node.log)
+	if lruLog == nil {
 		// the key/value pair is updated for the first time
 		if node.size > l.capacity {
+			l.mu.Unlock()
 			zap.L().Error("node is bigger than eviction value")
 			return false
 		}
 
-		log := &log{n: node}
-		l.recent.insert(log)
-		node.log = unsafe.Pointer(log)
+		lruLog = &log{n: node}
+		node.log = unsafe.Pointer(lruLog)
 		atomic.AddInt64(&l.inUse, node.size)
+		l.insert(lruLog)
 	} else {
-		log := (*log)(node.log)
-		log.remove()
-		l.recent.insert(log)
-		atomic.AddInt64(&l.inUse, diffSize)
+		atomic.AddInt64(&l.inUse, node.size-lruLog.charge)
+		if atomic.LoadInt32(&node.pinned) > 0 {
+			// not in any pool
+			lruLog.charge = node.size
+		} else {
+			// move it to the front of the pool of its (maybe new) priority
+			l.unlink(lruLog)
+			l.insert(lruLog)
+		}
 	}
+	l.balancePools()
 	evicted := l.balance()
 	l.mu.Unlock()
 
-	for _, n := range evicted {
-		n.s.mu.Lock()
-		n.s.evict(n)
-		n.s.mu.Unlock()
-	}
+	evictFromShards(evicted)
 
 	return true
 }
@@ -88,26 +109,115 @@ func (l *lru) Evict(node *kv) {
 	l.removeLRULog(currLog)
 }
 
+func (l *lru) Pin(node *kv) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lruLog := (*log)(node.log)
+	if lruLog == nil {
+		return false
+	}
+
+	if atomic.AddInt32(&node.pinned, 1) == 1 {
+		l.unlink(lruLog)
+	}
+	return true
+}
+
+func (l *lru) Unpin(node *kv) {
+	l.mu.Lock()
+	if atomic.AddInt32(&node.pinned, -1) > 0 {
+		l.mu.Unlock()
+		return
+	}
+
+	var evicted []*kv
+	if lruLog := (*log)(node.log); lruLog != nil {
+		l.insert(lruLog)
+		l.balancePools()
+		evicted = l.balance()
+	}
+	l.mu.Unlock()
+
+	evictFromShards(evicted)
+}
+
+// insert inserts the log at the front of the pool of its node's priority
+//
+//	Caller must ensure the lru is locked
+func (l *lru) insert(lruLog *log) {
+	lruLog.pool = lruLog.n.priority
+	if lruLog.pool >= numPriorities {
+		lruLog.pool = PriorityLow
+	}
+	lruLog.charge = lruLog.n.size
+	l.link(lruLog, lruLog.pool)
+}
+
+func (l *lru) link(lruLog *log, pool Priority) {
+	lruLog.pool = pool
+	l.recent[pool].insert(lruLog)
+	l.poolSizes[pool] += lruLog.charge
+}
+
+func (l *lru) unlink(lruLog *log) {
+	lruLog.remove()
+	l.poolSizes[lruLog.pool] -= lruLog.charge
+}
+
+// balancePools moves the least recent logs overflowing the high and low
+// priority pools to the next pool.
+//
+//	Caller must ensure the lru is locked
+func (l *lru) balancePools() {
+	overflow := func(from, to Priority, capacity int64) {
+		for l.poolSizes[from] > capacity {
+			leastRecent := l.recent[from].prev
+			if leastRecent == l.recent[from] {
+				return
+			}
+			l.unlink(leastRecent)
+			l.link(leastRecent, to)
+		}
+	}
+
+	overflow(PriorityHigh, PriorityLow, int64(float64(l.capacity)*l.highPoolRatio))
+	overflow(PriorityLow, PriorityBottom, int64(float64(l.capacity)*l.lowPoolRatio))
+}
+
 // balance evict nodes to balance the maxSize.
 //
 //	Caller must ensure the lru is locked
 func (l *lru) balance() (evicted []*kv) {
 	for l.inUse > l.capacity {
-		leastUpdate := l.recent.prev
-		if leastUpdate == nil {
-			panic("lru recent pointer is nil")
+		leastRecent := l.leastRecent()
+		if leastRecent == nil {
+			// only the pinned nodes are left
+			break
 		}
-		l.removeLRULog(leastUpdate)
-		evicted = append(evicted, leastUpdate.n)
+		l.removeLRULog(leastRecent)
+		evicted = append(evicted, leastRecent.n)
 	}
 
 	return evicted
 }
 
+// leastRecent returns the next log to evict, nil if all the pools are empty
+func (l *lru) leastRecent() *log {
+	for _, pool := range evictionOrder {
+		if leastRecent := l.recent[pool].prev; leastRecent != l.recent[pool] {
+			return leastRecent
+		}
+	}
+	return nil
+}
+
 func (l *lru) removeLRULog(lruLog *log) {
-	lruLog.remove()
+	if atomic.LoadInt32(&lruLog.n.pinned) == 0 {
+		l.unlink(lruLog)
+	}
 	lruLog.n.log = nil
-	atomic.AddInt64(&l.inUse, -int64(computeSize(lruLog.n.value)))
+	atomic.AddInt64(&l.inUse, -lruLog.charge)
 }
 
 func (l *lru) GetInUsed() int64 {
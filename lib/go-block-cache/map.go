@@ -18,6 +18,7 @@ const (
 	Unknown CacheType = iota
 	LRU
 	ClockPro
+	TinyLFU
 )
 
 func (ct CacheType) toString() string {
@@ -26,6 +27,8 @@ func (ct CacheType) toString() string {
 		return "LRU"
 	case ClockPro:
 		return "ClockPro"
+	case TinyLFU:
+		return "TinyLFU"
 	default:
 		panic("not supported cache type")
 	}
@@ -49,6 +52,9 @@ type hashMap struct {
 	maxSize   int64
 	cacheType CacheType
 	shardNum  int
+
+	// the shares of the capacity reserved to the priority pools
+	highPoolRatio, lowPoolRatio float64
 }
 
 func (h *hashMap) GetStats() Stats {
@@ -75,18 +81,26 @@ func (h *hashMap) GetInUsed() int64 {
 }
 
 func (h *hashMap) Set(fileNum, key uint64, value Value) bool {
+	return h.SetWithPriority(fileNum, key, value, PriorityLow)
+}
+
+func (h *hashMap) SetWithPriority(fileNum, key uint64, value Value, prio Priority) bool {
 	if int64(computeSize(value)) > h.maxSize/int64(h.shardNum) {
 		zap.L().Error("value size exceeds the maximum cache size per shard", zap.Int64("max_cache_size_per_shard", h.maxSize/int64(h.shardNum)))
 		return false
 	}
 
-	return h.getShard(fileNum, key).set(fileNum, key, value)
+	return h.getShard(fileNum, key).set(fileNum, key, value, prio)
 }
 
 func (h *hashMap) Get(fileNum, key uint64) (LazyValue, bool) {
 	return h.getShard(fileNum, key).get(fileNum, key)
 }
 
+func (h *hashMap) Pin(fileNum, key uint64) (LazyValue, bool) {
+	return h.getShard(fileNum, key).pin(fileNum, key)
+}
+
 func (h *hashMap) Delete(fileNum, key uint64) bool {
 	return h.getShard(fileNum, key).delete(fileNum, key)
 }
@@ -119,10 +133,17 @@ func (h *hashMap) getShard(fileNum, key uint64) *shard {
 }
 
 func NewMap(opts ...CacheOpt) IBlockCache {
+	return newHashMap(opts...)
+}
+
+func newHashMap(opts ...CacheOpt) *hashMap {
 	c := &hashMap{
 		shardNum:  defaultShardNum,
 		maxSize:   defaultCacheSize,
 		cacheType: defaultCacheType,
+
+		highPoolRatio: defaultHighPoolRatio,
+		lowPoolRatio:  defaultLowPoolRatio,
 	}
 
 	for _, opt := range opts {
@@ -136,7 +157,7 @@ func NewMap(opts ...CacheOpt) IBlockCache {
 	c.shards = make([]*shard, c.shardNum)
 	for i := range c.shardNum {
 		shardMaxSize := (c.maxSize + int64(c.shardNum-1)) / int64(c.shardNum) // round up
-		c.shards[i] = newShard(shardMaxSize, c.cacheType)
+		c.shards[i] = newShard(shardMaxSize, c.cacheType, c.highPoolRatio, c.lowPoolRatio)
 	}
 
 	return c
@@ -135,6 +135,7 @@ func Benchmark_NogoDB_Cache_Add_Read(b *testing.B) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 	for _, cp := range cachePolicies {
 		b.Run(cp.toString(), func(b *testing.B) {
@@ -144,7 +145,7 @@ func Benchmark_NogoDB_Cache_Add_Read(b *testing.B) {
 			runtime.ReadMemStats(&m0)
 
 			c := NewMap(
-				WithCacheType(LRU),
+				WithCacheType(cp),
 				WithMaxSize(100*KiB),
 			)
 
@@ -173,6 +174,7 @@ func Benchmark_NogoDB_Cache_Read_After_Write_Async(b *testing.B) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 	for _, cp := range cachePolicies {
 		b.Run(cp.toString(), func(b *testing.B) {
@@ -181,7 +183,7 @@ func Benchmark_NogoDB_Cache_Read_After_Write_Async(b *testing.B) {
 			runtime.GC()
 			runtime.ReadMemStats(&m0)
 			c := NewMap(
-				WithCacheType(LRU),
+				WithCacheType(cp),
 				WithMaxSize(100*KiB),
 			)
 
@@ -211,6 +213,7 @@ func Benchmark_NogoDB_Cache_Add_Read_Random_Async(b *testing.B) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 	for _, cp := range cachePolicies {
 		b.Run(cp.toString(), func(b *testing.B) {
@@ -219,7 +222,7 @@ func Benchmark_NogoDB_Cache_Add_Read_Random_Async(b *testing.B) {
 			runtime.GC()
 			runtime.ReadMemStats(&m0)
 			c := NewMap(
-				WithCacheType(LRU),
+				WithCacheType(cp),
 				WithMaxSize(100*KiB),
 			)
 
@@ -245,6 +248,58 @@ func Benchmark_NogoDB_Cache_Add_Read_Random_Async(b *testing.B) {
 	}
 }
 
+// Scan
+
+// Benchmark_NogoDB_Cache_Scan reads a hot set, filling half of the cache,
+// between the bursts of a long scan, each burst being twice as large as the
+// cache, e.g. the point lookups of an OLTP workload running along with the
+// range scans of an OLAP one
+func Benchmark_NogoDB_Cache_Scan(b *testing.B) {
+	const numHot, burstSize = 50, 200
+
+	cachePolicies := []CacheType{
+		LRU,
+		ClockPro,
+		TinyLFU,
+	}
+	for _, cp := range cachePolicies {
+		b.Run(cp.toString(), func(b *testing.B) {
+			c := NewMap(
+				WithCacheType(cp),
+				WithMaxSize(100*KiB),
+			)
+
+			read := func(k uint64) {
+				if _, ok := c.Get(0, k); !ok {
+					_ = c.Set(0, k, randomBytes(valueSize))
+				}
+			}
+
+			var hotHits, hotReads int
+			scanned := uint64(numHot)
+			cnt := 0
+			for b.Loop() {
+				cnt += 1
+				if cnt%(burstSize/numHot+1) == 0 {
+					hotReads++
+					k := uint64(rand.Intn(numHot))
+					if _, ok := c.Get(0, k); ok {
+						hotHits++
+					} else {
+						_ = c.Set(0, k, randomBytes(valueSize))
+					}
+					continue
+				}
+				read(scanned)
+				scanned++
+			}
+
+			b.ReportMetric(float64(c.GetStats().statHit)/float64(c.GetStats().statHit+c.GetStats().statMiss), "hit_ratio")
+			b.ReportMetric(float64(hotHits)/float64(max(hotReads, 1)), "hot_hit_ratio")
+		})
+	}
+}
+
 func randomBytes(sz int) []byte {
 	res := make([]byte, sz)
 	for i := range sz {
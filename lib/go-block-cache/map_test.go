@@ -24,6 +24,7 @@ func Test_HashMap_Set_Then_Get_Sync(t *testing.T) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 	for _, cachePolicy := range cachePolicies {
 		t.Run(fmt.Sprintf("Test_HashMap_Set_Then_Get_Sync_%s", cachePolicy.toString()), func(t *testing.T) {
@@ -67,6 +68,7 @@ func Test_HashMap_Capacity_Resizing(t *testing.T) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 	for _, cp := range cachePolicies {
 		t.Run(fmt.Sprintf("Test_HashMap_Capacity_Resizing_%s", cp.toString()), func(t *testing.T) {
@@ -112,6 +114,7 @@ func Test_LazyValue_Release(t *testing.T) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 	for _, cp := range cachePolicies {
 		t.Run(fmt.Sprintf("Test_LazyValue_Release_%s", cp.toString()), func(t *testing.T) {
@@ -151,6 +154,7 @@ func Test_LazyValue_Small_Cache(t *testing.T) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 	for _, cp := range cachePolicies {
 		t.Run(fmt.Sprintf("Test_LazyValue_Small_Cache_%s", cp.toString()), func(t *testing.T) {
@@ -305,6 +309,7 @@ func Test_HashMap_Hit_And_Miss(t *testing.T) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 
 	for _, cp := range cachePolicies {
@@ -339,6 +344,7 @@ func Test_HashMap_Delete(t *testing.T) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 	for _, cp := range cachePolicies {
 		t.Run(fmt.Sprintf("Test_HashMap_Delete_%s", cp.toString()), func(t *testing.T) {
@@ -384,6 +390,7 @@ func Test_HashMap_Eviction(t *testing.T) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 
 	for _, cp := range cachePolicies {
@@ -415,6 +422,7 @@ func Test_HashMap_Close(t *testing.T) {
 	cachePolicies := []CacheType{
 		LRU,
 		ClockPro,
+		TinyLFU,
 	}
 
 	parallelisms := []int{
@@ -454,3 +462,129 @@ func Test_HashMap_Close(t *testing.T) {
 		}
 	}
 }
+
+func Test_HashMap_Priority_Pools(t *testing.T) {
+	cachePolicies := []CacheType{
+		LRU,
+		ClockPro,
+	}
+
+	for _, cp := range cachePolicies {
+		t.Run(fmt.Sprintf("Test_HashMap_Priority_Pools_%s", cp.toString()), func(t *testing.T) {
+			cache := NewMap(
+				WithCacheType(cp),
+				WithMaxSize(100),
+				WithShardNum(1),
+			)
+			defer cache.Close()
+
+			// e.g. the index blocks, within the high priority pool
+			for i := range 4 {
+				ok := cache.SetWithPriority(0, uint64(i), randomBytes(10), PriorityHigh)
+				assert.True(t, ok)
+			}
+
+			// e.g. a long scan, the bottom priority values only evict each other
+			for i := range 100 {
+				ok := cache.SetWithPriority(1, uint64(i), randomBytes(10), PriorityBottom)
+				assert.True(t, ok)
+			}
+			assert.GreaterOrEqual(t, int64(110), cache.GetInUsed())
+
+			for i := range 4 {
+				_, ok := cache.Get(0, uint64(i))
+				assert.True(t, ok, "the high priority values must be kept")
+			}
+		})
+	}
+}
+
+func Test_HashMap_Pin(t *testing.T) {
+	cachePolicies := []CacheType{
+		LRU,
+		ClockPro,
+		TinyLFU,
+	}
+
+	for _, cp := range cachePolicies {
+		t.Run(fmt.Sprintf("Test_HashMap_Pin_%s", cp.toString()), func(t *testing.T) {
+			cache := NewMap(
+				WithCacheType(cp),
+				WithMaxSize(100),
+				WithShardNum(1),
+			)
+			defer cache.Close()
+
+			_, ok := cache.Pin(0, 1)
+			assert.False(t, ok, "a missing value can't be pinned")
+
+			ok = cache.Set(0, 1, dummy10Bytes)
+			assert.True(t, ok)
+			pinned, ok := cache.Pin(0, 1)
+			require.True(t, ok)
+
+			for i := range 100 {
+				ok := cache.Set(1, uint64(i), randomBytes(10))
+				assert.True(t, ok)
+			}
+			assert.GreaterOrEqual(t, int64(110), cache.GetInUsed())
+			assert.Equal(t, dummy10Bytes, []byte(pinned.Load()), "the pinned value must be kept")
+
+			// once unpinned, the value is evicted as usual
+			pinned.Release()
+			for i := range 100 {
+				ok := cache.Set(2, uint64(i), randomBytes(10))
+				assert.True(t, ok)
+			}
+			_, ok = cache.Get(0, 1)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func Test_HashMap_Scan_Resistance(t *testing.T) {
+	// a hot set filling half of the cache, read between the bursts of a long
+	// scan, each burst being twice as large as the cache
+	const numHot, burstSize, numRounds = 50, 200, 20
+
+	hotHitRatio := func(cachePolicy CacheType) float64 {
+		cache := NewMap(
+			WithCacheType(cachePolicy),
+			WithShardNum(1),
+			WithMaxSize(100*KiB),
+		)
+		defer cache.Close()
+
+		read := func(key uint64) bool {
+			if _, ok := cache.Get(0, key); ok {
+				return true
+			}
+			cache.Set(0, key, make([]byte, KiB))
+			return false
+		}
+
+		var hits, reads int
+		scanned := uint64(numHot)
+		for round := range numRounds {
+			for key := range uint64(numHot) {
+				hit := read(key)
+				if round >= numRounds/2 {
+					reads++
+					if hit {
+						hits++
+					}
+				}
+			}
+			for range burstSize {
+				read(scanned)
+				scanned++
+			}
+		}
+
+		return float64(hits) / float64(reads)
+	}
+
+	lruRatio, tinyLFURatio := hotHitRatio(LRU), hotHitRatio(TinyLFU)
+	assert.Greater(t, tinyLFURatio, 0.9, "the scan doesn't evict the hot set")
+	assert.Greater(t, tinyLFURatio, lruRatio)
+}
@@ -18,8 +18,72 @@ func WithMaxSize(maxSize int64) CacheOpt {
 	}
 }
 
+// WithHighPriorityPoolRatio reserves the given share of the capacity to the
+// PriorityHigh values, the ones overflowing it are treated as PriorityLow.
+// The default value is 0.5
+func WithHighPriorityPoolRatio(ratio float64) CacheOpt {
+	return func(c *hashMap) {
+		if ratio >= 0 && ratio <= 1 {
+			c.highPoolRatio = ratio
+		}
+	}
+}
+
+// WithLowPriorityPoolRatio reserves the given share of the capacity to the
+// PriorityLow values, the ones overflowing it are treated as PriorityBottom.
+// The default value is 0.5
+func WithLowPriorityPoolRatio(ratio float64) CacheOpt {
+	return func(c *hashMap) {
+		if ratio >= 0 && ratio <= 1 {
+			c.lowPoolRatio = ratio
+		}
+	}
+}
+
 func WithCacheType(cacheType CacheType) CacheOpt {
 	return func(c *hashMap) {
 		c.cacheType = cacheType
 	}
 }
+
+type PersistentCacheOpt func(p *PersistentCache)
+
+// WithPersistentCacheCapacity sets the maximum size of the persistent cache
+// segments. The default value is 1 GiB
+func WithPersistentCacheCapacity(capacity int64) PersistentCacheOpt {
+	return func(p *PersistentCache) {
+		if capacity > 0 {
+			p.capacity = capacity
+		}
+	}
+}
+
+// WithPersistentCacheSegmentSize sets the size of the segment files, the
+// persistent cache is evicted by a segment at a time. The default value is
+// 64 MiB
+func WithPersistentCacheSegmentSize(segmentSize int64) PersistentCacheOpt {
+	return func(p *PersistentCache) {
+		if segmentSize > segmentMagicLen+recordHeaderLen {
+			p.segmentSize = segmentSize
+		}
+	}
+}
+
+// WithPersistentCacheMaxPendingBytes throttles the admissions into the
+// persistent cache, the evicted values are dropped instead of written while
+// the pending writes exceed it. The default value is 16 MiB
+func WithPersistentCacheMaxPendingBytes(maxPendingBytes int64) PersistentCacheOpt {
+	return func(p *PersistentCache) {
+		if maxPendingBytes > 0 {
+			p.maxPendingBytes = maxPendingBytes
+		}
+	}
+}
+
+// WithPersistentCacheReset discards the existing segments on open, instead
+// of recovering them
+func WithPersistentCacheReset() PersistentCacheOpt {
+	return func(p *PersistentCache) {
+		p.reset = true
+	}
+}
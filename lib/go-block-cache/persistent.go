@@ -0,0 +1,608 @@
+package go_block_cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	go_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	"go.uber.org/zap"
+)
+
+// PersistentCache is a log-structured block cache on a local directory, it's
+// the secondary tier of NewTieredCache: the values evicted from the memory
+// are appended to it asynchronously, and the memory misses are looked up in
+// it before reading the source file.
+//
+// The values are appended as records to the current segment file, which is
+// sealed once full. The oldest segments are removed once the cache exceeds
+// its capacity, hence the values are evicted in FIFO order. An in-memory
+// index maps the keys to their records.
+//
+//	segment : magic (8 bytes) | record | record | ...
+//	record  : checksum (4 bytes) | fileNum (8 bytes) | key (8 bytes) |
+//	          priority (1 byte) | length (4 bytes) | value
+//
+// The checksum covers the record following it, it's validated on every read,
+// the corrupted records are dropped. A deleted key is recorded by an empty
+// tombstone record, of priority tombstonePriority, which drops the records of
+// the key written before it.
+//
+// On open, the index is rebuilt from the record headers of the existing
+// segments, a torn record ends its segment. As the keys are only unique for
+// the lifetime of their files, the directory must be dedicated to a single
+// DB whose file numbers are never reused, otherwise the cache must be reset
+// on open, see WithPersistentCacheReset.
+type PersistentCache struct {
+	fs  go_fs.FS
+	dir string
+
+	// options
+	capacity        int64
+	segmentSize     int64
+	maxPendingBytes int64
+	reset           bool
+
+	checksumer nogodb_common.IChecksum
+
+	// mu guards the index and the segments. No I/O is done under it, as the
+	// admissions are done under the lock of the in-memory shards
+	mu       sync.RWMutex
+	closed   bool
+	index    map[persistentKey]recordLocation
+	segments []*segment // ordered by seq
+	size     int64
+
+	// current is the segment the records are appended to, it's only
+	// appended to by the writer goroutine
+	current *segment
+
+	// queue holds the admitted values waiting to be written, they're read
+	// from pendingValues until written. pending is their total size, see
+	// WithPersistentCacheMaxPendingBytes
+	queue         chan admission
+	pendingValues map[persistentKey]admission
+	pending       int64
+	done          chan struct{}
+
+	// tombstones are the deleted keys waiting to be written, by the writer
+	// goroutine once woken up, or on close
+	tombstones []persistentKey
+	wakeWriter chan struct{}
+}
+
+type persistentKey struct {
+	fileNum, key uint64
+}
+
+type recordLocation struct {
+	seg    *segment
+	offset int64
+	length uint32
+}
+
+type segment struct {
+	seq  nogodb_common.DiskfileNum
+	file go_fs.File
+	// size is the offset of the end of the last record
+	size int64
+	// keys are the keys of the records, to drop them from the index once
+	// the segment is removed
+	keys []persistentKey
+}
+
+type admission struct {
+	k     persistentKey
+	value Value
+	prio  Priority
+}
+
+const (
+	// segmentMagic identifies the segment files, the segments of another
+	// format are reset on open
+	segmentMagic     uint64 = 0x6e6f676f50436131 // "nogoPCa1"
+	segmentMagicLen         = 8
+	recordHeaderLen         = 25
+	recordChecksumID        = 0x1
+
+	defaultPersistentCapacity        = 1 << 30  // 1 GiB
+	defaultPersistentSegmentSize     = 64 << 20 // 64 MiB
+	defaultPersistentMaxPendingBytes = 16 << 20 // 16 MiB
+
+	// tombstonePriority is the priority of the tombstone records, which
+	// isn't a valid Priority
+	tombstonePriority Priority = 0xff
+)
+
+var (
+	errCorruptedRecord = errors.New("the persistent cache record is corrupted")
+	errBadSegment      = errors.New("the file isn't a persistent cache segment")
+)
+
+// OpenPersistentCache opens the persistent cache on the given directory,
+// creating it if necessary, and recovers its index from the existing segments.
+func OpenPersistentCache(fs go_fs.FS, dir string, opts ...PersistentCacheOpt) (*PersistentCache, error) {
+	p := &PersistentCache{
+		fs:              fs,
+		dir:             dir,
+		capacity:        defaultPersistentCapacity,
+		segmentSize:     defaultPersistentSegmentSize,
+		maxPendingBytes: defaultPersistentMaxPendingBytes,
+		checksumer:      nogodb_common.NewChecksumer(nogodb_common.CRC32Checksum),
+		index:           make(map[persistentKey]recordLocation),
+		queue:           make(chan admission, 1024),
+		pendingValues:   make(map[persistentKey]admission),
+		done:            make(chan struct{}),
+		wakeWriter:      make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if err := p.recover(); err != nil {
+		p.closeSegments()
+		return nil, err
+	}
+
+	go p.runWriter()
+
+	return p, nil
+}
+
+// recover rebuilds the index from the existing segments, or removes them if
+// the cache is reset, then creates the current segment
+func (p *PersistentCache) recover() error {
+	if err := p.fs.MkdirAll(p.dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	names, err := p.fs.List(p.dir)
+	if err != nil {
+		return err
+	}
+
+	var seqs []nogodb_common.DiskfileNum
+	for _, name := range names {
+		if objType, seq, ok := nogodb_common.ParseFileName(p.fs.PathBase(name)); ok && objType == nogodb_common.TypeCache {
+			seqs = append(seqs, seq)
+		}
+	}
+	slices.Sort(seqs)
+
+	var nextSeq nogodb_common.DiskfileNum
+	for _, seq := range seqs {
+		nextSeq = seq + 1
+		if p.reset {
+			if err := p.fs.Remove(p.segmentPath(seq)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		seg, err := p.recoverSegment(seq)
+		if err != nil {
+			zap.L().Warn("reset the persistent cache segment",
+				zap.Int64("seq", int64(seq)), zap.Error(err))
+			if err := p.fs.Remove(p.segmentPath(seq)); err != nil {
+				return err
+			}
+			continue
+		}
+		p.addSegment(seg)
+	}
+	p.removeSegments(p.evictSegments())
+
+	return p.rotate(nextSeq)
+}
+
+// recoverSegment opens the segment read-only and indexes its records
+func (p *PersistentCache) recoverSegment(seq nogodb_common.DiskfileNum) (*segment, error) {
+	path := p.segmentPath(seq)
+	info, err := p.fs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := p.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segment{seq: seq, file: file}
+	var magic [segmentMagicLen]byte
+	if _, err := file.ReadAt(magic[:], 0); err != nil || binary.LittleEndian.Uint64(magic[:]) != segmentMagic {
+		_ = file.Close()
+		return nil, errBadSegment
+	}
+
+	// only the headers are read, the checksums are validated on the reads
+	var header [recordHeaderLen]byte
+	seg.size = segmentMagicLen
+	for seg.size+recordHeaderLen <= info.Size() {
+		if _, err := file.ReadAt(header[:], seg.size); err != nil {
+			break
+		}
+
+		k, prio, length := decodeRecordHeader(header[:])
+		end := seg.size + recordHeaderLen + int64(length)
+		if end > info.Size() {
+			// torn record
+			break
+		}
+
+		if prio == tombstonePriority {
+			delete(p.index, k)
+		} else {
+			p.index[k] = recordLocation{seg: seg, offset: seg.size, length: length}
+			seg.keys = append(seg.keys, k)
+		}
+		seg.size = end
+	}
+
+	return seg, nil
+}
+
+// Get returns the value of the key, false if it isn't cached or if its
+// record is corrupted
+func (p *PersistentCache) Get(fileNum, key uint64) (Value, Priority, bool) {
+	k := persistentKey{fileNum: fileNum, key: key}
+
+	p.mu.RLock()
+	if a, ok := p.pendingValues[k]; ok {
+		p.mu.RUnlock()
+		return a.value, a.prio, true
+	}
+
+	loc, ok := p.index[k]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, PriorityLow, false
+	}
+
+	record := make([]byte, recordHeaderLen+int(loc.length))
+	_, err := loc.seg.file.ReadAt(record, loc.offset)
+	if errors.Is(err, os.ErrClosed) {
+		// the segment is evicted, or the cache is closed
+		return nil, PriorityLow, false
+	}
+	if err == nil {
+		err = p.validateRecord(k, record)
+	}
+
+	if err != nil {
+		zap.L().Warn("failed to read the persistent cache record",
+			zap.Uint64("file_num", fileNum), zap.Uint64("key", key), zap.Error(err))
+		p.mu.Lock()
+		if p.index[k] == loc {
+			delete(p.index, k)
+		}
+		p.mu.Unlock()
+		return nil, PriorityLow, false
+	}
+
+	_, prio, _ := decodeRecordHeader(record)
+	return record[recordHeaderLen:], prio, true
+}
+
+// Delete drops the key from the index, the record is left in its segment. A
+// pending value is dropped before being written. A tombstone is written
+// asynchronously, so that the deleted value isn't recovered on the next open,
+// unless the process crashes before it's written.
+func (p *PersistentCache) Delete(fileNum, key uint64) bool {
+	k := persistentKey{fileNum: fileNum, key: key}
+
+	p.mu.Lock()
+	_, pending := p.pendingValues[k]
+	_, indexed := p.index[k]
+	delete(p.pendingValues, k)
+	delete(p.index, k)
+
+	deleted := pending || indexed
+	if deleted && !p.closed {
+		// the pending value might be written already
+		p.tombstones = append(p.tombstones, k)
+	}
+	p.mu.Unlock()
+
+	if deleted {
+		select {
+		case p.wakeWriter <- struct{}{}:
+		default:
+		}
+	}
+
+	return deleted
+}
+
+// Admit queues a copy of the value to be appended to the cache, the caller
+// keeps the ownership of the given value, e.g. a buffer returned to a pool
+// once evicted. The admissions are throttled: the value is dropped if it's
+// already cached, or if the pending admissions exceed
+// WithPersistentCacheMaxPendingBytes. It never blocks.
+func (p *PersistentCache) Admit(fileNum, key uint64, value Value, prio Priority) bool {
+	k := persistentKey{fileNum: fileNum, key: key}
+	size := int64(len(value))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.index[k]; ok || p.closed {
+		return false
+	}
+	if _, ok := p.pendingValues[k]; ok {
+		return false
+	}
+
+	if atomic.AddInt64(&p.pending, size) > p.maxPendingBytes {
+		atomic.AddInt64(&p.pending, -size)
+		return false
+	}
+
+	a := admission{k: k, value: slices.Clone(value), prio: prio}
+	select {
+	case p.queue <- a:
+		p.pendingValues[k] = a
+		return true
+	default:
+		atomic.AddInt64(&p.pending, -size)
+		return false
+	}
+}
+
+// runWriter appends the admitted values and the tombstones until the cache
+// is closed
+func (p *PersistentCache) runWriter() {
+	defer close(p.done)
+
+	for {
+		var a admission
+		var ok bool
+		select {
+		case a, ok = <-p.queue:
+			if !ok {
+				p.writeTombstones()
+				return
+			}
+		case <-p.wakeWriter:
+			p.writeTombstones()
+			continue
+		}
+
+		// the tombstones are written before the values admitted after them
+		p.writeTombstones()
+
+		p.mu.RLock()
+		_, ok = p.pendingValues[a.k]
+		p.mu.RUnlock()
+		if !ok {
+			// deleted in the meantime
+			atomic.AddInt64(&p.pending, -int64(len(a.value)))
+			continue
+		}
+
+		loc, err := p.write(a)
+		if err != nil {
+			zap.L().Error("failed to write to the persistent cache", zap.Error(err))
+		}
+
+		p.mu.Lock()
+		// the value might have been deleted in the meantime, its record is
+		// then left orphan
+		if _, ok := p.pendingValues[a.k]; ok {
+			delete(p.pendingValues, a.k)
+			if loc.seg != nil {
+				p.index[a.k] = loc
+				loc.seg.keys = append(loc.seg.keys, a.k)
+			}
+		}
+		p.mu.Unlock()
+		atomic.AddInt64(&p.pending, -int64(len(a.value)))
+	}
+}
+
+// writeTombstones appends the tombstones of the deleted keys
+func (p *PersistentCache) writeTombstones() {
+	p.mu.Lock()
+	tombstones := p.tombstones
+	p.tombstones = nil
+	p.mu.Unlock()
+
+	for _, k := range tombstones {
+		if _, err := p.write(admission{k: k, prio: tombstonePriority}); err != nil {
+			zap.L().Error("failed to write a tombstone to the persistent cache", zap.Error(err))
+		}
+	}
+}
+
+// write appends the value to the current segment, and returns the location
+// of its record, a nil segment if the value is too large to be cached
+func (p *PersistentCache) write(a admission) (recordLocation, error) {
+	recordLen := int64(recordHeaderLen + len(a.value))
+	if recordLen+segmentMagicLen > p.segmentSize || recordLen > p.capacity {
+		return recordLocation{}, nil
+	}
+
+	if p.current.size+recordLen > p.segmentSize {
+		if err := p.rotate(p.current.seq + 1); err != nil {
+			return recordLocation{}, err
+		}
+	}
+
+	record := make([]byte, recordLen)
+	encodeRecordHeader(record, a.k, a.prio, uint32(len(a.value)))
+	copy(record[recordHeaderLen:], a.value)
+	binary.LittleEndian.PutUint32(record[:4], p.checksumer.Checksum(record[4:], recordChecksumID))
+
+	seg := p.current
+	if _, err := seg.file.WriteAt(record, seg.size); err != nil {
+		// the segment might be torn, the next records go to a new one
+		if rotateErr := p.rotate(seg.seq + 1); rotateErr != nil {
+			return recordLocation{}, errors.Join(err, rotateErr)
+		}
+		return recordLocation{}, err
+	}
+
+	loc := recordLocation{seg: seg, offset: seg.size, length: uint32(len(a.value))}
+
+	p.mu.Lock()
+	seg.size += recordLen
+	p.size += recordLen
+	evicted := p.evictSegments()
+	p.mu.Unlock()
+
+	p.removeSegments(evicted)
+
+	return loc, nil
+}
+
+// rotate creates a new current segment, the previous one is sealed
+func (p *PersistentCache) rotate(seq nogodb_common.DiskfileNum) error {
+	file, err := p.fs.Create(p.segmentPath(seq), nogodb_common.TypeCache)
+	if err != nil {
+		return err
+	}
+
+	var magic [segmentMagicLen]byte
+	binary.LittleEndian.PutUint64(magic[:], segmentMagic)
+	if _, err := file.Write(magic[:]); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	seg := &segment{seq: seq, file: file, size: segmentMagicLen}
+
+	p.mu.Lock()
+	p.addSegment(seg)
+	p.current = seg
+	p.mu.Unlock()
+
+	return nil
+}
+
+// addSegment
+//
+//	Caller must ensure the cache is locked
+func (p *PersistentCache) addSegment(seg *segment) {
+	p.segments = append(p.segments, seg)
+	p.size += seg.size
+}
+
+// evictSegments drops the oldest sealed segments, and their records from the
+// index, until the cache fits its capacity. The evicted segments are then
+// removed by removeSegments
+//
+//	Caller must ensure the cache is locked
+func (p *PersistentCache) evictSegments() (evicted []*segment) {
+	for p.size > p.capacity && len(p.segments) > 0 && p.segments[0] != p.current {
+		seg := p.segments[0]
+		p.segments = p.segments[1:]
+		p.size -= seg.size
+
+		for _, k := range seg.keys {
+			if loc, ok := p.index[k]; ok && loc.seg == seg {
+				delete(p.index, k)
+			}
+		}
+		evicted = append(evicted, seg)
+	}
+
+	return evicted
+}
+
+// removeSegments closes and removes the segment files, the concurrent reads
+// of the segments then fail and are missed
+func (p *PersistentCache) removeSegments(segments []*segment) {
+	for _, seg := range segments {
+		if err := seg.file.Close(); err != nil {
+			zap.L().Warn("failed to close the persistent cache segment", zap.Error(err))
+		}
+		if err := p.fs.Remove(p.segmentPath(seg.seq)); err != nil {
+			zap.L().Warn("failed to remove the persistent cache segment", zap.Error(err))
+		}
+	}
+}
+
+// Close waits for the pending admissions to be written, then closes the
+// segments, they're recovered on the next open
+func (p *PersistentCache) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	<-p.done
+
+	p.closeSegments()
+}
+
+func (p *PersistentCache) closeSegments() {
+	p.mu.Lock()
+	current, segments := p.current, p.segments
+	p.current, p.segments = nil, nil
+	p.index = make(map[persistentKey]recordLocation)
+	p.size = 0
+	p.mu.Unlock()
+
+	if current != nil {
+		if err := current.file.Sync(); err != nil {
+			zap.L().Warn("failed to sync the persistent cache segment", zap.Error(err))
+		}
+	}
+	for _, seg := range segments {
+		_ = seg.file.Close()
+	}
+}
+
+// GetInUsed returns the size of the segments
+func (p *PersistentCache) GetInUsed() int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.size
+}
+
+func (p *PersistentCache) validateRecord(k persistentKey, record []byte) error {
+	if len(record) < recordHeaderLen {
+		return io.ErrUnexpectedEOF
+	}
+
+	checksum := binary.LittleEndian.Uint32(record[:4])
+	if checksum != p.checksumer.Checksum(record[4:], recordChecksumID) {
+		return errCorruptedRecord
+	}
+
+	recordKey, _, length := decodeRecordHeader(record)
+	if recordKey != k || int(length) != len(record)-recordHeaderLen {
+		return errCorruptedRecord
+	}
+
+	return nil
+}
+
+func (p *PersistentCache) segmentPath(seq nogodb_common.DiskfileNum) string {
+	return p.fs.PathJoin(p.dir, nogodb_common.GetFileName(nogodb_common.TypeCache, seq))
+}
+
+func encodeRecordHeader(dst []byte, k persistentKey, prio Priority, length uint32) {
+	binary.LittleEndian.PutUint64(dst[4:12], k.fileNum)
+	binary.LittleEndian.PutUint64(dst[12:20], k.key)
+	dst[20] = byte(prio)
+	binary.LittleEndian.PutUint32(dst[21:25], length)
+}
+
+func decodeRecordHeader(src []byte) (persistentKey, Priority, uint32) {
+	k := persistentKey{
+		fileNum: binary.LittleEndian.Uint64(src[4:12]),
+		key:     binary.LittleEndian.Uint64(src[12:20]),
+	}
+	return k, Priority(src[20]), binary.LittleEndian.Uint32(src[21:25])
+}
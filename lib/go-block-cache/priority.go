@@ -0,0 +1,36 @@
+package go_block_cache
+
+// Priority is the priority of a cached value, which decides which values are
+// evicted first under memory pressure. The values of a higher priority are
+// protected, up to the share of the cache capacity reserved to their pool,
+// see WithHighPriorityPoolRatio and WithLowPriorityPoolRatio.
+type Priority byte
+
+const (
+	// PriorityLow is the default priority, e.g. for the data blocks
+	PriorityLow Priority = iota
+	// PriorityHigh is for the values which are expensive to miss, e.g. the
+	// index and filter blocks
+	PriorityHigh
+	// PriorityBottom is for the values unlikely to be read again, e.g. the
+	// blocks of a long scan, they're evicted first
+	PriorityBottom
+
+	numPriorities
+)
+
+// evictionOrder is the order in which the priority pools are evicted
+var evictionOrder = [...]Priority{PriorityBottom, PriorityLow, PriorityHigh}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	case PriorityBottom:
+		return "bottom"
+	default:
+		return "unknown"
+	}
+}
@@ -14,6 +14,9 @@ var (
 	initialBucketSize = 1 << 4
 	defaultCacheSize  = 2 * MiB
 	defaultCacheType  = LRU
+
+	defaultHighPoolRatio = 0.5
+	defaultLowPoolRatio  = 0.5
 )
 
 type op byte
@@ -34,6 +37,11 @@ type shard struct {
 	cacher ICacher
 	stats  Stats
 
+	// onEvict, if set, is notified of the values evicted from the shard,
+	// e.g. to spill them into the persistent cache, see NewTieredCache. It's
+	// called under the shard lock, hence it mustn't block
+	onEvict evictionListener
+
 	closed bool
 	// points to state. As in the state don't have mutex
 	state unsafe.Pointer
@@ -49,7 +57,7 @@ func (s *shard) getInUsed() int64 {
 	return s.cacher.GetInUsed()
 }
 
-func (s *shard) set(fileNum, key uint64, value Value) bool {
+func (s *shard) set(fileNum, key uint64, value Value, prio Priority) bool {
 	s.mu.Lock()
 	if s.closed {
 		s.mu.Unlock()
@@ -75,7 +83,7 @@ func (s *shard) set(fileNum, key uint64, value Value) bool {
 		}
 
 		if value == nil || computeSize(value) == 0 {
-			s.evict(node)
+			s.remove(node, false)
 			s.mu.Unlock()
 			return true
 		}
@@ -83,6 +91,7 @@ func (s *shard) set(fileNum, key uint64, value Value) bool {
 		valSize := int64(computeSize(value))
 		diffSize := valSize - node.size
 		node.SetValue(value, valSize)
+		node.priority = prio
 		atomic.StoreInt32(&node.ref, 0)
 		atomic.AddInt64(&s.stats.statSet, 1)
 		s.mu.Unlock()
@@ -143,6 +152,43 @@ func (s *shard) get(fileNum, key uint64) (LazyValue, bool) {
 	return node.ToLazyValue(), true
 }
 
+// pin gets the node and pins it, see IBlockCache.Pin
+func (s *shard) pin(fileNum, key uint64) (LazyValue, bool) {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil, false
+	}
+	var isFrozen bool
+	var node *kv
+	// defer until the target bucket is initialised (aka migrate data from a frozen to a new bucket)
+	// but do not require blocking all operations to the hash map during the migration
+	for {
+		state := (*state)(atomic.LoadPointer(&s.state))
+		bucket := state.lazyLoadBucket(s.getBucketId(fileNum, key, state))
+		isFrozen, node = bucket.Get(fileNum, key)
+		if !isFrozen {
+			break
+		}
+	}
+
+	if node == nil || computeSize(node.value) == 0 {
+		atomic.AddInt64(&s.stats.statMiss, 1)
+		s.mu.RUnlock()
+		return nil, false
+	}
+
+	s.mu.RUnlock()
+	if !s.cacher.Pin(node) {
+		// evicted in the meantime
+		atomic.AddInt64(&s.stats.statMiss, 1)
+		return nil, false
+	}
+
+	atomic.AddInt64(&s.stats.statHit, 1)
+	return &pinHandle{n: unsafe.Pointer(node)}, true
+}
+
 func (s *shard) delete(fileNum, key uint64) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -164,16 +210,23 @@ func (s *shard) delete(fileNum, key uint64) bool {
 			return false
 		}
 
-		s.evict(node)
+		s.remove(node, false)
 		break
 	}
 
 	return true
 }
 
-// evict removes a node entirely from a hashmap
+// evict removes a node entirely from a hashmap, and notifies onEvict
 // Important: caller must ensure the lock of the hashmap
 func (s *shard) evict(node *kv) bool {
+	return s.remove(node, true)
+}
+
+// remove removes a node entirely from a hashmap, onEvict is only notified if
+// notify, i.e. not for the explicit deletions
+// Important: caller must ensure the lock of the hashmap
+func (s *shard) remove(node *kv, notify bool) bool {
 	if s.closed {
 		return false
 	}
@@ -190,6 +243,9 @@ func (s *shard) evict(node *kv) bool {
 
 		if removed {
 			s.cacher.Evict(node)
+			if notify {
+				s.notifyEvict(node)
+			}
 			node.value = nil
 			atomic.AddInt64(&s.stats.statDel, 1)
 		}
@@ -200,6 +256,24 @@ func (s *shard) evict(node *kv) bool {
 	return removed
 }
 
+// notifyEvict notifies onEvict that the value of the node is evicted from
+// the memory, i.e. the node is removed, or its value is de-allocated
+func (s *shard) notifyEvict(node *kv) {
+	if s.onEvict != nil && computeSize(node.value) > 0 {
+		s.onEvict(node.fileNum, node.key, node.value, node.priority)
+	}
+}
+
+// evictFromShards removes the nodes evicted by an ICacher from their shard,
+// the ICacher must not be locked
+func evictFromShards(evicted []*kv) {
+	for _, n := range evicted {
+		n.s.mu.Lock()
+		n.s.evict(n)
+		n.s.mu.Unlock()
+	}
+}
+
 func (s *shard) getBucketId(fileNum, key uint64, state *state) uint32 {
 	hash := murmur32(fileNum, key)
 	return hash & state.bucketMark
@@ -223,7 +297,7 @@ func (s *shard) close() {
 	}
 
 	for _, kv := range allKVs {
-		s.evict(kv)
+		s.remove(kv, false)
 	}
 
 	s.closed = true
@@ -239,7 +313,7 @@ func (s *shard) setCapacity(capacity int64) {
 	}
 }
 
-func newShard(cacheSize int64, cacheType CacheType) *shard {
+func newShard(cacheSize int64, cacheType CacheType, highPoolRatio, lowPoolRatio float64) *shard {
 	state := &state{
 		buckets:       make([]bucket, initialBucketSize),
 		bucketMark:    uint32(initialBucketSize - 1),
@@ -257,9 +331,11 @@ func newShard(cacheSize int64, cacheType CacheType) *shard {
 
 	switch cacheType {
 	case LRU:
-		c.cacher = newLRU(cacheSize)
+		c.cacher = newLRU(cacheSize, highPoolRatio, lowPoolRatio)
 	case ClockPro:
-		c.cacher = NewClockPro(cacheSize)
+		c.cacher = NewClockPro(cacheSize, highPoolRatio)
+	case TinyLFU:
+		c.cacher = newTinyLFU(cacheSize)
 	default:
 		msg := "unsupported cache type"
 		zap.L().Error(msg)
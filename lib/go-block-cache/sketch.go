@@ -0,0 +1,98 @@
+package go_block_cache
+
+// countMinSketch estimates the access frequencies of the keys, with 4 rows of
+// counters saturating at 15, as 4 bits counters. The estimate of a key is the
+// minimum of its counters, which only over-estimates on the hash collisions.
+//
+// The counters are halved once the sketch has counted resetAt increments, so
+// that the frequencies are aged, and the keys which were popular long ago
+// are forgotten.
+type countMinSketch struct {
+	rows  [sketchDepth][]byte
+	mask  uint32
+	count int
+	// resetAt is the number of increments between 2 agings
+	resetAt int
+}
+
+const (
+	sketchDepth      = 4
+	sketchMaxCounter = 15
+	// sketchSamplesPerCounter is the number of increments per counter
+	// between 2 agings, as in TinyLFU
+	sketchSamplesPerCounter = 10
+)
+
+// sketchSeeds are odd constants to derive the index of a key in each row
+var sketchSeeds = [sketchDepth]uint64{
+	0x9e3779b97f4a7c15,
+	0xc2b2ae3d27d4eb4f,
+	0x165667b19e3779f9,
+	0xd6e8feb86659fd93,
+}
+
+// newCountMinSketch creates a sketch of at least width counters per row,
+// rounded up to a power of 2
+func newCountMinSketch(width int) *countMinSketch {
+	w := 16
+	for w < width {
+		w <<= 1
+	}
+
+	s := &countMinSketch{
+		mask:    uint32(w - 1),
+		resetAt: sketchSamplesPerCounter * w,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]byte, w)
+	}
+	return s
+}
+
+func (s *countMinSketch) index(hash uint32, row int) uint32 {
+	return uint32((uint64(hash)*sketchSeeds[row])>>32) & s.mask
+}
+
+// increment counts an access to the key of the given hash
+func (s *countMinSketch) increment(hash uint32) {
+	incremented := false
+	for i := range s.rows {
+		idx := s.index(hash, i)
+		if s.rows[i][idx] < sketchMaxCounter {
+			s.rows[i][idx]++
+			incremented = true
+		}
+	}
+
+	if !incremented {
+		return
+	}
+
+	s.count++
+	if s.count >= s.resetAt {
+		s.age()
+	}
+}
+
+// estimate returns the estimated access frequency of the key of the given hash
+func (s *countMinSketch) estimate(hash uint32) byte {
+	freq := byte(sketchMaxCounter)
+	for i := range s.rows {
+		freq = min(freq, s.rows[i][s.index(hash, i)])
+	}
+	return freq
+}
+
+// age halves all the counters
+func (s *countMinSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.count /= 2
+}
+
+func (s *countMinSketch) width() int {
+	return int(s.mask) + 1
+}
@@ -0,0 +1,35 @@
+package go_block_cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CountMinSketch(t *testing.T) {
+	s := newCountMinSketch(1000)
+	assert.Equal(t, 1024, s.width())
+
+	hot, cold := murmur32(0, 1), murmur32(0, 2)
+	for range 10 {
+		s.increment(hot)
+	}
+	s.increment(cold)
+	assert.Equal(t, byte(10), s.estimate(hot))
+	assert.Equal(t, byte(1), s.estimate(cold))
+	assert.Zero(t, s.estimate(murmur32(0, 3)))
+
+	for range 100 {
+		s.increment(hot)
+	}
+	assert.Equal(t, byte(sketchMaxCounter), s.estimate(hot), "the counters saturate")
+
+	// the frequencies are halved once aged
+	for i := 0; s.count > 0 && i < s.resetAt; i++ {
+		s.increment(murmur32(1, uint64(i)))
+		if s.count == 0 || s.estimate(hot) < sketchMaxCounter {
+			break
+		}
+	}
+	assert.Equal(t, byte(sketchMaxCounter/2), s.estimate(hot))
+}
@@ -0,0 +1,98 @@
+package go_block_cache
+
+// evictionListener is notified of the values evicted from a shard under
+// memory pressure
+type evictionListener func(fileNum, key uint64, value Value, prio Priority)
+
+// tieredCache is an in-memory cache backed by a PersistentCache, i.e.:
+//   - the values are set into the memory, and spill into the persistent cache
+//     once evicted from the memory
+//   - the memory misses are looked up in the persistent cache, the values
+//     found there are promoted back into the memory
+//
+// Hence, e.g. once the sstables live on a remote object storage, the blocks
+// evicted from the memory are still read from the local disk.
+type tieredCache struct {
+	*hashMap
+	persistent *PersistentCache
+}
+
+// NewTieredCache creates an in-memory cache, with the given options, backed by
+// the persistent cache. The tiered cache owns the persistent cache, which is
+// closed along with it.
+func NewTieredCache(persistent *PersistentCache, opts ...CacheOpt) IBlockCache {
+	c := &tieredCache{
+		hashMap:    newHashMap(opts...),
+		persistent: persistent,
+	}
+
+	for _, s := range c.shards {
+		s.onEvict = func(fileNum, key uint64, value Value, prio Priority) {
+			persistent.Admit(fileNum, key, value, prio)
+		}
+	}
+
+	return c
+}
+
+func (c *tieredCache) Get(fileNum, key uint64) (LazyValue, bool) {
+	if v, ok := c.hashMap.Get(fileNum, key); ok {
+		return v, true
+	}
+
+	value, prio, ok := c.persistent.Get(fileNum, key)
+	if !ok {
+		return nil, false
+	}
+
+	if c.hashMap.SetWithPriority(fileNum, key, value, prio) {
+		if v, ok := c.hashMap.Get(fileNum, key); ok {
+			return v, true
+		}
+	}
+
+	// the value doesn't fit in the memory, or was evicted in the meantime
+	return &persistentValue{value: value}, true
+}
+
+func (c *tieredCache) Pin(fileNum, key uint64) (LazyValue, bool) {
+	if v, ok := c.hashMap.Pin(fileNum, key); ok {
+		return v, true
+	}
+
+	value, prio, ok := c.persistent.Get(fileNum, key)
+	if !ok || !c.hashMap.SetWithPriority(fileNum, key, value, prio) {
+		return nil, false
+	}
+
+	return c.hashMap.Pin(fileNum, key)
+}
+
+func (c *tieredCache) Delete(fileNum, key uint64) bool {
+	deleted := c.hashMap.Delete(fileNum, key)
+	return c.persistent.Delete(fileNum, key) || deleted
+}
+
+func (c *tieredCache) Close() {
+	c.hashMap.Close()
+	c.persistent.Close()
+}
+
+// persistentValue is a value read from the persistent cache which isn't
+// promoted into the memory
+type persistentValue struct {
+	value Value
+}
+
+func (v *persistentValue) Load() Value {
+	return v.value
+}
+
+func (v *persistentValue) Release() {
+	v.value = nil
+}
+
+var (
+	_ IBlockCache = (*tieredCache)(nil)
+	_ LazyValue   = (*persistentValue)(nil)
+)
@@ -0,0 +1,229 @@
+package go_block_cache
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	go_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blockOf(fileNum, key uint64) Value {
+	return []byte(fmt.Sprintf("block-%d-%d-%0128d", fileNum, key, key))
+}
+
+func waitForPendingWrites(t *testing.T, p *PersistentCache) {
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&p.pending) == 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+func Test_TieredCache_Spill_Then_Get(t *testing.T) {
+	cachePolicies := []CacheType{
+		LRU,
+		ClockPro,
+	}
+	for _, cachePolicy := range cachePolicies {
+		t.Run(fmt.Sprintf("Test_TieredCache_Spill_Then_Get_%s", cachePolicy.toString()), func(t *testing.T) {
+			persistent, err := OpenPersistentCache(go_fs.NewDefaultUnix(), t.TempDir())
+			require.NoError(t, err)
+
+			// a memory far smaller than the blocks
+			cache := NewTieredCache(
+				persistent,
+				WithCacheType(cachePolicy),
+				WithShardNum(1),
+				WithMaxSize(2*KiB),
+			)
+			defer cache.Close()
+
+			const numBlocks = 100
+			for i := range uint64(numBlocks) {
+				require.True(t, cache.SetWithPriority(1, i, blockOf(1, i), PriorityHigh))
+			}
+			assert.Positive(t, persistent.GetInUsed()+atomic.LoadInt64(&persistent.pending))
+
+			for i := range uint64(numBlocks) {
+				lazyValue, ok := cache.Get(1, i)
+				require.True(t, ok, "block %d", i)
+				assert.Equal(t, blockOf(1, i), lazyValue.Load())
+				lazyValue.Release()
+			}
+
+			_, ok := cache.Get(2, 0)
+			assert.False(t, ok)
+
+			// the promoted values keep their priority
+			_, prio, ok := persistent.Get(1, 0)
+			assert.True(t, ok)
+			assert.Equal(t, PriorityHigh, prio)
+
+			assert.True(t, cache.Delete(1, 0))
+			_, ok = cache.Get(1, 0)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func Test_PersistentCache_Recovery(t *testing.T) {
+	fs, dir := go_fs.NewDefaultUnix(), t.TempDir()
+
+	persistent, err := OpenPersistentCache(fs, dir, WithPersistentCacheSegmentSize(1*KiB))
+	require.NoError(t, err)
+	for i := range uint64(20) {
+		require.True(t, persistent.Admit(1, i, blockOf(1, i), PriorityLow))
+	}
+	waitForPendingWrites(t, persistent)
+	persistent.Close()
+
+	// the index is rebuilt from the segments
+	persistent, err = OpenPersistentCache(fs, dir, WithPersistentCacheSegmentSize(1*KiB))
+	require.NoError(t, err)
+	for i := range uint64(20) {
+		value, _, ok := persistent.Get(1, i)
+		require.True(t, ok, "block %d", i)
+		assert.Equal(t, blockOf(1, i), value)
+	}
+	assert.False(t, persistent.Admit(1, 0, blockOf(1, 0), PriorityLow), "the value is already cached")
+	persistent.Close()
+
+	// the segments are discarded
+	persistent, err = OpenPersistentCache(fs, dir, WithPersistentCacheReset())
+	require.NoError(t, err)
+	defer persistent.Close()
+	for i := range uint64(20) {
+		_, _, ok := persistent.Get(1, i)
+		assert.False(t, ok, "block %d", i)
+	}
+}
+
+func Test_PersistentCache_Corruption(t *testing.T) {
+	fs, dir := go_fs.NewDefaultUnix(), t.TempDir()
+
+	persistent, err := OpenPersistentCache(fs, dir)
+	require.NoError(t, err)
+	require.True(t, persistent.Admit(1, 1, blockOf(1, 1), PriorityLow))
+	require.True(t, persistent.Admit(1, 2, blockOf(1, 2), PriorityLow))
+	waitForPendingWrites(t, persistent)
+	persistent.Close()
+
+	// flip a byte of the 1st value, and tear the 2nd record
+	path := fs.PathJoin(dir, nogodb_common.GetFileName(nogodb_common.TypeCache, 0))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	content[segmentMagicLen+recordHeaderLen] ^= 0xff
+	require.NoError(t, os.WriteFile(path, content[:len(content)-1], 0o666))
+
+	persistent, err = OpenPersistentCache(fs, dir)
+	require.NoError(t, err)
+	defer persistent.Close()
+
+	_, _, ok := persistent.Get(1, 1)
+	assert.False(t, ok, "the checksum mismatches")
+	_, _, ok = persistent.Get(1, 2)
+	assert.False(t, ok, "the record is torn")
+	assert.True(t, persistent.Admit(1, 1, blockOf(1, 1), PriorityLow), "the corrupted record is dropped")
+}
+
+func Test_PersistentCache_Capacity(t *testing.T) {
+	capacity, segmentSize := 4*KiB, 1*KiB
+
+	persistent, err := OpenPersistentCache(
+		go_fs.NewDefaultUnix(),
+		t.TempDir(),
+		WithPersistentCacheCapacity(capacity),
+		WithPersistentCacheSegmentSize(segmentSize),
+	)
+	require.NoError(t, err)
+	defer persistent.Close()
+
+	const numBlocks = 200
+	for i := range uint64(numBlocks) {
+		persistent.Admit(1, i, blockOf(1, i), PriorityLow)
+		waitForPendingWrites(t, persistent)
+	}
+	assert.LessOrEqual(t, persistent.GetInUsed(), capacity)
+
+	// the oldest values are evicted first
+	_, _, ok := persistent.Get(1, 0)
+	assert.False(t, ok)
+	_, _, ok = persistent.Get(1, numBlocks-1)
+	assert.True(t, ok)
+}
+
+func Test_PersistentCache_Admission_Throttling(t *testing.T) {
+	persistent, err := OpenPersistentCache(
+		go_fs.NewDefaultUnix(),
+		t.TempDir(),
+		WithPersistentCacheMaxPendingBytes(1*KiB),
+	)
+	require.NoError(t, err)
+	defer persistent.Close()
+
+	var admitted int
+	for i := range uint64(1000) {
+		if persistent.Admit(1, i, blockOf(1, i), PriorityLow) {
+			admitted++
+		}
+	}
+	assert.Less(t, admitted, 1000, "the admissions beyond the pending bytes are dropped")
+	assert.Positive(t, admitted)
+}
+
+func Test_PersistentCache_Admit_Copies_The_Value(t *testing.T) {
+	persistent, err := OpenPersistentCache(go_fs.NewDefaultUnix(), t.TempDir())
+	require.NoError(t, err)
+	defer persistent.Close()
+
+	// e.g. a pooled buffer reused once evicted
+	buf := blockOf(1, 1)
+	require.True(t, persistent.Admit(1, 1, buf, PriorityLow))
+	for i := range buf {
+		buf[i] = 0
+	}
+
+	value, _, ok := persistent.Get(1, 1)
+	require.True(t, ok)
+	assert.Equal(t, blockOf(1, 1), value)
+
+	waitForPendingWrites(t, persistent)
+	value, _, ok = persistent.Get(1, 1)
+	require.True(t, ok)
+	assert.Equal(t, blockOf(1, 1), value)
+}
+
+func Test_PersistentCache_Delete_Survives_Recovery(t *testing.T) {
+	fs, dir := go_fs.NewDefaultUnix(), t.TempDir()
+
+	persistent, err := OpenPersistentCache(fs, dir)
+	require.NoError(t, err)
+	for i := range uint64(3) {
+		require.True(t, persistent.Admit(1, i, blockOf(1, i), PriorityLow))
+	}
+	waitForPendingWrites(t, persistent)
+
+	assert.True(t, persistent.Delete(1, 0))
+	assert.True(t, persistent.Delete(1, 1))
+	// re-admitted after its tombstone
+	require.True(t, persistent.Admit(1, 1, blockOf(1, 1), PriorityHigh))
+	assert.False(t, persistent.Delete(1, 3), "not cached")
+	persistent.Close()
+
+	persistent, err = OpenPersistentCache(fs, dir)
+	require.NoError(t, err)
+	defer persistent.Close()
+
+	_, _, ok := persistent.Get(1, 0)
+	assert.False(t, ok, "the deleted value isn't recovered")
+	value, prio, ok := persistent.Get(1, 1)
+	require.True(t, ok)
+	assert.Equal(t, blockOf(1, 1), value)
+	assert.Equal(t, PriorityHigh, prio)
+	_, _, ok = persistent.Get(1, 2)
+	assert.True(t, ok)
+}
@@ -0,0 +1,273 @@
+package go_block_cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"go.uber.org/zap"
+)
+
+// tinyLFU is a W-TinyLFU cache, see "TinyLFU: A Highly Efficient Cache
+// Admission Policy", by Gil Einziger, Roy Friedman, and Ben Manes. It's split
+// into:
+//   - a small window LRU, which all the new values enter
+//   - a segmented main LRU, i.e. a probation segment, and a protected segment
+//     which the values of the probation segment are promoted to once hit
+//
+// Once the cache is full, the least recent value of the window is only
+// admitted into the main LRU if its access frequency, estimated by a
+// count-min sketch, is higher than the one of the value the main LRU would
+// evict. Hence, e.g. the blocks of a long scan, read once, only evict each
+// other within the window, but not the frequently read blocks.
+//
+// The PriorityHigh values skip the window into the protected segment. The
+// pinned nodes are taken out of the segments until they're unpinned.
+type tinyLFU struct {
+	inUse    int64
+	capacity int64
+
+	mu sync.Mutex
+
+	sketch *countMinSketch
+
+	// recent dummy node of each segment, see lru
+	recent       [numLFUSegments]*log
+	segmentSizes [numLFUSegments]int64
+}
+
+type lfuSegment byte
+
+const (
+	lfuWindow lfuSegment = iota
+	lfuProbation
+	lfuProtected
+
+	numLFUSegments
+)
+
+const (
+	// lfuWindowRatio is the share of the capacity of the window LRU
+	lfuWindowRatio = 0.01
+	// lfuProtectedRatio is the share of the main LRU capacity of the
+	// protected segment
+	lfuProtectedRatio = 0.8
+	// lfuSketchEntrySize is the expected average size of the values, to size
+	// the sketch by the number of values the cache holds, e.g. the size of a
+	// data block
+	lfuSketchEntrySize = 4 << 10 // 4 KiB
+)
+
+func newTinyLFU(maxSize int64) *tinyLFU {
+	c := &tinyLFU{}
+	for seg := range c.recent {
+		dummy := new(log)
+		dummy.next = dummy
+		dummy.prev = dummy
+		c.recent[seg] = dummy
+	}
+	c.SetCapacity(maxSize)
+	return c
+}
+
+func (c *tinyLFU) SetCapacity(capacity int64) {
+	c.mu.Lock()
+	c.capacity = capacity
+	width := int(max(capacity/lfuSketchEntrySize, 1))
+	if c.sketch == nil || c.sketch.width() < width {
+		// the frequencies are forgotten
+		c.sketch = newCountMinSketch(width)
+	}
+	evicted := c.balance()
+	c.mu.Unlock()
+
+	evictFromShards(evicted)
+}
+
+func (c *tinyLFU) Promote(node *kv, diffSize int64, o op) bool {
+	c.mu.Lock()
+	c.sketch.increment(node.hash)
+
+	lfuLog := (*log)(node.log)
+	if lfuLog == nil {
+		// the key/value pair is updated for the first time
+		if node.size > c.capacity {
+			c.mu.Unlock()
+			zap.L().Error("node is bigger than eviction value")
+			return false
+		}
+
+		lfuLog = &log{n: node}
+		node.log = unsafe.Pointer(lfuLog)
+		atomic.AddInt64(&c.inUse, node.size)
+		if node.priority == PriorityHigh {
+			c.link(lfuLog, lfuProtected)
+		} else {
+			c.link(lfuLog, lfuWindow)
+		}
+	} else {
+		atomic.AddInt64(&c.inUse, node.size-lfuLog.charge)
+		if atomic.LoadInt32(&node.pinned) > 0 {
+			// not in any segment
+			lfuLog.charge = node.size
+		} else {
+			// a hit in the main LRU promotes the value to the protected segment
+			seg := lfuLog.segment
+			if seg == lfuProbation {
+				seg = lfuProtected
+			}
+			c.unlink(lfuLog)
+			c.link(lfuLog, seg)
+		}
+	}
+	evicted := c.balance()
+	c.mu.Unlock()
+
+	evictFromShards(evicted)
+
+	return true
+}
+
+func (c *tinyLFU) Evict(node *kv) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	currLog := (*log)(node.log)
+	if currLog == nil {
+		return
+	}
+	c.removeLog(currLog)
+}
+
+func (c *tinyLFU) Pin(node *kv) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lfuLog := (*log)(node.log)
+	if lfuLog == nil {
+		return false
+	}
+
+	if atomic.AddInt32(&node.pinned, 1) == 1 {
+		c.unlink(lfuLog)
+	}
+	return true
+}
+
+func (c *tinyLFU) Unpin(node *kv) {
+	c.mu.Lock()
+	if atomic.AddInt32(&node.pinned, -1) > 0 {
+		c.mu.Unlock()
+		return
+	}
+
+	var evicted []*kv
+	if lfuLog := (*log)(node.log); lfuLog != nil {
+		c.link(lfuLog, lfuLog.segment)
+		evicted = c.balance()
+	}
+	c.mu.Unlock()
+
+	evictFromShards(evicted)
+}
+
+// link inserts the log at the front of the segment
+//
+//	Caller must ensure the tinyLFU is locked
+func (c *tinyLFU) link(lfuLog *log, seg lfuSegment) {
+	lfuLog.segment = seg
+	lfuLog.charge = lfuLog.n.size
+	c.recent[seg].insert(lfuLog)
+	c.segmentSizes[seg] += lfuLog.charge
+}
+
+func (c *tinyLFU) unlink(lfuLog *log) {
+	lfuLog.remove()
+	c.segmentSizes[lfuLog.segment] -= lfuLog.charge
+}
+
+// leastRecent returns the least recent log of the segment, nil if it's empty
+func (c *tinyLFU) leastRecent(seg lfuSegment) *log {
+	if leastRecent := c.recent[seg].prev; leastRecent != c.recent[seg] {
+		return leastRecent
+	}
+	return nil
+}
+
+// balance demotes the overflow of the protected segment, then evicts nodes to
+// balance the maxSize: the candidate, i.e. the least recent log of the
+// window overflow, competes with the victim of the main LRU, the less
+// frequent one is evicted. Once the cache fits, the rest of the window
+// overflow is admitted into the probation segment.
+//
+//	Caller must ensure the tinyLFU is locked
+func (c *tinyLFU) balance() (evicted []*kv) {
+	maxWindow := int64(float64(c.capacity) * lfuWindowRatio)
+	maxProtected := int64(float64(c.capacity-maxWindow) * lfuProtectedRatio)
+
+	for c.segmentSizes[lfuProtected] > maxProtected {
+		leastRecent := c.leastRecent(lfuProtected)
+		if leastRecent == nil {
+			break
+		}
+		c.unlink(leastRecent)
+		c.link(leastRecent, lfuProbation)
+	}
+
+	for c.inUse > c.capacity {
+		var candidate *log
+		if c.segmentSizes[lfuWindow] > maxWindow {
+			candidate = c.leastRecent(lfuWindow)
+		}
+
+		victim := c.victim()
+		switch {
+		case victim == nil:
+			// only the pinned nodes are left
+			return evicted
+		case candidate == nil || candidate == victim:
+		case c.sketch.estimate(candidate.n.hash) > c.sketch.estimate(victim.n.hash):
+			// the candidate is admitted
+			c.unlink(candidate)
+			c.link(candidate, lfuProbation)
+		default:
+			victim = candidate
+		}
+
+		c.removeLog(victim)
+		evicted = append(evicted, victim.n)
+	}
+
+	for c.segmentSizes[lfuWindow] > maxWindow {
+		candidate := c.leastRecent(lfuWindow)
+		c.unlink(candidate)
+		c.link(candidate, lfuProbation)
+	}
+
+	return evicted
+}
+
+// victim returns the next log the main LRU would evict, or the window's if
+// the main LRU is empty
+func (c *tinyLFU) victim() *log {
+	for _, seg := range [...]lfuSegment{lfuProbation, lfuProtected, lfuWindow} {
+		if victim := c.leastRecent(seg); victim != nil {
+			return victim
+		}
+	}
+	return nil
+}
+
+func (c *tinyLFU) removeLog(lfuLog *log) {
+	if atomic.LoadInt32(&lfuLog.n.pinned) == 0 {
+		c.unlink(lfuLog)
+	}
+	lfuLog.n.log = nil
+	atomic.AddInt64(&c.inUse, -lfuLog.charge)
+}
+
+func (c *tinyLFU) GetInUsed() int64 {
+	return atomic.LoadInt64(&c.inUse)
+}
+
+var _ ICacher = (*tinyLFU)(nil)
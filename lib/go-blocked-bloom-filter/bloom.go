@@ -104,8 +104,15 @@ func (bw *bloomFilterWriter) Build(b *[]byte) {
 // End of Writer \\
 
 func (bf *bloomFilter) NewWriter() IWriter {
+	return bf.NewWriterWithBitsPerKey(defaultBitsPerKeys)
+}
+
+func (bf *bloomFilter) NewWriterWithBitsPerKey(bitsPerKey int) IWriter {
+	if bitsPerKey <= 0 {
+		bitsPerKey = defaultBitsPerKeys
+	}
 	return &bloomFilterWriter{
-		bitsPerKeys: defaultBitsPerKeys,
+		bitsPerKeys: bitsPerKey,
 		hashes:      []uint32{},
 	}
 }
@@ -79,3 +79,39 @@ func TestBloomFilter_VaryingLengths(t *testing.T) {
 	// number of mediocre filter set should not larger than 20% of the good ones
 	assert.Less(t, mediocre, (good+4)/5, fmt.Sprintf("mediocre (%d) is too high, it is higher than 20%% of good (%d)", mediocre, good))
 }
+
+func TestBloomFilter_BitsPerKey(t *testing.T) {
+	const n = 10_000
+	bf := NewBloomFilter()
+	falsePositiveRate := func(bitsPerKey int) (int, float64) {
+		writer := bf.NewWriterWithBitsPerKey(bitsPerKey)
+		for i := range n {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(i))
+			writer.Add(b[:])
+		}
+		var filter []byte
+		writer.Build(&filter)
+
+		var fp int
+		for i := range n {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(i))
+			assert.True(t, bf.MayContain(filter, b[:]), fmt.Sprintf("%d should be is membership, false negative is not allowed", i))
+			binary.LittleEndian.PutUint32(b[:], uint32(i+1e9))
+			if bf.MayContain(filter, b[:]) {
+				fp++
+			}
+		}
+		return len(filter), float64(fp) / n
+	}
+
+	smallSize, smallFpr := falsePositiveRate(5)
+	defaultSize, defaultFpr := falsePositiveRate(0)
+	bigSize, bigFpr := falsePositiveRate(16)
+	assert.Less(t, smallSize, defaultSize)
+	assert.Less(t, defaultSize, bigSize)
+	assert.Greater(t, smallFpr, defaultFpr)
+	assert.Greater(t, defaultFpr, bigFpr)
+	assert.LessOrEqual(t, bigFpr, 0.005, fmt.Sprintf("false positive rate: %v, is too high", bigFpr))
+}
@@ -4,6 +4,10 @@ package go_blocked_bloom_filter
 // Once probing begins, new insertions are not valid.
 type IFilter interface {
 	NewWriter() IWriter
+	// NewWriterWithBitsPerKey returns a writer which spends about bitsPerKey
+	// bits per added key, the more bits the lower the false positive rate.
+	// A non-positive bitsPerKey falls back to the default.
+	NewWriterWithBitsPerKey(bitsPerKey int) IWriter
 	Name() string
 	// MayContain returns whether the encoded filter may contain given key.
 	// False positives are possible, where it returns true for keys not in the
@@ -0,0 +1,44 @@
+package go_fs
+
+import (
+	"io"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// Copy copies the content of the oldname file into the newname one, which is
+// created, and syncs it
+func Copy(fs FS, oldname, newname string, objType nogodb_common.ObjectType) (err error) {
+	src, err := fs.Open(oldname)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := fs.Create(newname, objType)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+
+	return dst.Sync()
+}
+
+// LinkOrCopy hard-links newname to the oldname file, or copies it if the FS
+// can't link them, e.g. if they are on different devices. The linked file
+// shares its content with oldname, so oldname mustn't be modified afterward.
+func LinkOrCopy(fs FS, oldname, newname string, objType nogodb_common.ObjectType) error {
+	if err := fs.Link(oldname, newname); err == nil {
+		return nil
+	}
+
+	return Copy(fs, oldname, newname, objType)
+}
@@ -22,6 +22,12 @@ func (f *unixFile) Stat() (FileInfo, error) {
 	return f.File.Stat()
 }
 
+// Prefetch asks the kernel to read the range ahead into the page cache,
+// without blocking on it
+func (f unixFile) Prefetch(off, n int64) error {
+	return unix.Fadvise(int(f.Fd()), off, n, unix.FADV_WILLNEED)
+}
+
 type defaultUnix struct{}
 
 func NewDefaultUnix() *defaultUnix {
@@ -69,6 +75,23 @@ func (f *defaultUnix) Open(name string) (File, error) {
 	return wrapOSFile(osFile), nil
 }
 
+func (f *defaultUnix) ReuseForWrite(oldname, newname string, objType nogodb_common.ObjectType) (File, error) {
+	if err := os.Rename(oldname, newname); err != nil {
+		return nil, err
+	}
+
+	osFile, err := os.OpenFile(newname, os.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrapOSFile(osFile), nil
+}
+
+func (f *defaultUnix) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
 func (f *defaultUnix) Remove(name string) error {
 	return os.Remove(name)
 }
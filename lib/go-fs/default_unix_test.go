@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 )
 
 var lockFilename = flag.String("lockfile", "", "File to lock. A non-empty value implies a child process.")
@@ -98,3 +100,85 @@ func TestLockSameProcess(t *testing.T) {
 
 	require.NoError(t, lock1.Close())
 }
+
+// TestReuseForWrite verifies that a reused file is renamed and overwritten in
+// place, without being truncated.
+func TestReuseForWrite(t *testing.T) {
+	dir := t.TempDir()
+	defaultUnix := NewDefaultUnix()
+	oldname := defaultUnix.PathJoin(dir, "000001.log")
+	newname := defaultUnix.PathJoin(dir, "000002.log")
+
+	f, err := defaultUnix.Create(oldname, nogodb_common.TypeWAL)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = defaultUnix.ReuseForWrite(oldname, newname, nogodb_common.TypeWAL)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("HE"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = defaultUnix.Stat(oldname)
+	require.True(t, os.IsNotExist(err))
+
+	content, err := os.ReadFile(newname)
+	require.NoError(t, err)
+	require.Equal(t, "HEllo world", string(content))
+}
+
+func TestPrefetch(t *testing.T) {
+	dir := t.TempDir()
+	defaultUnix := NewDefaultUnix()
+	name := defaultUnix.PathJoin(dir, "000001.sst")
+
+	f, err := defaultUnix.Create(name, nogodb_common.TypeTable)
+	require.NoError(t, err)
+	_, err = f.Write(bytes.Repeat([]byte("a"), 1<<16))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = defaultUnix.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	p, ok := f.(Prefetcher)
+	require.True(t, ok, "the unix files must be prefetchable")
+	require.NoError(t, p.Prefetch(0, 1<<16))
+}
+
+// TestLinkOrCopy verifies that a linked file shares the content of the
+// original one, and that a copied file has the same content.
+func TestLinkOrCopy(t *testing.T) {
+	dir := t.TempDir()
+	defaultUnix := NewDefaultUnix()
+	name := defaultUnix.PathJoin(dir, "sst-1")
+
+	f, err := defaultUnix.Create(name, nogodb_common.TypeTable)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	linked := defaultUnix.PathJoin(dir, "sst-2")
+	require.NoError(t, LinkOrCopy(defaultUnix, name, linked, nogodb_common.TypeTable))
+	copied := defaultUnix.PathJoin(dir, "sst-3")
+	require.NoError(t, Copy(defaultUnix, name, copied, nogodb_common.TypeTable))
+
+	nameInfo, err := defaultUnix.Stat(name)
+	require.NoError(t, err)
+	linkedInfo, err := defaultUnix.Stat(linked)
+	require.NoError(t, err)
+	copiedInfo, err := defaultUnix.Stat(copied)
+	require.NoError(t, err)
+	require.True(t, os.SameFile(nameInfo, linkedInfo))
+	require.False(t, os.SameFile(nameInfo, copiedInfo))
+
+	for _, path := range []string{linked, copied} {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "hello world", string(content))
+	}
+}
@@ -46,10 +46,21 @@ type FS interface {
 	// Open opens the named file for reading. openOptions provides
 	Open(name string) (File, error)
 
+	// ReuseForWrite attempts to reuse the file with oldname by renaming it to
+	// newname and opening it for writing without truncation. The existing
+	// content is overwritten in place, so the caller must be able to tell the
+	// stale content apart. It is typically used to recycle the obsolete WAL
+	// files, which avoids allocating the file and updating its metadata.
+	ReuseForWrite(oldname, newname string, objType nogodb_common.ObjectType) (File, error)
+
 	// // OpenReadWrite opens the named file for reading and writing. If the file
 	// // does not exist, it is created.
 	// OpenReadWrite(name string, category ObjectType) (File, error)
 
+	// Link creates newname as a hard link to the oldname file. The FS
+	// implementations which can't link files return errors.ErrUnsupported.
+	Link(oldname, newname string) error
+
 	// OpenDir opens the named directory for syncing.
 	OpenDir(name string) (File, error)
 
@@ -2,6 +2,7 @@ package go_fs
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"sync"
 
@@ -116,6 +117,34 @@ func (i *inmemStorage) Create(objType nogodb_common.ObjectType, num nogodb_commo
 	return memWriter{memFile: i.files[fid]}, i.toFileDesc(objType, num), nil
 }
 
+// Reuse moves the object oldNum to newNum. Unlike the files, an in-memory
+// object can't be overwritten in place, so its content is dropped.
+func (i *inmemStorage) Reuse(objType nogodb_common.ObjectType, oldNum, newNum nogodb_common.DiskfileNum) (Writable, FileDesc, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	oldFid, newFid := i.toFileId(objType, oldNum), i.toFileId(objType, newNum)
+	file, ok := i.files[oldFid]
+	if !ok {
+		return nil, FileDesc{}, errFileNotFound
+	}
+	if _, ok := i.files[newFid]; ok {
+		return nil, FileDesc{}, errFileExists
+	}
+
+	delete(i.files, oldFid)
+	file.Reset()
+	file.open = true
+	i.files[newFid] = file
+
+	return memWriter{memFile: file}, i.toFileDesc(objType, newNum), nil
+}
+
+// Link is not supported, the in-memory objects aren't backed by files
+func (i *inmemStorage) Link(path string, objType nogodb_common.ObjectType, num nogodb_common.DiskfileNum) (FileDesc, error) {
+	return FileDesc{}, errors.ErrUnsupported
+}
+
 func (i *inmemStorage) LookUp(objType nogodb_common.ObjectType, num nogodb_common.DiskfileNum) (FileDesc, error) {
 	return i.toFileDesc(objType, num), nil
 }
@@ -64,6 +64,14 @@ type Readable interface {
 	Close() error
 }
 
+// Prefetcher is implemented by the Readables which can ask the OS to read
+// ahead asynchronously, e.g. the files of the Unix FS
+type Prefetcher interface {
+	// Prefetch hints that the [off, off+n) range of the object will be read
+	// soon. It returns errors.ErrUnsupported if the object can't prefetch.
+	Prefetch(off, n int64) error
+}
+
 // Storage is a singleton object used to access and manage objects.
 //
 // An object is conceptually like a large immutable file. The main use of
@@ -79,6 +87,16 @@ type Storage interface {
 	// until Sync is called.
 	Create(objType nogodb_common.ObjectType, num nogodb_common.DiskfileNum) (Writable, FileDesc, error)
 
+	// Reuse renames the existing object oldNum to newNum and opens it for
+	// writing. Unlike Create, the object isn't truncated, its content is
+	// overwritten in place.
+	Reuse(objType nogodb_common.ObjectType, oldNum, newNum nogodb_common.DiskfileNum) (Writable, FileDesc, error)
+
+	// Link adds the file at path as a new object, by hard-linking it, or by
+	// copying it if it can't be linked (see LinkOrCopy). The file is left
+	// untouched.
+	Link(path string, objType nogodb_common.ObjectType, num nogodb_common.DiskfileNum) (FileDesc, error)
+
 	// LookUp returns the metadata of an object that is already exists
 	// it doesn't perform any I/O operations
 	LookUp(objType nogodb_common.ObjectType, num nogodb_common.DiskfileNum) (FileDesc, error)
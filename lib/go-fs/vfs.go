@@ -124,6 +124,50 @@ func (v *vfsProvider) Create(objType nogodb_common.ObjectType, num nogodb_common
 	}, nil
 }
 
+// Reuse renames the existing object oldNum to newNum and opens it for
+// writing, without truncating it.
+func (v *vfsProvider) Reuse(objType nogodb_common.ObjectType, oldNum, newNum nogodb_common.DiskfileNum) (Writable, FileDesc, error) {
+	if _, err := v.LookUp(objType, oldNum); err != nil {
+		return nil, FileDesc{}, err
+	}
+
+	oldPath := v.fs.PathJoin(v.dirName, nogodb_common.GetFileName(objType, oldNum))
+	newPath := v.fs.PathJoin(v.dirName, nogodb_common.GetFileName(objType, newNum))
+	file, err := v.fs.ReuseForWrite(oldPath, newPath, objType)
+	if err != nil {
+		return nil, FileDesc{}, err
+	}
+
+	writable := NewBufferedFileWriable(
+		NewSyncableFile(file, v.bytesPerSync),
+	)
+
+	v.removeMeta(objType, oldNum)
+	v.addMeta(objType, newNum)
+
+	return writable, FileDesc{
+		Type: objType,
+		Num:  newNum,
+		Loc:  FileSystem,
+	}, nil
+}
+
+// Link adds the file at path as a new object, by hard-linking or copying it
+func (v *vfsProvider) Link(path string, objType nogodb_common.ObjectType, num nogodb_common.DiskfileNum) (FileDesc, error) {
+	filePath := v.fs.PathJoin(v.dirName, nogodb_common.GetFileName(objType, num))
+	if err := LinkOrCopy(v.fs, path, filePath, objType); err != nil {
+		return FileDesc{}, err
+	}
+
+	v.addMeta(objType, num)
+
+	return FileDesc{
+		Type: objType,
+		Num:  num,
+		Loc:  FileSystem,
+	}, nil
+}
+
 func (v *vfsProvider) addMeta(objType nogodb_common.ObjectType, num nogodb_common.DiskfileNum) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
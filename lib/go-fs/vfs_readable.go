@@ -1,5 +1,7 @@
 package go_fs
 
+import "errors"
+
 type wrapperFileReadable struct {
 	File
 
@@ -14,3 +16,14 @@ func (f *wrapperFileReadable) Size() uint64 {
 	}
 	return uint64(info.Size())
 }
+
+func (f *wrapperFileReadable) Prefetch(off, n int64) error {
+	p, ok := f.File.(Prefetcher)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	return p.Prefetch(off, n)
+}
+
+var _ Prefetcher = (*wrapperFileReadable)(nil)
@@ -0,0 +1,289 @@
+package go_ribbon_filter
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	defaultBitsPerKeys = 10
+	// ribbonWidth is the number of bits of the coefficient row of each key,
+	// each key spans ribbonWidth consecutive slots
+	ribbonWidth = 64
+	// trailerLen is the [number of slots (4 bytes), seed (1 byte), result bits (1 byte)]
+	trailerLen = 6
+	// seedsPerSize is the number of seeds tried before growing the number of slots
+	seedsPerSize = 8
+)
+
+// ribbonFilter is an implementation of the standard Ribbon filter
+// https://arxiv.org/abs/2103.02515
+//
+// Each key is hashed to a starting slot s, a coefficient row c of ribbonWidth
+// bits and an r-bit fingerprint f. The filter is the solution S (r bits per
+// slot) of the linear system over GF(2): for each key, the XOR of S[s+j] for
+// all the bits j set in c equals f. A key is a member if its equation holds,
+// a non-member matches with a probability of 2^-r.
+//
+// The system is built by an on-the-fly Gaussian elimination, in which the
+// equations are kept sorted by their first slot (a banded matrix). It might
+// fail with a few more keys than slots, it's then retried with another seed,
+// or with more slots.
+type (
+	ribbonFilter       struct{}
+	ribbonFilterWriter struct {
+		// bitsPerKeys is the bits per key of a Bloom filter with the same
+		// false positive rate, see calculateResultBits
+		bitsPerKeys int
+
+		hashes []uint64
+
+		// the banded matrix, reused across the Builds
+		coeffs  []uint64
+		results []uint16
+	}
+)
+
+// Writer \\
+
+func (rw *ribbonFilterWriter) Add(key []byte) {
+	rw.hashes = append(rw.hashes, ribbonHash(key))
+}
+
+func (rw *ribbonFilterWriter) Build(b *[]byte) {
+	// Layout
+	// | column 0 | column 1 | ... | column r-1 | nSlots (4 bytes) | seed (1 byte) | r (1 byte) |
+	// Column k holds the k-th bit of the solution of every slot, packed in
+	// nSlots/64 little endian uint64 words
+	resultBits := calculateResultBits(rw.bitsPerKeys)
+	numKeys := len(rw.hashes)
+	if numKeys == 0 {
+		*b = appendTrailer(*b, 0, 0, resultBits)
+		return
+	}
+
+	// ~6% more slots than keys, the number of slots is a multiple of 64
+	nSlots := roundUpSlots(numKeys + numKeys/16 + ribbonWidth)
+	var seed byte
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && attempt%seedsPerSize == 0 {
+			nSlots = roundUpSlots(nSlots + numKeys/32 + ribbonWidth)
+		}
+
+		seed = byte(attempt)
+		if rw.band(nSlots, seed, resultBits) {
+			break
+		}
+	}
+
+	nWords := nSlots / ribbonWidth
+	columns := make([]uint64, int(resultBits)*nWords)
+	rw.backSubstitute(columns, nSlots, resultBits)
+
+	for _, w := range columns {
+		*b = binary.LittleEndian.AppendUint64(*b, w)
+	}
+	*b = appendTrailer(*b, uint32(nSlots), seed, resultBits)
+
+	// Release
+	rw.hashes = rw.hashes[:0]
+}
+
+// band adds the equations of all the keys to the banded matrix, it returns
+// false if they're inconsistent
+func (rw *ribbonFilterWriter) band(nSlots int, seed byte, resultBits byte) bool {
+	if cap(rw.coeffs) < nSlots {
+		rw.coeffs = make([]uint64, nSlots)
+		rw.results = make([]uint16, nSlots)
+	}
+	rw.coeffs, rw.results = rw.coeffs[:nSlots], rw.results[:nSlots]
+	clear(rw.coeffs)
+	clear(rw.results)
+
+	for _, h := range rw.hashes {
+		s, c, f := deriveEquation(h, seed, nSlots, resultBits)
+		for {
+			if rw.coeffs[s] == 0 {
+				rw.coeffs[s], rw.results[s] = c, f
+				break
+			}
+
+			// eliminate the first slot with the equation already there,
+			// the first bits of both are set, hence c shifts by at least 1
+			c ^= rw.coeffs[s]
+			f ^= rw.results[s]
+			if c == 0 {
+				if f != 0 {
+					return false
+				}
+				// redundant, e.g. a duplicated key
+				break
+			}
+
+			tz := bits.TrailingZeros64(c)
+			c >>= tz
+			s += tz
+		}
+	}
+
+	return true
+}
+
+// backSubstitute solves the banded matrix from the last slot, the free
+// slots are set to 0
+func (rw *ribbonFilterWriter) backSubstitute(columns []uint64, nSlots int, resultBits byte) {
+	nWords := nSlots / ribbonWidth
+	for i := nSlots - 1; i >= 0; i-- {
+		c := rw.coeffs[i]
+		if c == 0 {
+			continue
+		}
+
+		for k := range int(resultBits) {
+			col := columns[k*nWords : (k+1)*nWords]
+			// the bit i itself isn't set yet
+			bit := uint16(bits.OnesCount64(c&window(col, i))&1) ^ (rw.results[i] >> k & 1)
+			col[i/ribbonWidth] |= uint64(bit) << (i % ribbonWidth)
+		}
+	}
+}
+
+// End of Writer \\
+
+func (rf *ribbonFilter) NewWriter() IWriter {
+	return rf.NewWriterWithBitsPerKey(defaultBitsPerKeys)
+}
+
+func (rf *ribbonFilter) NewWriterWithBitsPerKey(bitsPerKey int) IWriter {
+	if bitsPerKey <= 0 {
+		bitsPerKey = defaultBitsPerKeys
+	}
+	return &ribbonFilterWriter{
+		bitsPerKeys: bitsPerKey,
+		hashes:      []uint64{},
+	}
+}
+
+func (rf *ribbonFilter) MayContain(filter, key []byte) bool {
+	if len(filter) < trailerLen {
+		return false
+	}
+	n := len(filter) - trailerLen
+	nSlots := int(binary.LittleEndian.Uint32(filter[n:]))
+	seed, resultBits := filter[n+4], filter[n+5]
+	nWords := nSlots / ribbonWidth
+	if nSlots == 0 || n != int(resultBits)*nWords*8 {
+		return false
+	}
+
+	s, c, f := deriveEquation(ribbonHash(key), seed, nSlots, resultBits)
+	for k := range int(resultBits) {
+		col := filter[k*nWords*8 : (k+1)*nWords*8]
+		bit := uint16(bits.OnesCount64(c&encodedWindow(col, s)) & 1)
+		if bit != f>>k&1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (rf *ribbonFilter) Name() string {
+	return "nogodb.go_ribbon_filter.RibbonFilter"
+}
+
+func NewRibbonFilter() IFilter {
+	return &ribbonFilter{}
+}
+
+// calculateResultBits returns the number of fingerprint bits r giving the same
+// false positive rate (2^-r) as a Bloom filter with bitsPerKey bits per key
+// (~0.6185^bitsPerKey)
+func calculateResultBits(bitsPerKey int) byte {
+	r := int(float64(bitsPerKey)*0.69 + 0.5) // 0.69 =~ ln(2)
+	if r < 1 {
+		r = 1
+	}
+	if r > 16 {
+		r = 16
+	}
+	return byte(r)
+}
+
+func roundUpSlots(n int) int {
+	return (n + ribbonWidth - 1) / ribbonWidth * ribbonWidth
+}
+
+func appendTrailer(b []byte, nSlots uint32, seed, resultBits byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, nSlots)
+	return append(b, seed, resultBits)
+}
+
+// deriveEquation returns the starting slot within [0, nSlots-ribbonWidth],
+// the coefficient row (its first bit is always set) and the fingerprint of
+// the hashed key
+func deriveEquation(h uint64, seed byte, nSlots int, resultBits byte) (int, uint64, uint16) {
+	x := mix64(h + uint64(seed)*0x9e3779b97f4a7c15)
+	start, _ := bits.Mul64(x, uint64(nSlots-ribbonWidth+1))
+	c := mix64(x) | 1
+	f := uint16(x) & (1<<resultBits - 1)
+	return int(start), c, f
+}
+
+// window returns the ribbonWidth bits of the column starting at the slot s
+func window(col []uint64, s int) uint64 {
+	w, off := s/ribbonWidth, s%ribbonWidth
+	if off == 0 {
+		return col[w]
+	}
+
+	hi := uint64(0)
+	if w+1 < len(col) {
+		hi = col[w+1]
+	}
+	return col[w]>>off | hi<<(ribbonWidth-off)
+}
+
+// encodedWindow is the window of an encoded column
+func encodedWindow(col []byte, s int) uint64 {
+	w, off := s/ribbonWidth, s%ribbonWidth
+	lo := binary.LittleEndian.Uint64(col[w*8:])
+	if off == 0 {
+		return lo
+	}
+
+	hi := uint64(0)
+	if (w+1)*8 < len(col) {
+		hi = binary.LittleEndian.Uint64(col[(w+1)*8:])
+	}
+	return lo>>off | hi<<(ribbonWidth-off)
+}
+
+// ribbonHash return the 64-bit FNV-1a hash of the given data, it's mixed
+// further with the seed, see deriveEquation
+func ribbonHash(b []byte) uint64 {
+	const (
+		offset = 0xcbf29ce484222325
+		prime  = 0x100000001b3
+	)
+	h := uint64(offset)
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= prime
+	}
+	return h
+}
+
+// mix64 is the finalizer of SplitMix64
+func mix64(z uint64) uint64 {
+	z ^= z >> 30
+	z *= 0xbf58476d1ce4e5b9
+	z ^= z >> 27
+	z *= 0x94d049bb133111eb
+	z ^= z >> 31
+	return z
+}
+
+var (
+	_ IFilter = (*ribbonFilter)(nil)
+	_ IWriter = (*ribbonFilterWriter)(nil)
+)
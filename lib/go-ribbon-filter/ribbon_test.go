@@ -0,0 +1,148 @@
+package go_ribbon_filter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nextN(n int) int {
+	switch {
+	case n < 10:
+		n += 1
+	case n < 100:
+		n += 10
+	case n < 1000:
+		n += 100
+	default:
+		n += 1000
+	}
+	return n
+}
+
+func TestRibbonFilter_Small(t *testing.T) {
+	rf := NewRibbonFilter()
+	writer := rf.NewWriter()
+	writer.Add([]byte("hello"))
+	writer.Add([]byte("world"))
+	var filter []byte
+	writer.Build(&filter)
+	assert.True(t, rf.MayContain(filter, []byte("hello")))
+	assert.True(t, rf.MayContain(filter, []byte("world")))
+	assert.False(t, rf.MayContain(filter, []byte("x")))
+	assert.False(t, rf.MayContain(filter, []byte("foo")))
+}
+
+func TestRibbonFilter_Empty(t *testing.T) {
+	rf := NewRibbonFilter()
+	var filter []byte
+	rf.NewWriter().Build(&filter)
+	assert.Len(t, filter, trailerLen)
+	assert.False(t, rf.MayContain(filter, []byte("hello")))
+	assert.False(t, rf.MayContain(nil, []byte("hello")))
+}
+
+func TestRibbonFilter_DuplicatedKeys(t *testing.T) {
+	rf := NewRibbonFilter()
+	writer := rf.NewWriter()
+	for range 3 {
+		for i := range 1000 {
+			writer.Add(fmt.Appendf(nil, "key-%d", i))
+		}
+	}
+	var filter []byte
+	writer.Build(&filter)
+	for i := range 1000 {
+		assert.True(t, rf.MayContain(filter, fmt.Appendf(nil, "key-%d", i)))
+	}
+}
+
+func TestRibbonFilter_VaryingLengths(t *testing.T) {
+	var mediocre, good int
+	for n := 1; n < 100_000; n = nextN(n) {
+		rf := NewRibbonFilter()
+		writer := rf.NewWriter()
+		for i := range n {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(i))
+			writer.Add(b[:])
+		}
+
+		var filter []byte
+		writer.Build(&filter)
+
+		// assert must not false negative
+		for i := range n {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(i))
+			isIn := rf.MayContain(filter, b[:])
+			assert.True(t, isIn, fmt.Sprintf("%d should be is membership, false negative is not allowed", i))
+		}
+
+		// assert the false positive rate
+		var fpr float32
+		for i := range 10_000 {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(i+1e9))
+			if rf.MayContain(filter, b[:]) {
+				fpr++
+			}
+		}
+		fpr /= 10_000
+		// as we choose defaultBitsPerKeys = 10, the fingerprints have 7 bits,
+		// so the fpr should be ~0.0078
+		assert.LessOrEqual(t, fpr, float32(0.02), fmt.Sprintf("false positive rate: %v%%, is too high", fpr))
+		if fpr >= float32(0.0125) {
+			mediocre++
+		} else {
+			good++
+		}
+
+		// the filter must be ~30% smaller than a Bloom filter of 10 bits per key
+		if n >= 10_000 {
+			bitsPerKey := float64(8*len(filter)) / float64(n)
+			assert.Less(t, bitsPerKey, 7.8, fmt.Sprintf("bits per key: %v, is too high", bitsPerKey))
+		}
+	}
+
+	// number of mediocre filter set should not larger than 20% of the good ones
+	assert.Less(t, mediocre, (good+4)/5, fmt.Sprintf("mediocre (%d) is too high, it is higher than 20%% of good (%d)", mediocre, good))
+}
+
+func TestRibbonFilter_BitsPerKey(t *testing.T) {
+	const n = 10_000
+	rf := NewRibbonFilter()
+	falsePositiveRate := func(bitsPerKey int) (int, float64) {
+		writer := rf.NewWriterWithBitsPerKey(bitsPerKey)
+		for i := range n {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(i))
+			writer.Add(b[:])
+		}
+		var filter []byte
+		writer.Build(&filter)
+
+		var fp int
+		for i := range n {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(i))
+			assert.True(t, rf.MayContain(filter, b[:]), fmt.Sprintf("%d should be is membership, false negative is not allowed", i))
+			binary.LittleEndian.PutUint32(b[:], uint32(i+1e9))
+			if rf.MayContain(filter, b[:]) {
+				fp++
+			}
+		}
+		return len(filter), float64(fp) / n
+	}
+
+	smallSize, smallFpr := falsePositiveRate(5)
+	defaultSize, defaultFpr := falsePositiveRate(0)
+	bigSize, bigFpr := falsePositiveRate(16)
+	assert.Less(t, smallSize, defaultSize)
+	assert.Less(t, defaultSize, bigSize)
+	assert.Greater(t, smallFpr, defaultFpr)
+	assert.Greater(t, defaultFpr, bigFpr)
+	assert.LessOrEqual(t, bigFpr, 0.005, fmt.Sprintf("false positive rate: %v, is too high", bigFpr))
+}
@@ -0,0 +1,84 @@
+package go_skiplist
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Arena is a lock-free, append-only allocator over a fixed size buffer.
+// Memory is never freed individually, the whole arena is dropped at once.
+type Arena struct {
+	n   atomic.Uint64
+	buf []byte
+}
+
+const nodeAlignment = 4
+
+var ErrArenaFull = errors.New("allocation failed because arena is full")
+
+// NewArena allocates a new arena using the specified buffer as the backing
+// store. The buffer can't be larger than 4GB, since the offsets are uint32.
+func NewArena(buf []byte) *Arena {
+	if len(buf) > math.MaxUint32 {
+		buf = buf[:math.MaxUint32]
+	}
+
+	a := &Arena{buf: buf}
+	// The offset 0 is reserved to represent a nil pointer
+	a.n.Store(1)
+	return a
+}
+
+// Size returns the number of bytes allocated by the arena.
+func (a *Arena) Size() uint32 {
+	return uint32(min(a.n.Load(), math.MaxUint32))
+}
+
+// Capacity returns the capacity of the arena.
+func (a *Arena) Capacity() uint32 {
+	return uint32(len(a.buf))
+}
+
+// alloc allocates <size> bytes aligned on <alignment>. <overflow> is the
+// number of bytes after the allocation, which must be addressable as well
+// (e.g. the unused part of a node tower).
+func (a *Arena) alloc(size, alignment, overflow uint32) (uint32, error) {
+	if a.n.Load() > uint64(len(a.buf)) {
+		// the arena is already full, avoid overflowing the counter
+		return 0, ErrArenaFull
+	}
+
+	// pad the allocation with enough bytes to ensure the requested alignment
+	padded := uint64(size) + uint64(alignment) - 1
+
+	newSize := a.n.Add(padded)
+	if newSize+uint64(overflow) > uint64(len(a.buf)) {
+		return 0, ErrArenaFull
+	}
+
+	offset := (uint32(newSize-padded) + alignment - 1) &^ (alignment - 1)
+	return offset, nil
+}
+
+func (a *Arena) getBytes(offset, size uint32) []byte {
+	if offset == 0 {
+		return nil
+	}
+	return a.buf[offset : offset+size : offset+size]
+}
+
+func (a *Arena) getPointer(offset uint32) unsafe.Pointer {
+	if offset == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&a.buf[offset])
+}
+
+func (a *Arena) getPointerOffset(ptr unsafe.Pointer) uint32 {
+	if ptr == nil {
+		return 0
+	}
+	return uint32(uintptr(ptr) - uintptr(unsafe.Pointer(&a.buf[0])))
+}
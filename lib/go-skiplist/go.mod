@@ -0,0 +1,16 @@
+module github.com/datnguyenzzz/nogodb/lib/go-skiplist
+
+go 1.26.0
+
+require (
+	github.com/go-faker/faker/v4 v4.7.0
+	github.com/stretchr/testify v1.11.1
+	golang.org/x/sync v0.19.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/text v0.29.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,81 @@
+package go_skiplist
+
+// Iterator is an iterator over the skiplist. The iterator observes the keys
+// which have been added concurrently, as long as they're not behind its
+// current position. It is not safe to use an Iterator concurrently.
+type Iterator struct {
+	list *Skiplist
+	nd   *node
+}
+
+// Valid returns true if the iterator is positioned at a node
+func (it *Iterator) Valid() bool {
+	return it.nd != it.list.head && it.nd != it.list.tail && it.nd != nil
+}
+
+// Key returns the key at the current position. The returned slice points
+// into the arena, it must not be modified.
+func (it *Iterator) Key() []byte {
+	return it.nd.getKey(it.list.arena)
+}
+
+// Value returns the value at the current position. The returned slice
+// points into the arena, it must not be modified.
+func (it *Iterator) Value() []byte {
+	return it.nd.getValue(it.list.arena)
+}
+
+// SeekGE moves the iterator to the first key >= the given key.
+func (it *Iterator) SeekGE(key []byte) bool {
+	_, it.nd = it.seekForBaseSplice(key)
+	return it.Valid()
+}
+
+// SeekLT moves the iterator to the last key < the given key.
+func (it *Iterator) SeekLT(key []byte) bool {
+	it.nd, _ = it.seekForBaseSplice(key)
+	return it.Valid()
+}
+
+// First moves the iterator to the first key.
+func (it *Iterator) First() bool {
+	it.nd = it.list.getNext(it.list.head, 0)
+	return it.Valid()
+}
+
+// Last moves the iterator to the last key.
+func (it *Iterator) Last() bool {
+	it.nd = it.list.getPrev(it.list.tail, 0)
+	return it.Valid()
+}
+
+// Next moves the iterator to the next key.
+func (it *Iterator) Next() bool {
+	if it.nd == it.list.tail {
+		return false
+	}
+
+	it.nd = it.list.getNext(it.nd, 0)
+	return it.Valid()
+}
+
+// Prev moves the iterator to the previous key.
+func (it *Iterator) Prev() bool {
+	if it.nd == it.list.head {
+		return false
+	}
+
+	it.nd = it.list.getPrev(it.nd, 0)
+	return it.Valid()
+}
+
+// seekForBaseSplice returns the nodes at the base level between which the
+// key would be inserted. If the key exists, next holds the key.
+func (it *Iterator) seekForBaseSplice(key []byte) (prev, next *node) {
+	prev = it.list.head
+	for level := int(it.list.Height()) - 1; level >= 0; level-- {
+		prev, next, _ = it.list.findSpliceForLevel(key, level, prev)
+	}
+
+	return prev, next
+}
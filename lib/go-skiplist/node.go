@@ -0,0 +1,107 @@
+package go_skiplist
+
+import (
+	"math"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	maxHeight = 20
+	// pValue is the probability for a node to be promoted to the next level
+	pValue = 1 / math.E
+)
+
+// links of a node at a given level. Instead of pointers, the links hold the
+// offset of the previous and the next nodes within the arena.
+type links struct {
+	nextOffset atomic.Uint32
+	prevOffset atomic.Uint32
+}
+
+func (l *links) init(prevOffset, nextOffset uint32) {
+	l.nextOffset.Store(nextOffset)
+	l.prevOffset.Store(prevOffset)
+}
+
+// node is allocated in the arena, followed by its key and value.
+//
+//	+------------------+----------------------+---------+-----------+
+//	| Node header (16) | Tower (8 * height)   | Key (N) | Value (M) |
+//	+------------------+----------------------+---------+-----------+
+//
+// Only the first <height> levels of the tower are allocated, the node
+// memory never contains any Go pointer, so the GC ignores it.
+type node struct {
+	keyOffset uint32
+	keySize   uint32
+	valueSize uint32
+	allocSize uint32
+
+	tower [maxHeight]links
+}
+
+const (
+	linksSize   = uint32(unsafe.Sizeof(links{}))
+	maxNodeSize = uint32(unsafe.Sizeof(node{}))
+)
+
+// MaxNodeSize returns the maximum number of bytes that a node holding the
+// given key and value can take in the arena.
+func MaxNodeSize(keySize, valueSize uint32) uint32 {
+	return maxNodeSize + keySize + valueSize + nodeAlignment - 1
+}
+
+func newNode(arena *Arena, height uint32, key, value []byte) (*node, error) {
+	nd, err := newRawNode(arena, height, uint32(len(key)), uint32(len(value)))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(nd.getKey(arena), key)
+	copy(nd.getValue(arena), value)
+	return nd, nil
+}
+
+func newRawNode(arena *Arena, height, keySize, valueSize uint32) (*node, error) {
+	// compute the amount of the tower that will never be used, since the
+	// height is less than maxHeight
+	unusedSize := (maxHeight - height) * linksSize
+	nodeSize := maxNodeSize - unusedSize
+
+	nodeOffset, err := arena.alloc(nodeSize+keySize+valueSize, nodeAlignment, unusedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	nd := (*node)(arena.getPointer(nodeOffset))
+	nd.keyOffset = nodeOffset + nodeSize
+	nd.keySize = keySize
+	nd.valueSize = valueSize
+	nd.allocSize = nodeSize + keySize + valueSize
+	return nd, nil
+}
+
+func (n *node) getKey(arena *Arena) []byte {
+	return arena.getBytes(n.keyOffset, n.keySize)
+}
+
+func (n *node) getValue(arena *Arena) []byte {
+	return arena.getBytes(n.keyOffset+n.keySize, n.valueSize)
+}
+
+func (n *node) nextOffset(h int) uint32 {
+	return n.tower[h].nextOffset.Load()
+}
+
+func (n *node) prevOffset(h int) uint32 {
+	return n.tower[h].prevOffset.Load()
+}
+
+func (n *node) casNextOffset(h int, old, val uint32) bool {
+	return n.tower[h].nextOffset.CompareAndSwap(old, val)
+}
+
+func (n *node) casPrevOffset(h int, old, val uint32) bool {
+	return n.tower[h].prevOffset.CompareAndSwap(old, val)
+}
@@ -0,0 +1,257 @@
+package go_skiplist
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Inspired by PebbleDB's arenaskl, which itself is a fork of Badger's
+// skiplist.
+//
+// The skiplist is lock-free, the nodes are linked together at every level
+// in both directions, so it can be iterated forward and backward. Inserting
+// a node is made of a series of CAS operations, from the base level up to
+// the node height:
+//
+//	+----------------+     +------------+     +----------------+
+//	|      prev      |     |     nd     |     |      next      |
+//	| prevNextOffset |---->|            |     |                |
+//	|                |<----| prevOffset |     |                |
+//	|                |     | nextOffset |---->|                |
+//	|                |     |            |<----| nextPrevOffset |
+//	+----------------+     +------------+     +----------------+
+//
+// 1. Initialise the nd links to point to prev and next
+// 2. CAS prev.nextOffset from next to nd. If it fails, another node has been
+//    inserted between prev and next, so search the new prev and next again.
+// 3. CAS next.prevOffset from prev to nd. It can fail only if another thread
+//    has already helped to fix the link, see the comment in Add.
+//
+// The keys are never removed, nor updated.
+
+// ErrRecordExists is returned when the key has already been added
+var ErrRecordExists = errors.New("record with this key already exists")
+
+// probabilities[i] is the probability (scaled to MaxUint32) for a node
+// to have a height > i
+var probabilities [maxHeight]uint32
+
+func init() {
+	p := 1.0
+	for i := range maxHeight {
+		probabilities[i] = uint32(float64(math.MaxUint32) * p)
+		p *= pValue
+	}
+}
+
+// Skiplist is a lock-free, arena-allocated, ordered map of []byte keys
+// to []byte values.
+type Skiplist struct {
+	arena  *Arena
+	cmp    func(a, b []byte) int
+	head   *node
+	tail   *node
+	height atomic.Uint32 // Current height. 1 <= height <= maxHeight
+}
+
+// NewSkiplist constructs and initialises a new, empty skiplist. All nodes,
+// keys and values in the skiplist will be allocated from the given arena.
+func NewSkiplist(arena *Arena, cmp func(a, b []byte) int) *Skiplist {
+	s := &Skiplist{}
+	s.Reset(arena, cmp)
+	return s
+}
+
+// Reset the skiplist to an empty state, using the given arena.
+func (s *Skiplist) Reset(arena *Arena, cmp func(a, b []byte) int) {
+	head, err := newRawNode(arena, maxHeight, 0, 0)
+	if err != nil {
+		panic("arena is not large enough to hold the head node")
+	}
+	head.keyOffset = 0
+
+	tail, err := newRawNode(arena, maxHeight, 0, 0)
+	if err != nil {
+		panic("arena is not large enough to hold the tail node")
+	}
+	tail.keyOffset = 0
+
+	// link all head and tail levels together
+	headOffset := arena.getPointerOffset(unsafe.Pointer(head))
+	tailOffset := arena.getPointerOffset(unsafe.Pointer(tail))
+	for i := range maxHeight {
+		head.tower[i].nextOffset.Store(tailOffset)
+		tail.tower[i].prevOffset.Store(headOffset)
+	}
+
+	s.arena = arena
+	s.cmp = cmp
+	s.head = head
+	s.tail = tail
+	s.height.Store(1)
+}
+
+// Height returns the height of the highest tower within any of the nodes
+// that have ever been allocated as part of this skiplist.
+func (s *Skiplist) Height() uint32 { return s.height.Load() }
+
+// Arena returns the arena backing this skiplist.
+func (s *Skiplist) Arena() *Arena { return s.arena }
+
+// Size returns the number of bytes that have been allocated from the arena.
+func (s *Skiplist) Size() uint32 { return s.arena.Size() }
+
+// splice is the pair of nodes, between which a key should be inserted
+type splice struct {
+	prev *node
+	next *node
+}
+
+// Add adds a new key if it does not yet exist. If the key already exists,
+// then Add returns ErrRecordExists. If there isn't enough room in the arena,
+// then Add returns ErrArenaFull. Add is safe to be called concurrently.
+func (s *Skiplist) Add(key, value []byte) error {
+	var spl [maxHeight]splice
+	if s.findSplice(key, &spl) {
+		return ErrRecordExists
+	}
+
+	height := s.randomHeight()
+	nd, err := newNode(s.arena, height, key, value)
+	if err != nil {
+		return err
+	}
+	ndOffset := s.arena.getPointerOffset(unsafe.Pointer(nd))
+
+	// Try to increase s.height via CAS.
+	listHeight := s.Height()
+	for height > listHeight {
+		if s.height.CompareAndSwap(listHeight, height) {
+			break
+		}
+		listHeight = s.Height()
+	}
+
+	// We always insert from the base level and up. After you add a node in
+	// base level, we cannot create a node in the level above because it would
+	// have discovered the node in the base level.
+	for i := 0; i < int(height); i++ {
+		prev, next := spl[i].prev, spl[i].next
+		if prev == nil {
+			// The new node increased the height of the skiplist, the new
+			// level might not have been populated yet. The key can't be
+			// found here, since it has been inserted at the base level.
+			prev, next, _ = s.findSpliceForLevel(key, i, s.head)
+		}
+
+		for {
+			prevOffset := s.arena.getPointerOffset(unsafe.Pointer(prev))
+			nextOffset := s.arena.getPointerOffset(unsafe.Pointer(next))
+			nd.tower[i].init(prevOffset, nextOffset)
+
+			// Check whether next has an updated link to prev. If it does not,
+			// that can mean one of two things:
+			//   1. The thread that added the next node hasn't yet had a chance
+			//      to add the prev link (but will shortly).
+			//   2. Another thread has added a new node between prev and next.
+			if nextPrevOffset := next.prevOffset(i); nextPrevOffset != prevOffset {
+				// Determine whether #1 or #2 is true by checking whether prev
+				// is still pointing to next.
+				if prev.nextOffset(i) == nextOffset {
+					// Case #1, help the other thread along by updating the
+					// next node's prev link.
+					next.casPrevOffset(i, nextPrevOffset, prevOffset)
+				}
+			}
+
+			if prev.casNextOffset(i, nextOffset, ndOffset) {
+				// Managed to insert nd between prev and next, so update the
+				// next node's prev link and go to the next level.
+				next.casPrevOffset(i, prevOffset, ndOffset)
+				break
+			}
+
+			// CAS failed. We need to recompute prev and next. It is unlikely
+			// to be helpful to try to use a different level as we redo the
+			// search, because it is unlikely that lots of nodes are inserted
+			// between prev and next.
+			var found bool
+			prev, next, found = s.findSpliceForLevel(key, i, prev)
+			if found {
+				if i != 0 {
+					panic("how can another thread have inserted a node at a non-base level?")
+				}
+				// the allocated node is simply wasted in the arena
+				return ErrRecordExists
+			}
+		}
+	}
+
+	return nil
+}
+
+// NewIter returns a new Iterator over the skiplist. The iterator is not
+// positioned, call one of the seek or the First/Last methods first.
+func (s *Skiplist) NewIter() *Iterator {
+	return &Iterator{list: s, nd: s.head}
+}
+
+func (s *Skiplist) randomHeight() uint32 {
+	rnd := rand.Uint32()
+
+	h := uint32(1)
+	for h < maxHeight && rnd <= probabilities[h] {
+		h++
+	}
+
+	return h
+}
+
+// findSplice fills the splice of every level of the list. It returns true
+// if the key already exists.
+func (s *Skiplist) findSplice(key []byte, spl *[maxHeight]splice) (found bool) {
+	prev := s.head
+	for level := int(s.Height()) - 1; level >= 0; level-- {
+		var next *node
+		prev, next, found = s.findSpliceForLevel(key, level, prev)
+		spl[level] = splice{prev: prev, next: next}
+	}
+
+	return found
+}
+
+// findSpliceForLevel walks the level from <start>, which is assumed to
+// have a key < key, and returns the nodes between which the key should be
+// inserted. found is true if next holds the key.
+func (s *Skiplist) findSpliceForLevel(key []byte, level int, start *node) (prev, next *node, found bool) {
+	prev = start
+
+	for {
+		next = s.getNext(prev, level)
+		if next == s.tail {
+			return prev, next, false
+		}
+
+		switch c := s.cmp(key, next.getKey(s.arena)); {
+		case c < 0:
+			// prev.key < key < next.key, we're done for this level
+			return prev, next, false
+		case c == 0:
+			return prev, next, true
+		}
+
+		// keep moving right on this level
+		prev = next
+	}
+}
+
+func (s *Skiplist) getNext(nd *node, h int) *node {
+	return (*node)(s.arena.getPointer(nd.nextOffset(h)))
+}
+
+func (s *Skiplist) getPrev(nd *node, h int) *node {
+	return (*node)(s.arena.getPointer(nd.prevOffset(h)))
+}
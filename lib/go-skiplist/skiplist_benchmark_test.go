@@ -0,0 +1,152 @@
+package go_skiplist
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+// The benchmarks mirror the ones of the go-adaptive-radix-tree, with the same
+// workload, so that both results can be compared. There is no equivalent of
+// BenchmarkInsertDeleteAndGet, since the skiplist is append-only.
+
+const benchmarkKeyCount = 10_000_000
+
+type kv struct {
+	Key   []byte
+	Value []byte
+}
+
+func randomQuote() string {
+	quote := struct {
+		Sentence string `faker:"sentence"`
+	}{}
+
+	if err := faker.FakeData(&quote); err != nil {
+		return ""
+	}
+
+	return quote.Sentence
+}
+
+func seedKVs(sz int) []kv {
+	res := make([]kv, sz)
+	for i := range sz {
+		res[i] = kv{
+			Key:   fmt.Appendf(nil, "%d__%v", i, randomQuote()),
+			Value: []byte(randomQuote()),
+		}
+	}
+	return res
+}
+
+// newBenchSkiplist allocates a skiplist large enough to hold all the kvs
+func newBenchSkiplist(kvs []kv) *Skiplist {
+	size := 2 * maxNodeSize
+	for _, kv := range kvs {
+		size += MaxNodeSize(uint32(len(kv.Key)), uint32(len(kv.Value)))
+	}
+	return NewSkiplist(NewArena(make([]byte, size)), bytes.Compare)
+}
+
+func get(l *Skiplist, key []byte) ([]byte, bool) {
+	it := l.NewIter()
+	if !it.SeekGE(key) || !bytes.Equal(it.Key(), key) {
+		return nil, false
+	}
+	return it.Value(), true
+}
+
+func BenchmarkInsert(b *testing.B) {
+	kvs := seedKVs(benchmarkKeyCount)
+	ctx := context.Background()
+
+	concurrencies := []int{1, 10, 20}
+
+	for _, concurrency := range concurrencies {
+		b.Run(fmt.Sprintf("BenchmarkInsert-%d", concurrency), func(b *testing.B) {
+			for b.Loop() {
+				eg, _ := errgroup.WithContext(ctx)
+				eg.SetLimit(concurrency)
+				l := newBenchSkiplist(kvs)
+				for _, kv := range kvs {
+					eg.Go(func() error {
+						err := l.Add(kv.Key, kv.Value)
+						require.NoError(b, err)
+						return err
+					})
+				}
+
+				err := eg.Wait()
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	kvs := seedKVs(benchmarkKeyCount)
+	ctx := context.Background()
+	l := newBenchSkiplist(kvs)
+	for _, kv := range kvs {
+		_ = l.Add(kv.Key, kv.Value)
+	}
+
+	concurrencies := []int{1, 10, 20}
+
+	for _, concurrency := range concurrencies {
+		b.Run(fmt.Sprintf("BenchmarkGet-%d", concurrency), func(b *testing.B) {
+			for b.Loop() {
+				eg, _ := errgroup.WithContext(ctx)
+				eg.SetLimit(concurrency)
+				for _, kv := range kvs {
+					eg.Go(func() error {
+						v, ok := get(l, kv.Key)
+						assert.True(b, ok)
+						assert.Equal(b, kv.Value, v)
+						return nil
+					})
+				}
+
+				err := eg.Wait()
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkInsertAndGet(b *testing.B) {
+	kvs := seedKVs(benchmarkKeyCount)
+	ctx := context.Background()
+
+	concurrencies := []int{1, 10, 20}
+
+	for _, concurrency := range concurrencies {
+		b.Run(fmt.Sprintf("BenchmarkInsertAndGet-%d", concurrency), func(b *testing.B) {
+			for b.Loop() {
+				eg, _ := errgroup.WithContext(ctx)
+				eg.SetLimit(concurrency)
+				l := newBenchSkiplist(kvs)
+				for _, kv := range kvs {
+					eg.Go(func() error {
+						err := l.Add(kv.Key, kv.Value)
+						require.NoError(b, err)
+						v, ok := get(l, kv.Key)
+						assert.True(b, ok)
+						assert.Equal(b, kv.Value, v)
+						return nil
+					})
+				}
+
+				err := eg.Wait()
+				require.NoError(b, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package go_skiplist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const arenaSize = 1 << 20
+
+func makeKey(i int) []byte {
+	return fmt.Appendf(nil, "%05d", i)
+}
+
+func newTestSkiplist(size int) *Skiplist {
+	return NewSkiplist(NewArena(make([]byte, size)), bytes.Compare)
+}
+
+func TestSkiplist_Empty(t *testing.T) {
+	l := newTestSkiplist(arenaSize)
+	it := l.NewIter()
+
+	assert.False(t, it.Valid())
+	assert.False(t, it.First())
+	assert.False(t, it.Last())
+	assert.False(t, it.SeekGE([]byte("a")))
+	assert.False(t, it.SeekLT([]byte("a")))
+}
+
+func TestSkiplist_AddAndIterate(t *testing.T) {
+	l := newTestSkiplist(arenaSize)
+
+	n := 1000
+	perm := rand.Perm(n)
+	for _, i := range perm {
+		require.NoError(t, l.Add(makeKey(i), makeKey(i*10)))
+	}
+
+	t.Run("forward", func(t *testing.T) {
+		it := l.NewIter()
+		i := 0
+		for valid := it.First(); valid; valid = it.Next() {
+			assert.Equal(t, makeKey(i), it.Key())
+			assert.Equal(t, makeKey(i*10), it.Value())
+			i++
+		}
+		assert.Equal(t, n, i)
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		it := l.NewIter()
+		i := n - 1
+		for valid := it.Last(); valid; valid = it.Prev() {
+			assert.Equal(t, makeKey(i), it.Key())
+			i--
+		}
+		assert.Equal(t, -1, i)
+	})
+
+	t.Run("duplicated key", func(t *testing.T) {
+		assert.ErrorIs(t, l.Add(makeKey(10), nil), ErrRecordExists)
+	})
+}
+
+func TestSkiplist_Seek(t *testing.T) {
+	l := newTestSkiplist(arenaSize)
+	// only the even keys are added
+	for i := 0; i < 100; i += 2 {
+		require.NoError(t, l.Add(makeKey(i), nil))
+	}
+
+	tests := []struct {
+		name      string
+		seekGE    bool
+		key       int
+		wantValid bool
+		wantKey   int
+	}{
+		{name: "SeekGE existing key", seekGE: true, key: 10, wantValid: true, wantKey: 10},
+		{name: "SeekGE missing key", seekGE: true, key: 11, wantValid: true, wantKey: 12},
+		{name: "SeekGE before first", seekGE: true, key: -1, wantValid: true, wantKey: 0},
+		{name: "SeekGE after last", seekGE: true, key: 99, wantValid: false},
+		{name: "SeekLT existing key", key: 10, wantValid: true, wantKey: 8},
+		{name: "SeekLT missing key", key: 11, wantValid: true, wantKey: 10},
+		{name: "SeekLT first key", key: 0, wantValid: false},
+		{name: "SeekLT after last", key: 1000, wantValid: true, wantKey: 98},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			it := l.NewIter()
+			key := makeKey(tc.key)
+			if tc.key < 0 {
+				key = []byte{}
+			}
+
+			var valid bool
+			if tc.seekGE {
+				valid = it.SeekGE(key)
+			} else {
+				valid = it.SeekLT(key)
+			}
+
+			require.Equal(t, tc.wantValid, valid)
+			if tc.wantValid {
+				assert.Equal(t, makeKey(tc.wantKey), it.Key())
+			}
+		})
+	}
+}
+
+func TestSkiplist_ArenaFull(t *testing.T) {
+	l := newTestSkiplist(2*int(maxNodeSize) + 256)
+
+	var err error
+	for i := 0; err == nil; i++ {
+		err = l.Add(makeKey(i), make([]byte, 16))
+	}
+	assert.ErrorIs(t, err, ErrArenaFull)
+	assert.LessOrEqual(t, l.Size(), l.Arena().Capacity()+maxNodeSize)
+}
+
+func TestSkiplist_ConcurrentAdd(t *testing.T) {
+	l := newTestSkiplist(64 << 20)
+
+	numWorkers, perWorker := 8, 5000
+	var wg sync.WaitGroup
+	for w := range numWorkers {
+		wg.Go(func() {
+			for i := range perWorker {
+				key := binary.BigEndian.AppendUint32(nil, uint32(i*numWorkers+w))
+				assert.NoError(t, l.Add(key, key))
+			}
+		})
+	}
+
+	// read concurrently with the writers, the keys must always be ordered
+	wg.Go(func() {
+		for range 10 {
+			it := l.NewIter()
+			var prev []byte
+			for valid := it.First(); valid; valid = it.Next() {
+				assert.Negative(t, bytes.Compare(prev, it.Key()))
+				prev = slices.Clone(it.Key())
+			}
+		}
+	})
+	wg.Wait()
+
+	it := l.NewIter()
+	cnt := 0
+	for valid := it.First(); valid; valid = it.Next() {
+		assert.Equal(t, uint32(cnt), binary.BigEndian.Uint32(it.Key()))
+		assert.Equal(t, it.Key(), it.Value())
+		cnt++
+	}
+	assert.Equal(t, numWorkers*perWorker, cnt)
+
+	cnt = 0
+	for valid := it.Last(); valid; valid = it.Prev() {
+		cnt++
+	}
+	assert.Equal(t, numWorkers*perWorker, cnt)
+}
+
+func TestSkiplist_ConcurrentAddSameKey(t *testing.T) {
+	l := newTestSkiplist(arenaSize)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for range 16 {
+		wg.Go(func() {
+			if err := l.Add([]byte("same-key"), nil); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else {
+				assert.ErrorIs(t, err, ErrRecordExists)
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, succeeded)
+}
@@ -0,0 +1,159 @@
+package block
+
+import (
+	"encoding/binary"
+	"slices"
+	"strings"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+)
+
+// The properties of a block are the properties of each collector, in the
+// order of the collectors, prefixed by their length:
+//
+//	+------------------+---------+-----+------------------+---------+
+//	| Len_0 (uvarint)  | Prop_0  | ... | Len_n (uvarint)  | Prop_n  |
+//	+------------------+---------+-----+------------------+---------+
+//
+// They're appended to the encoded block handle of the index entries. The
+// properties block maps the name of each collector to its index and to its
+// property merged over the whole table, see common.UserProperty.
+
+// BlockPropsCollector drives the block property collectors of a table
+type BlockPropsCollector struct {
+	collectors []options.BlockPropertyCollector
+}
+
+// NewBlockPropsCollector returns nil if there is no collector
+func NewBlockPropsCollector(newCollectors []func() options.BlockPropertyCollector) *BlockPropsCollector {
+	if len(newCollectors) == 0 {
+		return nil
+	}
+
+	p := &BlockPropsCollector{
+		collectors: make([]options.BlockPropertyCollector, 0, len(newCollectors)),
+	}
+	for _, newCollector := range newCollectors {
+		p.collectors = append(p.collectors, newCollector())
+	}
+
+	return p
+}
+
+func (p *BlockPropsCollector) Add(key nogodb_common.InternalKey, value []byte) error {
+	if p == nil {
+		return nil
+	}
+
+	for _, c := range p.collectors {
+		if err := c.Add(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FinishDataBlock returns the encoded properties of the current data block
+func (p *BlockPropsCollector) FinishDataBlock() ([]byte, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	var encoded, prop []byte
+	var err error
+	for _, c := range p.collectors {
+		if prop, err = c.FinishDataBlock(prop[:0]); err != nil {
+			return nil, err
+		}
+		encoded = appendProp(encoded, prop)
+	}
+
+	return encoded, nil
+}
+
+// Merge returns the union of the encoded properties a and b. An empty a
+// (or b) is the properties of nothing.
+func (p *BlockPropsCollector) Merge(a, b []byte) ([]byte, error) {
+	switch {
+	case p == nil:
+		return nil, nil
+	case len(a) == 0:
+		return b, nil
+	case len(b) == 0:
+		return a, nil
+	}
+
+	propsA, err := DecodeBlockProps(a, len(p.collectors))
+	if err != nil {
+		return nil, err
+	}
+	propsB, err := DecodeBlockProps(b, len(p.collectors))
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded, prop []byte
+	for i, c := range p.collectors {
+		if prop, err = c.Merge(prop[:0], propsA[i], propsB[i]); err != nil {
+			return nil, err
+		}
+		encoded = appendProp(encoded, prop)
+	}
+
+	return encoded, nil
+}
+
+// UserProperties returns the properties of the collectors merged over the
+// whole table, sorted by name
+func (p *BlockPropsCollector) UserProperties(merged []byte) ([]common.UserProperty, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	props := make([][]byte, len(p.collectors))
+	if len(merged) > 0 {
+		var err error
+		if props, err = DecodeBlockProps(merged, len(p.collectors)); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make([]common.UserProperty, 0, len(p.collectors))
+	for i, c := range p.collectors {
+		res = append(res, common.UserProperty{Name: c.Name(), Index: i, Prop: props[i]})
+	}
+	slices.SortFunc(res, func(x, y common.UserProperty) int {
+		return strings.Compare(x.Name, y.Name)
+	})
+
+	return res, nil
+}
+
+// DecodeBlockProps splits the encoded properties of a block into the
+// properties of its n collectors
+func DecodeBlockProps(encoded []byte, n int) ([][]byte, error) {
+	props := make([][]byte, 0, n)
+	for len(encoded) > 0 {
+		l, m := binary.Uvarint(encoded)
+		if m <= 0 || uint64(len(encoded)-m) < l {
+			return nil, options.ErrInvalidBlockProperty
+		}
+
+		props = append(props, encoded[m:m+int(l)])
+		encoded = encoded[m+int(l):]
+	}
+
+	if len(props) != n {
+		return nil, options.ErrInvalidBlockProperty
+	}
+
+	return props, nil
+}
+
+func appendProp(buf, prop []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(prop)))
+	return append(buf, prop...)
+}
@@ -0,0 +1,92 @@
+package block
+
+import (
+	"testing"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BlockPropsCollector(t *testing.T) {
+	tagOf := func(_ nogodb_common.InternalKey, value []byte) (uint64, bool) {
+		if len(value) == 0 {
+			return 0, false
+		}
+		return uint64(value[0]), true
+	}
+
+	p := NewBlockPropsCollector([]func() options.BlockPropertyCollector{
+		func() options.BlockPropertyCollector { return options.NewUint64RangeCollector("z-tag", tagOf) },
+		options.NewMVCCTimeRangeCollector,
+	})
+
+	add := func(ts uint64, tag byte) {
+		key := nogodb_common.MakeKey(nogodb_common.EncodeMVCCKey([]byte("key"), ts), 0, nogodb_common.KeyKindSet)
+		require.NoError(t, p.Add(key, []byte{tag}))
+	}
+
+	// 1st data block: ts [10, 20], tag [3, 7]
+	add(20, 7)
+	add(10, 3)
+	first, err := p.FinishDataBlock()
+	require.NoError(t, err)
+
+	// 2nd data block: ts [30, 30], tag [1, 1]
+	add(30, 1)
+	second, err := p.FinishDataBlock()
+	require.NoError(t, err)
+
+	// 3rd data block: nothing collected
+	third, err := p.FinishDataBlock()
+	require.NoError(t, err)
+
+	merged, err := p.Merge(first, second)
+	require.NoError(t, err)
+	merged, err = p.Merge(merged, third)
+	require.NoError(t, err)
+
+	type param struct {
+		desc     string
+		encoded  []byte
+		filter   options.BlockPropertyFilter
+		expected bool
+	}
+
+	tests := []param{
+		{desc: "ts within the 1st block", encoded: first, filter: options.NewMVCCTimeRangeFilter(15, 15), expected: true},
+		{desc: "ts after the 1st block", encoded: first, filter: options.NewMVCCTimeRangeFilter(21, 100), expected: false},
+		{desc: "ts overlaps the 2nd block", encoded: second, filter: options.NewMVCCTimeRangeFilter(25, 35), expected: true},
+		{desc: "tag before the 1st block", encoded: first, filter: options.NewUint64RangeFilter("z-tag", 0, 2), expected: false},
+		{desc: "nothing collected in the 3rd block", encoded: third, filter: options.NewMVCCTimeRangeFilter(21, 29), expected: true},
+		{desc: "ts between the merged blocks", encoded: merged, filter: options.NewMVCCTimeRangeFilter(21, 29), expected: true},
+		{desc: "ts after the merged blocks", encoded: merged, filter: options.NewMVCCTimeRangeFilter(31, 100), expected: false},
+		{desc: "tag within the merged blocks", encoded: merged, filter: options.NewUint64RangeFilter("z-tag", 1, 1), expected: true},
+	}
+
+	tableProps, err := p.UserProperties(merged)
+	require.NoError(t, err)
+	require.Len(t, tableProps, 2)
+	assert.Equal(t, options.MVCCTimeRangePropertyName, tableProps[0].Name)
+	assert.Equal(t, "z-tag", tableProps[1].Name)
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			var up common.UserProperty
+			for _, candidate := range tableProps {
+				if candidate.Name == tc.filter.Name() {
+					up = candidate
+				}
+			}
+
+			props, err := DecodeBlockProps(tc.encoded, len(tableProps))
+			require.NoError(t, err)
+
+			matched, err := tc.filter.Intersects(props[up.Index])
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, matched)
+		})
+	}
+}
@@ -22,6 +22,11 @@ func NewLayoutDecoder(data []byte) *LayoutDecoder {
 	}
 }
 
+// Columns returns the number of columns of the block
+func (d *LayoutDecoder) Columns() uint16 {
+	return d.header.columns
+}
+
 func (d *LayoutDecoder) DataType(col uint16) codex.DataType {
 	offset := HeaderOffset + ColumnHeadSize*col
 
@@ -44,7 +49,7 @@ func Decode[T codex.EncodableDataType](
 	col uint16,
 	instructor codex.DecoderInstructor[T],
 ) codex.IColumnDecoder[T] {
-	if col > d.header.columns {
+	if col >= d.header.columns {
 		panic(fmt.Sprintf("requested col: %d is greater than columns in header: %d", col, d.header.columns))
 	}
 
@@ -123,6 +123,35 @@ func Test_iterating_over_a_block(t *testing.T) {
 	}
 }
 
+func Test_adding_keys_from_a_reused_buffer(t *testing.T) {
+	mvccComparer := newMvccComparer()
+	bp := predictable_size.NewPredictablePool()
+	writer := colblock.NewDataBlockWriter(mvccComparer)
+
+	// the caller may reuse the buffer of the key once added
+	userKeys := genInput(100, 5, true)
+	var buf []byte
+	for i, userKey := range userKeys {
+		buf = append(buf[:0], userKey...)
+		writer.Add(nogodb_common.MakeKey(buf, nogodb_common.SeqNum(i), nogodb_common.KeyKindSet), userKey)
+	}
+	require.Equal(t, userKeys[len(userKeys)-1], writer.CurrKey().UserKey)
+
+	estSize := int(writer.Size())
+	data := writer.Finish(uint32(len(userKeys)), estSize)
+	lz := nogodb_common.NewBlankInternalLazyValue(nogodb_common.ValueFromBuffer)
+	lz.ReserveBuffer(bp, len(data))
+	lz.SetBufferValue(data)
+
+	iter := colblock.NewDataBlockIter(bp, mvccComparer, &lz)
+	i := 0
+	for kv := iter.First(); kv != nil; kv = iter.Next() {
+		assertKv(t, i, "Next", kv, userKeys[i], userKeys[i])
+		i += 1
+	}
+	require.Equal(t, len(userKeys), i)
+}
+
 func Test_seeking_on_data_block(t *testing.T) {
 	type param struct {
 		desc            string
@@ -2,6 +2,7 @@ package col_block
 
 import (
 	"fmt"
+	"slices"
 
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	bitmapcodex "github.com/datnguyenzzz/nogodb/lib/go-sstable/block/col_block/codex/bitmap_codex"
@@ -77,9 +78,12 @@ func (d *DataBlockWriter) Reset() {
 	d.currKey = nil
 }
 
-// Add adds a key-value pair to the sstable.
+// Add adds a key-value pair to the sstable. The key columns reference the
+// user key until the block is finished, it's copied since the caller might
+// reuse its buffer.
 func (d *DataBlockWriter) Add(key nogodb_common.InternalKey, value []byte) {
 	d.rows += 1
+	key.UserKey = slices.Clone(key.UserKey)
 	d.currKey = &key
 
 	prefixLen := d.comparer.Split(key.UserKey)
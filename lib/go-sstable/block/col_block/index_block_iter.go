@@ -22,6 +22,10 @@ type IndexBlockIter struct {
 		length *uintcodex.UintDecoder[uint64]
 	}
 
+	// propsDecoder is nil if the block has no props column, i.e. it has been
+	// written before the block properties
+	propsDecoder *rawbytescodex.RawBytesDecoder
+
 	currRow uint32 // used for iterating over the block
 	closed  bool
 }
@@ -93,6 +97,7 @@ func (i *IndexBlockIter) Close() error {
 	i.keyDecoder = nil
 	i.blockHandleDecoder.offset = nil
 	i.blockHandleDecoder.length = nil
+	i.propsDecoder = nil
 	return nil
 }
 
@@ -118,12 +123,17 @@ func (i *IndexBlockIter) toKv() *nogodb_common.InternalKV {
 		Length: i.blockHandleDecoder.length.Get(i.currRow),
 	}
 
-	var buf [common.MaxBlockHandleBytes]byte
-	_ = bh.EncodeInto(buf[:])
+	// the value is the encoded block handle, followed by the block properties
+	var props []byte
+	if i.propsDecoder != nil {
+		props = i.propsDecoder.Get(i.currRow)
+	}
+	buf := make([]byte, common.MaxBlockHandleBytes, common.MaxBlockHandleBytes+len(props))
+	buf = append(buf[:bh.EncodeInto(buf)], props...)
 
 	v := nogodb_common.NewBlankInternalLazyValue(nogodb_common.ValueFromBuffer)
 	v.ReserveBuffer(i.bpool, len(buf))
-	if err := v.SetBufferValue(buf[:]); err != nil {
+	if err := v.SetBufferValue(buf); err != nil {
 		zap.L().Error("failed to set value", zap.Error(err))
 	}
 	iKv.V = v
@@ -150,6 +160,12 @@ func NewIndexBlockIter(
 	var ok bool
 
 	for i, cName := range indexColumnsOrder {
+		if i >= int(decoder.Columns()) {
+			// the trailing columns added since the block was written, e.g.
+			// the props, are left empty
+			break
+		}
+
 		switch cName {
 		case "key":
 			d.keyDecoder, ok = layoutcodex.Decode(
@@ -181,6 +197,16 @@ func NewIndexBlockIter(
 			if !ok {
 				panic("NewIndexBlockIter, failed to assert to UintDecoder")
 			}
+		case "props":
+			d.propsDecoder, ok = layoutcodex.Decode(
+				cp,
+				decoder,
+				uint16(i),
+				rawbytescodex.NewRawBytesDecoder,
+			).(*rawbytescodex.RawBytesDecoder)
+			if !ok {
+				panic("NewIndexBlockIter, failed to assert to RawBytesDecoder")
+			}
 		default:
 			panic("IndexBlockWriter unhandled column name")
 		}
@@ -10,6 +10,9 @@ import (
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	"github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool/predictable_size"
 	colblock "github.com/datnguyenzzz/nogodb/lib/go-sstable/block/col_block"
+	layoutcodex "github.com/datnguyenzzz/nogodb/lib/go-sstable/block/col_block/codex/layout_codex"
+	rawbytescodex "github.com/datnguyenzzz/nogodb/lib/go-sstable/block/col_block/codex/raw_bytes_codex"
+	uintcodex "github.com/datnguyenzzz/nogodb/lib/go-sstable/block/col_block/codex/uint_codex"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
 	"github.com/stretchr/testify/require"
 )
@@ -64,16 +67,20 @@ func Test_iterating_over_an_index_block(t *testing.T) {
 			// Prep input data
 			keys := reduceDuplicate(genInput(tc.size, tc.sharedPrefixLen, false))
 			blockHandles := make([]*common.BlockHandle, 0, len(keys))
+			props := make([][]byte, 0, len(keys))
 			for range keys {
 				blockHandles = append(blockHandles, &common.BlockHandle{
 					Offset: r.Uint64(),
 					Length: r.Uint64(),
 				})
+				prop := make([]byte, r.Intn(8))
+				r.Read(prop)
+				props = append(props, prop)
 			}
 
 			// Write data
 			for i := range keys {
-				writer.Add(keys[i], blockHandles[i])
+				writer.Add(keys[i], blockHandles[i], props[i])
 			}
 
 			estSize := int(writer.Size())
@@ -89,13 +96,13 @@ func Test_iterating_over_an_index_block(t *testing.T) {
 			// Verify iterating over a block
 			i := 0
 			for kv := iter.First(); kv != nil; kv = iter.Next() {
-				assertIndexKv(t, "Next", kv, keys[i], blockHandles[i])
+				assertIndexKv(t, "Next", kv, keys[i], blockHandles[i], props[i])
 				i += 1
 			}
 
 			i = len(keys) - 1
 			for kv := iter.Last(); kv != nil; kv = iter.Prev() {
-				assertIndexKv(t, "Prev", kv, keys[i], blockHandles[i])
+				assertIndexKv(t, "Prev", kv, keys[i], blockHandles[i], props[i])
 				i -= 1
 			}
 		})
@@ -161,7 +168,7 @@ func Test_Seeking_on_an_index_block(t *testing.T) {
 
 			// Write data
 			for i := range keys {
-				writer.Add(keys[i], blockHandles[i])
+				writer.Add(keys[i], blockHandles[i], nil)
 			}
 
 			estSize := int(writer.Size())
@@ -176,13 +183,13 @@ func Test_Seeking_on_an_index_block(t *testing.T) {
 
 			// 5 byte for the suffix
 			kv := iter.SeekGTE([]byte{0x0})
-			assertIndexKv(t, "SeekGTE", kv, keys[0], blockHandles[0])
+			assertIndexKv(t, "SeekGTE", kv, keys[0], blockHandles[0], nil)
 
 			for i := 1; i < len(keys); i++ {
 				// fmt.Println("testing", i, "-th")
 				// seeking GTE directly with a key
 				kv = iter.SeekGTE(keys[i])
-				assertIndexKv(t, "SeekGTE strict", kv, keys[i], blockHandles[i])
+				assertIndexKv(t, "SeekGTE strict", kv, keys[i], blockHandles[i], nil)
 
 				// seeking GTE with a smaller key
 				smallerKey := make([]byte, len(keys[i-1]))
@@ -191,12 +198,12 @@ func Test_Seeking_on_an_index_block(t *testing.T) {
 
 				if bytes.Compare(smallerKey, keys[i]) <= 0 {
 					kv = iter.SeekGTE(smallerKey)
-					assertIndexKv(t, "SeekGTE /w smaller", kv, keys[i], blockHandles[i])
+					assertIndexKv(t, "SeekGTE /w smaller", kv, keys[i], blockHandles[i], nil)
 				}
 
 				// seeking LTE directly with a key
 				kv = iter.SeekLTE(keys[i])
-				assertIndexKv(t, "SeekLTE strict", kv, keys[i], blockHandles[i])
+				assertIndexKv(t, "SeekLTE strict", kv, keys[i], blockHandles[i], nil)
 
 				// seeking LTE with a bigger key
 				biggerKey := make([]byte, len(keys[i]))
@@ -205,13 +212,60 @@ func Test_Seeking_on_an_index_block(t *testing.T) {
 
 				if i+1 < len(keys) && bytes.Compare(biggerKey, keys[i+1]) < 0 {
 					kv = iter.SeekLTE(biggerKey)
-					assertIndexKv(t, "SeekLTE /w bigger", kv, keys[i], blockHandles[i])
+					assertIndexKv(t, "SeekLTE /w bigger", kv, keys[i], blockHandles[i], nil)
 				}
 			}
 		})
 	}
 }
 
+func Test_Reading_an_index_block_without_props_column(t *testing.T) {
+	bp := predictable_size.NewPredictablePool()
+	keys := reduceDuplicate(genInput(100, 5, false))
+
+	// an index block written before the block properties has no props column
+	var keyEnc rawbytescodex.RawByteEncoder
+	var offsetEnc, lengthEnc uintcodex.UintEncoder[uint64]
+	keyEnc.Init()
+	offsetEnc.Init()
+	lengthEnc.Init()
+	blockHandles := make([]*common.BlockHandle, 0, len(keys))
+	for i, key := range keys {
+		bh := &common.BlockHandle{Offset: uint64(i) * 100, Length: 100}
+		blockHandles = append(blockHandles, bh)
+		keyEnc.Append(key)
+		offsetEnc.Append(bh.Offset)
+		lengthEnc.Append(bh.Length)
+	}
+
+	const columns = 3
+	size := uint32(layoutcodex.HeaderOffset + layoutcodex.ColumnHeadSize*columns)
+	size += keyEnc.Size(size)
+	size += offsetEnc.Size(size)
+	size += lengthEnc.Size(size)
+	size += 1
+
+	var layoutEnc layoutcodex.LayoutEncoder
+	layoutEnc.Init(int(size), layoutcodex.NewHeader(common.TableV2, columns, uint32(len(keys))))
+	layoutEnc.Encode(uint32(len(keys)), &keyEnc)
+	layoutEnc.Encode(uint32(len(keys)), &offsetEnc)
+	layoutEnc.Encode(uint32(len(keys)), &lengthEnc)
+	data := layoutEnc.Data()
+
+	lz := nogodb_common.NewBlankInternalLazyValue(nogodb_common.ValueFromBuffer)
+	lz.ReserveBuffer(bp, len(data))
+	require.NoError(t, lz.SetBufferValue(data))
+
+	iter := colblock.NewIndexBlockIter(bp, newMvccComparer(), &lz)
+	i := 0
+	for kv := iter.First(); kv != nil; kv = iter.Next() {
+		assertIndexKv(t, "Next", kv, keys[i], blockHandles[i], nil)
+		i += 1
+	}
+	require.Equal(t, len(keys), i)
+	require.NoError(t, iter.Close())
+}
+
 func reduceDuplicate(slice [][]byte) [][]byte {
 	res := make([][]byte, 0, len(slice))
 	for i := 0; i < len(slice); {
@@ -232,12 +286,14 @@ func assertIndexKv(
 	actual *nogodb_common.InternalKV,
 	expectedKey []byte,
 	expectedBh *common.BlockHandle,
+	expectedProps []byte,
 ) {
 	k, v := actual.K.UserKey, actual.V.Value()
 	bh := &common.BlockHandle{}
-	bh.DecodeFrom(v)
+	n := bh.DecodeFrom(v)
 
 	require.Equal(t, expectedKey, k, fmt.Sprintf("[%s]: key is mismatch", op))
 	require.Equal(t, expectedBh.Offset, bh.Offset, fmt.Sprintf("[%s]: offset is mismatch", op))
 	require.Equal(t, expectedBh.Length, bh.Length, fmt.Sprintf("[%s]: length is mismatch", op))
+	require.True(t, bytes.Equal(expectedProps, v[n:]), fmt.Sprintf("[%s]: props is mismatch", op))
 }
@@ -11,6 +11,7 @@ var indexColumnsOrder = []string{
 	"key",
 	"offset",
 	"length",
+	"props",
 }
 
 type IndexBlockWriter struct {
@@ -24,6 +25,9 @@ type IndexBlockWriter struct {
 		length uintcodex.UintEncoder[uint64]
 	}
 
+	// the encoded block properties of the indexed block, see block.BlockPropsCollector
+	propsEncoder rawbytescodex.RawByteEncoder
+
 	layoutEncoder layoutcodex.LayoutEncoder
 	rows          uint32
 }
@@ -34,6 +38,8 @@ func (i *IndexBlockWriter) Reset() {
 	i.blockHandleEncoder.offset.Reset()
 	i.blockHandleEncoder.length.Reset()
 
+	i.propsEncoder.Reset()
+
 	i.rows = 0
 }
 
@@ -43,6 +49,8 @@ func (i *IndexBlockWriter) Init() {
 	i.blockHandleEncoder.offset.Init()
 	i.blockHandleEncoder.length.Init()
 
+	i.propsEncoder.Init()
+
 	i.layoutEncoder.Reset()
 
 	i.rows = 0
@@ -54,7 +62,7 @@ func (i *IndexBlockWriter) Rows() uint32 {
 
 // Add, caller ensure that the key is full UserKey
 // that include MVCC suffix
-func (i *IndexBlockWriter) Add(key []byte, bh *common.BlockHandle) {
+func (i *IndexBlockWriter) Add(key []byte, bh *common.BlockHandle, props []byte) {
 	i.rows += 1
 
 	// for index key, we only interested in the UserKey
@@ -62,6 +70,8 @@ func (i *IndexBlockWriter) Add(key []byte, bh *common.BlockHandle) {
 
 	i.blockHandleEncoder.offset.Append(bh.Offset)
 	i.blockHandleEncoder.length.Append(bh.Length)
+
+	i.propsEncoder.Append(props)
 }
 
 func (i *IndexBlockWriter) Size() uint32 {
@@ -69,6 +79,7 @@ func (i *IndexBlockWriter) Size() uint32 {
 	offset += i.keyEncoder.Size(offset)
 	offset += i.blockHandleEncoder.offset.Size(offset)
 	offset += i.blockHandleEncoder.length.Size(offset)
+	offset += i.propsEncoder.Size(offset)
 	offset += 1
 
 	return offset
@@ -94,6 +105,8 @@ func (i *IndexBlockWriter) Finish(rows uint32, size int) []byte {
 			i.layoutEncoder.Encode(rows, &i.blockHandleEncoder.offset)
 		case "length":
 			i.layoutEncoder.Encode(rows, &i.blockHandleEncoder.length)
+		case "props":
+			i.layoutEncoder.Encode(rows, &i.propsEncoder)
 		default:
 			panic("IndexBlockWriter unhandled column name")
 		}
@@ -105,6 +118,7 @@ func (i *IndexBlockWriter) Finish(rows uint32, size int) []byte {
 func NewIndexBlockWriter() *IndexBlockWriter {
 	i := &IndexBlockWriter{
 		keyEncoder:    rawbytescodex.RawByteEncoder{},
+		propsEncoder:  rawbytescodex.RawByteEncoder{},
 		layoutEncoder: layoutcodex.LayoutEncoder{},
 	}
 
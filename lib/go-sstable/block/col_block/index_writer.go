@@ -5,9 +5,20 @@ import (
 	"github.com/datnguyenzzz/nogodb/lib/common/compression"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/block"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/filter"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/storage"
 )
 
+// bufferedIndex is a completed first level index block, along with its
+// filter partition if the filters are partitioned
+type bufferedIndex struct {
+	key        *nogodb_common.InternalKey
+	rows       int
+	props      []byte
+	compressed *common.PhysicalBlock
+	filter     []byte
+}
+
 type IndexWriter struct {
 	firstLevelIndex *IndexBlockWriter
 	uncompressed    []byte
@@ -23,13 +34,21 @@ type IndexWriter struct {
 	compressor   compression.ICompression
 	checksumer   nogodb_common.IChecksum
 
+	// block properties
+	propsCollector *block.BlockPropsCollector
+	// currProps is the merged properties of the current first level index block
+	currProps  []byte
+	tableProps []byte
+
+	// filterWriter builds the filter partition of the current first level
+	// index block, it's nil unless the filters are partitioned
+	filterWriter filter.IWriter
+
+	indexSize uint64
+
 	// indexBuffer holds the all compressed block of the completed first level index
 	// and they will be flushed to the storage at once when the SSTable is closed.
-	indexBuffer []struct {
-		key        *nogodb_common.InternalKey
-		rows       int
-		compressed *common.PhysicalBlock
-	}
+	indexBuffer []bufferedIndex
 
 	prevKey *nogodb_common.InternalKey
 }
@@ -40,6 +59,8 @@ func NewIndexWriter(
 	comparer nogodb_common.IComparer,
 	compressor compression.ICompression,
 	checksumer nogodb_common.IChecksum,
+	propsCollector *block.BlockPropsCollector,
+	filterWriter filter.IWriter,
 ) *IndexWriter {
 	return &IndexWriter{
 		firstLevelIndex:  NewIndexBlockWriter(),
@@ -51,29 +72,48 @@ func NewIndexWriter(
 		compressor:    compressor,
 		checksumer:    checksumer,
 
+		propsCollector: propsCollector,
+		filterWriter:   filterWriter,
+
 		prevKey: &nogodb_common.InternalKey{},
 	}
 }
 
-func (iw *IndexWriter) Add(key *nogodb_common.InternalKey, bh *common.BlockHandle) error {
+func (iw *IndexWriter) Add(key *nogodb_common.InternalKey, bh *common.BlockHandle, props []byte) error {
 	if len(iw.prevKey.UserKey) > 0 && iw.comparer.Compare(iw.prevKey.UserKey, key.UserKey) >= 0 {
 		panic("IndexWriter key must be in a strictly increasing order")
 	}
 
 	sizeBefore := iw.firstLevelIndex.Size()
 
-	iw.firstLevelIndex.Add(key.UserKey, bh)
+	iw.firstLevelIndex.Add(key.UserKey, bh, props)
 
 	if iw.flushDecider.ShouldFlush(int(sizeBefore), int(iw.firstLevelIndex.Size())) {
 		// flush the first index block to the buffered memory
 		// then re-adding the current KV because the first level index block
 		// will be reset after flushing
 		iw.flushToMemWithoutLastKey(int(sizeBefore))
-		iw.firstLevelIndex.Add(key.UserKey, bh)
+		iw.firstLevelIndex.Add(key.UserKey, bh, props)
 	}
 
+	var err error
+	iw.currProps, err = iw.propsCollector.Merge(iw.currProps, props)
 	iw.prevKey = key
-	return nil
+	return err
+}
+
+func (iw *IndexWriter) AddFilterKeys(keys [][]byte) {
+	for _, key := range keys {
+		iw.filterWriter.Add(key)
+	}
+}
+
+func (iw *IndexWriter) TableProps() []byte {
+	return iw.tableProps
+}
+
+func (iw *IndexWriter) IndexSize() uint64 {
+	return iw.indexSize
 }
 
 func (iw *IndexWriter) BuildIndex() (*common.BlockHandle, error) {
@@ -85,8 +125,12 @@ func (iw *IndexWriter) BuildIndex() (*common.BlockHandle, error) {
 		if err != nil {
 			return nil, err
 		}
+		iw.indexSize += idx.compressed.Size()
 
-		iw.secondLevelIndex.Add(idx.key.UserKey, &bh)
+		iw.secondLevelIndex.Add(idx.key.UserKey, &bh, idx.props)
+		if iw.tableProps, err = iw.propsCollector.Merge(iw.tableProps, idx.props); err != nil {
+			return nil, err
+		}
 	}
 
 	// build the 2nd level index and write to the storage
@@ -99,6 +143,34 @@ func (iw *IndexWriter) BuildIndex() (*common.BlockHandle, error) {
 	if err != nil {
 		return nil, err
 	}
+	iw.indexSize += pb.Size()
+
+	return &bh, nil
+}
+
+// BuildFilterIndex writes the filter partitions, then the top-level filter
+// index whose i'th key is the key of the i'th first level index block
+func (iw *IndexWriter) BuildFilterIndex() (*common.BlockHandle, error) {
+	filterIndex := NewIndexBlockWriter()
+	for _, idx := range iw.indexBuffer {
+		pb := block.CompressToPb(iw.compressor, iw.checksumer, idx.filter)
+		bh, err := iw.storageWriter.WritePhysicalBlock(*pb)
+		if err != nil {
+			return nil, err
+		}
+
+		filterIndex.Add(idx.key.UserKey, &bh, nil)
+	}
+
+	rows := filterIndex.Rows()
+	size := int(filterIndex.Size())
+	block.GrowSize(&iw.uncompressed, size)
+	iw.uncompressed = filterIndex.Finish(rows, size)
+	pb := block.CompressToPb(iw.compressor, iw.checksumer, iw.uncompressed)
+	bh, err := iw.storageWriter.WritePhysicalBlock(*pb)
+	if err != nil {
+		return nil, err
+	}
 
 	return &bh, nil
 }
@@ -107,23 +179,24 @@ func (iw *IndexWriter) BuildIndex() (*common.BlockHandle, error) {
 // without the last key, and reset the first level index block for the next entries
 func (iw *IndexWriter) flushToMemWithoutLastKey(size int) {
 	rows := int(iw.firstLevelIndex.Rows())
-	idx := struct {
-		key        *nogodb_common.InternalKey
-		rows       int
-		compressed *common.PhysicalBlock
-	}{
+	idx := bufferedIndex{
 		key:        iw.prevKey,
 		rows:       rows - 1,
+		props:      iw.currProps,
 		compressed: nil,
 	}
 
 	block.GrowSize(&iw.uncompressed, size)
 	iw.uncompressed = iw.firstLevelIndex.Finish(uint32(rows-1), size)
 	idx.compressed = block.CompressToPb(iw.compressor, iw.checksumer, iw.uncompressed)
+	if iw.filterWriter != nil {
+		iw.filterWriter.Build(&idx.filter)
+	}
 	iw.indexBuffer = append(iw.indexBuffer, idx)
 
 	// reset the first level index block for the next entries
 	iw.firstLevelIndex.Reset()
+	iw.currProps = nil
 	if cap(iw.uncompressed) > maxBlockRetainedSize {
 		iw.uncompressed = nil
 	}
@@ -132,23 +205,24 @@ func (iw *IndexWriter) flushToMemWithoutLastKey(size int) {
 func (iw *IndexWriter) flushAll() {
 	rows := int(iw.firstLevelIndex.Rows())
 	size := int(iw.firstLevelIndex.Size())
-	idx := struct {
-		key        *nogodb_common.InternalKey
-		rows       int
-		compressed *common.PhysicalBlock
-	}{
+	idx := bufferedIndex{
 		key:        iw.prevKey,
 		rows:       rows,
+		props:      iw.currProps,
 		compressed: nil,
 	}
 
 	block.GrowSize(&iw.uncompressed, size)
 	iw.uncompressed = iw.firstLevelIndex.Finish(uint32(rows), size)
 	idx.compressed = block.CompressToPb(iw.compressor, iw.checksumer, iw.uncompressed)
+	if iw.filterWriter != nil {
+		iw.filterWriter.Build(&idx.filter)
+	}
 	iw.indexBuffer = append(iw.indexBuffer, idx)
 
 	// reset the first level index block for the next entries
 	iw.firstLevelIndex.Reset()
+	iw.currProps = nil
 	if cap(iw.uncompressed) > maxBlockRetainedSize {
 		iw.uncompressed = nil
 	}
@@ -2,6 +2,8 @@ package col_block
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	"github.com/datnguyenzzz/nogodb/lib/common/compression"
@@ -32,6 +34,15 @@ type ColBlockWriter struct {
 
 	// filter
 	filterWriter filter.IWriter
+	// filterKeys are the filter keys of the current data block, they're
+	// added to the filter partition of its index block once it's flushed.
+	// Only used with the partitioned filters
+	filterKeys     [][]byte
+	filterPrefixes *block.FilterPrefixes
+
+	// block and table properties
+	propsCollector *block.BlockPropsCollector
+	props          common.TableProperties
 
 	// utilities
 	flushDecider common.IFlushDecider
@@ -65,15 +76,32 @@ func (c *ColBlockWriter) Add(key nogodb_common.InternalKey, value []byte) error
 		c.dataBlock.Add(key, value)
 	}
 
-	if c.filterWriter != nil {
-		// write the actual key, exclude the MVCC suffix
-		prefix := c.comparer.Split(key.UserKey)
-		c.filterWriter.Add(key.UserKey[:prefix])
+	if err := c.propsCollector.Add(key, value); err != nil {
+		return err
 	}
 
+	// write the actual key, exclude the MVCC suffix, along with its extracted
+	// prefix if any
+	userKey := key.UserKey[:c.comparer.Split(key.UserKey)]
+	c.addFilterKey(userKey)
+	if prefix := c.filterPrefixes.Next(userKey); prefix != nil {
+		c.addFilterKey(prefix)
+	}
+
+	c.props.AddEntry(key, len(value))
 	return nil
 }
 
+// addFilterKey adds the key to the filter of the table, or to the filter keys
+// of the current data block with the partitioned filters
+func (c *ColBlockWriter) addFilterKey(key []byte) {
+	if c.opt.PartitionedFilter {
+		c.filterKeys = append(c.filterKeys, slices.Clone(key))
+	} else if c.filterWriter != nil {
+		c.filterWriter.Add(key)
+	}
+}
+
 // Close finishes writing the table and closes the underlying file that the table was written to.
 func (c *ColBlockWriter) Close() error {
 	if c.dataBlock.Rows() > 0 {
@@ -100,8 +128,10 @@ func (c *ColBlockWriter) Close() error {
 				return err
 			}
 
-			encodedBH := make([]byte, common.MaxBlockHandleBytes)
+			// the filter block handle is followed by the filtering method
+			encodedBH := make([]byte, common.MaxBlockHandleBytes+1)
 			n := bh.EncodeInto(encodedBH)
+			encodedBH[n] = byte(c.opt.FilterMethod)
 			filterMetaKey := nogodb_common.MakeMetaIndexKey(nogodb_common.BlockKindFilter)
 			sz := filterMetaKey.Size()
 			if cap(sharedBuf) < sz {
@@ -109,7 +139,7 @@ func (c *ColBlockWriter) Close() error {
 			}
 
 			filterMetaKey.SerializeTo(sharedBuf)
-			c.metaIndexBlock.Add(sharedBuf, encodedBH[:n])
+			c.metaIndexBlock.Add(sharedBuf, encodedBH[:n+1])
 		}
 	}
 	// Build and Flush index block to the stable storage
@@ -129,6 +159,29 @@ func (c *ColBlockWriter) Close() error {
 		indexMetaKey.SerializeTo(sharedBuf)
 		c.metaIndexBlock.Add(sharedBuf, encodedBH[:n])
 	}
+	// Build and Flush the partitioned filters along with their index, which
+	// are aligned with the 1st level index blocks
+	if c.opt.PartitionedFilter {
+		bh, err := c.indexBlock.BuildFilterIndex()
+		if err != nil {
+			return err
+		}
+
+		encodedBH := make([]byte, common.MaxBlockHandleBytes+1)
+		n := bh.EncodeInto(encodedBH)
+		encodedBH[n] = byte(c.opt.FilterMethod)
+		filterIndexMetaKey := nogodb_common.MakeMetaIndexKey(nogodb_common.BlockKindFilterIndex)
+		sz := filterIndexMetaKey.Size()
+		if cap(sharedBuf) < sz {
+			block.GrowSize(&sharedBuf, sz)
+		}
+		filterIndexMetaKey.SerializeTo(sharedBuf)
+		c.metaIndexBlock.Add(sharedBuf, encodedBH[:n+1])
+	}
+	// Build and Flush properties block to the stable storage
+	if err := c.writePropertiesBlock(); err != nil {
+		return err
+	}
 	// Build and Flush meta index block to the stable storage
 	var metaBh common.BlockHandle
 	{
@@ -165,6 +218,41 @@ func (c *ColBlockWriter) Close() error {
 	return nil
 }
 
+// writePropertiesBlock writes the table properties, keyed by the property
+// names, see common.TableProperties
+func (c *ColBlockWriter) writePropertiesBlock() error {
+	var err error
+	c.props.IndexSize = c.indexBlock.IndexSize()
+	c.props.CreationTime = uint64(time.Now().Unix())
+	if c.props.UserProperties, err = c.propsCollector.UserProperties(c.indexBlock.TableProps()); err != nil {
+		return err
+	}
+
+	propsBlock := NewKVBlockWriter()
+	keys, values := c.props.Encode()
+	for i := range keys {
+		key := nogodb_common.InternalKey{UserKey: keys[i]}
+		buf := make([]byte, key.Size())
+		key.SerializeTo(buf)
+		propsBlock.Add(buf, values[i])
+	}
+
+	size := int(propsBlock.Size())
+	pb := block.CompressToPb(c.compressors, c.checksumer, propsBlock.Finish(propsBlock.Rows(), size))
+	bh, err := c.storageWriter.WritePhysicalBlock(*pb)
+	if err != nil {
+		return err
+	}
+
+	encodedBH := make([]byte, common.MaxBlockHandleBytes)
+	n := bh.EncodeInto(encodedBH)
+	propsMetaKey := nogodb_common.MakeMetaIndexKey(nogodb_common.BlockKindProperties)
+	buf := make([]byte, propsMetaKey.Size())
+	propsMetaKey.SerializeTo(buf)
+	c.metaIndexBlock.Add(buf, encodedBH[:n])
+	return nil
+}
+
 func (c *ColBlockWriter) validate(key nogodb_common.InternalKey) error {
 	if c.dataBlock.Rows() == 0 {
 		return nil
@@ -193,6 +281,10 @@ func (c *ColBlockWriter) doFlushWithoutLastKey(
 	block.GrowSize(&c.uncompressed, size)
 
 	c.uncompressed = c.dataBlock.Finish(currRows-1, size)
+	props, err := c.propsCollector.FinishDataBlock()
+	if err != nil {
+		return err
+	}
 
 	task := block.SpawnNewTask()
 	task.StorageWriter = c.storageWriter
@@ -201,8 +293,13 @@ func (c *ColBlockWriter) doFlushWithoutLastKey(
 		c.checksumer,
 		c.uncompressed,
 	)
+	c.props.AddDataBlock(task.Physical)
 	task.IndexKey = indexKey
+	task.IndexProps = props
 	task.IndexWriter = c.indexBlock
+	task.FilterKeys = c.filterKeys
+	c.filterKeys = nil
+	c.filterPrefixes.Reset()
 
 	c.taskQueue.Put(task)
 
@@ -225,6 +322,10 @@ func (c *ColBlockWriter) doFlushAll() error {
 	block.GrowSize(&c.uncompressed, size)
 
 	c.uncompressed = c.dataBlock.Finish(currRows, size)
+	props, err := c.propsCollector.FinishDataBlock()
+	if err != nil {
+		return err
+	}
 
 	task := block.SpawnNewTask()
 	task.StorageWriter = c.storageWriter
@@ -233,8 +334,13 @@ func (c *ColBlockWriter) doFlushAll() error {
 		c.checksumer,
 		c.uncompressed,
 	)
+	c.props.AddDataBlock(task.Physical)
 	task.IndexKey = indexKey
+	task.IndexProps = props
 	task.IndexWriter = c.indexBlock
+	task.FilterKeys = c.filterKeys
+	c.filterKeys = nil
+	c.filterPrefixes.Reset()
 
 	c.taskQueue.Put(task)
 
@@ -257,6 +363,30 @@ func NewColBlockWriter(
 	flushDecider := common.NewFlushDecider(opts.BlockSize, opts.BlockSizeThreshold)
 	compressor := compression.NewCompressor(opts.DefaultCompression)
 	checksumer := nogodb_common.NewChecksumer(nogodb_common.CRC32Checksum)
+	// the zone maps are always collected, ahead of the user collectors
+	propsCollector := block.NewBlockPropsCollector(append(
+		[]func() options.BlockPropertyCollector{
+			func() options.BlockPropertyCollector { return NewZoneMapCollector(comparer) },
+		},
+		opts.BlockPropertyCollectors...,
+	))
+
+	// the partitioned filters are built by the index writer
+	var filterWriter, partitionWriter filter.IWriter
+	if opts.PartitionedFilter {
+		partitionWriter = filter.NewFilterWriter(opts.FilterMethod, opts.FilterBitsPerKey)
+	} else {
+		filterWriter = filter.NewFilterWriter(opts.FilterMethod, opts.FilterBitsPerKey)
+	}
+
+	props := common.TableProperties{
+		ComparerName:     comparer.Name(),
+		CompressionName:  compressor.GetType().String(),
+		FilterPolicyName: opts.FilterMethod.String(),
+	}
+	if opts.PrefixExtractor != nil {
+		props.PrefixExtractorName = opts.PrefixExtractor.Name()
+	}
 
 	return &ColBlockWriter{
 		opt:          opts,
@@ -266,7 +396,11 @@ func NewColBlockWriter(
 
 		taskQueue: queue.NewQueue(flushQueueLen, false),
 
-		filterWriter: filter.NewFilterWriter(filter.BloomFilter),
+		filterWriter:   filterWriter,
+		filterPrefixes: block.NewFilterPrefixes(opts.PrefixExtractor),
+
+		propsCollector: propsCollector,
+		props:          props,
 
 		flushDecider: flushDecider,
 		comparer:     comparer,
@@ -280,6 +414,8 @@ func NewColBlockWriter(
 			comparer,
 			compressor,
 			checksumer,
+			propsCollector,
+			partitionWriter,
 		),
 
 		metaIndexBlock: NewKVBlockWriter(),
@@ -0,0 +1,309 @@
+package col_block
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+)
+
+// A zone map records the statistics of the columns of a data block: the
+// min/max user keys, the min/max values and the number of null values, aka
+// the tombstones and the empty values. The nulls are excluded from the
+// min/max values. It's collected as a block property, hence stored per data
+// block in the 1st level index, per index block in the 2nd level index and
+// per table in the properties block, see options.BlockPropertyCollector.
+//
+//	+-------------------+--------------------+-----------+
+//	| Rows (uvarint)    | Nulls (uvarint)    | Flags (1) |
+//	+-------------------+--------------------+-----------+
+//	| MinKey (varstring) | MaxKey (varstring) |
+//	+--------------------+--------------------+
+//	| MinValue (varstring) | MaxValue (varstring) |  if flagHasValues
+//	+----------------------+----------------------+
+//
+// The values are truncated to maxZoneMapValueLen, a truncated max value is
+// flagged by flagMaxValueTruncated.
+
+// ZoneMapPropertyName is the name of the zone map collector/filter
+const ZoneMapPropertyName = "nogodb.zone-map"
+
+const (
+	maxZoneMapValueLen = 64
+
+	flagHasValues         = 1 << 0
+	flagMaxValueTruncated = 1 << 1
+)
+
+type ZoneMap struct {
+	Rows  uint64
+	Nulls uint64
+
+	MinKey, MaxKey []byte
+	// MinValue and MaxValue are only set if HasValues
+	HasValues         bool
+	MinValue          []byte
+	MaxValue          []byte
+	MaxValueTruncated bool
+}
+
+func (z *ZoneMap) add(key nogodb_common.InternalKey, value []byte, cmp nogodb_common.IComparer) {
+	if z.Rows == 0 || cmp.Compare(key.UserKey, z.MinKey) < 0 {
+		z.MinKey = append(z.MinKey[:0], key.UserKey...)
+	}
+	if z.Rows == 0 || cmp.Compare(key.UserKey, z.MaxKey) > 0 {
+		z.MaxKey = append(z.MaxKey[:0], key.UserKey...)
+	}
+	z.Rows++
+
+	if len(value) == 0 || key.KeyKind() == nogodb_common.KeyKindDelete {
+		z.Nulls++
+		return
+	}
+
+	minValue := value[:min(len(value), maxZoneMapValueLen)]
+	if !z.HasValues || bytes.Compare(minValue, z.MinValue) < 0 {
+		z.MinValue = append(z.MinValue[:0], minValue...)
+	}
+	maxValue, truncated := minValue, len(value) > maxZoneMapValueLen
+	if !z.HasValues || maxValueLess(z.MaxValue, z.MaxValueTruncated, maxValue, truncated) {
+		z.MaxValue = append(z.MaxValue[:0], maxValue...)
+		z.MaxValueTruncated = truncated
+	}
+	z.HasValues = true
+}
+
+// maxValueLess returns true if the max value a is below the max value b. A
+// truncated max value is the greatest value having it as a prefix.
+func maxValueLess(a []byte, aTruncated bool, b []byte, bTruncated bool) bool {
+	switch {
+	case aTruncated && bTruncated:
+		return bytes.Compare(a, b) < 0
+	case aTruncated:
+		return bytes.Compare(a, b[:min(len(b), len(a))]) < 0
+	case bTruncated:
+		return bytes.Compare(a[:min(len(a), len(b))], b) <= 0
+	default:
+		return bytes.Compare(a, b) < 0
+	}
+}
+
+func (z *ZoneMap) merge(o *ZoneMap, cmp nogodb_common.IComparer) {
+	if o.Rows == 0 {
+		return
+	}
+
+	if z.Rows == 0 || cmp.Compare(o.MinKey, z.MinKey) < 0 {
+		z.MinKey = o.MinKey
+	}
+	if z.Rows == 0 || cmp.Compare(o.MaxKey, z.MaxKey) > 0 {
+		z.MaxKey = o.MaxKey
+	}
+	z.Rows += o.Rows
+	z.Nulls += o.Nulls
+
+	if !o.HasValues {
+		return
+	}
+
+	if !z.HasValues || bytes.Compare(o.MinValue, z.MinValue) < 0 {
+		z.MinValue = o.MinValue
+	}
+	if !z.HasValues || maxValueLess(z.MaxValue, z.MaxValueTruncated, o.MaxValue, o.MaxValueTruncated) {
+		z.MaxValue, z.MaxValueTruncated = o.MaxValue, o.MaxValueTruncated
+	}
+	z.HasValues = true
+}
+
+func (z *ZoneMap) encode(buf []byte) []byte {
+	if z.Rows == 0 {
+		return buf
+	}
+
+	buf = binary.AppendUvarint(buf, z.Rows)
+	buf = binary.AppendUvarint(buf, z.Nulls)
+
+	var flags byte
+	if z.HasValues {
+		flags |= flagHasValues
+	}
+	if z.MaxValueTruncated {
+		flags |= flagMaxValueTruncated
+	}
+	buf = append(buf, flags)
+
+	buf = appendVarstring(buf, z.MinKey)
+	buf = appendVarstring(buf, z.MaxKey)
+	if z.HasValues {
+		buf = appendVarstring(buf, z.MinValue)
+		buf = appendVarstring(buf, z.MaxValue)
+	}
+
+	return buf
+}
+
+// DecodeZoneMap decodes an encoded zone map, an empty one is the zone map of
+// an empty block
+func DecodeZoneMap(encoded []byte) (*ZoneMap, error) {
+	z := &ZoneMap{}
+	if len(encoded) == 0 {
+		return z, nil
+	}
+
+	var n int
+	if z.Rows, n = binary.Uvarint(encoded); n <= 0 {
+		return nil, options.ErrInvalidBlockProperty
+	}
+	encoded = encoded[n:]
+	if z.Nulls, n = binary.Uvarint(encoded); n <= 0 || len(encoded) == n {
+		return nil, options.ErrInvalidBlockProperty
+	}
+	flags := encoded[n]
+	encoded = encoded[n+1:]
+
+	var err error
+	if z.MinKey, encoded, err = readVarstring(encoded); err != nil {
+		return nil, err
+	}
+	if z.MaxKey, encoded, err = readVarstring(encoded); err != nil {
+		return nil, err
+	}
+
+	z.HasValues = flags&flagHasValues != 0
+	z.MaxValueTruncated = flags&flagMaxValueTruncated != 0
+	if z.HasValues {
+		if z.MinValue, encoded, err = readVarstring(encoded); err != nil {
+			return nil, err
+		}
+		if z.MaxValue, encoded, err = readVarstring(encoded); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(encoded) > 0 {
+		return nil, options.ErrInvalidBlockProperty
+	}
+
+	return z, nil
+}
+
+func appendVarstring(buf, s []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readVarstring(buf []byte) (s, rest []byte, err error) {
+	l, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < l {
+		return nil, nil, options.ErrInvalidBlockProperty
+	}
+
+	return buf[n : n+int(l)], buf[n+int(l):], nil
+}
+
+// Collector \\
+
+// zoneMapCollector collects the zone map of each data block
+type zoneMapCollector struct {
+	cmp  nogodb_common.IComparer
+	curr ZoneMap
+}
+
+// NewZoneMapCollector returns the collector of the zone maps, it's registered
+// by default in the columnar tables
+func NewZoneMapCollector(cmp nogodb_common.IComparer) options.BlockPropertyCollector {
+	return &zoneMapCollector{cmp: cmp}
+}
+
+func (c *zoneMapCollector) Name() string {
+	return ZoneMapPropertyName
+}
+
+func (c *zoneMapCollector) Add(key nogodb_common.InternalKey, value []byte) error {
+	c.curr.add(key, value, c.cmp)
+	return nil
+}
+
+func (c *zoneMapCollector) FinishDataBlock(buf []byte) ([]byte, error) {
+	buf = c.curr.encode(buf)
+	c.curr = ZoneMap{
+		MinKey:   c.curr.MinKey[:0],
+		MaxKey:   c.curr.MaxKey[:0],
+		MinValue: c.curr.MinValue[:0],
+		MaxValue: c.curr.MaxValue[:0],
+	}
+	return buf, nil
+}
+
+func (c *zoneMapCollector) Merge(buf, a, b []byte) ([]byte, error) {
+	za, err := DecodeZoneMap(a)
+	if err != nil {
+		return nil, err
+	}
+	zb, err := DecodeZoneMap(b)
+	if err != nil {
+		return nil, err
+	}
+
+	za.merge(zb, c.cmp)
+	return za.encode(buf), nil
+}
+
+var _ options.BlockPropertyCollector = (*zoneMapCollector)(nil)
+
+// Filter \\
+
+// zoneMapFilter skips the blocks whose zone map can't match the predicate
+type zoneMapFilter struct {
+	cmp  nogodb_common.IComparer
+	pred options.ZoneMapPredicate
+}
+
+// NewZoneMapFilter returns the filter of the zone maps by the given predicate
+func NewZoneMapFilter(cmp nogodb_common.IComparer, pred options.ZoneMapPredicate) options.BlockPropertyFilter {
+	return &zoneMapFilter{cmp: cmp, pred: pred}
+}
+
+func (f *zoneMapFilter) Name() string {
+	return ZoneMapPropertyName
+}
+
+func (f *zoneMapFilter) Intersects(prop []byte) (bool, error) {
+	z, err := DecodeZoneMap(prop)
+	if err != nil {
+		return false, err
+	}
+
+	if z.Rows == 0 {
+		return false, nil
+	}
+
+	// keys within [KeyLower, KeyUpper)
+	if f.pred.KeyLower != nil && f.cmp.Compare(z.MaxKey, f.pred.KeyLower) < 0 {
+		return false, nil
+	}
+	if f.pred.KeyUpper != nil && f.cmp.Compare(z.MinKey, f.pred.KeyUpper) >= 0 {
+		return false, nil
+	}
+
+	hasValueRange := f.pred.ValueLower != nil || f.pred.ValueUpper != nil
+	if !z.HasValues {
+		// all the values are null, it might hide the tombstones shadowing
+		// older versions, which is up to the caller, see
+		// options.ZoneMapPredicate
+		return !hasValueRange && !f.pred.SkipNulls, nil
+	}
+
+	// values within [ValueLower, ValueUpper)
+	if f.pred.ValueLower != nil && maxValueLess(z.MaxValue, z.MaxValueTruncated, f.pred.ValueLower, false) {
+		return false, nil
+	}
+	if f.pred.ValueUpper != nil && bytes.Compare(z.MinValue, f.pred.ValueUpper) >= 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+var _ options.BlockPropertyFilter = (*zoneMapFilter)(nil)
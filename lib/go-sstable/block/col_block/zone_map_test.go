@@ -0,0 +1,80 @@
+package col_block_test
+
+import (
+	"bytes"
+	"testing"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	colblock "github.com/datnguyenzzz/nogodb/lib/go-sstable/block/col_block"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_zone_map_pruning(t *testing.T) {
+	cmp := nogodb_common.NewComparer()
+	collector := colblock.NewZoneMapCollector(cmp)
+
+	finish := func(kvs ...[2]string) []byte {
+		for _, kv := range kvs {
+			kind := nogodb_common.KeyKindSet
+			if kv[1] == "" {
+				kind = nogodb_common.KeyKindDelete
+			}
+			key := nogodb_common.MakeKey([]byte(kv[0]), 0, kind)
+			require.NoError(t, collector.Add(key, []byte(kv[1])))
+		}
+		prop, err := collector.FinishDataBlock(nil)
+		require.NoError(t, err)
+		return prop
+	}
+
+	longValue := "m" + string(bytes.Repeat([]byte{'z'}, 100))
+	first := finish([2]string{"a", "d"}, [2]string{"b", ""}, [2]string{"c", "b"})
+	second := finish([2]string{"d", ""}, [2]string{"e", ""})
+	third := finish([2]string{"f", longValue}, [2]string{"g", "k"})
+	merged, err := collector.Merge(nil, first, second)
+	require.NoError(t, err)
+	merged, err = collector.Merge(nil, merged, third)
+	require.NoError(t, err)
+
+	z, err := colblock.DecodeZoneMap(merged)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), z.Rows)
+	assert.Equal(t, uint64(3), z.Nulls)
+	assert.Equal(t, []byte("a"), z.MinKey)
+	assert.Equal(t, []byte("g"), z.MaxKey)
+	assert.Equal(t, []byte("b"), z.MinValue)
+	assert.True(t, z.MaxValueTruncated)
+
+	type param struct {
+		desc     string
+		prop     []byte
+		pred     options.ZoneMapPredicate
+		expected bool
+	}
+
+	tests := []param{
+		{desc: "keys within the block", prop: first, pred: options.ZoneMapPredicate{KeyLower: []byte("b"), KeyUpper: []byte("z")}, expected: true},
+		{desc: "keys before the block", prop: third, pred: options.ZoneMapPredicate{KeyUpper: []byte("f")}, expected: false},
+		{desc: "keys after the block", prop: first, pred: options.ZoneMapPredicate{KeyLower: []byte("ca")}, expected: false},
+		{desc: "values within the block", prop: first, pred: options.ZoneMapPredicate{ValueLower: []byte("c"), ValueUpper: []byte("e")}, expected: true},
+		{desc: "values after the block", prop: first, pred: options.ZoneMapPredicate{ValueLower: []byte("e")}, expected: false},
+		{desc: "values before the block", prop: first, pred: options.ZoneMapPredicate{ValueUpper: []byte("b")}, expected: false},
+		{desc: "values of an all null block", prop: second, pred: options.ZoneMapPredicate{ValueLower: []byte("a")}, expected: false},
+		{desc: "all null block, nulls kept", prop: second, pred: options.ZoneMapPredicate{}, expected: true},
+		{desc: "all null block, nulls skipped", prop: second, pred: options.ZoneMapPredicate{SkipNulls: true}, expected: false},
+		{desc: "values within a truncated max value", prop: third, pred: options.ZoneMapPredicate{ValueLower: []byte(longValue + "a")}, expected: true},
+		{desc: "values after a truncated max value", prop: third, pred: options.ZoneMapPredicate{ValueLower: []byte("n")}, expected: false},
+		{desc: "values within the merged blocks", prop: merged, pred: options.ZoneMapPredicate{ValueLower: []byte("e"), ValueUpper: []byte("f")}, expected: true},
+		{desc: "values after the merged blocks", prop: merged, pred: options.ZoneMapPredicate{ValueLower: []byte("n")}, expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			matched, err := colblock.NewZoneMapFilter(cmp, tc.pred).Intersects(tc.prop)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, matched)
+		})
+	}
+}
@@ -13,7 +13,11 @@ type Task struct {
 	Physical      *common.PhysicalBlock
 	StorageWriter storage.ILayoutWriter
 	IndexKey      *nogodb_common.InternalKey
+	IndexProps    []byte
 	IndexWriter   IIndexWriter
+	// FilterKeys are the filter keys of the data block, only set with the
+	// partitioned filters
+	FilterKeys [][]byte
 }
 
 var taskPool = sync.Pool{
@@ -29,13 +33,22 @@ func (t *Task) Execute() error {
 		return err
 	}
 	// 2. write new index block (includes compute index KV, flush, ....)
-	err = t.IndexWriter.Add(t.IndexKey, &bh)
-	return err
+	err = t.IndexWriter.Add(t.IndexKey, &bh, t.IndexProps)
+	if err != nil {
+		return err
+	}
+	// 3. add the filter keys to the filter partition of the index block
+	if len(t.FilterKeys) > 0 {
+		t.IndexWriter.AddFilterKeys(t.FilterKeys)
+	}
+	return nil
 }
 
 func (t *Task) Release() {
 	t.Physical = &common.PhysicalBlock{}
 	t.StorageWriter = nil
+	t.IndexProps = nil
+	t.FilterKeys = nil
 	taskPool.Put(t)
 }
 
@@ -0,0 +1,45 @@
+package block
+
+import (
+	"bytes"
+
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+)
+
+// FilterPrefixes derives the extracted prefixes added to the filters along
+// with the whole keys, see options.BlockWriteOpt.PrefixExtractor. The keys
+// are added in order, hence the keys sharing a prefix are consecutive and
+// the prefix is only added once per data block.
+type FilterPrefixes struct {
+	extractor options.PrefixExtractor
+	// last is the last added prefix, valid if hasLast
+	last    []byte
+	hasLast bool
+}
+
+// Next returns the extracted prefix of the key to add to the filter, nil if
+// the key is out of the domain of the extractor, if the prefix is the whole
+// key or if it was already added
+func (f *FilterPrefixes) Next(key []byte) []byte {
+	if f.extractor == nil || !f.extractor.InDomain(key) {
+		return nil
+	}
+
+	prefix := f.extractor.Extract(key)
+	if len(prefix) == len(key) || (f.hasLast && bytes.Equal(prefix, f.last)) {
+		return nil
+	}
+
+	f.last, f.hasLast = append(f.last[:0], prefix...), true
+	return prefix
+}
+
+// Reset forgets the last added prefix, it's called once a data block is
+// flushed, so that the filter partition of each data block holds its prefixes
+func (f *FilterPrefixes) Reset() {
+	f.hasLast = false
+}
+
+func NewFilterPrefixes(extractor options.PrefixExtractor) *FilterPrefixes {
+	return &FilterPrefixes{extractor: extractor}
+}
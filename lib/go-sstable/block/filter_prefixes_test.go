@@ -0,0 +1,38 @@
+package block
+
+import (
+	"testing"
+
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterPrefixes(t *testing.T) {
+	fp := NewFilterPrefixes(options.NewDelimitedPrefixExtractor('/', 2))
+
+	assert.Equal(t, []byte("t1/p1/"), fp.Next([]byte("t1/p1/r1")))
+	assert.Nil(t, fp.Next([]byte("t1/p1/r2")), "the prefix is added once")
+	assert.Nil(t, fp.Next([]byte("t1/r1")), "the key is out of domain")
+	assert.Nil(t, fp.Next([]byte("t1/p2/")), "the prefix is the whole key")
+	assert.Equal(t, []byte("t1/p3/"), fp.Next([]byte("t1/p3/r1")))
+
+	fp.Reset()
+	assert.Equal(t, []byte("t1/p3/"), fp.Next([]byte("t1/p3/r2")), "the prefix is added again after a reset")
+
+	assert.Nil(t, NewFilterPrefixes(nil).Next([]byte("t1/p1/r1")), "no extractor")
+}
+
+func TestPrefixExtractors(t *testing.T) {
+	fixed := options.NewFixedPrefixExtractor(3)
+	assert.True(t, fixed.InDomain([]byte("abcd")))
+	assert.False(t, fixed.InDomain([]byte("ab")))
+	assert.Equal(t, []byte("abc"), fixed.Extract([]byte("abcd")))
+
+	delimited := options.NewDelimitedPrefixExtractor('/', 2)
+	assert.True(t, delimited.InDomain([]byte("t1/p1/")))
+	assert.False(t, delimited.InDomain([]byte("t1/p1")))
+	assert.Equal(t, []byte("t1/p1/"), delimited.Extract([]byte("t1/p1/r1/c1")))
+
+	assert.NotEqual(t, fixed.Name(), options.NewFixedPrefixExtractor(4).Name())
+	assert.NotEqual(t, delimited.Name(), options.NewDelimitedPrefixExtractor(':', 2).Name())
+}
@@ -6,8 +6,24 @@ import (
 )
 
 type IIndexWriter interface {
-	Add(key *nogodb_common.InternalKey, bh *common.BlockHandle) error
+	// Add adds the index entry of a data block, along with its encoded
+	// properties, see BlockPropsCollector
+	Add(key *nogodb_common.InternalKey, bh *common.BlockHandle, props []byte) error
 	// BuildIndex build the 2-level index for the SST, and write to the stable storage
 	BuildIndex() (*common.BlockHandle, error)
+	// TableProps returns the block properties merged over the whole table,
+	// available once the index is built
+	TableProps() []byte
+	// IndexSize returns the physical size of the index blocks (1st + 2nd
+	// levels), available once the index is built
+	IndexSize() uint64
+	// AddFilterKeys adds the filter keys of the data block added last, to the
+	// filter partition of its 1st level index block. Only used with the
+	// partitioned filters, see options.BlockWriteOpt.PartitionedFilter
+	AddFilterKeys(keys [][]byte)
+	// BuildFilterIndex writes the filter partitions and their top-level index
+	// to the stable storage, it must be called after BuildIndex. Only used
+	// with the partitioned filters
+	BuildFilterIndex() (*common.BlockHandle, error)
 	// ...
 }
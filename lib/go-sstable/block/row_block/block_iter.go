@@ -110,6 +110,7 @@ func (i *BlockIterator) SeekLTE(key []byte) *nogodb_common.InternalKV {
 }
 
 func (i *BlockIterator) First() *nogodb_common.InternalKV {
+	i.offset = 0
 	i.readEntry()
 	return i.toKV()
 }
@@ -337,6 +337,12 @@ func TestDataBlockIterator_First(t *testing.T) {
 			actualIterKey := nogodb_common.DeserializeKey(iter.key)
 			assert.Equal(t, tc.expectedFirstKey, string(actualIterKey.UserKey), "iterator's internal key should match first key")
 			assert.Equal(t, tc.expectedFirstValue, string(iter.value), "iterator's internal value should match first value")
+
+			// First() rewinds an already positioned iterator
+			iter.Last()
+			firstKV = iter.First()
+			assert.Equal(t, tc.expectedFirstKey, string(firstKV.K.UserKey), "First() after Last() should return the first key")
+			assert.Equal(t, tc.expectedFirstValue, string(firstKV.V.Value()), "First() after Last() should return the first value")
 		})
 	}
 }
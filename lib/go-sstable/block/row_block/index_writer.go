@@ -8,6 +8,7 @@ import (
 	"github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool/predictable_size"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/block"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/filter"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/storage"
 )
@@ -19,7 +20,10 @@ const (
 type firstLevelIndex struct {
 	key           *nogodb_common.InternalKey
 	entries       int
+	props         []byte
 	finishedBlock []byte
+	// filter is the built filter partition of the block, if partitioned
+	filter []byte
 }
 
 // indexWriter The `i'th` value is the encoded block handle of the `i'th` data block.
@@ -41,19 +45,53 @@ type indexWriter struct {
 	metaIndexBlock    *rowBlockBuf
 	bytesBufferPool   *predictable_size.PredictablePool
 	opts              *options.BlockWriteOpt
+
+	// block properties
+	propsCollector *block.BlockPropsCollector
+	// currProps is the merged properties of the current first level index block
+	currProps  []byte
+	tableProps []byte
+
+	// filterWriter builds the filter partition of the current first level
+	// index block, it's nil unless the filters are partitioned
+	filterWriter     filter.IWriter
+	filterCompressor compression.ICompression
+
+	indexSize uint64
 }
 
-func (w *indexWriter) Add(key *nogodb_common.InternalKey, bh *common.BlockHandle) error {
+func (w *indexWriter) Add(key *nogodb_common.InternalKey, bh *common.BlockHandle, props []byte) error {
 	if bh.Length == 0 {
 		return nil
 	}
 	if err := w.mightFlushToMem(key); err != nil {
 		return err
 	}
-	encoded := make([]byte, common.MaxBlockHandleBytes)
+	// the value is the encoded block handle, followed by the block properties
+	encoded := make([]byte, common.MaxBlockHandleBytes, common.MaxBlockHandleBytes+len(props))
 	n := bh.EncodeInto(encoded)
-	encoded = encoded[:n]
-	return w.firstLevelBlock.WriteEntry(*key, encoded)
+	encoded = append(encoded[:n], props...)
+	if err := w.firstLevelBlock.WriteEntry(*key, encoded); err != nil {
+		return err
+	}
+
+	var err error
+	w.currProps, err = w.propsCollector.Merge(w.currProps, props)
+	return err
+}
+
+func (w *indexWriter) AddFilterKeys(keys [][]byte) {
+	for _, key := range keys {
+		w.filterWriter.Add(key)
+	}
+}
+
+func (w *indexWriter) TableProps() []byte {
+	return w.tableProps
+}
+
+func (w *indexWriter) IndexSize() uint64 {
+	return w.indexSize
 }
 
 func (w *indexWriter) mightFlushToMem(key *nogodb_common.InternalKey) error {
@@ -85,18 +123,23 @@ func (w *indexWriter) flushFirstLevelIndexToMem(key *nogodb_common.InternalKey)
 	idx := &firstLevelIndex{
 		key:     prevKey,
 		entries: w.firstLevelBlock.EntryCount(),
+		props:   w.currProps,
 	}
 	uncompressed := w.bytesBufferPool.Get(w.firstLevelBlock.EstimateSize())
 	uncompressed = uncompressed[:w.firstLevelBlock.EstimateSize()]
 	w.firstLevelBlock.Finish(uncompressed)
 	idx.finishedBlock = make([]byte, len(uncompressed))
 	copy(idx.finishedBlock, uncompressed)
+	if w.filterWriter != nil {
+		w.filterWriter.Build(&idx.filter)
+	}
 	w.firstLevelIndices = append(w.firstLevelIndices, idx)
 	w.bytesBufferPool.Put(uncompressed)
 	// reset the current first level index block for the next subsequent writes
 	w.firstLevelBlock.CleanUpForReuse()
 	w.firstLevelBlock.Release()
 	w.firstLevelBlock = newBlock(1, w.bytesBufferPool, w.opts.BlockSize)
+	w.currProps = nil
 }
 
 // buildIndex build the 2-level index for the SST
@@ -110,11 +153,15 @@ func (w *indexWriter) BuildIndex() (*common.BlockHandle, error) {
 		if err != nil {
 			return nil, err
 		}
+		w.indexSize += pb.Size()
 		// 2. Write the encoded value of the 1-level index block handle
 		// into buffer
-		encodedBH := make([]byte, common.MaxBlockHandleBytes)
+		encodedBH := make([]byte, common.MaxBlockHandleBytes, common.MaxBlockHandleBytes+len(idx.props))
 		n := bh.EncodeInto(encodedBH)
-		if err := w.secondLevelBlock.WriteEntry(*idx.key, encodedBH[:n]); err != nil {
+		if err := w.secondLevelBlock.WriteEntry(*idx.key, append(encodedBH[:n], idx.props...)); err != nil {
+			return nil, err
+		}
+		if w.tableProps, err = w.propsCollector.Merge(w.tableProps, idx.props); err != nil {
 			return nil, err
 		}
 	}
@@ -126,6 +173,7 @@ func (w *indexWriter) BuildIndex() (*common.BlockHandle, error) {
 	pb := block.CompressToPb(w.compressor, w.checksumer, uncompressed)
 	bh, err := w.storageWriter.WritePhysicalBlock(*pb)
 	if err == nil {
+		w.indexSize += pb.Size()
 		return &bh, nil
 	}
 
@@ -133,6 +181,41 @@ func (w *indexWriter) BuildIndex() (*common.BlockHandle, error) {
 	return nil, err
 }
 
+// BuildFilterIndex writes the filter partitions, then the top-level filter
+// index whose i'th key is the key of the i'th first level index block
+func (w *indexWriter) BuildFilterIndex() (*common.BlockHandle, error) {
+	filterIndexBlock := newBlock(1, w.bytesBufferPool, w.opts.BlockSize)
+	defer func() {
+		filterIndexBlock.CleanUpForReuse()
+		filterIndexBlock.Release()
+	}()
+	for _, idx := range w.firstLevelIndices {
+		pb := block.CompressToPb(w.filterCompressor, w.checksumer, idx.filter)
+		bh, err := w.storageWriter.WritePhysicalBlock(*pb)
+		if err != nil {
+			return nil, err
+		}
+
+		encodedBH := make([]byte, common.MaxBlockHandleBytes)
+		n := bh.EncodeInto(encodedBH)
+		if err := filterIndexBlock.WriteEntry(*idx.key, encodedBH[:n]); err != nil {
+			return nil, err
+		}
+	}
+
+	uncompressed := w.bytesBufferPool.Get(filterIndexBlock.EstimateSize())
+	uncompressed = uncompressed[:filterIndexBlock.EstimateSize()]
+	filterIndexBlock.Finish(uncompressed)
+	pb := block.CompressToPb(w.compressor, w.checksumer, uncompressed)
+	bh, err := w.storageWriter.WritePhysicalBlock(*pb)
+	w.bytesBufferPool.Put(uncompressed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bh, nil
+}
+
 func (w *indexWriter) Release() {
 	w.firstLevelBlock.CleanUpForReuse()
 	w.firstLevelBlock.Release()
@@ -142,13 +225,20 @@ func (w *indexWriter) Release() {
 func newIndexWriter(
 	comparer nogodb_common.IComparer,
 	compressor compression.ICompression,
+	filterCompressor compression.ICompression,
 	checksumer nogodb_common.IChecksum,
 	flushDecider common.IFlushDecider,
 	storageWriter storage.ILayoutWriter,
 	metaIndexBlock *rowBlockBuf,
 	bufferPool *predictable_size.PredictablePool,
 	opts options.BlockWriteOpt,
+	propsCollector *block.BlockPropsCollector,
 ) *indexWriter {
+	var filterWriter filter.IWriter
+	if opts.PartitionedFilter {
+		filterWriter = filter.NewFilterWriter(opts.FilterMethod, opts.FilterBitsPerKey)
+	}
+
 	return &indexWriter{
 		// The index block also use the row oriented layout.
 		// And its restart interval is 1, aka every entry is a restart point.
@@ -156,6 +246,8 @@ func newIndexWriter(
 		secondLevelBlock:  newBlock(1, bufferPool, opts.BlockSize),
 		comparer:          comparer,
 		compressor:        compressor,
+		filterCompressor:  filterCompressor,
+		filterWriter:      filterWriter,
 		checksumer:        checksumer,
 		flushDecider:      flushDecider,
 		storageWriter:     storageWriter,
@@ -163,6 +255,7 @@ func newIndexWriter(
 		metaIndexBlock:    metaIndexBlock,
 		bytesBufferPool:   bufferPool,
 		opts:              &opts,
+		propsCollector:    propsCollector,
 	}
 }
 
@@ -71,8 +71,8 @@ func (_c *MockIBlockCacheWrapper_Close_Call) RunAndReturn(run func()) *MockIBloc
 }
 
 // Get provides a mock function for the type MockIBlockCacheWrapper
-func (_mock *MockIBlockCacheWrapper) Get(bh *common.BlockHandle) (*common0.InternalLazyValue, error) {
-	ret := _mock.Called(bh)
+func (_mock *MockIBlockCacheWrapper) Get(bh *common.BlockHandle, kind common0.BlockKind) (*common0.InternalLazyValue, error) {
+	ret := _mock.Called(bh, kind)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Get")
@@ -80,18 +80,18 @@ func (_mock *MockIBlockCacheWrapper) Get(bh *common.BlockHandle) (*common0.Inter
 
 	var r0 *common0.InternalLazyValue
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(*common.BlockHandle) (*common0.InternalLazyValue, error)); ok {
-		return returnFunc(bh)
+	if returnFunc, ok := ret.Get(0).(func(*common.BlockHandle, common0.BlockKind) (*common0.InternalLazyValue, error)); ok {
+		return returnFunc(bh, kind)
 	}
-	if returnFunc, ok := ret.Get(0).(func(*common.BlockHandle) *common0.InternalLazyValue); ok {
-		r0 = returnFunc(bh)
+	if returnFunc, ok := ret.Get(0).(func(*common.BlockHandle, common0.BlockKind) *common0.InternalLazyValue); ok {
+		r0 = returnFunc(bh, kind)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*common0.InternalLazyValue)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(*common.BlockHandle) error); ok {
-		r1 = returnFunc(bh)
+	if returnFunc, ok := ret.Get(1).(func(*common.BlockHandle, common0.BlockKind) error); ok {
+		r1 = returnFunc(bh, kind)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -105,18 +105,24 @@ type MockIBlockCacheWrapper_Get_Call struct {
 
 // Get is a helper method to define mock.On call
 //   - bh *common.BlockHandle
-func (_e *MockIBlockCacheWrapper_Expecter) Get(bh interface{}) *MockIBlockCacheWrapper_Get_Call {
-	return &MockIBlockCacheWrapper_Get_Call{Call: _e.mock.On("Get", bh)}
+//   - kind common0.BlockKind
+func (_e *MockIBlockCacheWrapper_Expecter) Get(bh interface{}, kind interface{}) *MockIBlockCacheWrapper_Get_Call {
+	return &MockIBlockCacheWrapper_Get_Call{Call: _e.mock.On("Get", bh, kind)}
 }
 
-func (_c *MockIBlockCacheWrapper_Get_Call) Run(run func(bh *common.BlockHandle)) *MockIBlockCacheWrapper_Get_Call {
+func (_c *MockIBlockCacheWrapper_Get_Call) Run(run func(bh *common.BlockHandle, kind common0.BlockKind)) *MockIBlockCacheWrapper_Get_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 *common.BlockHandle
 		if args[0] != nil {
 			arg0 = args[0].(*common.BlockHandle)
 		}
+		var arg1 common0.BlockKind
+		if args[1] != nil {
+			arg1 = args[1].(common0.BlockKind)
+		}
 		run(
 			arg0,
+			arg1,
 		)
 	})
 	return _c
@@ -127,22 +133,22 @@ func (_c *MockIBlockCacheWrapper_Get_Call) Return(internalLazyValue *common0.Int
 	return _c
 }
 
-func (_c *MockIBlockCacheWrapper_Get_Call) RunAndReturn(run func(bh *common.BlockHandle) (*common0.InternalLazyValue, error)) *MockIBlockCacheWrapper_Get_Call {
+func (_c *MockIBlockCacheWrapper_Get_Call) RunAndReturn(run func(bh *common.BlockHandle, kind common0.BlockKind) (*common0.InternalLazyValue, error)) *MockIBlockCacheWrapper_Get_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
 // Set provides a mock function for the type MockIBlockCacheWrapper
-func (_mock *MockIBlockCacheWrapper) Set(bh *common.BlockHandle, val *common0.InternalLazyValue) error {
-	ret := _mock.Called(bh, val)
+func (_mock *MockIBlockCacheWrapper) Set(bh *common.BlockHandle, kind common0.BlockKind, val *common0.InternalLazyValue) error {
+	ret := _mock.Called(bh, kind, val)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Set")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(*common.BlockHandle, *common0.InternalLazyValue) error); ok {
-		r0 = returnFunc(bh, val)
+	if returnFunc, ok := ret.Get(0).(func(*common.BlockHandle, common0.BlockKind, *common0.InternalLazyValue) error); ok {
+		r0 = returnFunc(bh, kind, val)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -156,24 +162,30 @@ type MockIBlockCacheWrapper_Set_Call struct {
 
 // Set is a helper method to define mock.On call
 //   - bh *common.BlockHandle
+//   - kind common0.BlockKind
 //   - val *common0.InternalLazyValue
-func (_e *MockIBlockCacheWrapper_Expecter) Set(bh interface{}, val interface{}) *MockIBlockCacheWrapper_Set_Call {
-	return &MockIBlockCacheWrapper_Set_Call{Call: _e.mock.On("Set", bh, val)}
+func (_e *MockIBlockCacheWrapper_Expecter) Set(bh interface{}, kind interface{}, val interface{}) *MockIBlockCacheWrapper_Set_Call {
+	return &MockIBlockCacheWrapper_Set_Call{Call: _e.mock.On("Set", bh, kind, val)}
 }
 
-func (_c *MockIBlockCacheWrapper_Set_Call) Run(run func(bh *common.BlockHandle, val *common0.InternalLazyValue)) *MockIBlockCacheWrapper_Set_Call {
+func (_c *MockIBlockCacheWrapper_Set_Call) Run(run func(bh *common.BlockHandle, kind common0.BlockKind, val *common0.InternalLazyValue)) *MockIBlockCacheWrapper_Set_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 *common.BlockHandle
 		if args[0] != nil {
 			arg0 = args[0].(*common.BlockHandle)
 		}
-		var arg1 *common0.InternalLazyValue
+		var arg1 common0.BlockKind
 		if args[1] != nil {
-			arg1 = args[1].(*common0.InternalLazyValue)
+			arg1 = args[1].(common0.BlockKind)
+		}
+		var arg2 *common0.InternalLazyValue
+		if args[2] != nil {
+			arg2 = args[2].(*common0.InternalLazyValue)
 		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
@@ -184,7 +196,7 @@ func (_c *MockIBlockCacheWrapper_Set_Call) Return(err error) *MockIBlockCacheWra
 	return _c
 }
 
-func (_c *MockIBlockCacheWrapper_Set_Call) RunAndReturn(run func(bh *common.BlockHandle, val *common0.InternalLazyValue) error) *MockIBlockCacheWrapper_Set_Call {
+func (_c *MockIBlockCacheWrapper_Set_Call) RunAndReturn(run func(bh *common.BlockHandle, kind common0.BlockKind, val *common0.InternalLazyValue) error) *MockIBlockCacheWrapper_Set_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -24,6 +24,14 @@ var (
 type blockCacheWrapper struct {
 	fileNum uint64
 	c       go_block_cache.IBlockCache
+
+	dontFill      bool
+	dataPriority  go_block_cache.Priority
+	pinMetaBlocks bool
+	// pins are the pinned index and filter blocks, by offset, they're
+	// released once the wrapper is closed. Only the iterator goroutine reads
+	// the meta blocks, the prefetcher only reads the data blocks
+	pins map[uint64]go_block_cache.LazyValue
 }
 
 type lazyValueWrapper struct {
@@ -40,16 +48,19 @@ func (l *lazyValueWrapper) Release() {
 
 //go:generate mockery --name=IBlockCacheWrapper --case=underscore --disable-version-string
 type IBlockCacheWrapper interface {
-	Get(bh *common.BlockHandle) (*nogodb_common.InternalLazyValue, error)
-	Set(bh *common.BlockHandle, val *nogodb_common.InternalLazyValue) error
+	Get(bh *common.BlockHandle, kind nogodb_common.BlockKind) (*nogodb_common.InternalLazyValue, error)
+	Set(bh *common.BlockHandle, kind nogodb_common.BlockKind, val *nogodb_common.InternalLazyValue) error
+	// Close releases the pinned blocks, the cache itself is shared across
+	// the tables, hence it's left open
 	Close()
 }
 
-func (w *blockCacheWrapper) Get(bh *common.BlockHandle) (*nogodb_common.InternalLazyValue, error) {
+func (w *blockCacheWrapper) Get(bh *common.BlockHandle, kind nogodb_common.BlockKind) (*nogodb_common.InternalLazyValue, error) {
 	lazyValue, exist := w.c.Get(w.fileNum, bh.Offset)
 	if !exist {
 		return nil, cacheMiss
 	}
+	w.mightPin(bh, kind)
 	val := nogodb_common.NewBlankInternalLazyValue(nogodb_common.ValueFromCache)
 	if err := val.SetCacheFetcher(&lazyValueWrapper{lazyValue}); err != nil {
 		return nil, err
@@ -58,18 +69,60 @@ func (w *blockCacheWrapper) Get(bh *common.BlockHandle) (*nogodb_common.Internal
 	return &val, nil
 }
 
-func (w *blockCacheWrapper) Set(bh *common.BlockHandle, val *nogodb_common.InternalLazyValue) error {
+func (w *blockCacheWrapper) Set(bh *common.BlockHandle, kind nogodb_common.BlockKind, val *nogodb_common.InternalLazyValue) error {
+	if w.dontFill {
+		return nil
+	}
+
+	prio := w.dataPriority
+	if isMetaBlock(kind) {
+		prio = go_block_cache.PriorityHigh
+	}
+
 	v := make([]byte, len(val.Value()))
 	copy(v, val.Value())
-	ok := w.c.Set(w.fileNum, bh.Offset, v)
+	ok := w.c.SetWithPriority(w.fileNum, bh.Offset, v, prio)
 	if !ok {
 		return failedUpdateToCache
 	}
+	w.mightPin(bh, kind)
 	return nil
 }
 
+// mightPin pins the index and filter blocks once, if enabled
+func (w *blockCacheWrapper) mightPin(bh *common.BlockHandle, kind nogodb_common.BlockKind) {
+	if !w.pinMetaBlocks || !isMetaBlock(kind) {
+		return
+	}
+
+	if _, pinned := w.pins[bh.Offset]; pinned {
+		return
+	}
+
+	if pin, ok := w.c.Pin(w.fileNum, bh.Offset); ok {
+		if w.pins == nil {
+			w.pins = make(map[uint64]go_block_cache.LazyValue)
+		}
+		w.pins[bh.Offset] = pin
+	}
+}
+
 func (w *blockCacheWrapper) Close() {
-	w.c.Close()
+	for _, pin := range w.pins {
+		pin.Release()
+	}
+	w.pins = nil
+}
+
+// isMetaBlock returns whether the blocks of the kind are read by every lookup
+// of the table, they're cached with a high priority
+func isMetaBlock(kind nogodb_common.BlockKind) bool {
+	switch kind {
+	case nogodb_common.BlockKindIndex, nogodb_common.BlockKindFilter, nogodb_common.BlockKindFilterIndex:
+		return true
+	default:
+		return false
+	}
 }
 
 //go:generate mockery --name=IBlockReader --case=underscore --disable-version-string
@@ -98,10 +151,13 @@ func (r *RowBlockReader) Init(
 ) {
 	r.bpool = bpool
 	r.storageReader = fr
-	if cacheOpts != nil {
+	if cacheOpts != nil && cacheOpts.Cache != nil {
 		r.blockCache = &blockCacheWrapper{
-			fileNum: uint64(cacheOpts.FileNum),
-			c:       cacheOpts.Cache,
+			fileNum:       uint64(cacheOpts.FileNum),
+			c:             cacheOpts.Cache,
+			dontFill:      cacheOpts.DontFillCache,
+			dataPriority:  cacheOpts.DataBlockPriority,
+			pinMetaBlocks: cacheOpts.PinMetaBlocks,
 		}
 	}
 }
@@ -123,7 +179,7 @@ func (r *RowBlockReader) ReadThroughCache(
 		return r.readFromStorage(bh, kind)
 	}
 
-	cachedVal, err := r.blockCache.Get(bh)
+	cachedVal, err := r.blockCache.Get(bh, kind)
 	if err == nil {
 		return cachedVal, nil
 	}
@@ -132,7 +188,7 @@ func (r *RowBlockReader) ReadThroughCache(
 	if err != nil {
 		return nil, err
 	}
-	if err := r.blockCache.Set(bh, fromStorageVal); err != nil {
+	if err := r.blockCache.Set(bh, kind, fromStorageVal); err != nil {
 		return nil, err
 	}
 
@@ -202,9 +202,9 @@ func Test_ReadThroughCache(t *testing.T) {
 				err := mockVal.SetCacheFetcher(&lazyValueWrapper{mockFetcher})
 				assert.NoError(t, err)
 
-				mockBlockCache.On("Get", bh).Return(&mockVal, nil).Once()
+				mockBlockCache.On("Get", bh, nogodb_common.BlockKindData).Return(&mockVal, nil).Once()
 			} else {
-				mockBlockCache.On("Get", bh).Return(nil, cacheMiss).Once()
+				mockBlockCache.On("Get", bh, nogodb_common.BlockKindData).Return(nil, cacheMiss).Once()
 			}
 
 			// Setup storage mock expectations if needed
@@ -226,9 +226,9 @@ func Test_ReadThroughCache(t *testing.T) {
 			// Setup cache set mock expectations if needed
 			if tc.expectCacheSet {
 				if tc.cacheSetError != nil {
-					mockBlockCache.On("Set", bh, mock.AnythingOfType("*common.InternalLazyValue")).Return(tc.cacheSetError).Once()
+					mockBlockCache.On("Set", bh, nogodb_common.BlockKindData, mock.AnythingOfType("*common.InternalLazyValue")).Return(tc.cacheSetError).Once()
 				} else {
-					mockBlockCache.On("Set", bh, mock.AnythingOfType("*common.InternalLazyValue")).Return(nil).Once()
+					mockBlockCache.On("Set", bh, nogodb_common.BlockKindData, mock.AnythingOfType("*common.InternalLazyValue")).Return(nil).Once()
 				}
 			}
 
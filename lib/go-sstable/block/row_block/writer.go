@@ -2,6 +2,8 @@ package row_block
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	"github.com/datnguyenzzz/nogodb/lib/common/compression"
@@ -20,14 +22,21 @@ type compressorPerBlock map[nogodb_common.BlockKind]compression.ICompression
 
 // RowBlockWriter is an implementation of common.InternalWriter, which writes SSTables with row-oriented blocks
 type RowBlockWriter struct {
-	opts            options.BlockWriteOpt
-	storageWriter   storage.ILayoutWriter
-	dataBlock       *rowBlockBuf
-	metaIndexBlock  *rowBlockBuf
-	indexWriter     *indexWriter
-	flushDecider    common.IFlushDecider
-	comparer        nogodb_common.IComparer
-	filterWriter    filter.IWriter
+	opts           options.BlockWriteOpt
+	storageWriter  storage.ILayoutWriter
+	dataBlock      *rowBlockBuf
+	metaIndexBlock *rowBlockBuf
+	indexWriter    *indexWriter
+	flushDecider   common.IFlushDecider
+	comparer       nogodb_common.IComparer
+	filterWriter   filter.IWriter
+	// filterKeys are the filter keys of the current data block, they're
+	// added to the filter partition of its index block once it's flushed.
+	// Only used with the partitioned filters
+	filterKeys      [][]byte
+	filterPrefixes  *block.FilterPrefixes
+	propsCollector  *block.BlockPropsCollector
+	props           common.TableProperties
 	compressors     compressorPerBlock
 	checksumer      nogodb_common.IChecksum
 	taskQueue       queue.IQueue
@@ -44,17 +53,33 @@ func (rw *RowBlockWriter) Add(key nogodb_common.InternalKey, value []byte) error
 		return err
 	}
 
-	if rw.filterWriter != nil {
-		rw.filterWriter.Add(key.UserKey)
+	if err := rw.propsCollector.Add(key, value); err != nil {
+		return err
+	}
+
+	rw.addFilterKey(key.UserKey)
+	if prefix := rw.filterPrefixes.Next(key.UserKey); prefix != nil {
+		rw.addFilterKey(prefix)
 	}
 
 	if err := rw.dataBlock.WriteEntry(key, value); err != nil {
 		return err
 	}
 
+	rw.props.AddEntry(key, len(value))
 	return nil
 }
 
+// addFilterKey adds the key to the filter of the table, or to the filter keys
+// of the current data block with the partitioned filters
+func (rw *RowBlockWriter) addFilterKey(key []byte) {
+	if rw.opts.PartitionedFilter {
+		rw.filterKeys = append(rw.filterKeys, slices.Clone(key))
+	} else if rw.filterWriter != nil {
+		rw.filterWriter.Add(key)
+	}
+}
+
 func (rw *RowBlockWriter) Close() error {
 	// Flush the last (current) data block to the storage
 	if rw.dataBlock.EntryCount() > 0 {
@@ -83,12 +108,14 @@ func (rw *RowBlockWriter) Close() error {
 			zap.L().Error("failed to write filter to the storage", zap.Error(err))
 			return err
 		}
-		// save the filter block location to the meta index block
-		encodedBH := make([]byte, common.MaxBlockHandleBytes)
+		// save the filter block location to the meta index block, followed
+		// by the filtering method
+		encodedBH := make([]byte, common.MaxBlockHandleBytes+1)
 		n := bh.EncodeInto(encodedBH)
+		encodedBH[n] = byte(rw.opts.FilterMethod)
 		err = rw.metaIndexBlock.WriteEntry(
 			nogodb_common.MakeMetaIndexKey(nogodb_common.BlockKindFilter),
-			encodedBH[:n],
+			encodedBH[:n+1],
 		)
 		if err != nil {
 			zap.L().Error("failed to write filter to the metaIndexBlock", zap.Error(err))
@@ -114,6 +141,31 @@ func (rw *RowBlockWriter) Close() error {
 			zap.L().Error("failed to write the 2-level index block to the meta index", zap.Error(err))
 		}
 	}
+	// Build and Flush the partitioned filters along with their index, which
+	// are aligned with the 1st level index blocks
+	if rw.opts.PartitionedFilter {
+		bh, err := rw.indexWriter.BuildFilterIndex()
+		if err != nil {
+			zap.L().Error("failed to build the partitioned filters", zap.Error(err))
+			return err
+		}
+		encodedBH := make([]byte, common.MaxBlockHandleBytes+1)
+		n := bh.EncodeInto(encodedBH)
+		encodedBH[n] = byte(rw.opts.FilterMethod)
+		err = rw.metaIndexBlock.WriteEntry(
+			nogodb_common.MakeMetaIndexKey(nogodb_common.BlockKindFilterIndex),
+			encodedBH[:n+1],
+		)
+		if err != nil {
+			zap.L().Error("failed to write the filter index to the metaIndexBlock", zap.Error(err))
+			return err
+		}
+	}
+	// Build and Flush properties block to the stable storage
+	if err := rw.writePropertiesBlock(); err != nil {
+		zap.L().Error("failed to write the properties block", zap.Error(err))
+		return err
+	}
 
 	// write the meta index block
 	metaIndexRaw := rw.bytesBufferPool.Get(rw.metaIndexBlock.EstimateSize())
@@ -157,6 +209,47 @@ func (rw *RowBlockWriter) Close() error {
 	return nil
 }
 
+// writePropertiesBlock writes the table properties, keyed by the property
+// names, see common.TableProperties
+func (rw *RowBlockWriter) writePropertiesBlock() error {
+	var err error
+	rw.props.IndexSize = rw.indexWriter.IndexSize()
+	rw.props.CreationTime = uint64(time.Now().Unix())
+	if rw.props.UserProperties, err = rw.propsCollector.UserProperties(rw.indexWriter.TableProps()); err != nil {
+		return err
+	}
+
+	propsBlock := newBlock(1, rw.bytesBufferPool, rw.opts.BlockSize)
+	defer func() {
+		propsBlock.CleanUpForReuse()
+		propsBlock.Release()
+	}()
+	keys, values := rw.props.Encode()
+	for i := range keys {
+		key := nogodb_common.InternalKey{UserKey: keys[i]}
+		if err := propsBlock.WriteEntry(key, values[i]); err != nil {
+			return err
+		}
+	}
+
+	uncompressed := rw.bytesBufferPool.Get(propsBlock.EstimateSize())
+	uncompressed = uncompressed[:propsBlock.EstimateSize()]
+	propsBlock.Finish(uncompressed)
+	pb := block.CompressToPb(rw.compressors[nogodb_common.BlockKindProperties], rw.checksumer, uncompressed)
+	bh, err := rw.storageWriter.WritePhysicalBlock(*pb)
+	rw.bytesBufferPool.Put(uncompressed)
+	if err != nil {
+		return err
+	}
+
+	encodedBH := make([]byte, common.MaxBlockHandleBytes)
+	n := bh.EncodeInto(encodedBH)
+	return rw.metaIndexBlock.WriteEntry(
+		nogodb_common.MakeMetaIndexKey(nogodb_common.BlockKindProperties),
+		encodedBH[:n],
+	)
+}
+
 // validateKey ensure the key is added in the asc order.
 func (rw *RowBlockWriter) validateKey(key nogodb_common.InternalKey) error {
 	if rw.dataBlock.EntryCount() == 0 {
@@ -187,15 +280,24 @@ func (rw *RowBlockWriter) doFlush(key nogodb_common.InternalKey) error {
 	uncompressed := rw.bytesBufferPool.Get(rw.dataBlock.EstimateSize())
 	uncompressed = uncompressed[:rw.dataBlock.EstimateSize()]
 	rw.dataBlock.Finish(uncompressed)
+	props, err := rw.propsCollector.FinishDataBlock()
+	if err != nil {
+		return err
+	}
 
 	// 2. Get the task from the pool and compute the physical format
 	// of the data block to prepare the needed input for the task
 	task := block.SpawnNewTask()
 	task.StorageWriter = rw.storageWriter
 	task.Physical = block.CompressToPb(rw.compressors[nogodb_common.BlockKindData], rw.checksumer, uncompressed)
+	rw.props.AddDataBlock(task.Physical)
 	// inputs for index writer
 	task.IndexKey = prevKey
+	task.IndexProps = props
 	task.IndexWriter = rw.indexWriter
+	task.FilterKeys = rw.filterKeys
+	rw.filterKeys = nil
+	rw.filterPrefixes.Reset()
 
 	// 3. Put the task into queue that is running on another go-routine
 	// for execution
@@ -227,6 +329,20 @@ func NewRowBlockWriter(w go_fs.Writable, opts options.BlockWriteOpt, version com
 	storageWriter := storage.NewLayoutWriter(w)
 	bp := predictable_size.NewPredictablePool()
 	metaIndexBlock := newBlock(1, bp, opts.BlockSize)
+	propsCollector := block.NewBlockPropsCollector(opts.BlockPropertyCollectors)
+	// the partitioned filters are built by the index writer
+	var filterWriter filter.IWriter
+	if !opts.PartitionedFilter {
+		filterWriter = filter.NewFilterWriter(opts.FilterMethod, opts.FilterBitsPerKey)
+	}
+	props := common.TableProperties{
+		ComparerName:     comparer.Name(),
+		CompressionName:  c[nogodb_common.BlockKindData].GetType().String(),
+		FilterPolicyName: opts.FilterMethod.String(),
+	}
+	if opts.PrefixExtractor != nil {
+		props.PrefixExtractorName = opts.PrefixExtractor.Name()
+	}
 	return &RowBlockWriter{
 		opts:           opts,
 		storageWriter:  storageWriter,
@@ -235,15 +351,20 @@ func NewRowBlockWriter(w go_fs.Writable, opts options.BlockWriteOpt, version com
 		indexWriter: newIndexWriter(
 			comparer,
 			c[nogodb_common.BlockKindIndex],
+			c[nogodb_common.BlockKindFilter],
 			crc32Checksum,
 			flushDecider,
 			storageWriter,
 			metaIndexBlock,
 			bp,
 			opts,
+			propsCollector,
 		),
 		comparer:        comparer,
-		filterWriter:    filter.NewFilterWriter(filter.BloomFilter), // Use bloom filter as a default method
+		filterWriter:    filterWriter,
+		filterPrefixes:  block.NewFilterPrefixes(opts.PrefixExtractor),
+		propsCollector:  propsCollector,
+		props:           props,
 		flushDecider:    flushDecider,
 		compressors:     c,
 		checksumer:      crc32Checksum,
@@ -0,0 +1,234 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// The properties block records what is inside a table, so that it doesn't
+// have to be scanned. Each property is an entry of the block, keyed by its
+// name. The integers are encoded as uvarints, the strings as is. The user
+// collected properties are keyed by userPropertyPrefix + collector name.
+const (
+	propNumEntries       = "nogodb.num.entries"
+	propNumDeletions     = "nogodb.num.deletions"
+	propNumMerges        = "nogodb.num.merges"
+	propRawKeySize       = "nogodb.raw.key.size"
+	propRawValueSize     = "nogodb.raw.value.size"
+	propDataSize         = "nogodb.data.size"
+	propIndexSize        = "nogodb.index.size"
+	propNumDataBlocks    = "nogodb.num.data.blocks"
+	propComparerName     = "nogodb.comparer"
+	propCompressionName  = "nogodb.compression"
+	propFilterPolicyName = "nogodb.filter.policy"
+	propPrefixExtractor  = "nogodb.prefix.extractor"
+	propSmallestSeqNum   = "nogodb.seqnum.smallest"
+	propLargestSeqNum    = "nogodb.seqnum.largest"
+	propCreationTime     = "nogodb.creation.time"
+
+	userPropertyPrefix = "user."
+)
+
+var (
+	ErrInvalidTableProperties = errors.New("sstable: invalid table properties")
+	ErrNoTableProperties      = errors.New("sstable: the table has no properties block")
+)
+
+type TableProperties struct {
+	// NumEntries is the number of entries, including the deletions and the merges
+	NumEntries   uint64
+	NumDeletions uint64
+	NumMerges    uint64
+	// RawKeySize is the total size of the internal keys
+	RawKeySize uint64
+	// RawValueSize is the total size of the values
+	RawValueSize uint64
+	// DataSize and IndexSize are the physical sizes of the data blocks and the
+	// index blocks (1st + 2nd levels)
+	DataSize      uint64
+	IndexSize     uint64
+	NumDataBlocks uint64
+
+	ComparerName string
+	// CompressionName is the compression of the data blocks
+	CompressionName  string
+	FilterPolicyName string
+	// PrefixExtractorName is the name of the prefix extractor whose prefixes
+	// are in the filter, empty if none
+	PrefixExtractorName string
+
+	// SmallestSeqNum and LargestSeqNum are the range of the sequence numbers
+	// of the entries
+	SmallestSeqNum nogodb_common.SeqNum
+	LargestSeqNum  nogodb_common.SeqNum
+
+	// CreationTime is the time the table was written, in unix seconds
+	CreationTime uint64
+
+	// UserProperties are the properties collected by the block property
+	// collectors over the whole table, sorted by name
+	UserProperties []UserProperty
+}
+
+// UserProperty is the property of a block property collector over the whole table
+type UserProperty struct {
+	Name string
+	// Index is the index of the collector in the properties of the blocks
+	Index int
+	Prop  []byte
+}
+
+// AddEntry accumulates the properties of an entry added to the table
+func (p *TableProperties) AddEntry(key nogodb_common.InternalKey, valueLen int) {
+	if seqNum := key.SeqNum(); p.NumEntries == 0 {
+		p.SmallestSeqNum, p.LargestSeqNum = seqNum, seqNum
+	} else {
+		p.SmallestSeqNum = min(p.SmallestSeqNum, seqNum)
+		p.LargestSeqNum = max(p.LargestSeqNum, seqNum)
+	}
+
+	p.NumEntries++
+	switch key.KeyKind() {
+	case nogodb_common.KeyKindDelete:
+		p.NumDeletions++
+	case nogodb_common.KeyKindMerge:
+		p.NumMerges++
+	default:
+	}
+
+	p.RawKeySize += uint64(key.Size())
+	p.RawValueSize += uint64(valueLen)
+}
+
+// AddDataBlock accumulates the properties of a data block written to the table
+func (p *TableProperties) AddDataBlock(pb *PhysicalBlock) {
+	p.NumDataBlocks++
+	p.DataSize += pb.Size()
+}
+
+// UserProperty returns the user collected property of the given name
+func (p *TableProperties) UserProperty(name string) (UserProperty, bool) {
+	i, found := slices.BinarySearchFunc(p.UserProperties, name, func(up UserProperty, name string) int {
+		return strings.Compare(up.Name, name)
+	})
+	if !found {
+		return UserProperty{}, false
+	}
+
+	return p.UserProperties[i], true
+}
+
+// Encode returns the entries of the properties block, sorted by key
+func (p *TableProperties) Encode() (keys, values [][]byte) {
+	type entry struct{ key, value []byte }
+	entries := make([]entry, 0, 16+len(p.UserProperties))
+	addUint := func(key string, v uint64) {
+		entries = append(entries, entry{[]byte(key), binary.AppendUvarint(nil, v)})
+	}
+	addString := func(key string, v string) {
+		entries = append(entries, entry{[]byte(key), []byte(v)})
+	}
+
+	addUint(propNumEntries, p.NumEntries)
+	addUint(propNumDeletions, p.NumDeletions)
+	addUint(propNumMerges, p.NumMerges)
+	addUint(propRawKeySize, p.RawKeySize)
+	addUint(propRawValueSize, p.RawValueSize)
+	addUint(propDataSize, p.DataSize)
+	addUint(propIndexSize, p.IndexSize)
+	addUint(propNumDataBlocks, p.NumDataBlocks)
+	addString(propComparerName, p.ComparerName)
+	addString(propCompressionName, p.CompressionName)
+	addString(propFilterPolicyName, p.FilterPolicyName)
+	addString(propPrefixExtractor, p.PrefixExtractorName)
+	addUint(propSmallestSeqNum, uint64(p.SmallestSeqNum))
+	addUint(propLargestSeqNum, uint64(p.LargestSeqNum))
+	addUint(propCreationTime, p.CreationTime)
+	for _, up := range p.UserProperties {
+		value := binary.AppendUvarint(nil, uint64(up.Index))
+		entries = append(entries, entry{[]byte(userPropertyPrefix + up.Name), append(value, up.Prop...)})
+	}
+
+	slices.SortFunc(entries, func(x, y entry) int {
+		return bytes.Compare(x.key, y.key)
+	})
+
+	keys, values = make([][]byte, 0, len(entries)), make([][]byte, 0, len(entries))
+	for _, e := range entries {
+		keys, values = append(keys, e.key), append(values, e.value)
+	}
+
+	return keys, values
+}
+
+// Decode loads an entry of the properties block, the unknown entries are
+// ignored. The value is copied.
+func (p *TableProperties) Decode(key, value []byte) error {
+	name := string(key)
+	if upName, ok := strings.CutPrefix(name, userPropertyPrefix); ok {
+		idx, n := binary.Uvarint(value)
+		if n <= 0 {
+			return ErrInvalidTableProperties
+		}
+
+		up := UserProperty{Name: upName, Index: int(idx), Prop: bytes.Clone(value[n:])}
+		i, _ := slices.BinarySearchFunc(p.UserProperties, upName, func(up UserProperty, name string) int {
+			return strings.Compare(up.Name, name)
+		})
+		p.UserProperties = slices.Insert(p.UserProperties, i, up)
+		return nil
+	}
+
+	var field *uint64
+	switch name {
+	case propComparerName:
+		p.ComparerName = string(value)
+		return nil
+	case propCompressionName:
+		p.CompressionName = string(value)
+		return nil
+	case propFilterPolicyName:
+		p.FilterPolicyName = string(value)
+		return nil
+	case propPrefixExtractor:
+		p.PrefixExtractorName = string(value)
+		return nil
+	case propNumEntries:
+		field = &p.NumEntries
+	case propNumDeletions:
+		field = &p.NumDeletions
+	case propNumMerges:
+		field = &p.NumMerges
+	case propRawKeySize:
+		field = &p.RawKeySize
+	case propRawValueSize:
+		field = &p.RawValueSize
+	case propDataSize:
+		field = &p.DataSize
+	case propIndexSize:
+		field = &p.IndexSize
+	case propNumDataBlocks:
+		field = &p.NumDataBlocks
+	case propSmallestSeqNum:
+		field = (*uint64)(&p.SmallestSeqNum)
+	case propLargestSeqNum:
+		field = (*uint64)(&p.LargestSeqNum)
+	case propCreationTime:
+		field = &p.CreationTime
+	default:
+		return nil
+	}
+
+	v, n := binary.Uvarint(value)
+	if n <= 0 || n != len(value) {
+		return ErrInvalidTableProperties
+	}
+
+	*field = v
+	return nil
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"testing"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TableProperties(t *testing.T) {
+	type param struct {
+		desc    string
+		entries []nogodb_common.InternalKey
+		props   TableProperties
+	}
+
+	key := func(k string, seqNum nogodb_common.SeqNum, kind nogodb_common.KeyKind) nogodb_common.InternalKey {
+		return nogodb_common.MakeKey([]byte(k), seqNum, kind)
+	}
+
+	tests := []param{
+		{
+			desc:  "empty table",
+			props: TableProperties{ComparerName: "DefaultComparer"},
+		},
+		{
+			desc: "sets, deletions and merges",
+			entries: []nogodb_common.InternalKey{
+				key("a", 7, nogodb_common.KeyKindSet),
+				key("b", 3, nogodb_common.KeyKindDelete),
+				key("c", 9, nogodb_common.KeyKindMerge),
+				key("d", 5, nogodb_common.KeyKindDelete),
+			},
+			props: TableProperties{
+				DataSize:            1024,
+				IndexSize:           64,
+				NumDataBlocks:       2,
+				ComparerName:        "DefaultComparer",
+				CompressionName:     "snappy",
+				FilterPolicyName:    "bloom",
+				PrefixExtractorName: "nogodb.FixedPrefix.4",
+				CreationTime:        1_700_000_000,
+				UserProperties: []UserProperty{
+					{Name: "a-collector", Index: 1, Prop: []byte{1, 2}},
+					{Name: "b-collector", Index: 0, Prop: []byte{}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			props := tc.props
+			for _, k := range tc.entries {
+				props.AddEntry(k, 10)
+			}
+
+			keys, values := props.Encode()
+			for i := 1; i < len(keys); i++ {
+				assert.Less(t, string(keys[i-1]), string(keys[i]), "the keys must be sorted")
+			}
+
+			decoded := TableProperties{}
+			for i := range keys {
+				require.NoError(t, decoded.Decode(keys[i], values[i]))
+			}
+			require.NoError(t, decoded.Decode([]byte("unknown.property"), []byte("ignored")))
+			assert.Equal(t, props, decoded)
+
+			if len(tc.entries) > 0 {
+				assert.Equal(t, uint64(4), decoded.NumEntries)
+				assert.Equal(t, uint64(2), decoded.NumDeletions)
+				assert.Equal(t, uint64(1), decoded.NumMerges)
+				assert.Equal(t, uint64(40), decoded.RawValueSize)
+				assert.Equal(t, nogodb_common.SeqNum(3), decoded.SmallestSeqNum)
+				assert.Equal(t, nogodb_common.SeqNum(9), decoded.LargestSeqNum)
+
+				up, ok := decoded.UserProperty("a-collector")
+				require.True(t, ok)
+				assert.Equal(t, 1, up.Index)
+				_, ok = decoded.UserProperty("missing")
+				assert.False(t, ok)
+			}
+		})
+	}
+
+	t.Run("corrupted value", func(t *testing.T) {
+		props := TableProperties{}
+		assert.ErrorIs(t, props.Decode([]byte(propNumEntries), []byte{0x80}), ErrInvalidTableProperties)
+	})
+}
@@ -1,6 +1,9 @@
 package filter
 
-import "github.com/datnguyenzzz/nogodb/lib/go-blocked-bloom-filter"
+import (
+	"github.com/datnguyenzzz/nogodb/lib/go-blocked-bloom-filter"
+	"github.com/datnguyenzzz/nogodb/lib/go-ribbon-filter"
+)
 
 type Method byte
 
@@ -10,6 +13,18 @@ const (
 	RibbonFilter
 )
 
+var methodToString = map[Method]string{
+	BloomFilter:  "bloom",
+	RibbonFilter: "ribbon",
+}
+
+func (m Method) String() string {
+	if s, ok := methodToString[m]; ok {
+		return s
+	}
+	return "unknown"
+}
+
 type IRead interface {
 	Name() string
 	// MayContain returns whether the encoded filter may contain given key.
@@ -25,18 +40,31 @@ type IWriter interface {
 	Build(pb *[]byte)
 }
 
-func NewFilterWriter(method Method) IWriter {
+// NewFilterWriter returns the writer of the given filtering method, spending
+// about bitsPerKey bits per key. A non-positive bitsPerKey falls back to the
+// default of the method.
+func NewFilterWriter(method Method, bitsPerKey int) IWriter {
 	switch method {
 	case BloomFilter:
 		bf := go_blocked_bloom_filter.NewBloomFilter()
-		return bf.NewWriter()
+		return bf.NewWriterWithBitsPerKey(bitsPerKey)
+	case RibbonFilter:
+		rf := go_ribbon_filter.NewRibbonFilter()
+		return rf.NewWriterWithBitsPerKey(bitsPerKey)
 	default:
 		panic("unsupported / unknown filtering method")
 	}
 }
 
+// iFilter is the probe phase of a filtering method, the bloom and the ribbon
+// filters share the same shape
+type iFilter interface {
+	Name() string
+	MayContain(filter, key []byte) bool
+}
+
 type Reader struct {
-	bl     go_blocked_bloom_filter.IFilter
+	bl     iFilter
 	filter []byte
 }
 
@@ -54,6 +82,8 @@ func NewFilterReader(method Method, filter []byte) IRead {
 	switch method {
 	case BloomFilter:
 		return &Reader{bl: go_blocked_bloom_filter.NewBloomFilter(), filter: filter}
+	case RibbonFilter:
+		return &Reader{bl: go_ribbon_filter.NewRibbonFilter(), filter: filter}
 	default:
 		panic("unsupported / unknown filtering method")
 	}
@@ -416,6 +416,7 @@ func (w *SSTSuiteFS) Test_Iterator_Seeking_Ops_single_table() {
 					go_block_cache.WithCacheType(tc.cacheType),
 					go_block_cache.WithShardNum(4),
 				)
+				defer c.Close()
 				iterOpts = []options.IteratorOptsFunc{
 					options.WithBlockCache(c, fd),
 				}
@@ -614,6 +615,7 @@ func (w *SSTSuiteFS) Test_Iterator_Seeking_Ops_single_table_MVCC_col_block() {
 					go_block_cache.WithCacheType(tc.cacheType),
 					go_block_cache.WithShardNum(4),
 				)
+				defer c.Close()
 				iterOpts = append(iterOpts, []options.IteratorOptsFunc{
 					options.WithBlockCache(c, fd),
 				}...)
@@ -809,6 +811,7 @@ func (w *SSTSuiteFS) Test_Iterator_Concurrently_Seeking_Ops_multiple_tables() {
 							go_block_cache.WithCacheType(tc.cacheType),
 							go_block_cache.WithShardNum(4),
 						)
+						defer c.Close()
 						iterOpts = []options.IteratorOptsFunc{
 							options.WithBlockCache(c, fd),
 						}
@@ -1016,6 +1019,7 @@ func (w *SSTSuiteFS) Test_Iterator_Concurrently_Seeking_Ops_multiple_tables_MVCC
 							go_block_cache.WithCacheType(tc.cacheType),
 							go_block_cache.WithShardNum(4),
 						)
+						defer c.Close()
 						iterOpts = append(iterOpts, []options.IteratorOptsFunc{
 							options.WithBlockCache(c, fd),
 						}...)
@@ -1208,6 +1212,7 @@ func (w *SSTSuiteFS) Test_Iterator_First_Then_Next_Ops() {
 							go_block_cache.WithCacheType(tc.cacheType),
 							go_block_cache.WithShardNum(4),
 						)
+						defer c.Close()
 						iterOpts = []options.IteratorOptsFunc{
 							options.WithBlockCache(c, fd),
 						}
@@ -1397,6 +1402,7 @@ func (w *SSTSuiteFS) Test_Iterator_First_Then_Next_Ops_MVCC_colblock() {
 							go_block_cache.WithCacheType(tc.cacheType),
 							go_block_cache.WithShardNum(4),
 						)
+						defer c.Close()
 						iterOpts = append(iterOpts, []options.IteratorOptsFunc{
 							options.WithBlockCache(c, fd),
 						}...)
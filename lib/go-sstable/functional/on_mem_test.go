@@ -4,8 +4,10 @@ package functional
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
 	"testing"
 	"time"
 
@@ -15,11 +17,13 @@ import (
 	go_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
 	go_sstable "github.com/datnguyenzzz/nogodb/lib/go-sstable"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/filter"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
 const (
@@ -397,6 +401,7 @@ func (w *SSTSuite) Test_Iterator_Seeking_Ops_single_table() {
 				go_block_cache.WithCacheType(tc.cacheType),
 				go_block_cache.WithShardNum(4),
 			)
+			defer cache.Close()
 			if tc.cacheSize > 0 {
 				iterOpts = []options.IteratorOptsFunc{
 					options.WithBlockCache(cache, fd),
@@ -596,6 +601,7 @@ func (w *SSTSuite) Test_Iterator_Seeking_Ops_single_table_MVCC_col_block() {
 					go_block_cache.WithCacheType(tc.cacheType),
 					go_block_cache.WithShardNum(4),
 				)
+				defer cache.Close()
 				iterOpts = append(iterOpts, []options.IteratorOptsFunc{
 					options.WithBlockCache(cache, fd),
 				}...)
@@ -792,6 +798,7 @@ func (w *SSTSuite) Test_Iterator_Concurrently_Seeking_Ops_multiple_tables() {
 							go_block_cache.WithCacheType(tc.cacheType),
 							go_block_cache.WithShardNum(4),
 						)
+						defer cache.Close()
 						iterOpts = []options.IteratorOptsFunc{
 							options.WithBlockCache(cache, fd),
 						}
@@ -1000,6 +1007,7 @@ func (w *SSTSuite) Test_Iterator_Concurrently_Seeking_Ops_multiple_tables_MVCC_c
 							go_block_cache.WithCacheType(tc.cacheType),
 							go_block_cache.WithShardNum(4),
 						)
+						defer cache.Close()
 						iterOpts = append(iterOpts, []options.IteratorOptsFunc{
 							options.WithBlockCache(cache, fd),
 						}...)
@@ -1045,7 +1053,7 @@ func (w *SSTSuite) Test_Iterator_Concurrently_Seeking_Ops_multiple_tables_MVCC_c
 	}
 }
 
-func validateSeekGTE(t *testing.T, iter go_sstable.IIterator, expectedKV kvType, prevKV kvType, i int) {
+func validateSeekGTE(t *testing.T, iter nogodb_common.InternalIterator[nogodb_common.InternalKV], expectedKV kvType, prevKV kvType, i int) {
 	kv := iter.SeekGTE(expectedKV.key)
 	require.NotNil(t, kv, fmt.Sprintf("SeekGTE with an exact key must found, test case #%d", i))
 	assertKv(t, expectedKV, kv, i)
@@ -1064,7 +1072,7 @@ func validateSeekGTE(t *testing.T, iter go_sstable.IIterator, expectedKV kvType,
 	assertKv(t, expectedKV, kv, i)
 }
 
-func validateSeekGTE_MVCC_Key(t *testing.T, iter go_sstable.IIterator, expectedKV kvType, prevKV kvType, i int) {
+func validateSeekGTE_MVCC_Key(t *testing.T, iter nogodb_common.InternalIterator[nogodb_common.InternalKV], expectedKV kvType, prevKV kvType, i int) {
 	kv := iter.SeekGTE(expectedKV.key)
 	require.NotNil(t, kv, fmt.Sprintf("SeekGTE with an exact key must found, test case #%d", i))
 	assertKv(t, expectedKV, kv, i)
@@ -1092,7 +1100,7 @@ func validateSeekGTE_MVCC_Key(t *testing.T, iter go_sstable.IIterator, expectedK
 	assertKv(t, expectedKV, kv, i)
 }
 
-func assertKv(t *testing.T, expectedKV kvType, kv *common.InternalKV, i int) {
+func assertKv(t *testing.T, expectedKV kvType, kv *nogodb_common.InternalKV, i int) {
 	require.Zero(t, bytes.Compare(expectedKV.key, kv.K.UserKey), fmt.Sprintf("SeekGTE with smaller key: key must match, test case #%d. Expected: %v, actual: %v", i, expectedKV.key, kv.K.UserKey))
 	require.Zero(t, bytes.Compare(expectedKV.value, kv.V.Value()), fmt.Sprintf("SeekGTE with smaller key: key must value, test case #%d. Expected: %v, actual: %v", i, expectedKV.value, kv.V.Value()))
 }
@@ -1245,6 +1253,7 @@ func (w *SSTSuite) Test_Iterator_First_Then_Next_Ops() {
 							go_block_cache.WithCacheType(tc.cacheType),
 							go_block_cache.WithShardNum(4),
 						)
+						defer cache.Close()
 						iterOpts = []options.IteratorOptsFunc{
 							options.WithBlockCache(cache, fd),
 						}
@@ -1435,6 +1444,7 @@ func (w *SSTSuite) Test_Iterator_First_Then_Next_Ops_MVCC_colblock() {
 							go_block_cache.WithCacheType(tc.cacheType),
 							go_block_cache.WithShardNum(4),
 						)
+						defer cache.Close()
 						iterOpts = append(iterOpts, []options.IteratorOptsFunc{
 							options.WithBlockCache(cache, fd),
 						}...)
@@ -1473,6 +1483,779 @@ func (w *SSTSuite) Test_Iterator_First_Then_Next_Ops_MVCC_colblock() {
 	}
 }
 
+func (w *SSTSuite) Test_Iterator_Last_Then_Prev_Ops() {
+	type param struct {
+		name      string
+		version   common.TableVersion
+		isUnique  bool
+		cacheSize int // 0 means no cache
+	}
+
+	tests := []param{
+		{
+			name:     "row block, all keys are unique, block cache disable",
+			version:  common.TableV1,
+			isUnique: true,
+		},
+		{
+			name:     "row block, keys are shared prefix, block cache disable",
+			version:  common.TableV1,
+			isUnique: false,
+		},
+		{
+			name:      "row block, keys are shared prefix, block cache enabled",
+			version:   common.TableV1,
+			isUnique:  false,
+			cacheSize: 1 * mB,
+		},
+		{
+			name:     "MVCC col block, all keys are unique, block cache disable",
+			version:  common.TableV2,
+			isUnique: true,
+		},
+		{
+			name:     "MVCC col block, keys are shared prefix, block cache disable",
+			version:  common.TableV2,
+			isUnique: false,
+		},
+		{
+			name:      "MVCC col block, keys are shared prefix, block cache enabled",
+			version:   common.TableV2,
+			isUnique:  false,
+			cacheSize: 1 * mB,
+		},
+	}
+
+	sampleSize := 100_000
+
+	t := w.T()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init a table
+			inMemStorage := go_fs.NewInmemStorage()
+			fileWritable, _, err := inMemStorage.Create(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			writeOpts := []go_sstable.WriteOptFn{
+				go_sstable.WithBlockRestartInterval(5),
+				go_sstable.WithBlockSize(2 * kB),
+			}
+			iterOpts := []options.IteratorOptsFunc{}
+			var kvs []kvType
+			if tc.version == common.TableV2 {
+				mvccComparer := NewMvccComparer()
+				writeOpts = append(writeOpts, go_sstable.WithComparer(mvccComparer))
+				iterOpts = append(iterOpts, options.WithComparer(mvccComparer))
+				kvs = generateKVWithSuffix(sampleSize, tc.isUnique)
+			} else {
+				kvs = generateKV(sampleSize, tc.isUnique)
+			}
+
+			writer := go_sstable.NewWriter(fileWritable, tc.version, writeOpts...)
+			for _, kv := range kvs {
+				err := writer.Set(kv.key, kv.value)
+				assert.NoError(t, err, "failed to set")
+			}
+			require.NoError(t, writer.Close())
+
+			fileReadable, fd, err := inMemStorage.Open(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			if tc.cacheSize > 0 {
+				cache := go_block_cache.NewMap(
+					go_block_cache.WithMaxSize(int64(tc.cacheSize)),
+					go_block_cache.WithShardNum(4),
+				)
+				defer cache.Close()
+				iterOpts = append(iterOpts, options.WithBlockCache(cache, fd))
+			}
+			iter, err := go_sstable.NewSingularIterator(
+				predictable_size.NewPredictablePool(),
+				fileReadable,
+				iterOpts...,
+			)
+			require.NoError(t, err)
+
+			defer func() {
+				err := iter.Close()
+				assert.NoError(t, err)
+			}()
+
+			// the forward scan, reversed
+			forward := make([]kvType, 0, len(kvs))
+			for kv := iter.First(); kv != nil; kv = iter.Next() {
+				forward = append(forward, kvType{
+					key:   slices.Clone(kv.K.UserKey),
+					value: slices.Clone(kv.V.Value()),
+				})
+			}
+			require.Len(t, forward, len(kvs))
+			slices.Reverse(forward)
+
+			lastKv := iter.Last()
+			assertKv(t, forward[0], lastKv, 0)
+			for i := 1; i < len(forward); i++ {
+				kv := iter.Prev()
+				assertKv(t, forward[i], kv, i)
+				assert.Zero(t, bytes.Compare(forward[i].value, kv.V.Value()), fmt.Sprintf("Prev: value must match, test case #%d", i))
+			}
+			assert.Nil(t, iter.Prev())
+
+			// Evaluate the result of the seek operations
+			for i := 0; i < len(kvs); i += 1 + rand.Intn(100) {
+				validateSeekLTE(t, iter, kvs, i)
+			}
+
+			// a key that is smaller than all the keys, long enough for the MVCC suffix
+			assert.Nil(t, iter.SeekLTE(make([]byte, suffixLen+1)))
+			// a key that is bigger than all the keys
+			k := slices.Clone(kvs[len(kvs)-1].key)
+			k[0] += 1
+			kv := iter.SeekLTE(k)
+			assertKv(t, kvs[len(kvs)-1], kv, len(kvs)-1)
+		})
+	}
+}
+
+func (w *SSTSuite) Test_Iterator_Bounded_Ops() {
+	type param struct {
+		name     string
+		version  common.TableVersion
+		isUnique bool
+	}
+
+	tests := []param{
+		{
+			name:     "row block, all keys are unique",
+			version:  common.TableV1,
+			isUnique: true,
+		},
+		{
+			name:     "row block, keys are shared prefix",
+			version:  common.TableV1,
+			isUnique: false,
+		},
+		{
+			name:     "MVCC col block, all keys are unique",
+			version:  common.TableV2,
+			isUnique: true,
+		},
+		{
+			name:     "MVCC col block, keys are shared prefix",
+			version:  common.TableV2,
+			isUnique: false,
+		},
+	}
+
+	sampleSize := 50_000
+
+	t := w.T()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init a table
+			inMemStorage := go_fs.NewInmemStorage()
+			fileWritable, _, err := inMemStorage.Create(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			writeOpts := []go_sstable.WriteOptFn{
+				go_sstable.WithBlockRestartInterval(5),
+				go_sstable.WithBlockSize(2 * kB),
+			}
+			var kvs []kvType
+			if tc.version == common.TableV2 {
+				writeOpts = append(writeOpts, go_sstable.WithComparer(NewMvccComparer()))
+				kvs = generateKVWithSuffix(sampleSize, tc.isUnique)
+			} else {
+				kvs = generateKV(sampleSize, tc.isUnique)
+			}
+
+			writer := go_sstable.NewWriter(fileWritable, tc.version, writeOpts...)
+			for _, kv := range kvs {
+				err := writer.Set(kv.key, kv.value)
+				assert.NoError(t, err, "failed to set")
+			}
+			require.NoError(t, writer.Close())
+
+			lo, hi := sampleSize/4, sampleSize/2
+			iterOpts := []options.IteratorOptsFunc{
+				options.WithBounds(kvs[lo].key, kvs[hi].key),
+			}
+			if tc.version == common.TableV2 {
+				iterOpts = append(iterOpts, options.WithComparer(NewMvccComparer()))
+			}
+
+			fileReadable, _, err := inMemStorage.Open(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			iter, err := go_sstable.NewSingularIterator(
+				predictable_size.NewPredictablePool(),
+				fileReadable,
+				iterOpts...,
+			)
+			require.NoError(t, err)
+
+			defer func() {
+				err := iter.Close()
+				assert.NoError(t, err)
+			}()
+
+			validateBoundedScan(t, iter, kvs[lo:hi])
+
+			// the seeks are clamped within the bounds
+			assertKv(t, kvs[lo], iter.SeekGTE(kvs[0].key), lo)
+			assertKv(t, kvs[hi-1], iter.SeekLTE(kvs[len(kvs)-1].key), hi-1)
+			assert.Nil(t, iter.SeekGTE(kvs[hi].key))
+			assert.Nil(t, iter.SeekLTE(kvs[lo-1].key))
+
+			// re-bound the iterator without reopening it
+			lo, hi = sampleSize/3, 3*sampleSize/4
+			iter.SetBounds(kvs[lo].key, kvs[hi].key)
+			validateBoundedScan(t, iter, kvs[lo:hi])
+
+			// only the lower bound
+			iter.SetBounds(kvs[lo].key, nil)
+			validateBoundedScan(t, iter, kvs[lo:])
+
+			// only the upper bound
+			iter.SetBounds(nil, kvs[hi].key)
+			validateBoundedScan(t, iter, kvs[:hi])
+
+			// unbounded
+			iter.SetBounds(nil, nil)
+			validateBoundedScan(t, iter, kvs)
+		})
+	}
+}
+
+func (w *SSTSuite) Test_Iterator_Prefetch_Ops() {
+	type param struct {
+		name      string
+		version   common.TableVersion
+		cacheSize int // 0 means no cache
+		sema      *semaphore.Weighted
+	}
+
+	tests := []param{
+		{
+			name:    "row block, block cache disable",
+			version: common.TableV1,
+		},
+		{
+			name:      "row block, block cache enabled, bounded parallel loads",
+			version:   common.TableV1,
+			cacheSize: 1 * mB,
+			sema:      semaphore.NewWeighted(2),
+		},
+		{
+			name:    "MVCC col block, block cache disable, bounded parallel loads",
+			version: common.TableV2,
+			sema:    semaphore.NewWeighted(2),
+		},
+		{
+			name:      "MVCC col block, block cache enabled",
+			version:   common.TableV2,
+			cacheSize: 1 * mB,
+		},
+	}
+
+	sampleSize := 50_000
+
+	t := w.T()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init a table
+			inMemStorage := go_fs.NewInmemStorage()
+			fileWritable, _, err := inMemStorage.Create(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			writeOpts := []go_sstable.WriteOptFn{
+				go_sstable.WithBlockRestartInterval(5),
+				go_sstable.WithBlockSize(2 * kB),
+			}
+			iterOpts := []options.IteratorOptsFunc{
+				options.WithPrefetch(4, tc.sema),
+			}
+			var kvs []kvType
+			if tc.version == common.TableV2 {
+				mvccComparer := NewMvccComparer()
+				writeOpts = append(writeOpts, go_sstable.WithComparer(mvccComparer))
+				iterOpts = append(iterOpts, options.WithComparer(mvccComparer))
+				kvs = generateKVWithSuffix(sampleSize, false)
+			} else {
+				kvs = generateKV(sampleSize, false)
+			}
+
+			writer := go_sstable.NewWriter(fileWritable, tc.version, writeOpts...)
+			for _, kv := range kvs {
+				err := writer.Set(kv.key, kv.value)
+				assert.NoError(t, err, "failed to set")
+			}
+			require.NoError(t, writer.Close())
+
+			fileReadable, fd, err := inMemStorage.Open(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			if tc.cacheSize > 0 {
+				cache := go_block_cache.NewMap(
+					go_block_cache.WithMaxSize(int64(tc.cacheSize)),
+					go_block_cache.WithShardNum(4),
+				)
+				defer cache.Close()
+				iterOpts = append(iterOpts, options.WithBlockCache(cache, fd))
+			}
+			iter, err := go_sstable.NewSingularIterator(
+				predictable_size.NewPredictablePool(),
+				fileReadable,
+				iterOpts...,
+			)
+			require.NoError(t, err)
+
+			// a full forward scan
+			i := 0
+			for kv := iter.First(); kv != nil; kv = iter.Next() {
+				assertKv(t, kvs[i], kv, i)
+				i++
+			}
+			require.Equal(t, len(kvs), i)
+
+			// seeking in the middle of a scan cancels the outstanding prefetches,
+			// the scan then continues from the found key
+			for i := 0; i < len(kvs); i += 1 + rand.Intn(5_000) {
+				kv := iter.SeekGTE(kvs[i].key)
+				assertKv(t, kvs[i], kv, i)
+				for j := i + 1; j < min(i+500, len(kvs)); j++ {
+					kv = iter.Next()
+					assertKv(t, kvs[j], kv, j)
+				}
+			}
+
+			// closing with outstanding prefetches
+			kv := iter.First()
+			assertKv(t, kvs[0], kv, 0)
+			require.NoError(t, iter.Close())
+		})
+	}
+}
+
+var errInjectedRead = errors.New("injected read error")
+
+// failingReadable fails the reads overlapping [failFrom, failTo)
+type failingReadable struct {
+	go_fs.Readable
+	failFrom, failTo int64
+}
+
+func (r *failingReadable) ReadAt(p []byte, off int64) (int, error) {
+	if off < r.failTo && off+int64(len(p)) > r.failFrom {
+		return 0, errInjectedRead
+	}
+	return r.Readable.ReadAt(p, off)
+}
+
+func (w *SSTSuite) Test_Iterator_Read_Errors() {
+	for _, version := range []common.TableVersion{common.TableV1, common.TableV2} {
+		w.T().Run(fmt.Sprintf("table version %d", version), func(t *testing.T) {
+			inMemStorage := go_fs.NewInmemStorage()
+			fileWritable, _, err := inMemStorage.Create(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			writeOpts := []go_sstable.WriteOptFn{go_sstable.WithBlockSize(2 * kB)}
+			var iterOpts []options.IteratorOptsFunc
+			var kvs []kvType
+			if version == common.TableV2 {
+				mvccComparer := NewMvccComparer()
+				writeOpts = append(writeOpts, go_sstable.WithComparer(mvccComparer))
+				iterOpts = append(iterOpts, options.WithComparer(mvccComparer))
+				kvs = generateKVWithSuffix(5_000, false)
+			} else {
+				kvs = generateKV(5_000, false)
+			}
+
+			writer := go_sstable.NewWriter(fileWritable, version, writeOpts...)
+			for _, kv := range kvs {
+				require.NoError(t, writer.Set(kv.key, kv.value))
+			}
+			require.NoError(t, writer.Close())
+
+			fileReadable, _, err := inMemStorage.Open(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			// a data block in the middle of the table can't be read
+			failFrom := int64(fileReadable.Size() / 4)
+			readable := &failingReadable{Readable: fileReadable, failFrom: failFrom, failTo: failFrom + 1}
+			iter, err := go_sstable.NewSingularIterator(predictable_size.NewPredictablePool(), readable, iterOpts...)
+			require.NoError(t, err)
+
+			// the scan stops at the block, and reports the error
+			i := 0
+			for kv := iter.First(); kv != nil; kv = iter.Next() {
+				assertKv(t, kvs[i], kv, i)
+				i++
+			}
+			assert.Less(t, i, len(kvs))
+			assert.ErrorIs(t, iter.Error(), errInjectedRead)
+
+			// repositioning resets the error
+			assertKv(t, kvs[0], iter.SeekGTE(kvs[0].key), 0)
+			assert.NoError(t, iter.Error())
+			require.NoError(t, iter.Close())
+		})
+	}
+}
+
+// validateBoundedScan scans the iterator forward then backward, both must
+// return exactly the expected key/value pairs
+func validateBoundedScan(t *testing.T, iter go_sstable.IIterator, expected []kvType) {
+	i := 0
+	for kv := iter.First(); kv != nil; kv = iter.Next() {
+		require.Less(t, i, len(expected), "forward scan must not exceed the upper bound")
+		assertKv(t, expected[i], kv, i)
+		i++
+	}
+	require.Equal(t, len(expected), i, "forward scan must reach the upper bound")
+
+	i = len(expected) - 1
+	for kv := iter.Last(); kv != nil; kv = iter.Prev() {
+		require.GreaterOrEqual(t, i, 0, "backward scan must not exceed the lower bound")
+		assertKv(t, expected[i], kv, i)
+		i--
+	}
+	require.Equal(t, -1, i, "backward scan must reach the lower bound")
+}
+
+func validateSeekLTE(t *testing.T, iter nogodb_common.InternalIterator[nogodb_common.InternalKV], kvs []kvType, i int) {
+	// SeekLTE with an exact key
+	kv := iter.SeekLTE(kvs[i].key)
+	require.NotNil(t, kv, fmt.Sprintf("SeekLTE with an exact key must found, test case #%d", i))
+	assertKv(t, kvs[i], kv, i)
+
+	// SeekLTE with a key between the current and the next keys
+	if i+1 < len(kvs) {
+		k := generateBytes(kvs[i].key, kvs[i+1].key)
+		assert.Greater(t, bytes.Compare(k, kvs[i].key), 0, fmt.Sprintf("a test key must be > than the current key, test case #%d.", i))
+		assert.Less(t, bytes.Compare(k, kvs[i+1].key), 0, fmt.Sprintf("a test key must be < than the next key, test case #%d.", i))
+		kv = iter.SeekLTE(k)
+		require.NotNil(t, kv, fmt.Sprintf("SeekLTE with bigger key must found, test case #%d", i))
+		assertKv(t, kvs[i], kv, i)
+	}
+
+	// then moving backward and forward from the found position
+	if i > 0 {
+		kv = iter.Prev()
+		assertKv(t, kvs[i-1], kv, i-1)
+		kv = iter.Next()
+		assertKv(t, kvs[i], kv, i)
+	}
+}
+
+func (w *SSTSuite) Test_Iterator_Filter_Methods() {
+	type param struct {
+		name        string
+		version     common.TableVersion
+		method      filter.Method
+		bitsPerKey  int // 0 means the default
+		partitioned bool
+		cacheSize   int // 0 means no cache
+	}
+
+	tests := []param{
+		{
+			name:    "row block, bloom filter",
+			version: common.TableV1,
+			method:  filter.BloomFilter,
+		},
+		{
+			name:    "row block, ribbon filter",
+			version: common.TableV1,
+			method:  filter.RibbonFilter,
+		},
+		{
+			name:    "MVCC col block, bloom filter",
+			version: common.TableV2,
+			method:  filter.BloomFilter,
+		},
+		{
+			name:    "MVCC col block, ribbon filter",
+			version: common.TableV2,
+			method:  filter.RibbonFilter,
+		},
+		{
+			name:       "row block, bloom filter, 16 bits per key",
+			version:    common.TableV1,
+			method:     filter.BloomFilter,
+			bitsPerKey: 16,
+		},
+		{
+			name:       "MVCC col block, ribbon filter, 16 bits per key",
+			version:    common.TableV2,
+			method:     filter.RibbonFilter,
+			bitsPerKey: 16,
+		},
+		{
+			name:        "row block, partitioned bloom filter",
+			version:     common.TableV1,
+			method:      filter.BloomFilter,
+			partitioned: true,
+		},
+		{
+			name:        "row block, partitioned ribbon filter, block cache enabled",
+			version:     common.TableV1,
+			method:      filter.RibbonFilter,
+			partitioned: true,
+			cacheSize:   1 * mB,
+		},
+		{
+			name:        "MVCC col block, partitioned bloom filter, block cache enabled",
+			version:     common.TableV2,
+			method:      filter.BloomFilter,
+			partitioned: true,
+			cacheSize:   1 * mB,
+		},
+		{
+			name:        "MVCC col block, partitioned ribbon filter",
+			version:     common.TableV2,
+			method:      filter.RibbonFilter,
+			partitioned: true,
+		},
+	}
+
+	sampleSize := 20_000
+
+	t := w.T()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init a table
+			inMemStorage := go_fs.NewInmemStorage()
+			fileWritable, _, err := inMemStorage.Create(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			writeOpts := []go_sstable.WriteOptFn{
+				go_sstable.WithBlockRestartInterval(5),
+				go_sstable.WithBlockSize(2 * kB),
+				go_sstable.WithFilterMethod(tc.method),
+			}
+			if tc.bitsPerKey > 0 {
+				writeOpts = append(writeOpts, go_sstable.WithFilterBitsPerKey(tc.bitsPerKey))
+			}
+			if tc.partitioned {
+				writeOpts = append(writeOpts, go_sstable.WithPartitionedFilter())
+			}
+			iterOpts := []options.IteratorOptsFunc{}
+			var kvs []kvType
+			if tc.version == common.TableV2 {
+				mvccComparer := NewMvccComparer()
+				writeOpts = append(writeOpts, go_sstable.WithComparer(mvccComparer))
+				iterOpts = append(iterOpts, options.WithComparer(mvccComparer))
+				kvs = generateKVWithSuffix(sampleSize, true)
+			} else {
+				kvs = generateKV(sampleSize, true)
+			}
+
+			writer := go_sstable.NewWriter(fileWritable, tc.version, writeOpts...)
+			for _, kv := range kvs {
+				err := writer.Set(kv.key, kv.value)
+				assert.NoError(t, err, "failed to set")
+			}
+			require.NoError(t, writer.Close())
+
+			fileReadable, fd, err := inMemStorage.Open(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			if tc.cacheSize > 0 {
+				cache := go_block_cache.NewMap(
+					go_block_cache.WithMaxSize(int64(tc.cacheSize)),
+					go_block_cache.WithShardNum(4),
+				)
+				defer cache.Close()
+				iterOpts = append(iterOpts, options.WithBlockCache(cache, fd))
+			}
+			bpool := predictable_size.NewPredictablePool()
+			props, err := go_sstable.ReadProperties(bpool, fileReadable)
+			require.NoError(t, err)
+			assert.Equal(t, tc.method.String(), props.FilterPolicyName)
+
+			iter, err := go_sstable.NewSingularIterator(bpool, fileReadable, iterOpts...)
+			require.NoError(t, err)
+
+			defer func() {
+				err := iter.Close()
+				assert.NoError(t, err)
+			}()
+
+			// the filter doesn't get in the way of the scans
+			i := 0
+			for kv := iter.First(); kv != nil; kv = iter.Next() {
+				assertKv(t, kvs[i], kv, i)
+				i++
+			}
+			require.Equal(t, len(kvs), i)
+
+			// no false negative
+			for i, kv := range kvs {
+				found := iter.SeekPrefixGTE(kv.key, kv.key)
+				require.NotNil(t, found, fmt.Sprintf("SeekPrefixGTE with an exact key must found, test case #%d", i))
+				assertKv(t, kv, found, i)
+			}
+
+			// the absent keys are mostly filtered out, a key right after
+			// an existing one is absent since all the keys are unique
+			falsePositives := 0
+			for _, kv := range kvs[:len(kvs)-1] {
+				absent := slices.Concat(kv.key, []byte{0})
+				if tc.version == common.TableV2 {
+					absent = slices.Concat(kv.key[:len(kv.key)-suffixLen], []byte{0}, make([]byte, suffixLen))
+				}
+				if iter.SeekPrefixGTE(absent, absent) != nil {
+					falsePositives++
+				}
+			}
+			assert.Less(t, falsePositives, len(kvs)/20, "the false positive rate must be < 5%")
+			if tc.bitsPerKey >= 16 {
+				assert.Less(t, falsePositives, len(kvs)/100, "the false positive rate must be < 1%")
+			}
+		})
+	}
+}
+
+func (w *SSTSuite) Test_Iterator_Prefix_Extractor() {
+	type param struct {
+		name        string
+		version     common.TableVersion
+		method      filter.Method
+		partitioned bool
+	}
+
+	tests := []param{
+		{
+			name:    "row block, bloom filter",
+			version: common.TableV1,
+			method:  filter.BloomFilter,
+		},
+		{
+			name:        "row block, partitioned ribbon filter",
+			version:     common.TableV1,
+			method:      filter.RibbonFilter,
+			partitioned: true,
+		},
+		{
+			name:    "MVCC col block, ribbon filter",
+			version: common.TableV2,
+			method:  filter.RibbonFilter,
+		},
+		{
+			name:        "MVCC col block, partitioned bloom filter",
+			version:     common.TableV2,
+			method:      filter.BloomFilter,
+			partitioned: true,
+		},
+	}
+
+	// the rows of the even tables only, the prefixes are "table/partition/"
+	const numTables, numPartitions, numRows = 40, 10, 50
+	extractor := options.NewDelimitedPrefixExtractor('/', 2)
+	prefixOf := func(table, partition int) []byte {
+		return fmt.Appendf(nil, "t%03d/p%02d/", table, partition)
+	}
+
+	t := w.T()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// the keys and the seek keys carry the MVCC suffix in the col blocks
+			suffix := []byte{}
+			if tc.version == common.TableV2 {
+				suffix = make([]byte, suffixLen)
+			}
+
+			var kvs []kvType
+			for table := 0; table < numTables; table += 2 {
+				for partition := range numPartitions {
+					for row := range numRows {
+						key := slices.Concat(prefixOf(table, partition), fmt.Appendf(nil, "r%04d", row), suffix)
+						kvs = append(kvs, kvType{key, []byte(randomQuote())})
+					}
+				}
+			}
+
+			// Init a table
+			inMemStorage := go_fs.NewInmemStorage()
+			fileWritable, _, err := inMemStorage.Create(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			writeOpts := []go_sstable.WriteOptFn{
+				go_sstable.WithBlockRestartInterval(5),
+				go_sstable.WithBlockSize(1 * kB),
+				go_sstable.WithFilterMethod(tc.method),
+				go_sstable.WithPrefixExtractor(extractor),
+			}
+			if tc.partitioned {
+				writeOpts = append(writeOpts, go_sstable.WithPartitionedFilter())
+			}
+			iterOpts := []options.IteratorOptsFunc{}
+			if tc.version == common.TableV2 {
+				mvccComparer := NewMvccComparer()
+				writeOpts = append(writeOpts, go_sstable.WithComparer(mvccComparer))
+				iterOpts = append(iterOpts, options.WithComparer(mvccComparer))
+			}
+
+			writer := go_sstable.NewWriter(fileWritable, tc.version, writeOpts...)
+			for _, kv := range kvs {
+				err := writer.Set(kv.key, kv.value)
+				assert.NoError(t, err, "failed to set")
+			}
+			require.NoError(t, writer.Close())
+
+			fileReadable, _, err := inMemStorage.Open(nogodb_common.TypeTable, nogodb_common.DiskfileNum(1))
+			require.NoError(t, err)
+			bpool := predictable_size.NewPredictablePool()
+			props, err := go_sstable.ReadProperties(bpool, fileReadable)
+			require.NoError(t, err)
+			assert.Equal(t, extractor.Name(), props.PrefixExtractorName)
+
+			iter, err := go_sstable.NewSingularIterator(
+				bpool, fileReadable, append(iterOpts, options.WithPrefixExtractor(extractor))...,
+			)
+			require.NoError(t, err)
+
+			defer func() {
+				err := iter.Close()
+				assert.NoError(t, err)
+			}()
+
+			// the whole keys still match
+			for i, kv := range kvs {
+				found := iter.SeekPrefixGTE(kv.key, kv.key)
+				require.NotNil(t, found, fmt.Sprintf("SeekPrefixGTE with an exact key must found, test case #%d", i))
+				assertKv(t, kv, found, i)
+			}
+
+			// the prefix scans of the present prefixes start at their first row,
+			// the ones of the absent tables are mostly filtered out
+			falsePositives := 0
+			for table := range numTables {
+				for partition := range numPartitions {
+					seekKey := slices.Concat(prefixOf(table, partition), suffix)
+					found := iter.SeekPrefixGTE(seekKey, seekKey)
+					if table%2 == 1 {
+						if found != nil {
+							falsePositives++
+						}
+						continue
+					}
+
+					i := (table/2*numPartitions + partition) * numRows
+					require.NotNil(t, found, fmt.Sprintf("SeekPrefixGTE with a present prefix must found, table %d partition %d", table, partition))
+					assertKv(t, kvs[i], found, i)
+				}
+			}
+			assert.Less(t, falsePositives, numTables*numPartitions/2/20, "the false positive rate must be < 5%")
+
+			// the tables written with another extractor aren't filtered
+			otherIter, err := go_sstable.NewSingularIterator(
+				bpool, fileReadable, append(iterOpts, options.WithPrefixExtractor(options.NewFixedPrefixExtractor(5)))...,
+			)
+			require.NoError(t, err)
+			defer func() {
+				err := otherIter.Close()
+				assert.NoError(t, err)
+			}()
+
+			seekKey := slices.Concat(prefixOf(1, 0), suffix)
+			found := otherIter.SeekPrefixGTE(seekKey, seekKey)
+			require.NotNil(t, found, "SeekPrefixGTE must fall back to SeekGTE")
+			assertKv(t, kvs[numPartitions*numRows], found, numPartitions*numRows)
+		})
+	}
+}
+
 func TestSSTSuite(t *testing.T) {
 	suite.Run(t, new(SSTSuite))
 }
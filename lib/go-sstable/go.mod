@@ -4,6 +4,8 @@ go 1.26.0
 
 replace github.com/datnguyenzzz/nogodb/lib/go-blocked-bloom-filter => ../go-blocked-bloom-filter
 
+replace github.com/datnguyenzzz/nogodb/lib/go-ribbon-filter => ../go-ribbon-filter
+
 replace github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool => ../go-bytesbufferpool
 
 replace github.com/datnguyenzzz/nogodb/lib/go-fs => ../go-fs
@@ -18,6 +20,7 @@ require (
 	github.com/datnguyenzzz/nogodb/lib/go-blocked-bloom-filter v0.0.0-00010101000000-000000000000
 	github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool v0.0.0-20250609152930-352a93d7ed86
 	github.com/datnguyenzzz/nogodb/lib/go-fs v0.0.0-00010101000000-000000000000
+	github.com/datnguyenzzz/nogodb/lib/go-ribbon-filter v0.0.0-00010101000000-000000000000
 	github.com/go-faker/faker/v4 v4.7.0
 	github.com/stretchr/testify v1.11.1
 	go.uber.org/zap v1.27.0
@@ -1,5 +1,7 @@
 package go_sstable
 
+import nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+
 // IWriter represent an interface of writer for downstream client to the SSTable
 // The data written layout is controlled internally, which caller of this function
 // shouldn't worry much about it
@@ -8,8 +10,25 @@ type IWriter interface {
 	Set(key, value []byte) error
 	// Delete a key within a table
 	Delete(key []byte) error
+	// Add appends an internal key/value pair to the table, keeping its seqnum
+	// and kind. The keys must be added in the internal key order, i.e. the
+	// versions of a user key from the newest one.
+	Add(key nogodb_common.InternalKey, value []byte) error
 	// Close will finalize the table. Calling Append is not possible after Close
 	Close() error
 	// TODO(med): support merge operation (read-modify-write loop)
 	// TODO(med): support range query (delete, ...)
 }
+
+// IIterator represent an iterator over the key/value pairs of the SSTable
+type IIterator interface {
+	nogodb_common.InternalIterator[nogodb_common.InternalKV]
+	// SetBounds re-bounds the iterator within [lower, upper) without reopening
+	// it, a nil bound is unbounded. The iterator must then be repositioned by
+	// a seek, First or Last.
+	SetBounds(lower, upper []byte)
+	// Error returns the error which has exhausted the iterator early, e.g. a
+	// block which failed to be read or decoded, it is nil if the iterator is
+	// exhausted, or positioned. It is reset by a seek, First or Last.
+	Error() error
+}
@@ -4,6 +4,7 @@ import (
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	"github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool/predictable_size"
 	go_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/iterators"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
 )
@@ -15,7 +16,7 @@ func NewSingularIterator(
 	bpool *predictable_size.PredictablePool, // shared buffer pool across iterator
 	r go_fs.Readable,
 	optFuncs ...options.IteratorOptsFunc,
-) (nogodb_common.InternalIterator[nogodb_common.InternalKV], error) {
+) (IIterator, error) {
 	o := &options.IteratorOpts{
 		Comparer: nogodb_common.NewComparer(),
 	} // default is no cache
@@ -25,3 +26,12 @@ func NewSingularIterator(
 	}
 	return iterators.NewIterator(bpool, r, o.Comparer, o)
 }
+
+// ReadProperties returns the table properties of the SSTable, see
+// common.TableProperties. The readable is left open.
+func ReadProperties(
+	bpool *predictable_size.PredictablePool,
+	r go_fs.Readable,
+) (*common.TableProperties, error) {
+	return iterators.ReadProperties(bpool, r)
+}
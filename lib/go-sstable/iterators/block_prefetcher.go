@@ -0,0 +1,177 @@
+package iterators
+
+import (
+	"context"
+	"sync"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/block/row_block"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"golang.org/x/sync/semaphore"
+)
+
+// prefetchedBlock is a data block read in background, the value is owned by
+// the prefetcher until it's taken
+type prefetchedBlock struct {
+	done chan struct{}
+	val  *nogodb_common.InternalLazyValue
+	err  error
+	// dropped is set once the block is dropped while still being read, the
+	// value is then released as soon as it's read. Guarded by the
+	// prefetcher's mu.
+	dropped bool
+}
+
+// blockPrefetcher reads the data blocks in background goroutines, ahead of a
+// forward scan, and hands them over to the data block iterator. The reads go
+// through the block cache, if any.
+type blockPrefetcher struct {
+	reader row_block.IBlockReader
+	// sema bounds the number of blocks loaded in parallel, across the iterators
+	sema *semaphore.Weighted
+	// blocks is the number of data blocks prefetched ahead of the current one
+	blocks int
+
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	pending map[uint64]*prefetchedBlock // by the block offset
+	// lastOffset is the offset of the last prefetched block, if hasLast. The
+	// next blocks are prefetched once the scan reaches it.
+	lastOffset uint64
+	hasLast    bool
+}
+
+func newBlockPrefetcher(reader row_block.IBlockReader, blocks int, sema *semaphore.Weighted) *blockPrefetcher {
+	p := &blockPrefetcher{
+		reader:  reader,
+		sema:    sema,
+		blocks:  blocks,
+		pending: make(map[uint64]*prefetchedBlock, blocks),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	return p
+}
+
+// prefetch starts reading the given data blocks, unless they're already pending
+func (p *blockPrefetcher) prefetch(bhs []common.BlockHandle) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, bh := range bhs {
+		p.lastOffset, p.hasLast = bh.Offset, true
+		if _, ok := p.pending[bh.Offset]; ok {
+			continue
+		}
+
+		pb := &prefetchedBlock{done: make(chan struct{})}
+		p.pending[bh.Offset] = pb
+		p.wg.Add(1)
+		go p.load(p.ctx, bh, pb)
+	}
+}
+
+// isAhead returns true if the blocks past the one at the given offset are
+// already prefetched, i.e. the scan hasn't reached the last prefetched block
+func (p *blockPrefetcher) isAhead(offset uint64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hasLast && offset < p.lastOffset
+}
+
+func (p *blockPrefetcher) load(ctx context.Context, bh common.BlockHandle, pb *prefetchedBlock) {
+	defer p.wg.Done()
+
+	var val *nogodb_common.InternalLazyValue
+	var err error
+	defer func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		pb.val, pb.err = val, err
+		if pb.dropped && err == nil {
+			val.Release()
+		}
+		close(pb.done)
+	}()
+
+	if p.sema != nil {
+		if err = p.sema.Acquire(ctx, 1); err != nil {
+			return
+		}
+		defer p.sema.Release(1)
+	}
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	val, err = p.reader.ReadThroughCache(&bh, nogodb_common.BlockKindData)
+}
+
+// take hands over the prefetched data block, waiting for it if it's still
+// being read. It returns false if the block hasn't been prefetched, or failed
+// to, the caller then reads it itself. The blocks before it, which the
+// forward scan has passed (e.g. filtered out), are dropped.
+func (p *blockPrefetcher) take(bh *common.BlockHandle) (*nogodb_common.InternalLazyValue, bool) {
+	p.mu.Lock()
+	pb, ok := p.pending[bh.Offset]
+	delete(p.pending, bh.Offset)
+	for offset, passed := range p.pending {
+		if offset < bh.Offset {
+			p.drop(offset, passed)
+		}
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	<-pb.done
+	if pb.err != nil {
+		return nil, false
+	}
+
+	return pb.val, true
+}
+
+// drop releases the pending block, or lets its load release it once read.
+// p.mu must be held when calling this.
+func (p *blockPrefetcher) drop(offset uint64, pb *prefetchedBlock) {
+	delete(p.pending, offset)
+	select {
+	case <-pb.done:
+		if pb.err == nil {
+			pb.val.Release()
+		}
+	default:
+		pb.dropped = true
+	}
+}
+
+// reset cancels the outstanding prefetches and releases the blocks which
+// haven't been taken
+func (p *blockPrefetcher) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.hasLast = false
+	if len(p.pending) == 0 {
+		return
+	}
+
+	p.cancel()
+	for offset, pb := range p.pending {
+		p.drop(offset, pb)
+	}
+
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+}
+
+// close cancels the outstanding prefetches and waits for them, it must be
+// called before the block reader is released
+func (p *blockPrefetcher) close() {
+	p.reset()
+	p.cancel()
+	p.wg.Wait()
+}
@@ -0,0 +1,125 @@
+package iterators
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	rowBlockMocks "github.com/datnguyenzzz/nogodb/lib/go-sstable/block/row_block/mocks"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// releaseCounter counts the released blocks, by their offset
+type releaseCounter struct {
+	mu       sync.Mutex
+	released map[uint64]int
+}
+
+func (c *releaseCounter) count(offset uint64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.released[offset]
+}
+
+type countingFetcher struct {
+	counter *releaseCounter
+	offset  uint64
+}
+
+func (f *countingFetcher) Load() []byte { return nil }
+
+func (f *countingFetcher) Release() {
+	f.counter.mu.Lock()
+	defer f.counter.mu.Unlock()
+	f.counter.released[f.offset]++
+}
+
+// newTestPrefetcher returns a prefetcher whose reads of the blocks in gated
+// wait until their channel is closed
+func newTestPrefetcher(t *testing.T, gated map[uint64]chan struct{}) (*blockPrefetcher, *releaseCounter) {
+	counter := &releaseCounter{released: make(map[uint64]int)}
+	reader := rowBlockMocks.NewMockIBlockReader(t)
+	reader.EXPECT().ReadThroughCache(mock.Anything, nogodb_common.BlockKindData).RunAndReturn(
+		func(bh *common.BlockHandle, _ nogodb_common.BlockKind) (*nogodb_common.InternalLazyValue, error) {
+			if gate, ok := gated[bh.Offset]; ok {
+				<-gate
+			}
+			return &nogodb_common.InternalLazyValue{
+				ValueSource:  nogodb_common.ValueFromCache,
+				CacheFetcher: &countingFetcher{counter: counter, offset: bh.Offset},
+			}, nil
+		},
+	).Maybe()
+
+	return newBlockPrefetcher(reader, 4, nil), counter
+}
+
+func blockHandles(offsets ...uint64) []common.BlockHandle {
+	bhs := make([]common.BlockHandle, 0, len(offsets))
+	for _, offset := range offsets {
+		bhs = append(bhs, common.BlockHandle{Offset: offset, Length: 10})
+	}
+	return bhs
+}
+
+func Test_BlockPrefetcher_Drops_The_Passed_Blocks(t *testing.T) {
+	gate := make(chan struct{})
+	p, counter := newTestPrefetcher(t, map[uint64]chan struct{}{10: gate})
+	bhs := blockHandles(0, 10, 20, 30)
+	p.prefetch(bhs)
+
+	// the scan has passed the blocks 0 and 10, e.g. filtered out
+	val, ok := p.take(&bhs[2])
+	require.True(t, ok)
+	assert.Len(t, p.pending, 1)
+	require.Eventually(t, func() bool { return counter.count(0) == 1 }, time.Second, time.Millisecond)
+
+	// the block still being read is released once read
+	assert.Zero(t, counter.count(10))
+	close(gate)
+	require.Eventually(t, func() bool { return counter.count(10) == 1 }, time.Second, time.Millisecond)
+
+	// the taken block is owned by the caller
+	assert.Zero(t, counter.count(20))
+	val.Release()
+
+	// the blocks which haven't been taken are released on close
+	p.close()
+	assert.Empty(t, p.pending)
+	for _, bh := range bhs {
+		assert.Equal(t, 1, counter.count(bh.Offset), "block %d", bh.Offset)
+	}
+}
+
+func Test_BlockPrefetcher_Is_Ahead(t *testing.T) {
+	p, counter := newTestPrefetcher(t, nil)
+	assert.False(t, p.isAhead(0), "nothing prefetched yet")
+
+	p.prefetch(blockHandles(10, 20, 30))
+	tests := []struct {
+		offset uint64
+		want   bool
+	}{
+		{offset: 0, want: true},
+		{offset: 20, want: true},
+		{offset: 30, want: false},
+		{offset: 40, want: false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, p.isAhead(tc.offset), "offset %d", tc.offset)
+	}
+
+	// a repositioned scan prefetches again
+	p.reset()
+	assert.False(t, p.isAhead(0))
+	assert.Empty(t, p.pending)
+
+	p.close()
+	for _, offset := range []uint64{10, 20, 30} {
+		assert.LessOrEqual(t, counter.count(offset), 1, "block %d", offset)
+	}
+}
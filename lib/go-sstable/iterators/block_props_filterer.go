@@ -0,0 +1,75 @@
+package iterators
+
+import (
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/block"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
+)
+
+// blockPropsFilterer evaluates the block property filters against the
+// encoded properties of the index entries, see block.BlockPropsCollector
+type blockPropsFilterer struct {
+	numCollectors int
+	filters       []indexedFilter
+}
+
+type indexedFilter struct {
+	// index of the collector of the same name within the table
+	index  int
+	filter options.BlockPropertyFilter
+}
+
+// newBlockPropsFilterer returns the filterer of the given filters, along with
+// whether the table properties match them. The filters whose collector is not
+// registered in the table are ignored. The filterer is nil if none is left.
+func newBlockPropsFilterer(
+	filters []options.BlockPropertyFilter,
+	tableProps map[string]common.UserProperty,
+) (*blockPropsFilterer, bool, error) {
+	f := &blockPropsFilterer{numCollectors: len(tableProps)}
+	for _, filter := range filters {
+		tp, ok := tableProps[filter.Name()]
+		if !ok {
+			continue
+		}
+		if tp.Index >= len(tableProps) {
+			return nil, false, options.ErrInvalidBlockProperty
+		}
+
+		matched, err := filter.Intersects(tp.Prop)
+		if err != nil {
+			return nil, false, err
+		}
+		if !matched {
+			return nil, false, nil
+		}
+
+		f.filters = append(f.filters, indexedFilter{index: tp.Index, filter: filter})
+	}
+
+	if len(f.filters) == 0 {
+		return nil, true, nil
+	}
+
+	return f, true, nil
+}
+
+// intersects returns false if the block of the encoded properties can't match
+func (f *blockPropsFilterer) intersects(encoded []byte) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+
+	props, err := block.DecodeBlockProps(encoded, f.numCollectors)
+	if err != nil {
+		return false, err
+	}
+
+	for _, ifl := range f.filters {
+		if matched, err := ifl.filter.Intersects(props[ifl.index]); err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -3,6 +3,7 @@ package iterators
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
@@ -28,6 +29,8 @@ type indexedIterator struct {
 	bpool     *predictable_size.PredictablePool
 	index     *nogodb_common.InternalKV
 	blockKind nogodb_common.BlockKind
+	// prefetcher hands over the blocks read ahead, if any
+	prefetcher *blockPrefetcher
 }
 
 func newIndexedIterator(
@@ -75,6 +78,13 @@ func (ii *indexedIterator) loadedIter() error {
 		zap.L().Error("failed to fully decode the index")
 		return fmt.Errorf("%w: failed to fully decode the index", common.InternalServerError)
 	}
+	if ii.prefetcher != nil {
+		if block, ok := ii.prefetcher.take(bh); ok {
+			ii.InternalIterator = getBlockIter(ii.ver, ii.blockKind, ii.bpool, ii.cmp, block)
+			return nil
+		}
+	}
+
 	block, err := ii.reader.ReadThroughCache(bh, ii.blockKind)
 	if err != nil {
 		zap.L().Error("failed to read the block", zap.Error(err))
@@ -117,8 +127,32 @@ type DataIterator struct {
 
 	bpool *predictable_size.PredictablePool
 	// filter
-	filterBH *common.BlockHandle
-	filter   filter.IRead
+	filterBH     *common.BlockHandle
+	filterMethod filter.Method
+	filter       filter.IRead
+	// the partitioned filters, if any, are probed instead of filter
+	filterIndexBH     *common.BlockHandle
+	partitionedFilter *partitionedFilter
+	// prefixExtractor extracts the prefixes probed by SeekPrefixGTE, see
+	// options.IteratorOpts.PrefixExtractor. prefixFiltered is unset if the
+	// filter doesn't hold the prefixes of the same extractor, the prefix
+	// scans then aren't filtered
+	prefixExtractor options.PrefixExtractor
+	prefixFiltered  bool
+
+	// block properties
+	propsBH       *common.BlockHandle
+	propsFilterer *blockPropsFilterer
+	// tableExcluded is set when the table properties don't match the
+	// block property filters, the iterator is then always exhausted
+	tableExcluded bool
+
+	// the user keys are bounded within [lower, upper), see options.IteratorOpts
+	lower, upper []byte
+
+	// prefetcher reads the next data blocks ahead of a forward scan, it's nil
+	// if the prefetching is disabled
+	prefetcher *blockPrefetcher
 
 	// the 2nd level index iterator do
 	secondLevelIndexBH   *common.BlockHandle
@@ -130,6 +164,9 @@ type DataIterator struct {
 	firstLevelIndexedIter *indexedIterator
 	// Index: firstLevelIndex , Iter: dataBlockIter
 	dataIndexedIter *indexedIterator
+
+	// err is the error which has exhausted the iterator early, see Error
+	err error
 }
 
 var dataBlockIteratorPool = sync.Pool{
@@ -143,30 +180,66 @@ var dataBlockIteratorPool = sync.Pool{
 // it did not fail bloom filter matching.
 //
 // key []byte is a full user key, aka internalKey.UserKey
+//
+// With a prefix extractor, the filter is probed with the extracted prefix of
+// the prefix instead, e.g. to skip the table on a scan of all the rows of a
+// table, see options.IteratorOpts.PrefixExtractor
 func (i *DataIterator) SeekPrefixGTE(prefix, key []byte) *nogodb_common.InternalKV {
+	i.err = nil
 	// Refer to the col_block.writer, we only write UserKey[:prefix]
 	// to the filter, without the MVCC suffix
 	prefix = prefix[:i.cmp.Split(prefix)]
-	if !i.filter.MayContain(prefix) {
+	if i.prefixExtractor != nil {
+		if !i.prefixFiltered || !i.prefixExtractor.InDomain(prefix) {
+			// the prefix might not be in the filter, e.g. if it isn't a
+			// whole key, hence it can't be probed
+			return i.SeekGTE(key)
+		}
+		prefix = i.prefixExtractor.Extract(prefix)
+	}
+
+	if !i.mayContain(prefix, key) {
 		// don't invalidate the indexes and data block, the other iterator might still read it
 		return nil
 	}
 	return i.SeekGTE(key)
 }
 
+func (i *DataIterator) mayContain(prefix, key []byte) bool {
+	if i.partitionedFilter != nil {
+		return i.partitionedFilter.MayContain(prefix, key)
+	}
+	return i.filter.MayContain(prefix)
+}
+
 // key []byte is a full user key, aka internalKey.UserKey
 func (i *DataIterator) SeekGTE(key []byte) *nogodb_common.InternalKV {
 	// Important notes:
 	//  - Ensure the data (lazyValue) is released properly once the block is no longer used
+	i.err = nil
+	if i.tableExcluded {
+		return nil
+	}
+
+	i.resetPrefetch()
+	if i.precedesLower(key) {
+		key = i.lower
+	}
+
+	if i.exceedsUpper(key) {
+		return nil
+	}
+
 	new2ndIndex := i.secondLevelIndexIter.SeekGTE(key)
 	if new2ndIndex == nil {
 		zap.L().Warn("the target key is not in the block")
 		return nil
 	}
 
-	err := i.firstLevelIndexedIter.SetIndexAndLoad(new2ndIndex)
-	if err != nil {
-		zap.L().Error("failed to load the first level index", zap.Error(err))
+	// if the block of the found index is filtered out, all the keys of the
+	// next matched blocks are > key, seeking the key within them lands on
+	// their first key
+	if !i.loadFirstLevelIndexForward(new2ndIndex) {
 		return nil
 	}
 
@@ -175,52 +248,99 @@ func (i *DataIterator) SeekGTE(key []byte) *nogodb_common.InternalKV {
 		panic("impossible, the 1st must be found")
 	}
 
-	err = i.dataIndexedIter.SetIndexAndLoad(new1stIndex)
-	if err != nil {
-		zap.L().Error("failed to load the data block", zap.Error(err))
+	if !i.loadDataBlockForward(new1stIndex) {
 		return nil
 	}
 
-	return i.dataIndexedIter.SeekGTE(key)
+	if kv := i.dataIndexedIter.SeekGTE(key); kv != nil {
+		return i.boundedForward(kv)
+	}
+
+	// the key is between the last key of the block and its index key, hence
+	// the next key is the first key of the next (matched) block
+	if !i.loadNextDataBlock() {
+		return nil
+	}
+
+	return i.boundedForward(i.dataIndexedIter.First())
 }
 
+// SeekLTE moves the iterator to the last key/value pair whose user key ≤ key.
+// The index keys are the upper bounds of the blocks, so it seeks the first
+// key > key by the indexes, then steps back.
+//
 // key []byte is a full user key, aka internalKey.UserKey
 func (i *DataIterator) SeekLTE(key []byte) *nogodb_common.InternalKV {
-	// TODO implement me
-	panic("implement me")
-}
+	i.err = nil
+	if i.tableExcluded || i.precedesLower(key) {
+		return nil
+	}
 
-func (i *DataIterator) First() *nogodb_common.InternalKV {
-	first2ndIndex := i.secondLevelIndexIter.First()
-	if first2ndIndex == nil {
-		panic("impossible, the first must be found on the 2nd level index")
+	i.resetPrefetch()
+	if i.exceedsUpper(key) {
+		return i.seekLT(i.upper)
 	}
 
-	err := i.firstLevelIndexedIter.SetIndexAndLoad(first2ndIndex)
-	if err != nil {
-		zap.L().Error("failed to load the first level index", zap.Error(err))
+	kv := i.SeekGTE(key)
+	// skip all the versions of the key, they might span over several blocks
+	for kv != nil && i.cmp.Compare(kv.K.UserKey, key) == 0 {
+		kv.V.Release()
+		kv = i.Next()
+	}
+
+	if kv == nil {
+		// all the keys within the bounds are ≤ key
+		return i.Last()
+	}
+
+	kv.V.Release()
+	return i.Prev()
+}
+
+func (i *DataIterator) First() *nogodb_common.InternalKV {
+	i.err = nil
+	if i.tableExcluded {
 		return nil
 	}
 
-	first1stIndex := i.firstLevelIndexedIter.First()
-	if first1stIndex == nil {
-		panic("impossible, the first must be found on the 1st level index")
+	if i.lower != nil {
+		return i.SeekGTE(i.lower)
 	}
-	err = i.dataIndexedIter.SetIndexAndLoad(first1stIndex)
-	if err != nil {
-		zap.L().Error("failed to load the data block", zap.Error(err))
+
+	i.resetPrefetch()
+	if !i.loadFirstLevelIndexForward(i.secondLevelIndexIter.First()) ||
+		!i.loadDataBlockForward(i.firstLevelIndexedIter.First()) {
 		return nil
 	}
 
-	return i.dataIndexedIter.First()
+	i.prefetchNextBlocks()
+	return i.boundedForward(i.dataIndexedIter.First())
 }
 
 func (i *DataIterator) Last() *nogodb_common.InternalKV {
-	// TODO implement me
-	panic("implement me")
+	i.err = nil
+	if i.tableExcluded {
+		return nil
+	}
+
+	i.resetPrefetch()
+	if i.upper != nil {
+		return i.seekLT(i.upper)
+	}
+
+	if !i.loadFirstLevelIndexBackward(i.secondLevelIndexIter.Last()) ||
+		!i.loadDataBlockBackward(i.firstLevelIndexedIter.Last()) {
+		return nil
+	}
+
+	return i.boundedBackward(i.dataIndexedIter.Last())
 }
 
 func (i *DataIterator) Next() *nogodb_common.InternalKV {
+	if i.tableExcluded {
+		return nil
+	}
+
 	if i.firstLevelIndexedIter == nil || i.dataIndexedIter == nil {
 		zap.L().Error("the firstLevelIndexedIter and dataIndexedIter is nil. First will be returned")
 		return i.First()
@@ -228,48 +348,57 @@ func (i *DataIterator) Next() *nogodb_common.InternalKV {
 
 	nextKv := i.dataIndexedIter.Next()
 	if nextKv != nil {
-		return nextKv
+		return i.boundedForward(nextKv)
 	}
 
-	// the current data block is at the end, moving to the next block by the 1st index
-	next1stIndex := i.firstLevelIndexedIter.Next()
-	if next1stIndex != nil {
-		err := i.dataIndexedIter.SetIndexAndLoad(next1stIndex)
-		if err != nil {
-			zap.L().Error("failed to load the data block", zap.Error(err))
-			return nil
-		}
-
-		return i.dataIndexedIter.First()
+	// the current data block is at the end, moving to the next (matched) block
+	// by the 1st index, or by the next 2nd index if the 1st one is at the end
+	if !i.loadNextDataBlock() {
+		return nil
 	}
 
-	// the current 1st level index is at the end, moving to the next 2nd index block
-	next2ndIndex := i.secondLevelIndexIter.Next()
-	if next2ndIndex == nil {
+	i.prefetchNextBlocks()
+	return i.boundedForward(i.dataIndexedIter.First())
+}
+
+func (i *DataIterator) Prev() *nogodb_common.InternalKV {
+	if i.tableExcluded {
 		return nil
 	}
 
-	err := i.firstLevelIndexedIter.SetIndexAndLoad(next2ndIndex)
-	if err != nil {
-		zap.L().Error("failed to load the first level index", zap.Error(err))
-		return nil
+	if i.firstLevelIndexedIter == nil || i.dataIndexedIter == nil {
+		zap.L().Error("the firstLevelIndexedIter and dataIndexedIter is nil. Last will be returned")
+		return i.Last()
 	}
-	err = i.dataIndexedIter.SetIndexAndLoad(i.firstLevelIndexedIter.First())
-	if err != nil {
-		zap.L().Error("failed to load the data block", zap.Error(err))
+
+	prevKv := i.dataIndexedIter.Prev()
+	if prevKv != nil {
+		return i.boundedBackward(prevKv)
+	}
+
+	// the current data block is at the beginning, moving to the previous (matched)
+	// block by the 1st index, or by the previous 2nd index if the 1st one is at
+	// the beginning
+	if !i.loadDataBlockBackward(i.firstLevelIndexedIter.Prev()) {
 		return nil
 	}
 
-	return i.dataIndexedIter.First()
+	return i.boundedBackward(i.dataIndexedIter.Last())
 }
 
-func (i *DataIterator) Prev() *nogodb_common.InternalKV {
-	// TODO implement me
-	panic("implement me")
+// SetBounds re-bounds the iterator within [lower, upper), a nil bound is
+// unbounded. The iterator must then be repositioned by a seek, First or Last.
+// The loaded blocks are kept, a re-bounded scan over them doesn't read them again.
+func (i *DataIterator) SetBounds(lower, upper []byte) {
+	i.lower, i.upper = lower, upper
 }
 
 func (i *DataIterator) Close() error {
 	var err error
+	if i.prefetcher != nil {
+		i.prefetcher.close()
+		i.prefetcher = nil
+	}
 	if i.secondLevelIndexIter != nil {
 		err = errors.Join(i.secondLevelIndexIter.Close())
 	}
@@ -279,6 +408,10 @@ func (i *DataIterator) Close() error {
 	if i.dataIndexedIter != nil {
 		err = errors.Join(i.dataIndexedIter.Close())
 	}
+	if i.partitionedFilter != nil {
+		err = errors.Join(i.partitionedFilter.Close())
+		i.partitionedFilter = nil
+	}
 	i.blockReader.Release()
 	dataBlockIteratorPool.Put(i)
 	i.secondLevelIndexIter = nil
@@ -287,10 +420,295 @@ func (i *DataIterator) Close() error {
 	return err
 }
 
+// Error returns the error which has exhausted the iterator early, e.g. a block
+// which failed to be read or decoded. It is reset once the iterator is
+// repositioned by a seek, First or Last.
+func (i *DataIterator) Error() error {
+	return i.err
+}
+
 func (i *DataIterator) IsClosed() bool {
 	return i.secondLevelIndexIter == nil || i.secondLevelIndexIter.IsClosed()
 }
 
+// seekLT moves the iterator to the last key/value pair whose user key < key,
+// it seeks the first key ≥ key by the indexes, then steps back
+func (i *DataIterator) seekLT(key []byte) *nogodb_common.InternalKV {
+	new2ndIndex := i.secondLevelIndexIter.SeekGTE(key)
+	if new2ndIndex == nil {
+		// all the keys are < key
+		if !i.loadFirstLevelIndexBackward(i.secondLevelIndexIter.Last()) ||
+			!i.loadDataBlockBackward(i.firstLevelIndexedIter.Last()) {
+			return nil
+		}
+
+		return i.boundedBackward(i.dataIndexedIter.Last())
+	}
+
+	if !i.loadFirstLevelIndexBackward(new2ndIndex) {
+		return nil
+	}
+
+	new1stIndex := i.firstLevelIndexedIter.SeekGTE(key)
+	if new1stIndex == nil {
+		// the block of the found 2nd index is filtered out, all the keys of
+		// the loaded previous one are < key
+		new1stIndex = i.firstLevelIndexedIter.Last()
+	}
+
+	if !i.loadDataBlockBackward(new1stIndex) {
+		return nil
+	}
+
+	kv := i.dataIndexedIter.SeekGTE(key)
+	if kv == nil {
+		return i.boundedBackward(i.dataIndexedIter.Last())
+	}
+
+	kv.V.Release()
+	return i.Prev()
+}
+
+// prefetchNextBlocks prefetches the (matched) data blocks of the next entries
+// of the loaded 1st level index block, then moves the 1st level index back.
+// The index is only walked once the scan reaches the last prefetched block.
+func (i *DataIterator) prefetchNextBlocks() {
+	if i.prefetcher == nil {
+		return
+	}
+
+	current := common.BlockHandle{}
+	if n := current.DecodeFrom(i.dataIndexedIter.index.V.Value()); n > 0 && i.prefetcher.isAhead(current.Offset) {
+		return
+	}
+
+	bhs := make([]common.BlockHandle, 0, i.prefetcher.blocks)
+	moved := 0
+	for len(bhs) < i.prefetcher.blocks {
+		index := i.firstLevelIndexedIter.Next()
+		if index == nil {
+			break
+		}
+		moved++
+
+		if i.intersects(index) {
+			bh := common.BlockHandle{}
+			if n := bh.DecodeFrom(index.V.Value()); n > 0 {
+				bhs = append(bhs, bh)
+			}
+		}
+
+		// the block exceeding the upper bound might still have a few keys
+		// within the bounds, but not the next ones
+		exceeded := i.exceedsUpper(index.K.UserKey)
+		index.V.Release()
+		if exceeded {
+			break
+		}
+	}
+
+	for ; moved > 0; moved-- {
+		if index := i.firstLevelIndexedIter.Prev(); index != nil {
+			index.V.Release()
+		}
+	}
+
+	i.prefetcher.prefetch(bhs)
+}
+
+// resetPrefetch cancels the outstanding prefetches, the iterator is repositioned
+func (i *DataIterator) resetPrefetch() {
+	if i.prefetcher != nil {
+		i.prefetcher.reset()
+	}
+}
+
+// exceedsUpper returns true if the key is ≥ the upper bound
+func (i *DataIterator) exceedsUpper(key []byte) bool {
+	return i.upper != nil && i.cmp.Compare(key, i.upper) >= 0
+}
+
+// precedesLower returns true if the key is < the lower bound
+func (i *DataIterator) precedesLower(key []byte) bool {
+	return i.lower != nil && i.cmp.Compare(key, i.lower) < 0
+}
+
+// boundedForward returns nil once a forward move passes the upper bound
+func (i *DataIterator) boundedForward(kv *nogodb_common.InternalKV) *nogodb_common.InternalKV {
+	if kv != nil && i.exceedsUpper(kv.K.UserKey) {
+		kv.V.Release()
+		return nil
+	}
+
+	return kv
+}
+
+// boundedBackward returns nil once a backward move passes the lower bound
+func (i *DataIterator) boundedBackward(kv *nogodb_common.InternalKV) *nogodb_common.InternalKV {
+	if kv != nil && i.precedesLower(kv.K.UserKey) {
+		kv.V.Release()
+		return nil
+	}
+
+	return kv
+}
+
+// loadNextDataBlock loads the next (matched) data block, unless all the keys
+// of the next blocks, which are > the index key of the current one, exceed
+// the upper bound
+func (i *DataIterator) loadNextDataBlock() bool {
+	if index := i.dataIndexedIter.index; index != nil && i.exceedsUpper(index.K.UserKey) {
+		return false
+	}
+
+	return i.loadDataBlockForward(i.firstLevelIndexedIter.Next())
+}
+
+// loadFirstLevelIndexForward loads the 1st level index block of the given
+// 2nd level index, or of the next ones if it's filtered out by the block
+// properties. It returns false once the table is exhausted, or once the
+// blocks exceed the upper bound.
+func (i *DataIterator) loadFirstLevelIndexForward(index *nogodb_common.InternalKV) bool {
+	for ; index != nil; index = i.secondLevelIndexIter.Next() {
+		if !i.intersects(index) {
+			exceeded := i.exceedsUpper(index.K.UserKey)
+			index.V.Release()
+			if exceeded {
+				return false
+			}
+			continue
+		}
+
+		if err := i.firstLevelIndexedIter.SetIndexAndLoad(index); err != nil {
+			i.err = err
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// loadDataBlockForward loads the data block of the given 1st level index, or
+// of the next ones, across the 1st level index blocks, if it's filtered out
+// by the block properties. It returns false once the table is exhausted, or
+// once the blocks exceed the upper bound.
+func (i *DataIterator) loadDataBlockForward(index *nogodb_common.InternalKV) bool {
+	for {
+		for ; index != nil; index = i.firstLevelIndexedIter.Next() {
+			if !i.intersects(index) {
+				exceeded := i.exceedsUpper(index.K.UserKey)
+				index.V.Release()
+				if exceeded {
+					return false
+				}
+				continue
+			}
+
+			if err := i.dataIndexedIter.SetIndexAndLoad(index); err != nil {
+				i.err = err
+				return false
+			}
+
+			return true
+		}
+
+		if index := i.firstLevelIndexedIter.index; index != nil && i.exceedsUpper(index.K.UserKey) {
+			return false
+		}
+
+		if !i.loadFirstLevelIndexForward(i.secondLevelIndexIter.Next()) {
+			return false
+		}
+		index = i.firstLevelIndexedIter.First()
+	}
+}
+
+// loadFirstLevelIndexBackward is the backward counterpart of
+// loadFirstLevelIndexForward, it moves to the previous 2nd level indexes
+// until the blocks precede the lower bound
+func (i *DataIterator) loadFirstLevelIndexBackward(index *nogodb_common.InternalKV) bool {
+	for ; index != nil; index = i.secondLevelIndexIter.Prev() {
+		if i.precedesLower(index.K.UserKey) {
+			// all the keys of this block and the previous ones are < the lower bound
+			index.V.Release()
+			return false
+		}
+
+		if !i.intersects(index) {
+			index.V.Release()
+			continue
+		}
+
+		if err := i.firstLevelIndexedIter.SetIndexAndLoad(index); err != nil {
+			i.err = err
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// loadDataBlockBackward is the backward counterpart of loadDataBlockForward,
+// it moves to the previous 1st level indexes, across the 1st level index blocks,
+// until the blocks precede the lower bound
+func (i *DataIterator) loadDataBlockBackward(index *nogodb_common.InternalKV) bool {
+	for {
+		for ; index != nil; index = i.firstLevelIndexedIter.Prev() {
+			if i.precedesLower(index.K.UserKey) {
+				index.V.Release()
+				return false
+			}
+
+			if !i.intersects(index) {
+				index.V.Release()
+				continue
+			}
+
+			if err := i.dataIndexedIter.SetIndexAndLoad(index); err != nil {
+				i.err = err
+				return false
+			}
+
+			return true
+		}
+
+		if !i.loadFirstLevelIndexBackward(i.secondLevelIndexIter.Prev()) {
+			return false
+		}
+		index = i.firstLevelIndexedIter.Last()
+	}
+}
+
+// intersects returns false if the block of the given index entry is filtered
+// out by the block properties. The value of an index entry is the encoded
+// block handle, followed by the encoded block properties.
+func (i *DataIterator) intersects(index *nogodb_common.InternalKV) bool {
+	if i.propsFilterer == nil {
+		return true
+	}
+
+	val := index.V.Value()
+	bh := &common.BlockHandle{}
+	n := bh.DecodeFrom(val)
+	if n <= 0 {
+		// let the loading report the corrupted index
+		return true
+	}
+
+	matched, err := i.propsFilterer.intersects(val[n:])
+	if err != nil {
+		// the block is read anyway, but the scan reports the corruption
+		i.err = err
+		return true
+	}
+
+	return matched
+}
+
 func (i *DataIterator) readMetaIndexBlock(footer *block.Footer) error {
 	// TODO(low): Should we cache the metaIndex block ?
 	// Read and decode the meta index block
@@ -303,7 +721,8 @@ func (i *DataIterator) readMetaIndexBlock(footer *block.Footer) error {
 	for iter := blkIter.First(); iter != nil; iter = blkIter.Next() {
 		val := iter.V.Value()
 		bh := &common.BlockHandle{}
-		if sz := bh.DecodeFrom(val); sz <= 0 {
+		sz := bh.DecodeFrom(val)
+		if sz <= 0 {
 			zap.L().Error("failed to decode block, corrupted size", zap.Any("block", i))
 			return fmt.Errorf("failed to decode block, corrupted size. %w", common.InternalServerError)
 		}
@@ -313,6 +732,16 @@ func (i *DataIterator) readMetaIndexBlock(footer *block.Footer) error {
 			i.secondLevelIndexBH = bh
 		case nogodb_common.BlockKindFilter:
 			i.filterBH = bh
+			if i.filterMethod, err = readFilterMethod(val[sz:]); err != nil {
+				return err
+			}
+		case nogodb_common.BlockKindFilterIndex:
+			i.filterIndexBH = bh
+			if i.filterMethod, err = readFilterMethod(val[sz:]); err != nil {
+				return err
+			}
+		case nogodb_common.BlockKindProperties:
+			i.propsBH = bh
 		default:
 		}
 	}
@@ -320,6 +749,24 @@ func (i *DataIterator) readMetaIndexBlock(footer *block.Footer) error {
 	return nil
 }
 
+// readFilterMethod reads the filtering method following the block handle of
+// the filter in the meta index block
+func readFilterMethod(buf []byte) (filter.Method, error) {
+	// the tables written before the filter method was recorded are always
+	// bloom filtered
+	if len(buf) == 0 {
+		return filter.BloomFilter, nil
+	}
+
+	method := filter.Method(buf[0])
+	if method != filter.BloomFilter && method != filter.RibbonFilter {
+		zap.L().Error("unknown filter method", zap.Stringer("method", method))
+		return filter.Unknown, fmt.Errorf("unknown filter method %d. %w", method, common.InternalServerError)
+	}
+
+	return method, nil
+}
+
 func (i *DataIterator) init2ndLevelIndexBlockIterator() error {
 	if i.secondLevelIndexBH == nil {
 		zap.L().Error("the secondLevelIndex block handle is nil")
@@ -335,14 +782,100 @@ func (i *DataIterator) init2ndLevelIndexBlockIterator() error {
 	return nil
 }
 
+// readBlockProperties builds the filterer of the block property filters from
+// the table properties, and excludes the whole table if they don't match
+func (i *DataIterator) readBlockProperties(filters []options.BlockPropertyFilter) error {
+	if len(filters) == 0 || i.propsBH == nil {
+		// the table has no block properties, nothing can be skipped
+		return nil
+	}
+
+	props, err := i.readTableProperties()
+	if err != nil {
+		return err
+	}
+
+	tableProps := make(map[string]common.UserProperty, len(props.UserProperties))
+	for _, up := range props.UserProperties {
+		tableProps[up.Name] = up
+	}
+
+	filterer, matched, err := newBlockPropsFilterer(filters, tableProps)
+	if err != nil {
+		return err
+	}
+
+	i.propsFilterer = filterer
+	i.tableExcluded = !matched
+	return nil
+}
+
+// readPrefixExtractor enables the prefix extractor of the iterator if the
+// filter holds the prefixes of an extractor of the same name
+func (i *DataIterator) readPrefixExtractor(extractor options.PrefixExtractor) error {
+	i.prefixExtractor, i.prefixFiltered = extractor, false
+	if extractor == nil || i.propsBH == nil {
+		return nil
+	}
+
+	props, err := i.readTableProperties()
+	if err != nil {
+		return err
+	}
+
+	i.prefixFiltered = props.PrefixExtractorName == extractor.Name()
+	return nil
+}
+
+// readTableProperties reads and decodes the properties block
+func (i *DataIterator) readTableProperties() (*common.TableProperties, error) {
+	if i.propsBH == nil {
+		return nil, common.ErrNoTableProperties
+	}
+
+	propsBuf, err := i.blockReader.Read(i.propsBH, nogodb_common.BlockKindProperties)
+	if err != nil {
+		zap.L().Error("failed to read the properties block", zap.Error(err))
+		return nil, err
+	}
+	defer propsBuf.Release()
+
+	props := &common.TableProperties{}
+	blkIter := getBlockIter(i.ver, nogodb_common.BlockKindProperties, i.bpool, i.cmp, propsBuf)
+	for iter := blkIter.First(); iter != nil; iter = blkIter.Next() {
+		if err := props.Decode(iter.K.UserKey, iter.V.Value()); err != nil {
+			return nil, err
+		}
+	}
+
+	return props, nil
+}
+
 func (i *DataIterator) readFilter() error {
+	if i.filterIndexBH != nil {
+		// only the top-level filter index is read, the partitions are
+		// loaded on demand
+		filterIndexBuf, err := i.blockReader.Read(i.filterIndexBH, nogodb_common.BlockKindFilterIndex)
+		if err != nil {
+			zap.L().Error("failed to read filter index", zap.Error(err))
+			return err
+		}
+
+		i.partitionedFilter = &partitionedFilter{
+			method:      i.filterMethod,
+			blockReader: i.blockReader,
+			index:       getBlockIter(i.ver, nogodb_common.BlockKindIndex, i.bpool, i.cmp, filterIndexBuf),
+		}
+		return nil
+	}
+
 	filterBlock, err := i.blockReader.ReadThroughCache(i.filterBH, nogodb_common.BlockKindFilter)
 	if err != nil {
 		zap.L().Error("failed to read filter", zap.Error(err))
 		return err
 	}
 
-	i.filter = filter.NewFilterReader(filter.BloomFilter, filterBlock.Value())
+	i.filter = filter.NewFilterReader(i.filterMethod, filterBlock.Value())
 	return nil
 }
 
@@ -362,7 +895,7 @@ func getBlockIter(
 		return col_block.NewDataBlockIter(bp, cp, data)
 	case nogodb_common.BlockKindIndex:
 		return col_block.NewIndexBlockIter(bp, cp, data)
-	case nogodb_common.BlockKindMetaIntex:
+	case nogodb_common.BlockKindMetaIntex, nogodb_common.BlockKindProperties:
 		return col_block.NewKVBlockIter(bp, cp, data)
 	default:
 		panic(fmt.Sprintf("can not create iterator for block kind: %v", blockKind))
@@ -382,7 +915,7 @@ func NewIterator(
 
 	iter.bpool = bpool
 	iter.cmp = cmp
-	layoutReader = storage.NewLayoutReader(fr)
+	layoutReader = storage.NewLayoutReader(fr, opts.SequentialReads)
 	fullSize := fr.Size()
 	footer, err = block.ReadFooter(layoutReader, fullSize)
 	if err != nil {
@@ -394,9 +927,19 @@ func NewIterator(
 	}
 
 	iter.ver = footer.Version
+	iter.filterBH, iter.propsBH = nil, nil
+	iter.filterIndexBH, iter.partitionedFilter = nil, nil
+	iter.propsFilterer, iter.tableExcluded = nil, false
+	iter.err = nil
+	iter.lower, iter.upper = opts.LowerBound, opts.UpperBound
 	iter.blockReader.Init(iter.bpool, layoutReader, opts.CacheOpts)
 	iter.firstLevelIndexedIter = newIndexedIterator(cmp, iter.ver, iter.blockReader, iter.bpool, nogodb_common.BlockKindIndex)
 	iter.dataIndexedIter = newIndexedIterator(cmp, iter.ver, iter.blockReader, iter.bpool, nogodb_common.BlockKindData)
+	iter.prefetcher = nil
+	if opts.PrefetchBlocks > 0 {
+		iter.prefetcher = newBlockPrefetcher(iter.blockReader, opts.PrefetchBlocks, opts.LoadBlockSema)
+		iter.dataIndexedIter.prefetcher = iter.prefetcher
+	}
 
 	if err = iter.readMetaIndexBlock(footer); err != nil {
 		return nil, err
@@ -410,6 +953,20 @@ func NewIterator(
 		return nil, err
 	}
 
+	if err = iter.readPrefixExtractor(opts.PrefixExtractor); err != nil {
+		return nil, err
+	}
+
+	filters := opts.BlockPropertyFilters
+	if opts.ZoneMapPredicate != nil {
+		// the zone maps are only collected in the columnar tables, the
+		// filter is ignored otherwise
+		filters = append(slices.Clip(filters), col_block.NewZoneMapFilter(cmp, *opts.ZoneMapPredicate))
+	}
+	if err = iter.readBlockProperties(filters); err != nil {
+		return nil, err
+	}
+
 	return iter, nil
 }
 
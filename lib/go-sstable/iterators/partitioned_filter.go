@@ -0,0 +1,83 @@
+package iterators
+
+import (
+	"bytes"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/block/row_block"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/filter"
+	"go.uber.org/zap"
+)
+
+// partitionedFilter probes the filter partition of the 1st level index block
+// covering a key. The partitions are located by the top-level filter index,
+// whose keys are the keys of the 1st level index blocks, and are loaded
+// lazily through the block cache.
+type partitionedFilter struct {
+	method      filter.Method
+	blockReader row_block.IBlockReader
+	index       nogodb_common.InternalIterator[nogodb_common.InternalKV]
+
+	// the last probed partition, kept as the consecutive lookups usually
+	// land on the same one
+	partitionBH common.BlockHandle
+	partition   *nogodb_common.InternalLazyValue
+	reader      filter.IRead
+}
+
+// MayContain returns whether the partition covering the key may contain the
+// prefix. The key is the full user key, the first key ≥ key is either in
+// that partition or in the next one. The latter has no key with the prefix
+// unless the index key of the former has the prefix, e.g. the extracted
+// prefixes, which span many keys, then it's probed as well.
+func (f *partitionedFilter) MayContain(prefix, key []byte) bool {
+	for kv := f.index.SeekGTE(key); kv != nil; kv = f.index.Next() {
+		if f.partitionMayContain(kv, prefix) {
+			return true
+		}
+
+		if !bytes.HasPrefix(kv.K.UserKey, prefix) {
+			return false
+		}
+	}
+
+	// all the keys of the table are < key
+	return false
+}
+
+// partitionMayContain probes the partition of the filter index entry
+func (f *partitionedFilter) partitionMayContain(kv *nogodb_common.InternalKV, prefix []byte) bool {
+	bh := common.BlockHandle{}
+	if n := bh.DecodeFrom(kv.V.Value()); n <= 0 {
+		zap.L().Error("failed to decode the filter partition block handle")
+		return true
+	}
+
+	if f.partition == nil || f.partitionBH != bh {
+		partition, err := f.blockReader.ReadThroughCache(&bh, nogodb_common.BlockKindFilter)
+		if err != nil {
+			// don't filter out the key, the caller seeks it instead
+			zap.L().Error("failed to read the filter partition", zap.Error(err))
+			return true
+		}
+
+		f.releasePartition()
+		f.partitionBH, f.partition = bh, partition
+		f.reader = filter.NewFilterReader(f.method, partition.Value())
+	}
+
+	return f.reader.MayContain(prefix)
+}
+
+func (f *partitionedFilter) releasePartition() {
+	if f.partition != nil {
+		f.partition.Release()
+	}
+	f.partition, f.reader = nil, nil
+}
+
+func (f *partitionedFilter) Close() error {
+	f.releasePartition()
+	return f.index.Close()
+}
@@ -0,0 +1,38 @@
+package iterators
+
+import (
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool/predictable_size"
+	go_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/block"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/block/row_block"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/common"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/storage"
+)
+
+// ReadProperties reads the table properties of the SSTable, without loading
+// its index and filter. The readable is left open.
+func ReadProperties(
+	bpool *predictable_size.PredictablePool,
+	fr go_fs.Readable,
+) (*common.TableProperties, error) {
+	layoutReader := storage.NewLayoutReader(fr, false)
+	footer, err := block.ReadFooter(layoutReader, fr.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	blockReader := &row_block.RowBlockReader{}
+	blockReader.Init(bpool, layoutReader, nil)
+	i := &DataIterator{
+		cmp:         nogodb_common.NewComparer(),
+		blockReader: blockReader,
+		ver:         footer.Version,
+		bpool:       bpool,
+	}
+	if err := i.readMetaIndexBlock(footer); err != nil {
+		return nil, err
+	}
+
+	return i.readTableProperties()
+}
@@ -3,6 +3,7 @@ package options
 import (
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	"github.com/datnguyenzzz/nogodb/lib/common/compression"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/filter"
 )
 
 type CompressionOpts map[nogodb_common.BlockKind]compression.CompressionType
@@ -32,4 +33,36 @@ type BlockWriteOpt struct {
 	// DefaultCompression In case the block doesn't have a specified compression to use,
 	// the algorithm defined by DefaultCompression will be chosen
 	DefaultCompression compression.CompressionType
+
+	// FilterMethod is the filtering method of the filter block, it's recorded
+	// in the table so the readers pick the matching decoder.
+	//
+	// The default value is filter.BloomFilter.
+	FilterMethod filter.Method
+
+	// FilterBitsPerKey is the number of bits spent per key by the filter block,
+	// the more bits the lower the false positive rate. For instance, ~1% with
+	// 10 bits per key, ~0.1% with 15 bits per key on a bloom filter.
+	//
+	// The default value is 10.
+	FilterBitsPerKey int
+
+	// PartitionedFilter builds one filter per 1st level index block, plus a
+	// top-level filter index, instead of a single filter for the whole table.
+	// The readers then only load the filter partition they need, through the
+	// block cache, which suits the large tables whose filter would thrash the
+	// cache.
+	//
+	// The default value is false.
+	PartitionedFilter bool
+
+	// PrefixExtractor, if set, adds the extracted prefixes of the keys to the
+	// filters, along with the whole keys, so that the prefix scans of the
+	// readers with the same extractor skip the tables without the prefix.
+	// See PrefixExtractor
+	PrefixExtractor PrefixExtractor
+
+	// BlockPropertyCollectors creates the block property collectors of the table,
+	// a new collector is created per table. See BlockPropertyCollector
+	BlockPropertyCollectors []func() BlockPropertyCollector
 }
@@ -0,0 +1,194 @@
+package options
+
+import (
+	"encoding/binary"
+	"errors"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+)
+
+// Inspired by PebbleDB
+// A block property collector computes a property (e.g. the min/max MVCC
+// timestamps) over the keys of each data block. The encoded property is
+// stored along with the block handle in the 1st level index, merged per
+// 1st level index block in the 2nd level index, and merged over the whole
+// table in the properties block. A matching block property filter lets the
+// iterator skip the tables, the index blocks and the data blocks whose
+// property can't match.
+
+var ErrInvalidBlockProperty = errors.New("sstable: invalid block property")
+
+// BlockPropertyCollector collects a property over the keys of the data blocks.
+// A collector is created per table, see BlockWriteOpt.BlockPropertyCollectors
+type BlockPropertyCollector interface {
+	// Name identifies the collector, it must be unique within a table and match
+	// the name of its BlockPropertyFilter
+	Name() string
+	// Add is called for each key/value pair added to the current data block
+	Add(key nogodb_common.InternalKey, value []byte) error
+	// FinishDataBlock appends the encoded property of the current data block
+	// to buf, then resets the collector for the next data block
+	FinishDataBlock(buf []byte) ([]byte, error)
+	// Merge appends the union of the encoded properties a and b to buf. An
+	// empty property is the property of a block without any collected key.
+	// It must be safe to call concurrently with Add and FinishDataBlock,
+	// because the index blocks are built in background.
+	Merge(buf, a, b []byte) ([]byte, error)
+}
+
+// BlockPropertyFilter decides from a property collected by the
+// BlockPropertyCollector of the same Name whether a block (or a table)
+// might contain the keys the iterator is interested in
+type BlockPropertyFilter interface {
+	Name() string
+	// Intersects returns false if none of the keys of the block can match
+	Intersects(prop []byte) (bool, error)
+}
+
+// Uint64 range \\
+
+// The uint64 range property is the [min, max] range of a value extracted
+// from the keys, encoded as 2 uvarints. It's empty if nothing has been
+// extracted.
+
+// uint64RangeCollector collects the [min, max] of the values extracted by extract
+type uint64RangeCollector struct {
+	name    string
+	extract func(key nogodb_common.InternalKey, value []byte) (uint64, bool)
+
+	min, max uint64
+	found    bool
+}
+
+// NewUint64RangeCollector returns a collector of the [min, max] range of the
+// values extracted from the key/value pairs, e.g. a custom tag. The pairs
+// which extract returns false for are ignored.
+func NewUint64RangeCollector(
+	name string,
+	extract func(key nogodb_common.InternalKey, value []byte) (uint64, bool),
+) BlockPropertyCollector {
+	return &uint64RangeCollector{name: name, extract: extract}
+}
+
+func (c *uint64RangeCollector) Name() string {
+	return c.name
+}
+
+func (c *uint64RangeCollector) Add(key nogodb_common.InternalKey, value []byte) error {
+	v, ok := c.extract(key, value)
+	if !ok {
+		return nil
+	}
+
+	if !c.found {
+		c.min, c.max, c.found = v, v, true
+		return nil
+	}
+
+	c.min, c.max = min(c.min, v), max(c.max, v)
+	return nil
+}
+
+func (c *uint64RangeCollector) FinishDataBlock(buf []byte) ([]byte, error) {
+	if c.found {
+		buf = encodeUint64Range(buf, c.min, c.max)
+	}
+
+	c.min, c.max, c.found = 0, 0, false
+	return buf, nil
+}
+
+func (c *uint64RangeCollector) Merge(buf, a, b []byte) ([]byte, error) {
+	switch {
+	case len(a) == 0:
+		return append(buf, b...), nil
+	case len(b) == 0:
+		return append(buf, a...), nil
+	}
+
+	minA, maxA, err := decodeUint64Range(a)
+	if err != nil {
+		return nil, err
+	}
+	minB, maxB, err := decodeUint64Range(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeUint64Range(buf, min(minA, minB), max(maxA, maxB)), nil
+}
+
+var _ BlockPropertyCollector = (*uint64RangeCollector)(nil)
+
+// uint64RangeFilter matches the blocks whose range intersects [lo, hi]
+type uint64RangeFilter struct {
+	name   string
+	lo, hi uint64
+}
+
+// NewUint64RangeFilter returns a filter matching the blocks whose range,
+// collected by NewUint64RangeCollector, intersects [lo, hi]. The blocks
+// without any extracted value always match.
+func NewUint64RangeFilter(name string, lo, hi uint64) BlockPropertyFilter {
+	return &uint64RangeFilter{name: name, lo: lo, hi: hi}
+}
+
+func (f *uint64RangeFilter) Name() string {
+	return f.name
+}
+
+func (f *uint64RangeFilter) Intersects(prop []byte) (bool, error) {
+	if len(prop) == 0 {
+		return true, nil
+	}
+
+	minV, maxV, err := decodeUint64Range(prop)
+	if err != nil {
+		return false, err
+	}
+
+	return minV <= f.hi && f.lo <= maxV, nil
+}
+
+var _ BlockPropertyFilter = (*uint64RangeFilter)(nil)
+
+func encodeUint64Range(buf []byte, minV, maxV uint64) []byte {
+	buf = binary.AppendUvarint(buf, minV)
+	return binary.AppendUvarint(buf, maxV)
+}
+
+func decodeUint64Range(prop []byte) (minV, maxV uint64, err error) {
+	minV, n := binary.Uvarint(prop)
+	if n <= 0 {
+		return 0, 0, ErrInvalidBlockProperty
+	}
+	maxV, m := binary.Uvarint(prop[n:])
+	if m <= 0 || n+m != len(prop) {
+		return 0, 0, ErrInvalidBlockProperty
+	}
+
+	return minV, maxV, nil
+}
+
+// MVCC time range \\
+
+// MVCCTimeRangePropertyName is the name of the MVCC time range collector/filter
+const MVCCTimeRangePropertyName = "nogodb.mvcc-time-range"
+
+// NewMVCCTimeRangeCollector returns a collector of the [min, max] timestamps
+// of the MVCC keys, see nogodb_common.EncodeMVCCKey. The bare keys are ignored.
+func NewMVCCTimeRangeCollector() BlockPropertyCollector {
+	return NewUint64RangeCollector(
+		MVCCTimeRangePropertyName,
+		func(key nogodb_common.InternalKey, _ []byte) (uint64, bool) {
+			_, ts, ok := nogodb_common.DecodeMVCCKey(key.UserKey)
+			return ts, ok && ts > 0
+		},
+	)
+}
+
+// NewMVCCTimeRangeFilter returns a filter matching the blocks having MVCC
+// keys whose timestamps are within [lo, hi], e.g. for the time-bounded scans
+func NewMVCCTimeRangeFilter(lo, hi uint64) BlockPropertyFilter {
+	return NewUint64RangeFilter(MVCCTimeRangePropertyName, lo, hi)
+}
@@ -8,4 +8,21 @@ import (
 type CacheOptions struct {
 	Cache   go_block_cache.IBlockCache
 	FileNum nogodb_common.DiskfileNum
+
+	// DontFillCache doesn't add the blocks read from the storage to the cache,
+	// the cached blocks are still read. e.g. for the compactions, whose blocks
+	// won't likely be read again.
+	DontFillCache bool
+
+	// DataBlockPriority is the cache priority of the data blocks, e.g.
+	// go_block_cache.PriorityBottom for the long scans. The index and filter
+	// blocks are always cached with go_block_cache.PriorityHigh.
+	//
+	// The default value is go_block_cache.PriorityLow.
+	DataBlockPriority go_block_cache.Priority
+
+	// PinMetaBlocks pins the index and filter blocks in the cache once read,
+	// until the iterator is closed, e.g. for the L0 tables, which are probed
+	// by every lookup
+	PinMetaBlocks bool
 }
@@ -4,6 +4,7 @@ import (
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	go_block_cache "github.com/datnguyenzzz/nogodb/lib/go-block-cache"
 	go_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	"golang.org/x/sync/semaphore"
 )
 
 type IteratorOptsFunc func(opts *IteratorOpts)
@@ -11,6 +12,58 @@ type IteratorOptsFunc func(opts *IteratorOpts)
 type IteratorOpts struct {
 	CacheOpts *CacheOptions
 	Comparer  nogodb_common.IComparer
+
+	// LowerBound and UpperBound bound the user keys the iterator returns within
+	// [LowerBound, UpperBound). A nil bound is unbounded. The blocks entirely
+	// outside the bounds are never loaded.
+	LowerBound, UpperBound []byte
+
+	// SequentialReads reads the table ahead from the first read, instead of
+	// once the reads are detected to be sequential, e.g. for the compactions
+	// which scan the whole tables
+	SequentialReads bool
+
+	// PrefetchBlocks is the number of data blocks read in background ahead of
+	// a forward scan, 0 disables the prefetching. The prefetches are cancelled
+	// once the iterator is repositioned.
+	PrefetchBlocks int
+	// LoadBlockSema, if set, bounds the number of blocks prefetched in
+	// parallel, e.g. shared with the DB's options.DBOption.LoadBlockSema
+	LoadBlockSema *semaphore.Weighted
+
+	// BlockPropertyFilters skips the tables and the blocks whose properties,
+	// collected by the BlockPropertyCollector of the same name, don't match
+	BlockPropertyFilters []BlockPropertyFilter
+
+	// ZoneMapPredicate skips the data blocks of the columnar tables whose zone
+	// map can't match it, see ZoneMapPredicate for when pruning by the values
+	// is safe
+	ZoneMapPredicate *ZoneMapPredicate
+
+	// PrefixExtractor, if set, makes SeekPrefixGTE probe the filters with the
+	// extracted prefix of the given prefix, e.g. to scan all the rows of a
+	// table. It's only used on the tables written with an extractor of the
+	// same name, the others aren't filtered. See PrefixExtractor
+	PrefixExtractor PrefixExtractor
+}
+
+// ZoneMapPredicate is a range predicate over the keys and the values. A nil
+// bound is unbounded. The blocks are pruned as a whole, the keys of a matched
+// block are all returned, regardless of the predicate.
+//
+// Note: Pruning by the values (ValueLower, ValueUpper and SkipNulls) is only
+// safe over the tables without shadowed keys, e.g. a single table read on its
+// own. Across the levels of an LSM, a pruned block might hold the latest
+// version of a key, a tombstone or a value out of the range, and its older
+// version, in another table, would resurface. The key bounds are always safe.
+type ZoneMapPredicate struct {
+	// KeyLower and KeyUpper bound the user keys within [KeyLower, KeyUpper)
+	KeyLower, KeyUpper []byte
+	// ValueLower and ValueUpper bound the values within [ValueLower, ValueUpper),
+	// the null values (i.e. tombstones and empty values) never match them
+	ValueLower, ValueUpper []byte
+	// SkipNulls skips the blocks whose values are all null
+	SkipNulls bool
 }
 
 func WithComparer(comparer nogodb_common.IComparer) IteratorOptsFunc {
@@ -21,10 +74,83 @@ func WithComparer(comparer nogodb_common.IComparer) IteratorOptsFunc {
 
 func WithBlockCache(cache go_block_cache.IBlockCache, fd go_fs.FileDesc) IteratorOptsFunc {
 	return func(opts *IteratorOpts) {
-		opts.CacheOpts = &CacheOptions{}
+		if opts.CacheOpts == nil {
+			opts.CacheOpts = &CacheOptions{}
+		}
 		opts.CacheOpts.Cache = cache
 		// notes: the block cache use file_num as a part of the cache key
 		// a block cache with key = [file_num + offset], value = data[offset:offset + length]
 		opts.CacheOpts.FileNum = go_fs.FromFileDescToFileNum(fd)
 	}
 }
+
+// WithDontFillCache doesn't add the blocks read to the block cache, see
+// CacheOptions.DontFillCache
+func WithDontFillCache() IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		if opts.CacheOpts == nil {
+			opts.CacheOpts = &CacheOptions{}
+		}
+		opts.CacheOpts.DontFillCache = true
+	}
+}
+
+// WithDataBlockCachePriority sets the cache priority of the data blocks, see
+// CacheOptions.DataBlockPriority
+func WithDataBlockCachePriority(prio go_block_cache.Priority) IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		if opts.CacheOpts == nil {
+			opts.CacheOpts = &CacheOptions{}
+		}
+		opts.CacheOpts.DataBlockPriority = prio
+	}
+}
+
+// WithPinnedMetaBlocks pins the index and filter blocks in the block cache
+// until the iterator is closed, see CacheOptions.PinMetaBlocks
+func WithPinnedMetaBlocks() IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		if opts.CacheOpts == nil {
+			opts.CacheOpts = &CacheOptions{}
+		}
+		opts.CacheOpts.PinMetaBlocks = true
+	}
+}
+
+func WithBounds(lower, upper []byte) IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		opts.LowerBound = lower
+		opts.UpperBound = upper
+	}
+}
+
+func WithSequentialReads() IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		opts.SequentialReads = true
+	}
+}
+
+func WithPrefetch(blocks int, sema *semaphore.Weighted) IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		opts.PrefetchBlocks = blocks
+		opts.LoadBlockSema = sema
+	}
+}
+
+func WithBlockPropertyFilters(filters ...BlockPropertyFilter) IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		opts.BlockPropertyFilters = append(opts.BlockPropertyFilters, filters...)
+	}
+}
+
+func WithZoneMapPredicate(pred ZoneMapPredicate) IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		opts.ZoneMapPredicate = &pred
+	}
+}
+
+func WithPrefixExtractor(extractor PrefixExtractor) IteratorOptsFunc {
+	return func(opts *IteratorOpts) {
+		opts.PrefixExtractor = extractor
+	}
+}
@@ -0,0 +1,92 @@
+package options
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A prefix extractor derives a prefix from the user keys (without their MVCC
+// suffix), e.g. the table ID, or the table and partition IDs of the key. The
+// extracted prefixes are added to the filters, along with the whole keys, so
+// that the prefix scans (SeekPrefixGTE) skip the tables which don't contain
+// the prefix.
+
+// PrefixExtractor extracts the prefixes added to the filters. The extracted
+// prefix of a key must be a prefix of the key, and must be the extracted prefix
+// of all the keys sharing it, so that the keys with the same prefix are
+// contiguous in the table.
+type PrefixExtractor interface {
+	// Name identifies the extractor, it's recorded in the table properties,
+	// the filters are only probed for the prefixes by a matching extractor.
+	Name() string
+	// InDomain reports whether a prefix can be extracted from the key
+	InDomain(key []byte) bool
+	// Extract returns the prefix of the key, it's only called if InDomain
+	Extract(key []byte) []byte
+}
+
+type fixedPrefixExtractor struct {
+	n int
+}
+
+// NewFixedPrefixExtractor extracts the first n bytes of the keys, the shorter
+// keys are out of domain.
+func NewFixedPrefixExtractor(n int) PrefixExtractor {
+	return &fixedPrefixExtractor{n: n}
+}
+
+func (e *fixedPrefixExtractor) Name() string {
+	return fmt.Sprintf("nogodb.FixedPrefix.%d", e.n)
+}
+
+func (e *fixedPrefixExtractor) InDomain(key []byte) bool {
+	return len(key) >= e.n
+}
+
+func (e *fixedPrefixExtractor) Extract(key []byte) []byte {
+	return key[:e.n]
+}
+
+type delimitedPrefixExtractor struct {
+	delim byte
+	n     int
+}
+
+// NewDelimitedPrefixExtractor extracts the first n components of the keys,
+// separated by delim, the trailing delimiter included. e.g. with '/' and n = 2,
+// the prefix of "T1/P2/row" is "T1/P2/". The keys with less than n delimiters
+// are out of domain.
+func NewDelimitedPrefixExtractor(delim byte, n int) PrefixExtractor {
+	return &delimitedPrefixExtractor{delim: delim, n: n}
+}
+
+func (e *delimitedPrefixExtractor) Name() string {
+	return fmt.Sprintf("nogodb.DelimitedPrefix.%q.%d", e.delim, e.n)
+}
+
+func (e *delimitedPrefixExtractor) InDomain(key []byte) bool {
+	return e.prefixLen(key) >= 0
+}
+
+func (e *delimitedPrefixExtractor) Extract(key []byte) []byte {
+	return key[:e.prefixLen(key)]
+}
+
+// prefixLen returns the length of the prefix, -1 if the key has less than n
+// delimiters
+func (e *delimitedPrefixExtractor) prefixLen(key []byte) int {
+	l := 0
+	for range e.n {
+		i := bytes.IndexByte(key[l:], e.delim)
+		if i < 0 {
+			return -1
+		}
+		l += i + 1
+	}
+	return l
+}
+
+var (
+	_ PrefixExtractor = (*fixedPrefixExtractor)(nil)
+	_ PrefixExtractor = (*delimitedPrefixExtractor)(nil)
+)
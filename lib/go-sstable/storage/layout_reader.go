@@ -1,11 +1,38 @@
 package storage
 
 import (
+	"sync"
+
 	go_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
 )
 
+const (
+	// initialReadaheadSize is the readahead window once the reads are detected
+	// to be sequential, it's doubled on each following window up to maxReadaheadSize
+	initialReadaheadSize = 64 << 10
+	maxReadaheadSize     = 256 << 10
+	// minSequentialReads is the number of sequential reads before reading ahead
+	minSequentialReads = 2
+)
+
 type layoutReader struct {
 	fsReader go_fs.Readable
+	// size of the object, the tables are immutable
+	size uint64
+
+	// mu guards the readahead state and the buffer, it's not held across the
+	// reads of the object, so that the concurrent reads of the table (e.g.
+	// the prefetched blocks) run in parallel
+	mu sync.Mutex
+	ra readahead
+	// prefetcher asks the underlying storage to read ahead, if it can. The
+	// window is otherwise read ahead into buf
+	prefetcher go_fs.Prefetcher
+	buf        []byte
+	bufOffset  uint64
+	// spare is the previous buf, reused by the next readahead. It's owned by
+	// a single readahead at a time, until it's swapped in as buf.
+	spare []byte
 }
 
 // ILayoutReader is used to perform reads that are related and might benefit from
@@ -22,21 +49,133 @@ type ILayoutReader interface {
 	Close() error
 }
 
+// readahead detects the sequential reads and sizes the readahead windows.
+// A read is sequential if it starts at the end of the previous read, or
+// within the window already read ahead, e.g. when the blocks in between
+// are served by the block cache.
+type readahead struct {
+	// forced reads ahead from the first read, see NewLayoutReader
+	forced bool
+
+	// prevEnd is the end offset of the previous read
+	prevEnd uint64
+	// limit is the end offset of the window already read ahead
+	limit          uint64
+	size           uint64
+	sequentialHits int
+}
+
+// next records the read of [off, off+n) and returns the size of the window
+// to read ahead from off, or 0 if nothing has to be read ahead
+func (ra *readahead) next(off, n uint64) uint64 {
+	if ra.forced || (off >= ra.prevEnd && off <= max(ra.prevEnd, ra.limit)) {
+		ra.sequentialHits++
+	} else {
+		ra.sequentialHits, ra.size, ra.limit = 0, 0, 0
+	}
+	ra.prevEnd = off + n
+
+	if !ra.forced && ra.sequentialHits < minSequentialReads {
+		return 0
+	}
+
+	if off+n <= ra.limit {
+		// already read ahead
+		return 0
+	}
+
+	switch {
+	case ra.forced:
+		ra.size = maxReadaheadSize
+	case ra.size == 0:
+		ra.size = initialReadaheadSize
+	default:
+		ra.size = min(2*ra.size, maxReadaheadSize)
+	}
+
+	size := max(ra.size, n)
+	ra.limit = off + size
+	return size
+}
+
 // Implementations \\
 
-func (l layoutReader) ReadAt(p []byte, off uint64) error {
-	_, err := l.fsReader.ReadAt(p, int64(off))
-	return err
+func (l *layoutReader) ReadAt(p []byte, off uint64) error {
+	n := uint64(len(p))
+
+	l.mu.Lock()
+	if off >= l.bufOffset && off+n <= l.bufOffset+uint64(len(l.buf)) {
+		_ = l.ra.next(off, n)
+		copy(p, l.buf[off-l.bufOffset:])
+		l.mu.Unlock()
+		return nil
+	}
+
+	size := l.ra.next(off, n)
+	if size == 0 || off+n > l.size {
+		// not sequential, or past the end of the object which must fail
+		l.mu.Unlock()
+		_, err := l.fsReader.ReadAt(p, int64(off))
+		return err
+	}
+
+	prefetcher := l.prefetcher
+	var buf []byte
+	if prefetcher == nil {
+		buf, l.spare = l.spare, nil
+	}
+	l.mu.Unlock()
+
+	if prefetcher != nil {
+		if err := prefetcher.Prefetch(int64(off), int64(size)); err == nil {
+			_, err = l.fsReader.ReadAt(p, int64(off))
+			return err
+		}
+
+		// fall back to the buffered readahead
+		l.mu.Lock()
+		l.prefetcher = nil
+		l.mu.Unlock()
+	}
+
+	size = min(size, l.size-off)
+	if uint64(cap(buf)) < size {
+		buf = make([]byte, size)
+	}
+	buf = buf[:size]
+	if _, err := l.fsReader.ReadAt(buf, int64(off)); err != nil {
+		l.mu.Lock()
+		l.spare = buf
+		l.mu.Unlock()
+		return err
+	}
+	copy(p, buf)
+
+	// the buffer is only read under the lock, the previous one can be reused
+	l.mu.Lock()
+	l.spare, l.buf, l.bufOffset = l.buf, buf, off
+	l.mu.Unlock()
+	return nil
 }
 
-func (l layoutReader) Close() error {
+func (l *layoutReader) Close() error {
 	return l.fsReader.Close()
 }
 
-func NewLayoutReader(fsReader go_fs.Readable) ILayoutReader {
-	return &layoutReader{
+// NewLayoutReader returns a reader which reads ahead once the reads are
+// detected to be sequential, or from the first read if sequential is set,
+// e.g. for the compactions which scan the whole table
+func NewLayoutReader(fsReader go_fs.Readable, sequential bool) ILayoutReader {
+	l := &layoutReader{
 		fsReader: fsReader,
+		size:     fsReader.Size(),
+		ra:       readahead{forced: sequential},
 	}
+	if p, ok := fsReader.(go_fs.Prefetcher); ok {
+		l.prefetcher = p
+	}
+
+	return l
 }
 
 var _ ILayoutReader = (*layoutReader)(nil)
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReadable is an in-memory go_fs.Readable counting the reads
+type countingReadable struct {
+	data  []byte
+	reads int
+}
+
+func (r *countingReadable) ReadAt(p []byte, off int64) (int, error) {
+	r.reads++
+	if off+int64(len(p)) > int64(len(r.data)) {
+		return 0, fmt.Errorf("read past end of file")
+	}
+	return copy(p, r.data[off:]), nil
+}
+
+func (r *countingReadable) Read(p []byte) (int, error) { return 0, errors.ErrUnsupported }
+func (r *countingReadable) Size() uint64               { return uint64(len(r.data)) }
+func (r *countingReadable) Close() error               { return nil }
+
+// prefetchingReadable records the prefetched ranges
+type prefetchingReadable struct {
+	countingReadable
+	prefetched [][2]int64
+}
+
+func (r *prefetchingReadable) Prefetch(off, n int64) error {
+	r.prefetched = append(r.prefetched, [2]int64{off, n})
+	return nil
+}
+
+// blockingReadable blocks the reads at blockedOff until released
+type blockingReadable struct {
+	data       []byte
+	blockedOff int64
+	blocked    chan struct{}
+	released   chan struct{}
+}
+
+func (r *blockingReadable) ReadAt(p []byte, off int64) (int, error) {
+	if off == r.blockedOff {
+		close(r.blocked)
+		<-r.released
+	}
+	if off+int64(len(p)) > int64(len(r.data)) {
+		return 0, fmt.Errorf("read past end of file")
+	}
+	return copy(p, r.data[off:]), nil
+}
+
+func (r *blockingReadable) Read(p []byte) (int, error) { return 0, errors.ErrUnsupported }
+func (r *blockingReadable) Size() uint64               { return uint64(len(r.data)) }
+func (r *blockingReadable) Close() error               { return nil }
+
+func TestReadahead_Next(t *testing.T) {
+	ra := &readahead{}
+
+	// random reads never read ahead
+	assert.Zero(t, ra.next(1000, 100))
+	assert.Zero(t, ra.next(0, 100))
+
+	// the window grows on each sequential read past the previous window
+	assert.Zero(t, ra.next(100, 100), "not enough sequential reads yet")
+	assert.Equal(t, uint64(initialReadaheadSize), ra.next(200, 100))
+	assert.Zero(t, ra.next(300, 100), "within the window already read ahead")
+	// skipping a few blocks within the window is still sequential
+	assert.Zero(t, ra.next(1000, 100))
+	assert.Equal(t, uint64(2*initialReadaheadSize), ra.next(200+initialReadaheadSize, 100))
+	for off := uint64(200 + 3*initialReadaheadSize); off < 10*maxReadaheadSize; off += maxReadaheadSize {
+		assert.LessOrEqual(t, ra.next(off, 100), uint64(maxReadaheadSize))
+	}
+
+	// a random read resets the window
+	assert.Zero(t, ra.next(0, 100))
+	assert.Zero(t, ra.next(100, 100))
+	assert.Equal(t, uint64(initialReadaheadSize), ra.next(200, 100))
+
+	// a forced readahead reads the max window from the first read
+	ra = &readahead{forced: true}
+	assert.Equal(t, uint64(maxReadaheadSize), ra.next(1000, 100))
+	assert.Zero(t, ra.next(5000, 100))
+}
+
+func TestLayoutReader_BufferedReadahead(t *testing.T) {
+	data := make([]byte, 2*maxReadaheadSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	fr := &countingReadable{data: data}
+	lr := NewLayoutReader(fr, false)
+
+	blockSize := 4 << 10
+	p := make([]byte, blockSize)
+	for off := 0; off+blockSize <= len(data); off += blockSize {
+		require.NoError(t, lr.ReadAt(p, uint64(off)))
+		require.True(t, bytes.Equal(data[off:off+blockSize], p), "offset %d", off)
+	}
+	assert.Less(t, fr.reads, len(data)/blockSize/4, "the sequential reads must be served by the readahead buffer")
+
+	// the reads past the end of the object still fail
+	assert.Error(t, lr.ReadAt(p, uint64(len(data)-blockSize/2)))
+}
+
+func TestLayoutReader_PrefetchedReadahead(t *testing.T) {
+	data := make([]byte, maxReadaheadSize)
+	fr := &prefetchingReadable{countingReadable: countingReadable{data: data}}
+	lr := NewLayoutReader(fr, true)
+
+	p := make([]byte, 4<<10)
+	require.NoError(t, lr.ReadAt(p, 0))
+	require.NoError(t, lr.ReadAt(p, uint64(len(p))))
+	assert.Equal(t, [][2]int64{{0, maxReadaheadSize}}, fr.prefetched)
+	assert.Equal(t, 2, fr.reads, "the prefetched reads are read from the object")
+}
+
+func TestLayoutReader_ConcurrentReads(t *testing.T) {
+	data := make([]byte, 4*maxReadaheadSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	blockSize := 4 << 10
+	fr := &blockingReadable{
+		data:       data,
+		blockedOff: int64(len(data) - blockSize),
+		blocked:    make(chan struct{}),
+		released:   make(chan struct{}),
+	}
+	lr := NewLayoutReader(fr, true)
+
+	// a read blocked in the object doesn't block the others
+	blockedErr := make(chan error, 1)
+	go func() {
+		blockedErr <- lr.ReadAt(make([]byte, blockSize), uint64(fr.blockedOff))
+	}()
+	<-fr.blocked
+
+	var wg sync.WaitGroup
+	for g := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, blockSize)
+			for off := g * blockSize; off+blockSize <= int(fr.blockedOff); off += 4 * blockSize {
+				if !assert.NoError(t, lr.ReadAt(p, uint64(off))) {
+					return
+				}
+				assert.True(t, bytes.Equal(data[off:off+blockSize], p), "offset %d", off)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the reads are serialised behind the blocked one")
+	}
+
+	close(fr.released)
+	require.NoError(t, <-blockedErr)
+}
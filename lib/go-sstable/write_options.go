@@ -3,6 +3,7 @@ package go_sstable
 import (
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	"github.com/datnguyenzzz/nogodb/lib/common/compression"
+	"github.com/datnguyenzzz/nogodb/lib/go-sstable/filter"
 	"github.com/datnguyenzzz/nogodb/lib/go-sstable/options"
 )
 
@@ -13,6 +14,7 @@ var DefaultWriteOpt = &options.BlockWriteOpt{
 	BlockSize:            4 * 1024,
 	BlockSizeThreshold:   0.9,
 	DefaultCompression:   compression.SnappyCompression,
+	FilterMethod:         filter.BloomFilter,
 	Comparer:             nogodb_common.NewComparer(),
 }
 
@@ -45,3 +47,39 @@ func WithCompression(compressor compression.CompressionType) WriteOptFn {
 		w.datablockOpts.DefaultCompression = compressor
 	}
 }
+
+func WithFilterMethod(method filter.Method) WriteOptFn {
+	return func(w *Writer) {
+		w.datablockOpts.FilterMethod = method
+	}
+}
+
+func WithFilterBitsPerKey(bitsPerKey int) WriteOptFn {
+	return func(w *Writer) {
+		w.datablockOpts.FilterBitsPerKey = bitsPerKey
+	}
+}
+
+// WithPartitionedFilter partitions the filter along with the 1st level index
+// blocks, see options.BlockWriteOpt.PartitionedFilter
+func WithPartitionedFilter() WriteOptFn {
+	return func(w *Writer) {
+		w.datablockOpts.PartitionedFilter = true
+	}
+}
+
+// WithPrefixExtractor adds the extracted prefixes of the keys to the filters,
+// see options.BlockWriteOpt.PrefixExtractor
+func WithPrefixExtractor(extractor options.PrefixExtractor) WriteOptFn {
+	return func(w *Writer) {
+		w.datablockOpts.PrefixExtractor = extractor
+	}
+}
+
+// WithBlockPropertyCollectors registers the block property collectors of the
+// table, see options.BlockPropertyCollector
+func WithBlockPropertyCollectors(collectors ...func() options.BlockPropertyCollector) WriteOptFn {
+	return func(w *Writer) {
+		w.datablockOpts.BlockPropertyCollectors = append(w.datablockOpts.BlockPropertyCollectors, collectors...)
+	}
+}
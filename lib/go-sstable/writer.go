@@ -22,6 +22,10 @@ func (w *Writer) Delete(key []byte) error {
 	return w.rw.Add(nogodb_common.MakeKey(key, 0, nogodb_common.KeyKindDelete), nil)
 }
 
+func (w *Writer) Add(key nogodb_common.InternalKey, value []byte) error {
+	return w.rw.Add(key, value)
+}
+
 // Close finishes writing the table and closes the underlying file that the
 // table was written to.
 func (w *Writer) Close() error {
@@ -29,8 +33,10 @@ func (w *Writer) Close() error {
 }
 
 func NewWriter(writable go_fs.Writable, tableVersion common.TableVersion, opts ...WriteOptFn) *Writer {
+	// copy the default options, they're mutated by the WriteOptFn
+	datablockOpts := *DefaultWriteOpt
 	w := &Writer{
-		datablockOpts: DefaultWriteOpt,
+		datablockOpts: &datablockOpts,
 	}
 
 	for _, o := range opts {
@@ -0,0 +1,362 @@
+package go_wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_record "github.com/datnguyenzzz/nogodb/lib/common/record"
+	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+)
+
+// segmentHeaderMagic begins the first record of each segment, so that the
+// reader tells the segment header apart from the first record of a WAL written
+// without one. Its last byte is the version of the segment format.
+var segmentHeaderMagic = [8]byte{'n', 'o', 'g', 'o', 'w', 'a', 'l', 1}
+
+// segmentHeaderSize is the size of the first record of each segment, which
+// holds the magic (8 bytes), the index of the segment within its logical WAL
+// (1 byte), and the logical offset (uint64, little-endian) of the segment's
+// next record
+const segmentHeaderSize = len(segmentHeaderMagic) + 1 + 8
+
+var (
+	errStalled      = errors.New("go-wal: operation stalled")
+	errWriterClosed = errors.New("go-wal: writer is closed")
+)
+
+// segment is a physical file holding a part of a logical WAL. Once it stalls,
+// the segment is abandoned: the stalled operation keeps running in the monitor
+// of the writer, which closes the segment when it completes.
+type segment struct {
+	dir    dirIndex
+	wf     nogodb_fs.Writable
+	wr     *nogodb_record.Writer
+	closed bool
+}
+
+func newSegment(
+	wf nogodb_fs.Writable,
+	fileNum nogodb_common.DiskfileNum,
+	dir dirIndex,
+	index uint8,
+	logicalOffset uint64,
+) (*segment, error) {
+	s := &segment{
+		dir: dir,
+		wf:  wf,
+		// TODO(low): wf is buffered to the RAM before flushing, is it ok ?
+		wr: nogodb_record.NewRecyclableWriter(wf, fileNum),
+	}
+
+	var header [segmentHeaderSize]byte
+	n := copy(header[:], segmentHeaderMagic[:])
+	header[n] = index
+	binary.LittleEndian.PutUint64(header[n+1:], logicalOffset)
+	if err := s.writeRecord(header[:]); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *segment) writeRecord(p []byte) error {
+	next, err := s.wr.Next()
+	if err != nil {
+		return err
+	}
+
+	_, err = next.Write(p)
+	return err
+}
+
+// sync writes the buffered records to the file, and syncs it
+func (s *segment) sync() error {
+	if err := s.wr.Flush(); err != nil {
+		return err
+	}
+
+	return s.wf.Sync()
+}
+
+// close syncs the remaining records and closes the file
+func (s *segment) close() error {
+	if s.closed {
+		return nil
+	}
+
+	if err := s.wr.Close(); err != nil {
+		s.abort()
+		return err
+	}
+
+	if err := s.wf.Sync(); err != nil {
+		s.abort()
+		return err
+	}
+
+	s.closed = true
+	return s.wf.Finish()
+}
+
+// abort gives up on the segment, without flushing the buffered records
+func (s *segment) abort() {
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	s.wf.Abort()
+}
+
+// segmentOp is an operation run by the monitor of a writer against a segment
+type segmentOp struct {
+	s  *segment
+	fn func(s *segment) error
+}
+
+// pendingRecord is a record which isn't durable yet
+type pendingRecord struct {
+	logicalOffset uint64
+	data          []byte
+}
+
+// writer writes the records of a logical WAL. When a secondary directory is
+// configured, every operation on the current segment is run by the monitor, a
+// goroutine living as long as the writer: if the operation stalls past the
+// failover threshold, or fails, the writer switches to a new segment in the
+// other directory and replays the records which aren't durable yet. A logical
+// WAL fails over at most once, the operations of its second segment run in the
+// caller's goroutine and their stall or failure is returned to the caller.
+//
+// Write, Sync and Close are serialized, so Sync can be called by a background
+// goroutine while the records are being written.
+type writer struct {
+	mu sync.Mutex
+
+	wal     *WAL
+	fileNum nogodb_common.DiskfileNum
+	curr    *segment
+	closed  bool
+
+	// logicalOffset is the number of payload bytes written to the logical WAL
+	logicalOffset uint64
+	// pending holds the records written to the current segment which aren't
+	// durable yet (i.e. since the last Sync), so they can be replayed on
+	// failover. It is only populated when a secondary directory is configured,
+	// and it is bounded by maxPendingBytes: the writer syncs the segment itself
+	// once reached, e.g. when the caller doesn't sync.
+	pending      []pendingRecord
+	pendingBytes int
+
+	// ops sends the operations to the monitor, it is nil once the monitor is
+	// stopped, or if the failover is disabled
+	ops chan segmentOp
+	// results receives the result of the operation sent to the monitor
+	results chan error
+	// timer bounds the wait for the result of the monitored operation
+	timer *time.Timer
+}
+
+func newWriter(wal *WAL, fileNum nogodb_common.DiskfileNum, seg *segment) *writer {
+	w := &writer{
+		wal:     wal,
+		fileNum: fileNum,
+		curr:    seg,
+	}
+
+	if wal.canFailover() {
+		w.ops = make(chan segmentOp)
+		// buffered, so that the monitor doesn't block on a stalled operation
+		// whose result is no longer awaited
+		w.results = make(chan error, 1)
+		w.timer = time.NewTimer(wal.opts.failoverThreshold)
+		w.timer.Stop()
+		go w.monitor(w.ops)
+	}
+
+	return w
+}
+
+// monitor runs the operations sent through ops, until ops is closed. Then it
+// closes the latest segment, which is either closed already, or abandoned
+// after its operation has stalled.
+func (w *writer) monitor(ops <-chan segmentOp) {
+	var last *segment
+	for op := range ops {
+		last = op.s
+		w.results <- op.fn(op.s)
+	}
+
+	if last != nil {
+		_ = last.close()
+	}
+}
+
+// stopMonitor stops the monitor, the next operations run in the caller's
+// goroutine. The monitor exits once its current operation, if any, completes.
+func (w *writer) stopMonitor() {
+	if w.ops == nil {
+		return
+	}
+
+	close(w.ops)
+	w.ops = nil
+}
+
+func (w *writer) threshold() time.Duration {
+	if w.ops == nil {
+		return 0
+	}
+
+	return w.wal.opts.failoverThreshold
+}
+
+// run runs op against the current segment. If the writer is monitored, it
+// waits at most the failover threshold for op, then returns errStalled.
+func (w *writer) run(op func(s *segment) error) error {
+	if w.ops == nil {
+		return op(w.curr)
+	}
+
+	w.ops <- segmentOp{s: w.curr, fn: op}
+	w.timer.Reset(w.threshold())
+	select {
+	case err := <-w.results:
+		w.timer.Stop()
+		return err
+	case <-w.timer.C:
+		return errStalled
+	}
+}
+
+func (w *writer) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errWriterClosed
+	}
+
+	if w.threshold() > 0 {
+		// the record must outlive the call, it might be replayed, or still be
+		// in use by a stalled segment
+		p = append([]byte(nil), p...)
+		w.pending = append(w.pending, pendingRecord{logicalOffset: w.logicalOffset, data: p})
+		w.pendingBytes += len(p)
+	}
+
+	err = w.run(func(s *segment) error { return s.writeRecord(p) })
+	if err != nil {
+		if err = w.maybeFailover(err); err != nil {
+			return 0, err
+		}
+	}
+
+	w.logicalOffset += uint64(len(p))
+
+	if w.pendingBytes >= w.wal.opts.maxPendingBytes {
+		if err := w.sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (w *writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		// the records have been synced by Close
+		return nil
+	}
+
+	return w.sync()
+}
+
+// sync syncs the current segment, failing over if it stalls or fails.
+// w.mu must be held when calling this.
+func (w *writer) sync() error {
+	err := w.run(func(s *segment) error { return s.sync() })
+	if err != nil {
+		if err = w.maybeFailover(err); err != nil {
+			return err
+		}
+
+		if err = w.curr.sync(); err != nil {
+			return err
+		}
+	}
+
+	// the records are durable, they don't need to be replayed anymore
+	clear(w.pending)
+	w.pending = w.pending[:0]
+	w.pendingBytes = 0
+	return nil
+}
+
+func (w *writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	defer w.stopMonitor()
+	err := w.run(func(s *segment) error { return s.close() })
+	if err == nil {
+		return nil
+	}
+
+	if err = w.maybeFailover(err); err != nil {
+		return err
+	}
+
+	return w.curr.close()
+}
+
+// maybeFailover switches the logical WAL to a new segment in the other
+// directory, replaying the pending records, if the current segment has
+// stalled or failed with cause. Otherwise cause is returned.
+func (w *writer) maybeFailover(cause error) error {
+	if w.threshold() <= 0 {
+		return cause
+	}
+
+	from := w.curr.dir
+	to := 1 - from
+	w.wal.opts.logger.Errorf("go-wal: WAL %d failing over from the %s to the %s directory: %v", w.fileNum, from, to, cause)
+	if !errors.Is(cause, errStalled) {
+		w.curr.abort()
+	}
+	w.stopMonitor()
+
+	start := w.logicalOffset
+	if len(w.pending) > 0 {
+		start = w.pending[0].logicalOffset
+	}
+
+	w.wal.mu.Lock()
+	seg, err := w.wal.createSegment(w.fileNum, to, 1, start)
+	if err == nil {
+		w.wal.onFailover(w.fileNum, to)
+	}
+	w.wal.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("go-wal: failover of WAL %d failed: %w (cause: %w)", w.fileNum, err, cause)
+	}
+
+	w.curr = seg
+	for _, r := range w.pending {
+		if err := w.curr.writeRecord(r.data); err != nil {
+			return err
+		}
+	}
+	w.pending = nil
+	w.pendingBytes = 0
+
+	return nil
+}
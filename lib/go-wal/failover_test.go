@@ -0,0 +1,301 @@
+package go_wal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_record "github.com/datnguyenzzz/nogodb/lib/common/record"
+	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTornWrite = errors.New("torn write")
+
+// stallFS stalls the writes to the files of a directory, until they are
+// released
+type stallFS struct {
+	nogodb_fs.FS
+
+	mu       sync.Mutex
+	dir      string
+	released chan struct{}
+	// torn makes the stalled writes persist only half of their data once
+	// released, then fail
+	torn bool
+}
+
+func newStallFS() *stallFS {
+	return &stallFS{FS: nogodb_fs.NewDefaultUnix()}
+}
+
+// stall stalls the writes to the files of dir
+func (fs *stallFS) stall(dir string, torn bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.dir, fs.torn = dir, torn
+	fs.released = make(chan struct{})
+}
+
+// release resumes the stalled writes
+func (fs *stallFS) release() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.released != nil {
+		close(fs.released)
+	}
+	fs.dir, fs.released = "", nil
+}
+
+func (fs *stallFS) Create(name string, objType nogodb_common.ObjectType) (nogodb_fs.File, error) {
+	f, err := fs.FS.Create(name, objType)
+	if err != nil {
+		return nil, err
+	}
+	return &stallFile{File: f, fs: fs, dir: filepath.Dir(name)}, nil
+}
+
+func (fs *stallFS) ReuseForWrite(oldname, newname string, objType nogodb_common.ObjectType) (nogodb_fs.File, error) {
+	f, err := fs.FS.ReuseForWrite(oldname, newname, objType)
+	if err != nil {
+		return nil, err
+	}
+	return &stallFile{File: f, fs: fs, dir: filepath.Dir(newname)}, nil
+}
+
+type stallFile struct {
+	nogodb_fs.File
+	fs  *stallFS
+	dir string
+}
+
+func (f *stallFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	released, torn := f.fs.released, f.fs.torn
+	stalled := f.fs.dir == f.dir
+	f.fs.mu.Unlock()
+
+	if !stalled {
+		return f.File.Write(p)
+	}
+
+	<-released
+	if torn {
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errTornWrite
+	}
+	return f.File.Write(p)
+}
+
+// logBuffer records the logged errors
+type logBuffer struct {
+	nogodb_common.Logger
+	mu   sync.Mutex
+	errs []string
+}
+
+func (l *logBuffer) Errorf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, format)
+}
+
+func (l *logBuffer) failovers() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, e := range l.errs {
+		if strings.Contains(e, "failing over") {
+			n++
+		}
+	}
+	return n
+}
+
+func newFailoverTestWAL(t *testing.T, fs *stallFS, opts ...OptionFn) (w *WAL, primary, secondary string, logger *logBuffer) {
+	t.Helper()
+	primary, secondary = t.TempDir(), t.TempDir()
+	logger = &logBuffer{Logger: nogodb_common.DefaultLogger}
+	w, err := NewWalManager(
+		primary,
+		append([]OptionFn{
+			WithFS(fs),
+			WithSecondaryDir(secondary),
+			WithFailoverThreshold(20 * time.Millisecond),
+			WithLogger(logger),
+		}, opts...)...,
+	)
+	require.NoError(t, err)
+	return w, primary, secondary, logger
+}
+
+func (w *WAL) dirsOf(fileNum nogodb_common.DiskfileNum) []dirIndex {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, l := range w.mu.queue {
+		if l.fileNum == fileNum {
+			return l.dirs
+		}
+	}
+	return nil
+}
+
+func Test_WAL_Fails_Over_On_Primary_Stall(t *testing.T) {
+	tests := []struct {
+		name string
+		torn bool
+	}{
+		{name: "the stalled write completes", torn: false},
+		{name: "the stalled write leaves a torn tail", torn: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := newStallFS()
+			defer fs.release()
+			w, primary, secondary, logger := newFailoverTestWAL(t, fs)
+
+			lw, err := w.Create(1)
+			require.NoError(t, err)
+
+			var want [][]byte
+			write := func(from, to int) {
+				for i := from; i < to; i++ {
+					r := testRecord(1, i, 100)
+					_, err := lw.Write(r)
+					require.NoError(t, err)
+					want = append(want, r)
+				}
+			}
+
+			// the first records are durable in the primary directory
+			write(0, 3)
+			require.NoError(t, lw.Sync())
+
+			// the sync of the next records stalls, they are replayed to the
+			// secondary directory
+			fs.stall(primary, tc.torn)
+			write(3, 6)
+			require.NoError(t, lw.Sync())
+			assert.Equal(t, 1, logger.failovers())
+			assert.Equal(t, []dirIndex{primaryDirIndex, secondaryDirIndex}, w.dirsOf(1))
+
+			// the next records go to the secondary directory, which is not
+			// monitored anymore
+			write(6, 8)
+			require.NoError(t, lw.Sync())
+			require.NoError(t, lw.Close())
+
+			// the abandoned segment is closed once its write completes
+			fs.release()
+
+			// the records of the stalled segment are merged with the replayed
+			// ones, without duplicates
+			assert.Equal(t, want, readTestWALs(t, primary, 0, WithReaderSecondaryDir(secondary)))
+		})
+	}
+}
+
+func Test_WAL_Fails_Back_To_The_Primary(t *testing.T) {
+	fs := newStallFS()
+	defer fs.release()
+	w, primary, secondary, logger := newFailoverTestWAL(t, fs)
+
+	lw, err := w.Create(1)
+	require.NoError(t, err)
+	want := [][]byte{testRecord(1, 0, 100)}
+	fs.stall(primary, false)
+	_, err = lw.Write(want[0])
+	require.NoError(t, err)
+	require.NoError(t, lw.Close())
+	assert.Equal(t, 1, logger.failovers())
+	fs.release()
+
+	// the new WALs are created in the secondary directory for a while
+	want = append(want, writeTestWAL(t, w, 2, 2, 100)...)
+	assert.Equal(t, []dirIndex{secondaryDirIndex}, w.dirsOf(2))
+	assert.NoFileExists(t, filepath.Join(primary, nogodb_common.GetFileName(nogodb_common.TypeWAL, 2)))
+
+	// then fail back to the primary one
+	w.mu.Lock()
+	w.mu.failedOverAt = time.Now().Add(-failbackInterval)
+	w.mu.Unlock()
+	want = append(want, writeTestWAL(t, w, 3, 2, 100)...)
+	assert.Equal(t, []dirIndex{primaryDirIndex}, w.dirsOf(3))
+	assert.NoFileExists(t, filepath.Join(secondary, nogodb_common.GetFileName(nogodb_common.TypeWAL, 3)))
+	assert.Equal(t, 1, logger.failovers())
+
+	assert.Equal(t, want, readTestWALs(t, primary, 0, WithReaderSecondaryDir(secondary)))
+}
+
+func Test_WAL_Without_Stall_Stays_In_The_Primary(t *testing.T) {
+	fs := newStallFS()
+	w, primary, secondary, logger := newFailoverTestWAL(t, fs)
+
+	var want [][]byte
+	for fileNum := nogodb_common.DiskfileNum(1); fileNum <= 3; fileNum++ {
+		want = append(want, writeTestWAL(t, w, fileNum, 50, 1<<10)...)
+		assert.Equal(t, []dirIndex{primaryDirIndex}, w.dirsOf(fileNum))
+	}
+	assert.Zero(t, logger.failovers())
+
+	assert.Equal(t, want, readTestWALs(t, primary, 0, WithReaderSecondaryDir(secondary)))
+	assert.Equal(t, want, readTestWALs(t, primary, 0))
+}
+
+func Test_WAL_Bounds_The_Pending_Records(t *testing.T) {
+	const maxPendingBytes = 4 << 10
+	fs := newStallFS()
+	w, primary, secondary, logger := newFailoverTestWAL(t, fs, WithMaxPendingBytes(maxPendingBytes))
+
+	lw, err := w.Create(1)
+	require.NoError(t, err)
+
+	// the records are never synced by the caller
+	var want [][]byte
+	for i := range 100 {
+		r := testRecord(1, i, 1<<10)
+		_, err := lw.Write(r)
+		require.NoError(t, err)
+		want = append(want, r)
+
+		assert.Less(t, lw.(*writer).pendingBytes, maxPendingBytes)
+		assert.LessOrEqual(t, len(lw.(*writer).pending), maxPendingBytes>>10)
+	}
+	require.NoError(t, lw.Close())
+	assert.Zero(t, logger.failovers())
+
+	assert.Equal(t, want, readTestWALs(t, primary, 0, WithReaderSecondaryDir(secondary)))
+}
+
+func Test_WAL_Reads_The_WALs_Without_Segment_Header(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, nogodb_common.GetFileName(nogodb_common.TypeWAL, 1)))
+	require.NoError(t, err)
+
+	// a WAL written before the failover support, its first record is a batch
+	var want [][]byte
+	rw := nogodb_record.NewWriter(f)
+	for i := range 3 {
+		r := testRecord(1, i, segmentHeaderSize)
+		next, err := rw.Next()
+		require.NoError(t, err)
+		_, err = next.Write(r)
+		require.NoError(t, err)
+		want = append(want, r)
+	}
+	require.NoError(t, rw.Close())
+	require.NoError(t, f.Close())
+
+	w, err := NewWalManager(dir, WithFS(nogodb_fs.NewDefaultUnix()))
+	require.NoError(t, err)
+	want = append(want, writeTestWAL(t, w, 2, 2, 100)...)
+
+	assert.Equal(t, want, readTestWALs(t, dir, 0))
+}
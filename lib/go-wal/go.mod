@@ -11,9 +11,13 @@ replace github.com/datnguyenzzz/nogodb/lib/common => ../common
 require (
 	github.com/datnguyenzzz/nogodb/lib/common v0.0.0-00010101000000-000000000000
 	github.com/datnguyenzzz/nogodb/lib/go-fs v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.11.1
 )
 
 require (
 	github.com/datnguyenzzz/nogodb/lib/go-bytesbufferpool v0.0.0-00010101000000-000000000000 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.44.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -11,14 +11,23 @@ type IWalWriter interface {
 	// List does not perform I/O
 	List() []nogodb_common.DiskfileNum
 	// Obsolete informs the manager that all WALs less than minUnflushedNum are obsolete.
-	Obsolete(minUnflushedNum nogodb_common.DiskfileNum) (toDelete []nogodb_common.DiskfileNum, err error)
+	// Their files are either recycled or deleted, it returns the deleted WALs.
+	Obsolete(minUnflushedNum nogodb_common.DiskfileNum) (deleted []nogodb_common.DiskfileNum, err error)
 	// Create creates a new WAL. NumWALs passed to successive Create calls must be
 	// monotonically increasing, and be greater than any NumWAL seen earlier. The
 	// caller must close the previous Writer before calling Create.
-	Create(fileNum nogodb_common.DiskfileNum) (io.WriteCloser, error)
+	Create(fileNum nogodb_common.DiskfileNum) (ILogWriter, error)
 	Close() error
 }
 
+// ILogWriter writes the records of a single WAL. Each Write call writes a record.
+type ILogWriter interface {
+	io.WriteCloser
+	// Sync makes the records written so far durable. Close syncs the
+	// remaining records as well.
+	Sync() error
+}
+
 type IWalReader interface {
 	// Next returns a reader for the next record. It returns io.EOF if there
 	// are no more records. The reader returned becomes stale after the next Next
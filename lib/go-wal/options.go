@@ -1,6 +1,8 @@
 package go_wal
 
 import (
+	"time"
+
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
 )
@@ -13,15 +15,81 @@ type options struct {
 	// bytesPerSync specifies the number of bytes to write before calling sync.
 	bytesPerSync uint32
 
+	// maxRecycledFiles is the maximum number of obsolete WAL files kept for
+	// being reused by the next WALs. 0 disables the recycling.
+	maxRecycledFiles int
+
+	// secondaryDir is the directory the WAL fails over to, when the primary
+	// one stalls or fails. The failover is disabled if it's empty.
+	secondaryDir string
+	// failoverThreshold is the latency of a WAL operation from which the
+	// primary directory is considered stalled.
+	failoverThreshold time.Duration
+	// maxPendingBytes bounds the records which aren't durable yet, and kept
+	// to be replayed on failover. Once reached, the WAL is synced.
+	maxPendingBytes int
+
 	logger nogodb_common.Logger
 }
 
+const (
+	defaultFailoverThreshold = 200 * time.Millisecond
+	defaultMaxPendingBytes   = 4 << 20
+)
+
+func (o *options) setDefault() {
+	if o.failoverThreshold == 0 {
+		o.failoverThreshold = defaultFailoverThreshold
+	}
+
+	if o.maxPendingBytes <= 0 {
+		o.maxPendingBytes = defaultMaxPendingBytes
+	}
+
+	if o.logger == nil {
+		o.logger = nogodb_common.DefaultLogger
+	}
+}
+
 func WithBytesPerSync(bytesPerSync uint32) OptionFn {
 	return func(wal *WAL) {
 		wal.opts.bytesPerSync = bytesPerSync
 	}
 }
 
+// WithMaxRecycledFiles bounds the pool of obsolete WAL files, which are
+// reused instead of creating new files
+func WithMaxRecycledFiles(n int) OptionFn {
+	return func(w *WAL) {
+		w.opts.maxRecycledFiles = n
+	}
+}
+
+// WithSecondaryDir enables the failover of the WAL to dir, when the primary
+// directory stalls or fails
+func WithSecondaryDir(dir string) OptionFn {
+	return func(w *WAL) {
+		w.opts.secondaryDir = dir
+	}
+}
+
+// WithFailoverThreshold sets the latency of a WAL operation from which the
+// primary directory is considered stalled
+func WithFailoverThreshold(d time.Duration) OptionFn {
+	return func(w *WAL) {
+		w.opts.failoverThreshold = d
+	}
+}
+
+// WithMaxPendingBytes bounds the records kept to be replayed on failover, i.e.
+// written since the last sync. Once n bytes are pending, the WAL is synced,
+// even if the caller never syncs it.
+func WithMaxPendingBytes(n int) OptionFn {
+	return func(w *WAL) {
+		w.opts.maxPendingBytes = n
+	}
+}
+
 func WithFS(fs nogodb_fs.FS) OptionFn {
 	return func(w *WAL) {
 		w.opts.fs = fs
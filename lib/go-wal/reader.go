@@ -2,8 +2,10 @@ package go_wal
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"io"
+	"slices"
 
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
 	nogodb_record "github.com/datnguyenzzz/nogodb/lib/common/record"
@@ -17,9 +19,43 @@ type Offset struct {
 	offset int64
 }
 
+// segmentReader reads the records of a segment
+type segmentReader struct {
+	f nogodb_fs.Readable
+	r *nogodb_record.Reader
+	// index of the segment within its logical WAL
+	index uint8
+	// logicalOffset is the logical offset of the next record
+	logicalOffset uint64
+	// first holds the first record of a WAL written without segment header,
+	// which is read by openSegment and still has to be returned
+	first []byte
+}
+
+type ReaderOptionFn func(*WALReader)
+
+// WithReaderSecondaryDir makes the reader merge the WALs of the primary
+// directory with the ones failed over to dir
+func WithReaderSecondaryDir(dir string) ReaderOptionFn {
+	return func(wr *WALReader) {
+		wr.secondaryDir = dir
+	}
+}
+
+// WALReader reads the records of the WALs in ascending order of file number.
+// A WAL which has failed over is made of 2 segments, one in each directory.
+// The segments are merged by their logical offsets: the records of the first
+// segment which have been replayed to the second one are skipped.
 type WALReader struct {
-	currReader *nogodb_record.Reader
-	storager   nogodb_fs.Storage
+	secondaryDir string
+	minFileNum   nogodb_common.DiskfileNum
+	storagers    []nogodb_fs.Storage
+	// fileNums are the remaining WALs to read
+	fileNums []nogodb_common.DiskfileNum
+
+	// segments are the remaining segments of the current WAL, in the order of
+	// creation. The current segment is segments[0].
+	segments []*segmentReader
 	// off describes the current Offset within the WAL.
 	off Offset
 	// recordBuf is a buffer used to hold the latest record read from a physical
@@ -30,53 +66,101 @@ type WALReader struct {
 	recordBuf bytes.Buffer
 }
 
-func NewWalReader(dir string, fs nogodb_fs.FS) (*WALReader, error) {
-	storager, err := nogodb_fs.OpenVfsProvider(
-		nogodb_fs.WithDirName(dir),
-		nogodb_fs.WithFS(fs),
-	)
-	if err != nil {
-		return nil, err
+// NewWalReader returns a reader of the WALs of dir whose file number is at
+// least minFileNum, which is usually the smallest unflushed WAL. The files
+// below it are obsolete: a file waiting in the recycling pool keeps the name
+// of its obsolete WAL until it is reused, so its records mustn't be replayed.
+func NewWalReader(
+	dir string,
+	fs nogodb_fs.FS,
+	minFileNum nogodb_common.DiskfileNum,
+	opts ...ReaderOptionFn,
+) (*WALReader, error) {
+	wr := &WALReader{minFileNum: minFileNum}
+	for _, o := range opts {
+		o(wr)
 	}
-	return &WALReader{
-		storager: storager,
-		off:      Offset{},
-	}, nil
+
+	dirs := []string{dir}
+	if wr.secondaryDir != "" {
+		dirs = append(dirs, wr.secondaryDir)
+	}
+
+	for _, d := range dirs {
+		storager, err := nogodb_fs.OpenVfsProvider(
+			nogodb_fs.WithDirName(d),
+			nogodb_fs.WithFS(fs),
+		)
+		if err != nil {
+			return nil, err
+		}
+		wr.storagers = append(wr.storagers, storager)
+
+		for _, fd := range storager.List(nogodb_common.TypeWAL) {
+			if fd.Num >= wr.minFileNum {
+				wr.fileNums = append(wr.fileNums, fd.Num)
+			}
+		}
+	}
+
+	slices.Sort(wr.fileNums)
+	wr.fileNums = slices.Compact(wr.fileNums)
+
+	return wr, nil
 }
 
 // Next returns a reader for the next record. It returns io.EOF if there
 // are no more records. The reader returned becomes stale after the next Next
 // call, and should no longer be used.
 func (wr *WALReader) Next() (io.Reader, Offset, error) {
-	var err error
-	if wr.currReader == nil {
-		if err = wr.nextFile(); err != nil {
-			return nil, Offset{}, err
-		}
-	}
-
 	for {
-		wr.off.offset = wr.currReader.Offset()
-		next, err := wr.currReader.Next()
-		if errors.Is(err, io.EOF) {
-			// the current file exhausted
-			if err = wr.nextFile(); err != nil {
+		if len(wr.segments) == 0 {
+			if err := wr.nextFile(); err != nil {
 				return nil, Offset{}, err
 			}
 
 			continue
 		}
 
-		if err != nil {
-			return nil, Offset{}, err
-		}
+		seg := wr.segments[0]
+		hasNextSegment := len(wr.segments) > 1
 
 		wr.recordBuf.Reset()
-		_, err = io.Copy(&wr.recordBuf, next)
-		if err != nil {
+		var err error
+		if seg.first != nil {
+			wr.off.offset = 0
+			wr.recordBuf.Write(seg.first)
+			seg.first = nil
+		} else {
+			wr.off.offset = seg.r.Offset()
+			var next io.Reader
+			next, err = seg.r.Next()
+			if err == nil {
+				_, err = io.Copy(&wr.recordBuf, next)
+			}
+		}
+
+		switch {
+		case errors.Is(err, io.EOF):
+			// the current segment exhausted
+			wr.popSegment()
+			continue
+		case err != nil && hasNextSegment:
+			// the segment has stalled or failed while being written, its
+			// tail is torn, the remaining records are in the next segment.
+			wr.popSegment()
+			continue
+		case err != nil:
 			return nil, Offset{}, err
 		}
 
+		if hasNextSegment && seg.logicalOffset >= wr.segments[1].logicalOffset {
+			// the record has been replayed to the next segment
+			wr.popSegment()
+			continue
+		}
+		seg.logicalOffset += uint64(wr.recordBuf.Len())
+
 		// TODO(high): Parse Batch from recordBuf
 		// and don't return record if SeqNum is <= last seen seq num
 
@@ -84,24 +168,93 @@ func (wr *WALReader) Next() (io.Reader, Offset, error) {
 	}
 }
 
+// popSegment closes the current segment, and moves to the next one
+func (wr *WALReader) popSegment() {
+	_ = wr.segments[0].f.Close()
+	wr.segments = wr.segments[1:]
+}
+
+// nextFile opens the segments of the next WAL
 func (wr *WALReader) nextFile() error {
-	wr.currReader = nil
-	wr.off.fileNum += 1
+	if len(wr.fileNums) == 0 {
+		return io.EOF
+	}
+
+	wr.off.fileNum, wr.fileNums = wr.fileNums[0], wr.fileNums[1:]
 	wr.off.offset = 0
 
-	rf, _, err := wr.storager.Open(nogodb_common.TypeWAL, wr.off.fileNum)
-	if err != nil {
-		return err
+	for _, storager := range wr.storagers {
+		if _, err := storager.LookUp(nogodb_common.TypeWAL, wr.off.fileNum); err != nil {
+			continue
+		}
+
+		rf, _, err := storager.Open(nogodb_common.TypeWAL, wr.off.fileNum)
+		if err != nil {
+			return err
+		}
+
+		seg, err := openSegment(rf, wr.off.fileNum)
+		if errors.Is(err, io.EOF) {
+			// the segment has been created, but nothing has been written
+			_ = rf.Close()
+			continue
+		}
+		if err != nil {
+			_ = rf.Close()
+			return err
+		}
+
+		wr.segments = append(wr.segments, seg)
 	}
-	wr.currReader = nogodb_record.NewReader(rf)
+
+	slices.SortFunc(wr.segments, func(a, b *segmentReader) int {
+		return int(a.index) - int(b.index)
+	})
+
 	return nil
 }
 
+// openSegment reads the header of a segment. A WAL written without segment
+// header, i.e. before the failover support, is a single segment whose first
+// record is kept to be returned.
+func openSegment(rf nogodb_fs.Readable, fileNum nogodb_common.DiskfileNum) (*segmentReader, error) {
+	r := nogodb_record.NewReader(rf, fileNum)
+	next, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := io.ReadAll(next)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segmentReader{f: rf, r: r}
+	magicLen := len(segmentHeaderMagic)
+	if len(record) != segmentHeaderSize || !bytes.Equal(record[:magicLen], segmentHeaderMagic[:]) {
+		seg.first = record
+		return seg, nil
+	}
+
+	seg.index = record[magicLen]
+	seg.logicalOffset = binary.LittleEndian.Uint64(record[magicLen+1:])
+	return seg, nil
+}
+
 // Close the reader.
 func (wr *WALReader) Close() error {
-	wr.currReader = nil
+	var err error
+	for _, seg := range wr.segments {
+		err = errors.Join(err, seg.f.Close())
+	}
+	for _, storager := range wr.storagers {
+		err = errors.Join(err, storager.Close())
+	}
+
+	wr.segments = nil
+	wr.storagers = nil
 	wr.recordBuf.Reset()
-	return nil
+	return err
 }
 
 var _ IWalReader = (*WALReader)(nil)
@@ -1,20 +1,65 @@
 package go_wal
 
 import (
+	"errors"
 	"fmt"
-	"io"
+	"slices"
 	"sync"
+	"time"
 
 	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
-	nogodb_record "github.com/datnguyenzzz/nogodb/lib/common/record"
 	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
 )
 
-// TODO(med): How to recycle the existing obsolete files, instead of re-creating
+// failbackInterval is the time during which the new WALs keep being created
+// in the secondary directory after a failover, before trying the primary one
+// again.
+const failbackInterval = 30 * time.Second
+
+// dirIndex identifies a WAL directory
+type dirIndex uint8
+
+const (
+	primaryDirIndex dirIndex = iota
+	secondaryDirIndex
+)
+
+var dirIndexToString = map[dirIndex]string{
+	primaryDirIndex:   "primary",
+	secondaryDirIndex: "secondary",
+}
+
+func (d dirIndex) String() string {
+	return dirIndexToString[d]
+}
+
+// logicalWAL is a WAL as seen by the caller. Its records are stored in one
+// segment (physical file), or two if it has failed over to the other directory.
+type logicalWAL struct {
+	fileNum nogodb_common.DiskfileNum
+	// dirs holds the directory of each segment, in the order of creation
+	dirs []dirIndex
+}
+
+// walFile is a physical WAL file
+type walFile struct {
+	fileNum nogodb_common.DiskfileNum
+	dir     dirIndex
+}
+
+// WAL manages the WAL files. The obsolete files are kept in a bounded pool
+// and recycled by the next Create calls, which overwrite them in place instead
+// of creating new files. As a recycled file isn't truncated, its records embed
+// the WAL number, see nogodb_record.NewRecyclableWriter.
+//
+// If a secondary directory is configured, the WAL fails over to it when the
+// primary directory stalls or fails, see writer.
 type WAL struct {
 	opts options
 
-	storager nogodb_fs.Storage
+	// storagers holds the storage of each WAL directory, indexed by dirIndex.
+	// The secondary one is nil if it isn't configured.
+	storagers [2]nogodb_fs.Storage
 
 	// fileNum current created fileNum is openned for writing
 	fileNum nogodb_common.DiskfileNum
@@ -25,7 +70,11 @@ type WAL struct {
 		sync.Mutex
 		// The queue of WALs, containing both flushed and unflushed WALs. The
 		// flushed logs are a prefix, the unflushed logs a suffix.
-		queue []nogodb_common.DiskfileNum
+		queue []logicalWAL
+		// recycled is the pool of obsolete WAL files, waiting to be reused
+		recycled []walFile
+		// failedOverAt is the time of the latest failover
+		failedOverAt time.Time
 	}
 }
 
@@ -36,14 +85,23 @@ func NewWalManager(dir string, opts ...OptionFn) (*WAL, error) {
 	for _, o := range opts {
 		o(w)
 	}
-	var err error
-	w.storager, err = nogodb_fs.OpenVfsProvider(
-		nogodb_fs.WithDirName(dir),
-		nogodb_fs.WithBytesPerSync(int64(w.opts.bytesPerSync)),
-		nogodb_fs.WithFS(w.opts.fs),
-	)
-	if err != nil {
-		return nil, err
+	w.opts.setDefault()
+
+	dirs := []string{dir}
+	if w.opts.secondaryDir != "" {
+		dirs = append(dirs, w.opts.secondaryDir)
+	}
+
+	for i, d := range dirs {
+		storager, err := nogodb_fs.OpenVfsProvider(
+			nogodb_fs.WithDirName(d),
+			nogodb_fs.WithBytesPerSync(int64(w.opts.bytesPerSync)),
+			nogodb_fs.WithFS(w.opts.fs),
+		)
+		if err != nil {
+			return nil, err
+		}
+		w.storagers[i] = storager
 	}
 
 	return w, nil
@@ -55,71 +113,133 @@ func (w *WAL) List() []nogodb_common.DiskfileNum {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	return w.mu.queue
+	res := make([]nogodb_common.DiskfileNum, 0, len(w.mu.queue))
+	for _, l := range w.mu.queue {
+		res = append(res, l.fileNum)
+	}
+
+	return res
 }
 
 // Obsolete informs the manager that all WALs less than minUnflushedNum are obsolete.
-func (w *WAL) Obsolete(minUnflushedNum nogodb_common.DiskfileNum) (toDelete []nogodb_common.DiskfileNum, err error) {
+// The files of the obsolete WALs are moved to the recycling pool as long as it
+// isn't full, the others are deleted. It returns the WALs having a deleted file.
+func (w *WAL) Obsolete(minUnflushedNum nogodb_common.DiskfileNum) (deleted []nogodb_common.DiskfileNum, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	start := len(w.mu.queue)
-	for i, fn := range w.mu.queue {
-		if fn >= minUnflushedNum {
+	for i, l := range w.mu.queue {
+		if l.fileNum >= minUnflushedNum {
 			start = i
 			break
 		}
 
-		toDelete = append(toDelete, fn)
+		for _, dir := range l.dirs {
+			if len(w.mu.recycled) < w.opts.maxRecycledFiles {
+				w.mu.recycled = append(w.mu.recycled, walFile{fileNum: l.fileNum, dir: dir})
+				continue
+			}
+
+			if rmErr := w.storagers[dir].Remove(nogodb_common.TypeWAL, l.fileNum); rmErr != nil {
+				err = errors.Join(err, rmErr)
+				continue
+			}
+
+			if len(deleted) == 0 || deleted[len(deleted)-1] != l.fileNum {
+				deleted = append(deleted, l.fileNum)
+			}
+		}
 	}
 
 	w.mu.queue = w.mu.queue[start:]
 
-	return toDelete, nil
+	return deleted, err
 }
 
 // Create creates a new WAL. NumWALs passed to successive Create calls must be
 // monotonically increasing, and be greater than any NumWAL seen earlier. The
 // caller must close the previous Writer before calling Create.
-func (w *WAL) Create(fileNum nogodb_common.DiskfileNum) (io.WriteCloser, error) {
+func (w *WAL) Create(fileNum nogodb_common.DiskfileNum) (ILogWriter, error) {
 	if fileNum <= w.fileNum {
 		return nil, fmt.Errorf("the requested fileNum must be monotonically increasing, last value: %d", w.fileNum)
 	}
 
-	wf, _, err := w.storager.Create(nogodb_common.TypeWAL, fileNum)
-	if err != nil {
-		return nil, err
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dir := primaryDirIndex
+	if w.canFailover() && time.Since(w.mu.failedOverAt) < failbackInterval {
+		dir = secondaryDirIndex
 	}
 
-	if err := w.storager.Sync(nogodb_common.TypeWAL, fileNum); err != nil {
+	seg, err := w.createSegment(fileNum, dir, 0, 0)
+	if err != nil && w.canFailover() {
+		w.opts.logger.Errorf("go-wal: failed to create WAL %d in the %s directory, failing over: %v", fileNum, dir, err)
+		dir = 1 - dir
+		seg, err = w.createSegment(fileNum, dir, 0, 0)
+	}
+	if err != nil {
 		return nil, err
 	}
-	// TODO(low): wf is buffered to the RAM before flushing, is it ok ?
-	wr := nogodb_record.NewWriter(wf)
-	w.writer = &writer{wr}
-	w.mu.Lock()
-	defer w.mu.Unlock()
 
-	w.mu.queue = append(w.mu.queue, fileNum)
+	w.writer = newWriter(w, fileNum, seg)
+	w.fileNum = fileNum
+
+	w.mu.queue = append(w.mu.queue, logicalWAL{fileNum: fileNum, dirs: []dirIndex{dir}})
 
 	return w.writer, nil
 }
 
-type writer struct {
-	wr *nogodb_record.Writer
-}
+// createSegment creates the index-th segment of the WAL fileNum in dir, whose
+// first record is at logicalOffset. A recycled file is reused when available.
+// w.mu must be held when calling this.
+func (w *WAL) createSegment(
+	fileNum nogodb_common.DiskfileNum,
+	dir dirIndex,
+	index uint8,
+	logicalOffset uint64,
+) (*segment, error) {
+	storager := w.storagers[dir]
+
+	var wf nogodb_fs.Writable
+	var err error
+	if i := slices.IndexFunc(w.mu.recycled, func(f walFile) bool { return f.dir == dir }); i >= 0 {
+		// reuse the oldest obsolete WAL file of the directory
+		wf, _, err = storager.Reuse(nogodb_common.TypeWAL, w.mu.recycled[i].fileNum, fileNum)
+		if err != nil {
+			return nil, err
+		}
+		w.mu.recycled = slices.Delete(w.mu.recycled, i, i+1)
+	} else {
+		wf, _, err = storager.Create(nogodb_common.TypeWAL, fileNum)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-func (w *writer) Write(p []byte) (n int, err error) {
-	next, err := w.wr.Next()
-	if err != nil {
-		return n, err
+	if err := storager.Sync(nogodb_common.TypeWAL, fileNum); err != nil {
+		return nil, err
 	}
 
-	return next.Write(p)
+	return newSegment(wf, fileNum, dir, index, logicalOffset)
+}
+
+// canFailover returns true if a secondary directory is configured
+func (w *WAL) canFailover() bool {
+	return w.storagers[secondaryDirIndex] != nil
 }
 
-func (w *writer) Close() error {
-	return w.wr.Close()
+// onFailover records that the WAL fileNum has switched to a new segment in dir.
+// w.mu must be held when calling this.
+func (w *WAL) onFailover(fileNum nogodb_common.DiskfileNum, dir dirIndex) {
+	w.mu.failedOverAt = time.Now()
+	for i := range w.mu.queue {
+		if w.mu.queue[i].fileNum == fileNum {
+			w.mu.queue[i].dirs = append(w.mu.queue[i].dirs, dir)
+			return
+		}
+	}
 }
 
 func (w *WAL) Close() error {
@@ -0,0 +1,141 @@
+package go_wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nogodb_common "github.com/datnguyenzzz/nogodb/lib/common"
+	nogodb_fs "github.com/datnguyenzzz/nogodb/lib/go-fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecord(fileNum nogodb_common.DiskfileNum, i, size int) []byte {
+	prefix := fmt.Sprintf("wal_%d_record_%d_", fileNum, i)
+	return append([]byte(prefix), bytes.Repeat([]byte{'x'}, max(size-len(prefix), 0))...)
+}
+
+// writeTestWAL creates the WAL fileNum, and writes n records of the given size
+func writeTestWAL(t *testing.T, w *WAL, fileNum nogodb_common.DiskfileNum, n, size int) [][]byte {
+	t.Helper()
+	lw, err := w.Create(fileNum)
+	require.NoError(t, err)
+
+	var records [][]byte
+	for i := range n {
+		r := testRecord(fileNum, i, size)
+		_, err := lw.Write(r)
+		require.NoError(t, err)
+		records = append(records, r)
+	}
+	require.NoError(t, lw.Close())
+	return records
+}
+
+// readTestWALs reads all the records of the WALs in dir, from the WAL minFileNum
+func readTestWALs(t *testing.T, dir string, minFileNum nogodb_common.DiskfileNum, opts ...ReaderOptionFn) [][]byte {
+	t.Helper()
+	wr, err := NewWalReader(dir, nogodb_fs.NewDefaultUnix(), minFileNum, opts...)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, wr.Close()) }()
+
+	var records [][]byte
+	for {
+		r, _, err := wr.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		record, err := io.ReadAll(r)
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func walFileSize(t *testing.T, dir string, fileNum nogodb_common.DiskfileNum) int64 {
+	t.Helper()
+	fi, err := os.Stat(filepath.Join(dir, nogodb_common.GetFileName(nogodb_common.TypeWAL, fileNum)))
+	require.NoError(t, err)
+	return fi.Size()
+}
+
+func Test_WAL_Recycles_The_Obsolete_Files(t *testing.T) {
+	tests := []struct {
+		name string
+		// records written to the WAL reusing the obsolete file
+		n, size int
+	}{
+		{name: "no records", n: 0, size: 0},
+		{name: "a few small records", n: 3, size: 100},
+		{name: "records spanning blocks", n: 4, size: 50 << 10},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewWalManager(dir, WithFS(nogodb_fs.NewDefaultUnix()), WithMaxRecycledFiles(1))
+			require.NoError(t, err)
+
+			writeTestWAL(t, w, 1, 20, 32<<10)
+			staleSize := walFileSize(t, dir, 1)
+			want := writeTestWAL(t, w, 2, 2, 100)
+
+			deleted, err := w.Obsolete(2)
+			require.NoError(t, err)
+			assert.Empty(t, deleted, "the file is recycled")
+			assert.Equal(t, []nogodb_common.DiskfileNum{2}, w.List())
+
+			// the WAL 3 overwrites the file of the WAL 1, leaving its tail
+			want = append(want, writeTestWAL(t, w, 3, tc.n, tc.size)...)
+			require.NoFileExists(t, filepath.Join(dir, nogodb_common.GetFileName(nogodb_common.TypeWAL, 1)))
+			require.Equal(t, staleSize, walFileSize(t, dir, 3), "the file isn't truncated")
+
+			// the records of the WAL 1 are not replayed
+			assert.Equal(t, want, readTestWALs(t, dir, 2))
+		})
+	}
+}
+
+func Test_WAL_Deletes_The_Files_Beyond_The_Recycling_Pool(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWalManager(dir, WithFS(nogodb_fs.NewDefaultUnix()), WithMaxRecycledFiles(1))
+	require.NoError(t, err)
+
+	for fileNum := nogodb_common.DiskfileNum(1); fileNum <= 3; fileNum++ {
+		writeTestWAL(t, w, fileNum, 1, 100)
+	}
+
+	deleted, err := w.Obsolete(3)
+	require.NoError(t, err)
+	assert.Equal(t, []nogodb_common.DiskfileNum{2}, deleted)
+	assert.Equal(t, []nogodb_common.DiskfileNum{3}, w.List())
+
+	// the recycled file keeps the name of the WAL 1 until it is reused, the
+	// reader skips it below the min file number
+	require.FileExists(t, filepath.Join(dir, nogodb_common.GetFileName(nogodb_common.TypeWAL, 1)))
+	assert.Equal(t, [][]byte{testRecord(3, 0, 100)}, readTestWALs(t, dir, 3))
+
+	want := writeTestWAL(t, w, 4, 1, 10)
+	require.NoFileExists(t, filepath.Join(dir, nogodb_common.GetFileName(nogodb_common.TypeWAL, 1)))
+	assert.Equal(t, append([][]byte{testRecord(3, 0, 100)}, want...), readTestWALs(t, dir, 3))
+}
+
+func Test_WAL_Without_Recycling(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWalManager(dir, WithFS(nogodb_fs.NewDefaultUnix()))
+	require.NoError(t, err)
+
+	writeTestWAL(t, w, 1, 1, 100)
+	want := writeTestWAL(t, w, 2, 1, 100)
+
+	deleted, err := w.Obsolete(2)
+	require.NoError(t, err)
+	assert.Equal(t, []nogodb_common.DiskfileNum{1}, deleted)
+	require.NoFileExists(t, filepath.Join(dir, nogodb_common.GetFileName(nogodb_common.TypeWAL, 1)))
+	assert.Equal(t, want, readTestWALs(t, dir, 2))
+}